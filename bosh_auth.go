@@ -0,0 +1,116 @@
+package cfbackup
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"strings"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//directorAuth - decorates requests to the director with its credentials
+type directorAuth interface {
+	authorize(req *http.Request) error
+	//reset - drops any cached credentials, returns false when there is
+	//nothing to reset and retrying a rejected request is pointless
+	reset() bool
+}
+
+type basicDirectorAuth struct {
+	username string
+	password string
+}
+
+func (s *basicDirectorAuth) authorize(req *http.Request) error {
+	req.SetBasicAuth(s.username, s.password)
+	return nil
+}
+
+func (s *basicDirectorAuth) reset() bool {
+	return false
+}
+
+//uaaDirectorAuth - authorizes with bearer tokens from the uaa the director
+//delegates to. the director credentials are tried as a uaa client first and
+//as a uaa user (through the bosh cli client) second
+type uaaDirectorAuth struct {
+	tokens *UAATokenSource
+}
+
+func newUAADirectorAuth(client *http.Client, uaaURL, username, password string) *uaaDirectorAuth {
+	return &uaaDirectorAuth{
+		tokens: &UAATokenSource{
+			HTTPClient: func() (*http.Client, error) { return client, nil },
+			TokenURL:   strings.TrimRight(uaaURL, "/") + "/oauth/token",
+			Grants: []UAAGrant{
+				{
+					ClientID:     username,
+					ClientSecret: password,
+					Values:       url.Values{"grant_type": {UAAGrantTypeClientCredentials}},
+				},
+				{
+					ClientID: UAABoshCLIClient,
+					Values: url.Values{
+						"grant_type": {UAAGrantTypePassword},
+						"username":   {username},
+						"password":   {password},
+					},
+				},
+			},
+		},
+	}
+}
+
+func (s *uaaDirectorAuth) authorize(req *http.Request) error {
+	token, err := s.tokens.Token()
+	if err != nil {
+		return errwrap.Wrap(err, "failed fetching uaa token")
+	}
+	req.Header.Set("Authorization", "Bearer "+token)
+	return nil
+}
+
+func (s *uaaDirectorAuth) reset() bool {
+	s.tokens.Reset()
+	return true
+}
+
+//newDirectorHTTPClient - the director usually runs with a self signed cert,
+//which is only verified when the tls config asks for it
+func newDirectorHTTPClient(tlsConfig TLSConfig) (*http.Client, error) {
+	client, err := tlsConfig.HTTPClient()
+	if err != nil {
+		return nil, errwrap.Wrap(err, "invalid director tls config")
+	}
+	return client, nil
+}
+
+//newDirectorAuth - looks up the auth mode of the director on its info
+//endpoint, directors which do not advertise uaa get basic auth
+func newDirectorAuth(client *http.Client, endpoint, username, password string) (directorAuth, error) {
+	res, err := client.Get(endpoint)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed calling director info")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		return nil, fmt.Errorf("unsuccessful status code from director info: %v", res.StatusCode)
+	}
+	info, err := ReadBoshInfo(res.Body)
+	if err != nil {
+		return nil, err
+	}
+
+	if info.UserAuthentication.Type == BOSHAuthTypeUAA {
+		lo.G.Debug("director uses uaa auth at ", info.UserAuthentication.Options.URL)
+		return newUAADirectorAuth(client, info.UserAuthentication.Options.URL, username, password), nil
+	}
+	lo.G.Debug("director uses basic auth")
+	return &basicDirectorAuth{
+		username: username,
+		password: password,
+	}, nil
+}
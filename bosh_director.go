@@ -0,0 +1,161 @@
+package cfbackup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+
+	errwrap "github.com/pkg/errors"
+)
+
+//GetDeployments - returns the deployments on the targetted bosh director
+func (s *boshDirector) GetDeployments() (deployments []BoshDeployment, err error) {
+	err = s.getJSON(fmt.Sprintf("%s:%d/deployments", s.ip, s.port), &deployments)
+	return
+}
+
+//GetInstances - returns the instances of the given deployment along with
+//their process state and az. the full listing runs as a bosh task
+func (s *boshDirector) GetInstances(deploymentName string) (instances []BoshInstance, err error) {
+	output, err := s.runListTask(fmt.Sprintf("%s:%d/deployments/%s/instances?format=full", s.ip, s.port, deploymentName))
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed fetching instances task result")
+	}
+	defer output.Close()
+
+	decoder := json.NewDecoder(output)
+	for decoder.More() {
+		var instance BoshInstance
+		if err = decoder.Decode(&instance); err != nil {
+			return nil, errwrap.Wrap(err, "unable to unmarshal instances task result")
+		}
+		instances = append(instances, instance)
+	}
+	return
+}
+
+//GetTaskOutput - returns the given output (result, event or debug) of a task
+func (s *boshDirector) GetTaskOutput(taskID int, outputType string) (io.ReadCloser, error) {
+	return s.get(fmt.Sprintf("%s:%d/tasks/%d/output?type=%s", s.ip, s.port, taskID, outputType))
+}
+
+//GetTaskEvents - returns the events a task has logged past the given offset
+//of its event output, along with the offset to ask for the next ones from.
+//only the new part of the output is fetched, a line the director is still
+//writing is left for the next call
+func (s *boshDirector) GetTaskEvents(taskID int, offset int64) (events []BoshTaskEvent, next int64, err error) {
+	var (
+		header http.Header
+		res    *http.Response
+		body   []byte
+	)
+	endpoint := fmt.Sprintf("%s:%d/tasks/%d/output?type=%s", s.ip, s.port, taskID, BOSHTaskOutputEvent)
+	if offset > 0 {
+		header = http.Header{"Range": {fmt.Sprintf("bytes=%d-", offset)}}
+	}
+	if res, err = s.do("GET", endpoint, header); err != nil {
+		return nil, offset, errwrap.Wrap(err, "failed fetching task events")
+	}
+	defer res.Body.Close()
+
+	switch res.StatusCode {
+	case http.StatusRequestedRangeNotSatisfiable:
+		return nil, offset, nil
+	case http.StatusPartialContent:
+	case http.StatusOK:
+		//the director ignored the range and sent the whole output
+		if _, err = io.CopyN(ioutil.Discard, res.Body, offset); err == io.EOF {
+			return nil, offset, nil
+		} else if err != nil {
+			return nil, offset, errwrap.Wrap(err, "failed reading task events")
+		}
+	default:
+		return nil, offset, fmt.Errorf("unsuccessful status code fetching task events: %v", res.StatusCode)
+	}
+	if body, err = ioutil.ReadAll(res.Body); err != nil {
+		return nil, offset, errwrap.Wrap(err, "failed reading task events")
+	}
+
+	complete := bytes.LastIndexByte(body, '\n') + 1
+	decoder := json.NewDecoder(bytes.NewReader(body[:complete]))
+	for decoder.More() {
+		var event BoshTaskEvent
+		if err = decoder.Decode(&event); err != nil {
+			return nil, offset, errwrap.Wrap(err, "unable to unmarshal task event")
+		}
+		events = append(events, event)
+	}
+	return events, offset + int64(complete), nil
+}
+
+//CancelTask - cancels a queued or processing task
+func (s *boshDirector) CancelTask(taskID int) error {
+	res, err := s.do("DELETE", fmt.Sprintf("%s:%d/task/%d", s.ip, s.port, taskID), nil)
+	if err != nil {
+		return err
+	}
+	res.Body.Close()
+
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		return fmt.Errorf("unsuccessful status code cancelling task %d: %v", taskID, res.StatusCode)
+	}
+	return nil
+}
+
+//GetReleases - returns the releases uploaded to the targetted bosh director
+func (s *boshDirector) GetReleases() (releases []BoshRelease, err error) {
+	err = s.getJSON(fmt.Sprintf("%s:%d/releases", s.ip, s.port), &releases)
+	return
+}
+
+//GetStemcells - returns the stemcells uploaded to the targetted bosh director
+func (s *boshDirector) GetStemcells() (stemcells []BoshStemcell, err error) {
+	err = s.getJSON(fmt.Sprintf("%s:%d/stemcells", s.ip, s.port), &stemcells)
+	return
+}
+
+//runListTask - full listings run as a bosh task, we wait on the task the
+//director redirects us to and return its result output
+func (s *boshDirector) runListTask(endpoint string) (io.ReadCloser, error) {
+	res, err := s.do("GET", endpoint, nil)
+	if err != nil {
+		return nil, err
+	}
+	res.Body.Close()
+
+	taskID, err := retrieveTaskID(res)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed retrieving taskid from response")
+	}
+
+	if err = waitUntilDone(taskID, s); err != nil {
+		return nil, errwrap.Wrap(err, "failed waiting on list task")
+	}
+	return s.GetTaskOutput(taskID, BOSHTaskOutputResult)
+}
+
+func (s *boshDirector) getJSON(endpoint string, v interface{}) error {
+	body, err := s.get(endpoint)
+	if err != nil {
+		return err
+	}
+	defer body.Close()
+
+	if err = json.NewDecoder(body).Decode(v); err != nil {
+		return errwrap.Wrap(err, "unable to unmarshal response body")
+	}
+	return nil
+}
+
+//HasIP - whether the ip is one of the instance's
+func (s BoshInstance) HasIP(ip string) bool {
+	for _, instanceIP := range s.IPs {
+		if instanceIP == ip {
+			return true
+		}
+	}
+	return false
+}
@@ -0,0 +1,235 @@
+package cfbackup_test
+
+import (
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strconv"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+
+	. "github.com/pivotalservices/cfbackup"
+)
+
+var _ = Describe("Bosh director typed calls", func() {
+	const basicAuthBoshInfo = `{"name":"enaml-bosh","uuid":"31631ff9-ac41-4eba-a944-04c820633e7f","version":"1.3232.2.0 (00000000)","user_authentication":{"type":"basic","options":{}}}`
+
+	var (
+		server            *ghttp.Server
+		director          Bosh
+		originalFrequency = TaskPingFreq
+	)
+
+	BeforeEach(func() {
+		TaskPingFreq = time.Millisecond
+		server = ghttp.NewTLSServer()
+		server.AppendHandlers(
+			ghttp.CombineHandlers(
+				ghttp.VerifyRequest("GET", "/info"),
+				ghttp.RespondWith(http.StatusOK, basicAuthBoshInfo),
+			),
+		)
+		u, _ := url.Parse(server.URL())
+		host, port, _ := net.SplitHostPort(u.Host)
+		portInt, _ := strconv.Atoi(port)
+		var err error
+		director, err = NewDirector(u.Scheme+"://"+host, "admin", "admin", portInt, TLSConfig{})
+		Expect(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		TaskPingFreq = originalFrequency
+		server.Close()
+	})
+
+	Context("when listing deployments", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/deployments"),
+					ghttp.RespondWith(http.StatusOK, `[{"name":"cf-abc","cloud_config":"none","releases":[{"name":"cf","version":"231"}],"stemcells":[{"name":"bosh-aws-xen-hvm-ubuntu-trusty-go_agent","version":"3232.4"}]}]`),
+				),
+			)
+		})
+
+		It("then it should return the typed deployments", func() {
+			deployments, err := director.GetDeployments()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(deployments).Should(HaveLen(1))
+			Ω(deployments[0].Name).Should(Equal("cf-abc"))
+			Ω(deployments[0].Releases).Should(ConsistOf(BoshNameVersion{Name: "cf", Version: "231"}))
+		})
+	})
+
+	Context("when listing instances", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/deployments/cf-abc/instances", "format=full"),
+					ghttp.RespondWith(http.StatusFound, "", http.Header{"Location": []string{"/tasks/7"}}),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/tasks/7"),
+					ghttp.RespondWith(http.StatusOK, `{"id":7,"state":"processing"}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/tasks/7"),
+					ghttp.RespondWith(http.StatusOK, `{"id":7,"state":"done"}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/tasks/7/output", "type=result"),
+					ghttp.RespondWith(http.StatusOK, `{"id":"a1","job_name":"cloud_controller","index":0,"az":"z1","job_state":"running","processes":[{"name":"cloud_controller_ng","state":"running"}]}
+{"id":"b2","job_name":"cloud_controller","index":1,"az":"z2","job_state":"failing","processes":[{"name":"cloud_controller_ng","state":"failing"}]}
+`),
+				),
+			)
+		})
+
+		It("then it should return the instances with their process state and az", func() {
+			instances, err := director.GetInstances("cf-abc")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(instances).Should(HaveLen(2))
+			Ω(instances[1].ID).Should(Equal("b2"))
+			Ω(instances[1].AZ).Should(Equal("z2"))
+			Ω(instances[1].ProcessState).Should(Equal("failing"))
+			Ω(instances[1].Processes).Should(ConsistOf(BoshProcess{Name: "cloud_controller_ng", State: "failing"}))
+		})
+	})
+
+	Context("when fetching task output", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/tasks/9/output", "type=debug"),
+					ghttp.RespondWith(http.StatusOK, "debug log"),
+				),
+			)
+		})
+
+		It("then it should return the requested output", func() {
+			output, err := director.GetTaskOutput(9, BOSHTaskOutputDebug)
+			Ω(err).ShouldNot(HaveOccurred())
+			defer output.Close()
+			b, _ := ioutil.ReadAll(output)
+			Ω(string(b)).Should(Equal("debug log"))
+		})
+	})
+
+	Context("when fetching task events", func() {
+		var output = `{"time":1460000000,"stage":"Updating job","tags":["cloud_controller"],"total":2,"task":"cloud_controller/0","index":1,"state":"started","progress":0}
+{"time":1460000010,"stage":"Updating job","tags":["cloud_controller"],"total":2,"task":"cloud_controller/0","index":1,"state":"failed","progress":100,"error":{"code":450001,"message":"timed out"}}
+`
+		var first = int64(strings.Index(output, "\n") + 1)
+
+		It("then it should return the typed events and the offset past them", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/tasks/9/output", "type=event"),
+					ghttp.RespondWith(http.StatusOK, output),
+				),
+			)
+			events, next, err := director.GetTaskEvents(9, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(events).Should(HaveLen(2))
+			Ω(events[0].Error).Should(BeNil())
+			Ω(events[1].State).Should(Equal("failed"))
+			Ω(events[1].Error).Should(Equal(&BoshTaskEventError{Code: 450001, Message: "timed out"}))
+			Ω(next).Should(Equal(int64(len(output))))
+		})
+
+		It("then it should only ask for the output past the offset", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/tasks/9/output", "type=event"),
+					ghttp.VerifyHeaderKV("Range", fmt.Sprintf("bytes=%d-", first)),
+					ghttp.RespondWith(http.StatusPartialContent, output[first:]),
+				),
+			)
+			events, next, err := director.GetTaskEvents(9, first)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(events).Should(HaveLen(1))
+			Ω(events[0].State).Should(Equal("failed"))
+			Ω(next).Should(Equal(int64(len(output))))
+		})
+
+		It("then it should skip the events already seen when the director sends the whole output", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, output))
+			events, _, err := director.GetTaskEvents(9, first)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(events).Should(HaveLen(1))
+			Ω(events[0].State).Should(Equal("failed"))
+		})
+
+		It("then it should return no events when there are none past the offset", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusRequestedRangeNotSatisfiable, ""))
+			events, next, err := director.GetTaskEvents(9, int64(len(output)))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(events).Should(BeEmpty())
+			Ω(next).Should(Equal(int64(len(output))))
+		})
+
+		It("then it should leave a line still being written for the next call", func() {
+			server.AppendHandlers(ghttp.RespondWith(http.StatusOK, output[:len(output)-10]))
+			events, next, err := director.GetTaskEvents(9, 0)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(events).Should(HaveLen(1))
+			Ω(next).Should(Equal(first))
+		})
+	})
+
+	Context("when cancelling a task", func() {
+		It("then it should succeed when the director accepts the cancellation", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/task/9"),
+					ghttp.RespondWith(http.StatusNoContent, ""),
+				),
+			)
+			Ω(director.CancelTask(9)).Should(Succeed())
+		})
+
+		It("then it should fail when the director refuses the cancellation", func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("DELETE", "/task/9"),
+					ghttp.RespondWith(http.StatusBadRequest, ""),
+				),
+			)
+			Ω(director.CancelTask(9)).ShouldNot(Succeed())
+		})
+	})
+
+	Context("when listing releases and stemcells", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/releases"),
+					ghttp.RespondWith(http.StatusOK, `[{"name":"cf","release_versions":[{"version":"231","commit_hash":"2a4b4b2e","currently_deployed":true}]}]`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyRequest("GET", "/stemcells"),
+					ghttp.RespondWith(http.StatusOK, `[{"name":"bosh-aws-xen-hvm-ubuntu-trusty-go_agent","operating_system":"ubuntu-trusty","version":"3232.4","cid":"ami-123","deployments":[{"name":"cf-abc"}]}]`),
+				),
+			)
+		})
+
+		It("then it should return the typed releases and stemcells", func() {
+			releases, err := director.GetReleases()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(releases).Should(HaveLen(1))
+			Ω(releases[0].ReleaseVersions[0].CurrentlyDeployed).Should(BeTrue())
+
+			stemcells, err := director.GetStemcells()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stemcells).Should(HaveLen(1))
+			Ω(stemcells[0].OperatingSystem).Should(Equal("ubuntu-trusty"))
+			Ω(stemcells[0].Deployments[0].Name).Should(Equal("cf-abc"))
+		})
+	})
+})
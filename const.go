@@ -3,6 +3,7 @@ package cfbackup
 import (
 	"errors"
 	"os"
+	"time"
 
 	"github.com/pivotalservices/gtils/command"
 )
@@ -23,6 +24,23 @@ const (
 	NfsDirPath string = "/var/vcap/store"
 	//NfsArchiveDir - this is the archive dir name
 	NfsArchiveDir string = "shared"
+	//BlobstoreArchiveDir - this is the dir name of the director's local blobstore
+	BlobstoreArchiveDir string = "blobstore"
+	//MonitPath - the monit of a bosh vm
+	MonitPath string = "/var/vcap/bosh/bin/monit"
+	//MonitStateRunning - the monit summary state of a running process
+	MonitStateRunning string = "running"
+	//MonitStateNotMonitored - the monit summary state of a stopped process
+	MonitStateNotMonitored string = "not monitored"
+	//RedisCLIPath - the redis-cli of the redis release
+	RedisCLIPath string = "/var/vcap/packages/redis/bin/redis-cli"
+	//RedisAppendOnlyMarker - left in the instance dir while the append only
+	//file is turned off for loading a restored rdb
+	RedisAppendOnlyMarker string = ".cfbackup-appendonly"
+	//RedisReloadAttempts - how many seconds a restarted instance has to answer
+	RedisReloadAttempts = 60
+	//BOSHDirectorDBPort - port the director's postgres listens on
+	BOSHDirectorDBPort = 5432
 
 	//ERDefaultSystemUser - default user for system vms
 	ERDefaultSystemUser = "vcap"
@@ -44,6 +62,12 @@ const (
 	ERMySQL = "MysqldbInfo"
 	//ERNfs -- key
 	ERNfs = "NfsInfo"
+	//ERDirectorDB -- key
+	ERDirectorDB = "DirectorDBInfo"
+	//ERDirectorBlobstore -- key
+	ERDirectorBlobstore = "DirectorBlobstoreInfo"
+	//ERDirectorCredentialStore -- key
+	ERDirectorCredentialStore = "DirectorCredentialStoreInfo"
 	//ERBackupFileFormat -- format of archive filename
 	ERBackupFileFormat = "%s.backup"
 	//ERInvalidDirectorCredsMsg -- error message for invalid creds on director
@@ -84,6 +108,49 @@ const (
 	SDIdentifier string = "Identifier"
 )
 
+const (
+	//ProgressStepStarted - a step of a backup or restore started
+	ProgressStepStarted = "step-started"
+	//ProgressStepFinished - a step of a backup or restore finished, failed
+	//when the event has an error
+	ProgressStepFinished = "step-finished"
+	//ProgressCCStopped - the cloud controller jobs were stopped
+	ProgressCCStopped = "cc-stopped"
+	//ProgressCCStarted - the cloud controller jobs were started again
+	ProgressCCStarted = "cc-started"
+	//ProgressBytesTransferred - bytes of an artifact were written or read
+	ProgressBytesTransferred = "bytes-transferred"
+	//ProgressTileStarted - backing up or restoring a tile started
+	ProgressTileStarted = "tile-started"
+	//ProgressTileFinished - backing up or restoring a tile finished, failed
+	//when the event has an error
+	ProgressTileFinished = "tile-finished"
+	//ProgressBOSHTask - a bosh task changing the state of a job finished
+	ProgressBOSHTask = "bosh-task"
+	//ProgressWarning - something went wrong which does not fail the backup
+	//or restore
+	ProgressWarning = "warning"
+	//ProgressFoundation - the foundation being backed up or restored
+	ProgressFoundation = "foundation"
+	//RunReportFileName - the report of a run of the orchestrator, by action
+	RunReportFileName = "%s-report.json"
+	//MetricsProviderDisk - the storage provider label of the disk
+	MetricsProviderDisk = "disk"
+	//MetricsProviderS3 - the storage provider label of s3
+	MetricsProviderS3 = "s3"
+	//MetricsErrorTile - the error type of a failed tile
+	MetricsErrorTile = "tile"
+	//MetricsErrorComponent - the error type of a failed component of a tile
+	MetricsErrorComponent = "component"
+	//MetricsErrorBOSHTask - the error type of a failed bosh task
+	MetricsErrorBOSHTask = "bosh_task"
+	//MetricsErrorStorage - the error type of a file the storage provider
+	//could not open
+	MetricsErrorStorage = "storage"
+	//MetricsErrorNFS - the error type of a failed nfs dump or import
+	MetricsErrorNFS = "nfs"
+)
+
 const (
 	//ImportArchive --
 	ImportArchive = iota
@@ -98,11 +165,57 @@ const (
 	BOSHQueued     int = 4
 )
 
+const (
+	//BOSHTaskOutputResult - task output holding the result of the task
+	BOSHTaskOutputResult = "result"
+	//BOSHTaskOutputEvent - task output holding the progress events of the task
+	BOSHTaskOutputEvent = "event"
+	//BOSHTaskOutputDebug - task output holding the debug log of the task
+	BOSHTaskOutputDebug = "debug"
+)
+
+const (
+	//BOSHJobStateRunning - job state reported for a running instance
+	BOSHJobStateRunning = "running"
+	//BOSHJobStateStopped - job state reported for an instance stopped by an operator
+	BOSHJobStateStopped = "stopped"
+)
+
+const (
+	//BOSHAuthTypeUAA - user authentication type advertised by a director
+	//which delegates auth to a uaa
+	BOSHAuthTypeUAA = "uaa"
+	//UAABoshCLIClient - uaa client used for password grants against the
+	//director's uaa
+	UAABoshCLIClient = "bosh_cli"
+	//UAAGrantTypeClientCredentials --
+	UAAGrantTypeClientCredentials = "client_credentials"
+	//UAAGrantTypePassword --
+	UAAGrantTypePassword = "password"
+	//UAAGrantTypeRefreshToken --
+	UAAGrantTypeRefreshToken = "refresh_token"
+)
+
 var Taskresult map[string]int = map[string]int{"error": BOSHError, "processing": BOSHProcessing, "done": BOSHDone, "queued": BOSHQueued}
 var (
+	//ProgressInterval - how often the bytes transferred of an artifact are
+	//reported while it is written or read
+	ProgressInterval = 5 * time.Second
+
 	//NfsNewRemoteExecuter - this is a function which is able to execute a remote command against the nfs server
 	NfsNewRemoteExecuter = command.NewRemoteExecutor
 
+	//DirectorProcessPatterns - the monit processes of the director job which
+	//write its database and blobstore: the api, its workers and its
+	//schedulers. postgres and the blobstore itself keep running
+	DirectorProcessPatterns = []string{"director", "worker_*", "director_scheduler", "director_sync_dns"}
+	//DirectorProcessTimeout - how long the director processes get to stop
+	//or start
+	DirectorProcessTimeout = 5 * time.Minute
+	//DirectorProcessPollInterval - how often monit is asked whether the
+	//director processes stopped or started
+	DirectorProcessPollInterval = 5 * time.Second
+
 	//ErrERDirectorCreds - error for director creds
 	ErrERDirectorCreds = errors.New(ERInvalidDirectorCredsMsg)
 	//ErrEREmptyDBList - error for db list empty
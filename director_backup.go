@@ -0,0 +1,196 @@
+package cfbackup
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"path"
+	"sort"
+	"strings"
+	"time"
+
+	"github.com/pivotalservices/gtils/command"
+	"github.com/pivotalservices/gtils/osutils"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//ReadBoshInfo - reads the body of a director info response
+func ReadBoshInfo(body io.Reader) (info *BoshInfo, err error) {
+	info = &BoshInfo{}
+	if err = json.NewDecoder(body).Decode(info); err != nil {
+		return nil, errwrap.Wrap(err, "unable to unmarshal director info")
+	}
+	return
+}
+
+//EnableDirectorBackup - registers the director database, its local blobstore
+//and its credential store so PersistentSystems includes them. they share the
+//director job (and its ip) with the director api credentials
+func (s SystemsInfo) EnableDirectorBackup(sshKey string) {
+	const directorRemoteArchivePath = "/var/vcap/store/director-archive.backup"
+	boshName := s.SystemDumps[ERDirector].Get(SDProduct)
+
+	s.SystemDumps[ERDirectorDB] = &DirectorInfo{
+		SystemInfo: SystemInfo{
+			Product:           boshName,
+			Component:         "director",
+			Identifier:        "postgres_credentials",
+			SSHPrivateKey:     sshKey,
+			RemoteArchivePath: directorRemoteArchivePath,
+		},
+		Database: "bosh",
+	}
+	s.SystemDumps[ERDirectorCredentialStore] = &DirectorInfo{
+		SystemInfo: SystemInfo{
+			Product:           boshName,
+			Component:         "director",
+			Identifier:        "postgres_credentials",
+			SSHPrivateKey:     sshKey,
+			RemoteArchivePath: directorRemoteArchivePath,
+		},
+		Database: "credhub",
+	}
+	s.SystemDumps[ERDirectorBlobstore] = &DirectorBlobstoreInfo{
+		SystemInfo: SystemInfo{
+			Product:           boshName,
+			Component:         "director",
+			Identifier:        "vm_credentials",
+			SSHPrivateKey:     sshKey,
+			RemoteArchivePath: directorRemoteArchivePath,
+		},
+	}
+}
+
+//NewBlobstoreBackup - constructor for a backup of the director's local
+//blobstore, the director is dialed directly
+func NewBlobstoreBackup(username, password, ip, sslKey, remoteArchivePath string) (blobstore *BlobstoreBackup, err error) {
+	return newBlobstoreBackup(nil, username, password, ip, sslKey, remoteArchivePath)
+}
+
+func newBlobstoreBackup(tunnels *SSHTunnels, username, password, ip, sslKey, remoteArchivePath string) (blobstore *BlobstoreBackup, err error) {
+	config := command.SshConfig{
+		Username: username,
+		Password: password,
+		Host:     ip,
+		Port:     22,
+		SSLKey:   sslKey,
+	}
+	var remoteExecuter command.Executer
+
+	if config, err = tunnels.TunnelSSHConfig(config); err != nil {
+		return
+	}
+	if remoteExecuter, err = NfsNewRemoteExecuter(config); err == nil {
+		blobstore = &BlobstoreBackup{
+			Caller:    remoteExecuter,
+			RemoteOps: osutils.NewRemoteOperationsWithPath(config, remoteArchivePath),
+		}
+	}
+	return
+}
+
+//Dump - will write a tarball of the blobstore to the given writer
+func (s *BlobstoreBackup) Dump(dest io.Writer) (err error) {
+	return s.Caller.Execute(dest, fmt.Sprintf("cd %s && tar cz %s", NfsDirPath, BlobstoreArchiveDir))
+}
+
+//Import - will upload the given blobstore tarball to the director and unpack it
+//in place of the blobstore, so blobs added since the backup do not linger. the
+//director processes are expected to be stopped while it runs
+func (s *BlobstoreBackup) Import(lfile io.Reader) (err error) {
+	lo.G.Debug("uploading blobstore archive")
+	if err = s.RemoteOps.UploadFile(lfile); err == nil {
+		err = s.Caller.Execute(ioutil.Discard, fmt.Sprintf("cd %s && rm -rf %s && tar zxf %s", NfsDirPath, BlobstoreArchiveDir, s.RemoteOps.Path()))
+	}
+	s.RemoteOps.RemoveRemoteFile()
+	return
+}
+
+//Stop - stops the director processes with monit and waits for them to stop,
+//the databases and the blobstore of the director stay up
+func (s *DirectorProcesses) Stop() error {
+	return s.monit("stop", MonitStateNotMonitored)
+}
+
+//Start - starts the director processes with monit and waits for them to run
+func (s *DirectorProcesses) Start() error {
+	return s.monit("start", MonitStateRunning)
+}
+
+func (s *DirectorProcesses) monit(action, state string) (err error) {
+	var processes []string
+	if processes, err = s.processes(); err != nil {
+		return
+	}
+	for _, process := range processes {
+		lo.G.Debug(fmt.Sprintf("%s director process %s", action, process))
+		if err = s.Caller.Execute(ioutil.Discard, fmt.Sprintf("sudo %s %s %s", MonitPath, action, process)); err != nil {
+			return errwrap.Wrap(err, fmt.Sprintf("failed to %s director process %s", action, process))
+		}
+	}
+	return s.waitFor(processes, state)
+}
+
+//processes - the processes monit watches on the director job which match the
+//patterns. the director is never left running for want of a match
+func (s *DirectorProcesses) processes() (processes []string, err error) {
+	var states map[string]string
+	if states, err = s.summary(); err != nil {
+		return
+	}
+	for process := range states {
+		for _, pattern := range s.Patterns {
+			if matched, _ := path.Match(pattern, process); matched {
+				processes = append(processes, process)
+				break
+			}
+		}
+	}
+	if len(processes) == 0 {
+		return nil, fmt.Errorf("monit watches none of the director processes %v", s.Patterns)
+	}
+	sort.Strings(processes)
+	return
+}
+
+func (s *DirectorProcesses) waitFor(processes []string, state string) error {
+	deadline := time.Now().Add(DirectorProcessTimeout)
+	for {
+		states, err := s.summary()
+		if err != nil {
+			return err
+		}
+		pending := ""
+		for _, process := range processes {
+			if states[process] != state {
+				pending = process
+				break
+			}
+		}
+		if pending == "" {
+			return nil
+		}
+		if time.Now().After(deadline) {
+			return fmt.Errorf("director process %s is %s rather than %s after %v", pending, states[pending], state, DirectorProcessTimeout)
+		}
+		time.Sleep(DirectorProcessPollInterval)
+	}
+}
+
+//summary - the state of every process monit watches on the director job
+func (s *DirectorProcesses) summary() (states map[string]string, err error) {
+	var b bytes.Buffer
+	if err = s.Caller.Execute(&b, fmt.Sprintf("sudo %s summary", MonitPath)); err != nil {
+		return nil, errwrap.Wrap(err, "failed reading the monit summary of the director")
+	}
+	states = make(map[string]string)
+	for _, line := range strings.Split(b.String(), "\n") {
+		if fields := strings.SplitN(line, "'", 3); len(fields) == 3 && strings.TrimSpace(fields[0]) == "Process" {
+			states[fields[1]] = strings.TrimSpace(fields[2])
+		}
+	}
+	return
+}
@@ -0,0 +1,114 @@
+package cfbackup_test
+
+import (
+	"errors"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+	. "github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/fakes"
+	"github.com/pivotalservices/gtils/mock"
+)
+
+var _ = Describe("DirectorProcesses", func() {
+	var (
+		monit     *fakes.FakeMonit
+		processes *DirectorProcesses
+		timeout   time.Duration
+		interval  time.Duration
+	)
+
+	BeforeEach(func() {
+		timeout, interval = DirectorProcessTimeout, DirectorProcessPollInterval
+		DirectorProcessTimeout, DirectorProcessPollInterval = 10*time.Millisecond, time.Millisecond
+		monit = fakes.NewFakeMonit("director", "worker_1", "worker_2", "director_scheduler", "director_nginx", "postgres", "blobstore_nginx")
+		processes = &DirectorProcesses{Caller: monit, Patterns: DirectorProcessPatterns}
+	})
+
+	AfterEach(func() {
+		DirectorProcessTimeout, DirectorProcessPollInterval = timeout, interval
+	})
+
+	Describe("given a Stop() method", func() {
+		It("then it should stop the api, the workers and the scheduler of the director", func() {
+			Ω(processes.Stop()).Should(Succeed())
+			Ω(monit.Stopped()).Should(Equal([]string{"director", "director_scheduler", "worker_1", "worker_2"}))
+		})
+
+		It("then it should leave the database and the blobstore running", func() {
+			processes.Stop()
+			Ω(monit.Processes["postgres"]).Should(Equal(MonitStateRunning))
+			Ω(monit.Processes["blobstore_nginx"]).Should(Equal(MonitStateRunning))
+		})
+
+		Context("when a process does not stop in time", func() {
+			It("then it should fail", func() {
+				monit.Stuck = "worker_2"
+				err := processes.Stop()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("worker_2"))
+			})
+		})
+
+		Context("when monit watches none of the director processes", func() {
+			It("then it should fail rather than leave the director running", func() {
+				processes.Caller = fakes.NewFakeMonit("postgres")
+				Ω(processes.Stop()).ShouldNot(Succeed())
+			})
+		})
+
+		Context("when monit can not be reached", func() {
+			It("then it should fail", func() {
+				monit.FailOn, monit.ErrFake = "summary", errors.New("connection refused")
+				Ω(processes.Stop()).ShouldNot(Succeed())
+			})
+		})
+	})
+
+	Describe("given a Start() method", func() {
+		It("then it should start the stopped processes again", func() {
+			processes.Stop()
+			Ω(processes.Start()).Should(Succeed())
+			Ω(monit.Stopped()).Should(BeEmpty())
+		})
+	})
+})
+
+var _ = Describe("BlobstoreBackup", func() {
+	var (
+		blobstore *BlobstoreBackup
+		executer  *fakes.SuccessMockNFSExecuter
+		buffer    *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		executer = &fakes.SuccessMockNFSExecuter{}
+		buffer = gbytes.NewBuffer()
+		blobstore = fakes.GetBlobstore(buffer, executer)
+	})
+
+	Describe("given an Import() method", func() {
+		var controlString = "blobstore tarball"
+
+		It("then it should upload the tarball", func() {
+			Ω(blobstore.Import(strings.NewReader(controlString))).Should(Succeed())
+			Ω(buffer).Should(gbytes.Say(controlString))
+		})
+
+		It("then it should remove the blobstore before unpacking the tarball in its place", func() {
+			Ω(blobstore.Import(strings.NewReader(controlString))).Should(Succeed())
+			Ω(executer.ActualCommand).Should(Equal("cd " + NfsDirPath + " && rm -rf " + BlobstoreArchiveDir + " && tar zxf " + blobstore.RemoteOps.Path()))
+		})
+
+		Context("when the upload fails", func() {
+			It("then it should leave the blobstore in place", func() {
+				err := blobstore.Import(mock.NewReadWriteCloser(mock.ErrReadFailure, nil, nil))
+				Ω(err).Should(Equal(mock.ErrReadFailure))
+				Ω(executer.ActualCommand).Should(BeEmpty())
+			})
+		})
+	})
+})
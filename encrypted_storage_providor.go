@@ -26,6 +26,14 @@ func NewEncryptedStorageProvider(storageProvider StorageProvider, encryptionKey
 	return
 }
 
+//SetTLSConfig - passes the config on to the wrapped provider, when it is
+//reached over http
+func (s *EncryptedStorageProvider) SetTLSConfig(config TLSConfig) {
+	if configured, ok := s.wrappedStorageProvider.(TLSConfigured); ok {
+		configured.SetTLSConfig(config)
+	}
+}
+
 //Reader - returns the encrpyted reader for the given path
 func (s *EncryptedStorageProvider) Reader(path ...string) (decryptReader io.ReadCloser, err error) {
 	var unEncryptedReader io.ReadCloser
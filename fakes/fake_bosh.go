@@ -75,6 +75,93 @@ type FakeBosh struct {
 		result1 *cfbackup.Task
 		result2 error
 	}
+	GetDeploymentsStub        func() ([]cfbackup.BoshDeployment, error)
+	getDeploymentsMutex       sync.RWMutex
+	getDeploymentsArgsForCall []struct{}
+	getDeploymentsReturns     struct {
+		result1 []cfbackup.BoshDeployment
+		result2 error
+	}
+	getDeploymentsReturnsOnCall map[int]struct {
+		result1 []cfbackup.BoshDeployment
+		result2 error
+	}
+	GetInstancesStub        func(deploymentName string) ([]cfbackup.BoshInstance, error)
+	getInstancesMutex       sync.RWMutex
+	getInstancesArgsForCall []struct {
+		deploymentName string
+	}
+	getInstancesReturns struct {
+		result1 []cfbackup.BoshInstance
+		result2 error
+	}
+	getInstancesReturnsOnCall map[int]struct {
+		result1 []cfbackup.BoshInstance
+		result2 error
+	}
+	GetTaskOutputStub        func(taskID int, outputType string) (io.ReadCloser, error)
+	getTaskOutputMutex       sync.RWMutex
+	getTaskOutputArgsForCall []struct {
+		taskID     int
+		outputType string
+	}
+	getTaskOutputReturns struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	getTaskOutputReturnsOnCall map[int]struct {
+		result1 io.ReadCloser
+		result2 error
+	}
+	GetTaskEventsStub        func(taskID int, offset int64) ([]cfbackup.BoshTaskEvent, int64, error)
+	getTaskEventsMutex       sync.RWMutex
+	getTaskEventsArgsForCall []struct {
+		taskID int
+		offset int64
+	}
+	getTaskEventsReturns struct {
+		result1 []cfbackup.BoshTaskEvent
+		result2 int64
+		result3 error
+	}
+	getTaskEventsReturnsOnCall map[int]struct {
+		result1 []cfbackup.BoshTaskEvent
+		result2 int64
+		result3 error
+	}
+	CancelTaskStub        func(taskID int) error
+	cancelTaskMutex       sync.RWMutex
+	cancelTaskArgsForCall []struct {
+		taskID int
+	}
+	cancelTaskReturns struct {
+		result1 error
+	}
+	cancelTaskReturnsOnCall map[int]struct {
+		result1 error
+	}
+	GetReleasesStub        func() ([]cfbackup.BoshRelease, error)
+	getReleasesMutex       sync.RWMutex
+	getReleasesArgsForCall []struct{}
+	getReleasesReturns     struct {
+		result1 []cfbackup.BoshRelease
+		result2 error
+	}
+	getReleasesReturnsOnCall map[int]struct {
+		result1 []cfbackup.BoshRelease
+		result2 error
+	}
+	GetStemcellsStub        func() ([]cfbackup.BoshStemcell, error)
+	getStemcellsMutex       sync.RWMutex
+	getStemcellsArgsForCall []struct{}
+	getStemcellsReturns     struct {
+		result1 []cfbackup.BoshStemcell
+		result2 error
+	}
+	getStemcellsReturnsOnCall map[int]struct {
+		result1 []cfbackup.BoshStemcell
+		result2 error
+	}
 	invocations      map[string][][]interface{}
 	invocationsMutex sync.RWMutex
 }
@@ -329,6 +416,341 @@ func (fake *FakeBosh) RetrieveTaskStatusReturnsOnCall(i int, result1 *cfbackup.T
 	}{result1, result2}
 }
 
+func (fake *FakeBosh) GetDeployments() ([]cfbackup.BoshDeployment, error) {
+	fake.getDeploymentsMutex.Lock()
+	ret, specificReturn := fake.getDeploymentsReturnsOnCall[len(fake.getDeploymentsArgsForCall)]
+	fake.getDeploymentsArgsForCall = append(fake.getDeploymentsArgsForCall, struct{}{})
+	fake.recordInvocation("GetDeployments", []interface{}{})
+	fake.getDeploymentsMutex.Unlock()
+	if fake.GetDeploymentsStub != nil {
+		return fake.GetDeploymentsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getDeploymentsReturns.result1, fake.getDeploymentsReturns.result2
+}
+
+func (fake *FakeBosh) GetDeploymentsCallCount() int {
+	fake.getDeploymentsMutex.RLock()
+	defer fake.getDeploymentsMutex.RUnlock()
+	return len(fake.getDeploymentsArgsForCall)
+}
+
+func (fake *FakeBosh) GetDeploymentsReturns(result1 []cfbackup.BoshDeployment, result2 error) {
+	fake.GetDeploymentsStub = nil
+	fake.getDeploymentsReturns = struct {
+		result1 []cfbackup.BoshDeployment
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetDeploymentsReturnsOnCall(i int, result1 []cfbackup.BoshDeployment, result2 error) {
+	fake.GetDeploymentsStub = nil
+	if fake.getDeploymentsReturnsOnCall == nil {
+		fake.getDeploymentsReturnsOnCall = make(map[int]struct {
+			result1 []cfbackup.BoshDeployment
+			result2 error
+		})
+	}
+	fake.getDeploymentsReturnsOnCall[i] = struct {
+		result1 []cfbackup.BoshDeployment
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetInstances(deploymentName string) ([]cfbackup.BoshInstance, error) {
+	fake.getInstancesMutex.Lock()
+	ret, specificReturn := fake.getInstancesReturnsOnCall[len(fake.getInstancesArgsForCall)]
+	fake.getInstancesArgsForCall = append(fake.getInstancesArgsForCall, struct {
+		deploymentName string
+	}{deploymentName})
+	fake.recordInvocation("GetInstances", []interface{}{deploymentName})
+	fake.getInstancesMutex.Unlock()
+	if fake.GetInstancesStub != nil {
+		return fake.GetInstancesStub(deploymentName)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getInstancesReturns.result1, fake.getInstancesReturns.result2
+}
+
+func (fake *FakeBosh) GetInstancesCallCount() int {
+	fake.getInstancesMutex.RLock()
+	defer fake.getInstancesMutex.RUnlock()
+	return len(fake.getInstancesArgsForCall)
+}
+
+func (fake *FakeBosh) GetInstancesArgsForCall(i int) string {
+	fake.getInstancesMutex.RLock()
+	defer fake.getInstancesMutex.RUnlock()
+	return fake.getInstancesArgsForCall[i].deploymentName
+}
+
+func (fake *FakeBosh) GetInstancesReturns(result1 []cfbackup.BoshInstance, result2 error) {
+	fake.GetInstancesStub = nil
+	fake.getInstancesReturns = struct {
+		result1 []cfbackup.BoshInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetInstancesReturnsOnCall(i int, result1 []cfbackup.BoshInstance, result2 error) {
+	fake.GetInstancesStub = nil
+	if fake.getInstancesReturnsOnCall == nil {
+		fake.getInstancesReturnsOnCall = make(map[int]struct {
+			result1 []cfbackup.BoshInstance
+			result2 error
+		})
+	}
+	fake.getInstancesReturnsOnCall[i] = struct {
+		result1 []cfbackup.BoshInstance
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetTaskOutput(taskID int, outputType string) (io.ReadCloser, error) {
+	fake.getTaskOutputMutex.Lock()
+	ret, specificReturn := fake.getTaskOutputReturnsOnCall[len(fake.getTaskOutputArgsForCall)]
+	fake.getTaskOutputArgsForCall = append(fake.getTaskOutputArgsForCall, struct {
+		taskID     int
+		outputType string
+	}{taskID, outputType})
+	fake.recordInvocation("GetTaskOutput", []interface{}{taskID, outputType})
+	fake.getTaskOutputMutex.Unlock()
+	if fake.GetTaskOutputStub != nil {
+		return fake.GetTaskOutputStub(taskID, outputType)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getTaskOutputReturns.result1, fake.getTaskOutputReturns.result2
+}
+
+func (fake *FakeBosh) GetTaskOutputCallCount() int {
+	fake.getTaskOutputMutex.RLock()
+	defer fake.getTaskOutputMutex.RUnlock()
+	return len(fake.getTaskOutputArgsForCall)
+}
+
+func (fake *FakeBosh) GetTaskOutputArgsForCall(i int) (int, string) {
+	fake.getTaskOutputMutex.RLock()
+	defer fake.getTaskOutputMutex.RUnlock()
+	return fake.getTaskOutputArgsForCall[i].taskID, fake.getTaskOutputArgsForCall[i].outputType
+}
+
+func (fake *FakeBosh) GetTaskOutputReturns(result1 io.ReadCloser, result2 error) {
+	fake.GetTaskOutputStub = nil
+	fake.getTaskOutputReturns = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetTaskOutputReturnsOnCall(i int, result1 io.ReadCloser, result2 error) {
+	fake.GetTaskOutputStub = nil
+	if fake.getTaskOutputReturnsOnCall == nil {
+		fake.getTaskOutputReturnsOnCall = make(map[int]struct {
+			result1 io.ReadCloser
+			result2 error
+		})
+	}
+	fake.getTaskOutputReturnsOnCall[i] = struct {
+		result1 io.ReadCloser
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetTaskEvents(taskID int, offset int64) ([]cfbackup.BoshTaskEvent, int64, error) {
+	fake.getTaskEventsMutex.Lock()
+	ret, specificReturn := fake.getTaskEventsReturnsOnCall[len(fake.getTaskEventsArgsForCall)]
+	fake.getTaskEventsArgsForCall = append(fake.getTaskEventsArgsForCall, struct {
+		taskID int
+		offset int64
+	}{taskID, offset})
+	fake.recordInvocation("GetTaskEvents", []interface{}{taskID, offset})
+	fake.getTaskEventsMutex.Unlock()
+	if fake.GetTaskEventsStub != nil {
+		return fake.GetTaskEventsStub(taskID, offset)
+	}
+	if specificReturn {
+		return ret.result1, ret.result2, ret.result3
+	}
+	return fake.getTaskEventsReturns.result1, fake.getTaskEventsReturns.result2, fake.getTaskEventsReturns.result3
+}
+
+func (fake *FakeBosh) GetTaskEventsCallCount() int {
+	fake.getTaskEventsMutex.RLock()
+	defer fake.getTaskEventsMutex.RUnlock()
+	return len(fake.getTaskEventsArgsForCall)
+}
+
+func (fake *FakeBosh) GetTaskEventsArgsForCall(i int) (int, int64) {
+	fake.getTaskEventsMutex.RLock()
+	defer fake.getTaskEventsMutex.RUnlock()
+	return fake.getTaskEventsArgsForCall[i].taskID, fake.getTaskEventsArgsForCall[i].offset
+}
+
+func (fake *FakeBosh) GetTaskEventsReturns(result1 []cfbackup.BoshTaskEvent, result2 int64, result3 error) {
+	fake.GetTaskEventsStub = nil
+	fake.getTaskEventsReturns = struct {
+		result1 []cfbackup.BoshTaskEvent
+		result2 int64
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeBosh) GetTaskEventsReturnsOnCall(i int, result1 []cfbackup.BoshTaskEvent, result2 int64, result3 error) {
+	fake.GetTaskEventsStub = nil
+	if fake.getTaskEventsReturnsOnCall == nil {
+		fake.getTaskEventsReturnsOnCall = make(map[int]struct {
+			result1 []cfbackup.BoshTaskEvent
+			result2 int64
+			result3 error
+		})
+	}
+	fake.getTaskEventsReturnsOnCall[i] = struct {
+		result1 []cfbackup.BoshTaskEvent
+		result2 int64
+		result3 error
+	}{result1, result2, result3}
+}
+
+func (fake *FakeBosh) CancelTask(taskID int) error {
+	fake.cancelTaskMutex.Lock()
+	ret, specificReturn := fake.cancelTaskReturnsOnCall[len(fake.cancelTaskArgsForCall)]
+	fake.cancelTaskArgsForCall = append(fake.cancelTaskArgsForCall, struct {
+		taskID int
+	}{taskID})
+	fake.recordInvocation("CancelTask", []interface{}{taskID})
+	fake.cancelTaskMutex.Unlock()
+	if fake.CancelTaskStub != nil {
+		return fake.CancelTaskStub(taskID)
+	}
+	if specificReturn {
+		return ret.result1
+	}
+	return fake.cancelTaskReturns.result1
+}
+
+func (fake *FakeBosh) CancelTaskCallCount() int {
+	fake.cancelTaskMutex.RLock()
+	defer fake.cancelTaskMutex.RUnlock()
+	return len(fake.cancelTaskArgsForCall)
+}
+
+func (fake *FakeBosh) CancelTaskArgsForCall(i int) int {
+	fake.cancelTaskMutex.RLock()
+	defer fake.cancelTaskMutex.RUnlock()
+	return fake.cancelTaskArgsForCall[i].taskID
+}
+
+func (fake *FakeBosh) CancelTaskReturns(result1 error) {
+	fake.CancelTaskStub = nil
+	fake.cancelTaskReturns = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBosh) CancelTaskReturnsOnCall(i int, result1 error) {
+	fake.CancelTaskStub = nil
+	if fake.cancelTaskReturnsOnCall == nil {
+		fake.cancelTaskReturnsOnCall = make(map[int]struct {
+			result1 error
+		})
+	}
+	fake.cancelTaskReturnsOnCall[i] = struct {
+		result1 error
+	}{result1}
+}
+
+func (fake *FakeBosh) GetReleases() ([]cfbackup.BoshRelease, error) {
+	fake.getReleasesMutex.Lock()
+	ret, specificReturn := fake.getReleasesReturnsOnCall[len(fake.getReleasesArgsForCall)]
+	fake.getReleasesArgsForCall = append(fake.getReleasesArgsForCall, struct{}{})
+	fake.recordInvocation("GetReleases", []interface{}{})
+	fake.getReleasesMutex.Unlock()
+	if fake.GetReleasesStub != nil {
+		return fake.GetReleasesStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getReleasesReturns.result1, fake.getReleasesReturns.result2
+}
+
+func (fake *FakeBosh) GetReleasesCallCount() int {
+	fake.getReleasesMutex.RLock()
+	defer fake.getReleasesMutex.RUnlock()
+	return len(fake.getReleasesArgsForCall)
+}
+
+func (fake *FakeBosh) GetReleasesReturns(result1 []cfbackup.BoshRelease, result2 error) {
+	fake.GetReleasesStub = nil
+	fake.getReleasesReturns = struct {
+		result1 []cfbackup.BoshRelease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetReleasesReturnsOnCall(i int, result1 []cfbackup.BoshRelease, result2 error) {
+	fake.GetReleasesStub = nil
+	if fake.getReleasesReturnsOnCall == nil {
+		fake.getReleasesReturnsOnCall = make(map[int]struct {
+			result1 []cfbackup.BoshRelease
+			result2 error
+		})
+	}
+	fake.getReleasesReturnsOnCall[i] = struct {
+		result1 []cfbackup.BoshRelease
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetStemcells() ([]cfbackup.BoshStemcell, error) {
+	fake.getStemcellsMutex.Lock()
+	ret, specificReturn := fake.getStemcellsReturnsOnCall[len(fake.getStemcellsArgsForCall)]
+	fake.getStemcellsArgsForCall = append(fake.getStemcellsArgsForCall, struct{}{})
+	fake.recordInvocation("GetStemcells", []interface{}{})
+	fake.getStemcellsMutex.Unlock()
+	if fake.GetStemcellsStub != nil {
+		return fake.GetStemcellsStub()
+	}
+	if specificReturn {
+		return ret.result1, ret.result2
+	}
+	return fake.getStemcellsReturns.result1, fake.getStemcellsReturns.result2
+}
+
+func (fake *FakeBosh) GetStemcellsCallCount() int {
+	fake.getStemcellsMutex.RLock()
+	defer fake.getStemcellsMutex.RUnlock()
+	return len(fake.getStemcellsArgsForCall)
+}
+
+func (fake *FakeBosh) GetStemcellsReturns(result1 []cfbackup.BoshStemcell, result2 error) {
+	fake.GetStemcellsStub = nil
+	fake.getStemcellsReturns = struct {
+		result1 []cfbackup.BoshStemcell
+		result2 error
+	}{result1, result2}
+}
+
+func (fake *FakeBosh) GetStemcellsReturnsOnCall(i int, result1 []cfbackup.BoshStemcell, result2 error) {
+	fake.GetStemcellsStub = nil
+	if fake.getStemcellsReturnsOnCall == nil {
+		fake.getStemcellsReturnsOnCall = make(map[int]struct {
+			result1 []cfbackup.BoshStemcell
+			result2 error
+		})
+	}
+	fake.getStemcellsReturnsOnCall[i] = struct {
+		result1 []cfbackup.BoshStemcell
+		result2 error
+	}{result1, result2}
+}
+
 func (fake *FakeBosh) Invocations() map[string][][]interface{} {
 	fake.invocationsMutex.RLock()
 	defer fake.invocationsMutex.RUnlock()
@@ -342,6 +764,20 @@ func (fake *FakeBosh) Invocations() map[string][][]interface{} {
 	defer fake.changeJobStateMutex.RUnlock()
 	fake.retrieveTaskStatusMutex.RLock()
 	defer fake.retrieveTaskStatusMutex.RUnlock()
+	fake.getDeploymentsMutex.RLock()
+	defer fake.getDeploymentsMutex.RUnlock()
+	fake.getInstancesMutex.RLock()
+	defer fake.getInstancesMutex.RUnlock()
+	fake.getTaskOutputMutex.RLock()
+	defer fake.getTaskOutputMutex.RUnlock()
+	fake.getTaskEventsMutex.RLock()
+	defer fake.getTaskEventsMutex.RUnlock()
+	fake.cancelTaskMutex.RLock()
+	defer fake.cancelTaskMutex.RUnlock()
+	fake.getReleasesMutex.RLock()
+	defer fake.getReleasesMutex.RUnlock()
+	fake.getStemcellsMutex.RLock()
+	defer fake.getStemcellsMutex.RUnlock()
 	return fake.invocations
 }
 
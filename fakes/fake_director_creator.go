@@ -8,13 +8,14 @@ import (
 )
 
 type FakeDirectorCreator struct {
-	Stub        func(ip, username, password string, port int) (cfbackup.Bosh, error)
+	Stub        func(ip, username, password string, port int, tlsConfig cfbackup.TLSConfig) (cfbackup.Bosh, error)
 	mutex       sync.RWMutex
 	argsForCall []struct {
-		ip       string
-		username string
-		password string
-		port     int
+		ip        string
+		username  string
+		password  string
+		port      int
+		tlsConfig cfbackup.TLSConfig
 	}
 	returns struct {
 		result1 cfbackup.Bosh
@@ -28,19 +29,20 @@ type FakeDirectorCreator struct {
 	invocationsMutex sync.RWMutex
 }
 
-func (fake *FakeDirectorCreator) Spy(ip string, username string, password string, port int) (cfbackup.Bosh, error) {
+func (fake *FakeDirectorCreator) Spy(ip string, username string, password string, port int, tlsConfig cfbackup.TLSConfig) (cfbackup.Bosh, error) {
 	fake.mutex.Lock()
 	ret, specificReturn := fake.returnsOnCall[len(fake.argsForCall)]
 	fake.argsForCall = append(fake.argsForCall, struct {
-		ip       string
-		username string
-		password string
-		port     int
-	}{ip, username, password, port})
-	fake.recordInvocation("DirectorCreator", []interface{}{ip, username, password, port})
+		ip        string
+		username  string
+		password  string
+		port      int
+		tlsConfig cfbackup.TLSConfig
+	}{ip, username, password, port, tlsConfig})
+	fake.recordInvocation("DirectorCreator", []interface{}{ip, username, password, port, tlsConfig})
 	fake.mutex.Unlock()
 	if fake.Stub != nil {
-		return fake.Stub(ip, username, password, port)
+		return fake.Stub(ip, username, password, port, tlsConfig)
 	}
 	if specificReturn {
 		return ret.result1, ret.result2
@@ -54,10 +56,10 @@ func (fake *FakeDirectorCreator) CallCount() int {
 	return len(fake.argsForCall)
 }
 
-func (fake *FakeDirectorCreator) ArgsForCall(i int) (string, string, string, int) {
+func (fake *FakeDirectorCreator) ArgsForCall(i int) (string, string, string, int, cfbackup.TLSConfig) {
 	fake.mutex.RLock()
 	defer fake.mutex.RUnlock()
-	return fake.argsForCall[i].ip, fake.argsForCall[i].username, fake.argsForCall[i].password, fake.argsForCall[i].port
+	return fake.argsForCall[i].ip, fake.argsForCall[i].username, fake.argsForCall[i].password, fake.argsForCall[i].port, fake.argsForCall[i].tlsConfig
 }
 
 func (fake *FakeDirectorCreator) Returns(result1 cfbackup.Bosh, result2 error) {
@@ -0,0 +1,364 @@
+package fakes
+
+import (
+	"bytes"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+	"time"
+
+	"github.com/pivotalservices/cfbackup"
+)
+
+var (
+	fakeDirectorDeploymentPath = regexp.MustCompile(`^/deployments/([^/]+)$`)
+	fakeDirectorVMsPath        = regexp.MustCompile(`^/deployments/([^/]+)/vms$`)
+	fakeDirectorInstancesPath  = regexp.MustCompile(`^/deployments/([^/]+)/instances$`)
+	fakeDirectorJobPath        = regexp.MustCompile(`^/deployments/([^/]+)/jobs/([^/]+)/(\d+)$`)
+	fakeDirectorTaskPath       = regexp.MustCompile(`^/tasks?/(\d+)$`)
+	fakeDirectorTaskOutputPath = regexp.MustCompile(`^/tasks/(\d+)/output$`)
+)
+
+//FakeDirectorServer - an in-process bosh director serving the parts of the
+//director api we call, over tls and with basic auth. tasks move through
+//queued and processing to done (or error) one poll at a time, and failures
+//can be scripted per request or per job state change
+type FakeDirectorServer struct {
+	*httptest.Server
+	Username string
+	Password string
+	UUID     string
+	Version  string
+
+	mutex       sync.Mutex
+	deployments map[string][]cfbackup.VMObject
+	manifests   map[string]string
+	tasks       map[int]*fakeDirectorTask
+	lastTaskID  int
+	failures    []fakeDirectorFailure
+	taskErrors  map[string]string
+	requests    []string
+}
+
+type fakeDirectorTask struct {
+	cfbackup.Task
+	steps  []string
+	result string
+	events []cfbackup.BoshTaskEvent
+	onDone func()
+}
+
+type fakeDirectorFailure struct {
+	method     string
+	path       string
+	statusCode int
+}
+
+//NewFakeDirectorServer - starts a fake director accepting the given
+//credentials, an empty username accepts any credentials
+func NewFakeDirectorServer(username, password string) *FakeDirectorServer {
+	s := &FakeDirectorServer{
+		Username:    username,
+		Password:    password,
+		UUID:        FakeDirectorUUID,
+		Version:     "1.3262.0.0 (00000000)",
+		deployments: make(map[string][]cfbackup.VMObject),
+		manifests:   make(map[string]string),
+		tasks:       make(map[int]*fakeDirectorTask),
+		taskErrors:  make(map[string]string),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+//AddDeployment - registers a deployment with its vms and manifest
+func (s *FakeDirectorServer) AddDeployment(name, manifest string, vms []cfbackup.VMObject) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deployments[name] = vms
+	s.manifests[name] = manifest
+}
+
+//FailNextRequest - answers the next request for method and path with the
+//given status code instead of serving it
+func (s *FakeDirectorServer) FailNextRequest(method, path string, statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, fakeDirectorFailure{method: method, path: path, statusCode: statusCode})
+}
+
+//FailJobState - makes the task changing job/index to state end in error
+func (s *FakeDirectorServer) FailJobState(job string, index int, state, message string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.taskErrors[jobStateKey(job, index, state)] = message
+}
+
+//JobState - the state the director holds for job/index of a deployment
+func (s *FakeDirectorServer) JobState(deployment, job string, index int) string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, vm := range s.deployments[deployment] {
+		if vm.Job == job && vm.Index == index {
+			return vm.State
+		}
+	}
+	return ""
+}
+
+//CertificatePEM - the self signed certificate the server presents, pem encoded
+func (s *FakeDirectorServer) CertificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.TLS.Certificates[0].Certificate[0]}))
+}
+
+//Requests - every request served so far as "METHOD /path"
+func (s *FakeDirectorServer) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+//Address - the ip (with scheme) and port to point a director client at
+func (s *FakeDirectorServer) Address() (ip string, port int) {
+	u, _ := url.Parse(s.URL)
+	host, p, _ := net.SplitHostPort(u.Host)
+	port, _ = strconv.Atoi(p)
+	return u.Scheme + "://" + host, port
+}
+
+//DirectorCreator - wraps create so every director it builds talks to this
+//server, whatever ip and port it is asked for
+func (s *FakeDirectorServer) DirectorCreator(create cfbackup.DirectorCreator) cfbackup.DirectorCreator {
+	return func(_, username, password string, _ int, tlsConfig cfbackup.TLSConfig) (cfbackup.Bosh, error) {
+		ip, port := s.Address()
+		return create(ip, username, password, port, tlsConfig)
+	}
+}
+
+func (s *FakeDirectorServer) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+
+	for i, failure := range s.failures {
+		if failure.method == req.Method && failure.path == req.URL.Path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			w.WriteHeader(failure.statusCode)
+			return
+		}
+	}
+
+	if req.URL.Path == "/info" {
+		s.writeJSON(w, map[string]interface{}{
+			"name":                "fake-director",
+			"uuid":                s.UUID,
+			"version":             s.Version,
+			"user_authentication": map[string]interface{}{"type": "basic", "options": map[string]string{}},
+		})
+		return
+	}
+
+	if username, password, ok := req.BasicAuth(); !ok || (s.Username != "" && (username != s.Username || password != s.Password)) {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+
+	path := req.URL.Path
+	switch {
+	case req.Method == "GET" && path == "/deployments":
+		var deployments []cfbackup.BoshDeployment
+		for name := range s.deployments {
+			deployments = append(deployments, cfbackup.BoshDeployment{Name: name})
+		}
+		s.writeJSON(w, deployments)
+
+	case req.Method == "GET" && (path == "/releases" || path == "/stemcells"):
+		s.writeJSON(w, []interface{}{})
+
+	case req.Method == "GET" && fakeDirectorDeploymentPath.MatchString(path):
+		name := fakeDirectorDeploymentPath.FindStringSubmatch(path)[1]
+		if _, ok := s.deployments[name]; !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		s.writeJSON(w, map[string]string{"manifest": s.manifests[name]})
+
+	case req.Method == "GET" && fakeDirectorVMsPath.MatchString(path):
+		s.serveVMs(w, req, fakeDirectorVMsPath.FindStringSubmatch(path)[1], false)
+
+	case req.Method == "GET" && fakeDirectorInstancesPath.MatchString(path):
+		s.serveVMs(w, req, fakeDirectorInstancesPath.FindStringSubmatch(path)[1], true)
+
+	case req.Method == "PUT" && fakeDirectorJobPath.MatchString(path):
+		s.serveChangeJobState(w, req, fakeDirectorJobPath.FindStringSubmatch(path))
+
+	case req.Method == "GET" && fakeDirectorTaskOutputPath.MatchString(path):
+		s.serveTaskOutput(w, req, fakeDirectorTaskOutputPath.FindStringSubmatch(path)[1])
+
+	case req.Method == "GET" && fakeDirectorTaskPath.MatchString(path):
+		s.serveTask(w, fakeDirectorTaskPath.FindStringSubmatch(path)[1])
+
+	case req.Method == "DELETE" && fakeDirectorTaskPath.MatchString(path):
+		task, ok := s.task(fakeDirectorTaskPath.FindStringSubmatch(path)[1])
+		if !ok {
+			w.WriteHeader(http.StatusNotFound)
+			return
+		}
+		task.State = "cancelled"
+		task.steps = nil
+		w.WriteHeader(http.StatusNoContent)
+
+	default:
+		w.WriteHeader(http.StatusNotFound)
+	}
+}
+
+func (s *FakeDirectorServer) serveVMs(w http.ResponseWriter, req *http.Request, name string, instances bool) {
+	vms, ok := s.deployments[name]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	if req.URL.Query().Get("format") != "full" {
+		s.writeJSON(w, vms)
+		return
+	}
+
+	var lines []string
+	for _, vm := range vms {
+		var b []byte
+		if instances {
+			b, _ = json.Marshal(cfbackup.BoshInstance{Job: vm.Job, Index: vm.Index, AZ: vm.AZ, IPs: vm.IPs, ProcessState: vm.State})
+		} else {
+			b, _ = json.Marshal(vm)
+		}
+		lines = append(lines, string(b))
+	}
+	task := s.newTask("retrieve vm-stats", "")
+	task.result = strings.Join(lines, "\n") + "\n"
+	s.redirectToTask(w, req, task)
+}
+
+func (s *FakeDirectorServer) serveChangeJobState(w http.ResponseWriter, req *http.Request, match []string) {
+	deployment, job := match[1], match[2]
+	index, _ := strconv.Atoi(match[3])
+	state := req.URL.Query().Get("state")
+
+	vms, ok := s.deployments[deployment]
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	taskError := s.taskErrors[jobStateKey(job, index, state)]
+	task := s.newTask(fmt.Sprintf("%s %s/%d", state, job, index), taskError)
+	event := cfbackup.BoshTaskEvent{Stage: "Updating instance", Task: fmt.Sprintf("%s/%d", job, index), Tags: []string{job}, Index: 1, Total: 1}
+	started, finished := event, event
+	started.State = "started"
+	finished.State = "finished"
+	finished.Progress = 100
+	if taskError != "" {
+		finished.State = "failed"
+		finished.Error = &cfbackup.BoshTaskEventError{Code: 450001, Message: taskError}
+	}
+	task.events = []cfbackup.BoshTaskEvent{started, finished}
+	task.onDone = func() {
+		for i := range vms {
+			if vms[i].Job == job && vms[i].Index == index {
+				vms[i].State = cfbackup.BOSHJobStateRunning
+				if state == "stopped" {
+					vms[i].State = cfbackup.BOSHJobStateStopped
+				}
+			}
+		}
+	}
+	s.redirectToTask(w, req, task)
+}
+
+func (s *FakeDirectorServer) serveTask(w http.ResponseWriter, id string) {
+	task, ok := s.task(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	s.writeJSON(w, task.Task)
+
+	if len(task.steps) > 0 {
+		task.State, task.steps = task.steps[0], task.steps[1:]
+		if task.State == "done" && task.onDone != nil {
+			task.onDone()
+		}
+	}
+}
+
+func (s *FakeDirectorServer) serveTaskOutput(w http.ResponseWriter, req *http.Request, id string) {
+	task, ok := s.task(id)
+	if !ok {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch req.URL.Query().Get("type") {
+	case cfbackup.BOSHTaskOutputResult:
+		fmt.Fprint(w, task.result)
+	case cfbackup.BOSHTaskOutputEvent:
+		events := task.events
+		if task.State == "queued" {
+			events = nil
+		} else if task.State == "processing" && len(events) > 1 {
+			events = events[:1]
+		}
+		var output bytes.Buffer
+		for _, event := range events {
+			b, _ := json.Marshal(event)
+			fmt.Fprintln(&output, string(b))
+		}
+		http.ServeContent(w, req, "", time.Time{}, bytes.NewReader(output.Bytes()))
+	default:
+		fmt.Fprintf(w, "D, debug output for task %d: %s\n", task.Id, task.Description)
+	}
+}
+
+func (s *FakeDirectorServer) newTask(description, taskError string) *fakeDirectorTask {
+	s.lastTaskID++
+	task := &fakeDirectorTask{
+		Task:  cfbackup.Task{Id: s.lastTaskID, State: "queued", Description: description},
+		steps: []string{"processing", "done"},
+	}
+	if taskError != "" {
+		task.steps = []string{"processing", "error"}
+		task.Result = taskError
+	}
+	s.tasks[task.Id] = task
+	return task
+}
+
+func (s *FakeDirectorServer) task(id string) (*fakeDirectorTask, bool) {
+	taskID, _ := strconv.Atoi(id)
+	task, ok := s.tasks[taskID]
+	return task, ok
+}
+
+//redirectToTask - the director answers task creating requests with a
+//redirect to the task
+func (s *FakeDirectorServer) redirectToTask(w http.ResponseWriter, req *http.Request, task *fakeDirectorTask) {
+	http.Redirect(w, req, fmt.Sprintf("/tasks/%d", task.Id), http.StatusFound)
+}
+
+func (s *FakeDirectorServer) writeJSON(w http.ResponseWriter, v interface{}) {
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+func jobStateKey(job string, index int, state string) string {
+	return fmt.Sprintf("%s/%d/%s", job, index, state)
+}
@@ -0,0 +1,70 @@
+package fakes
+
+import (
+	"fmt"
+	"io"
+	"sort"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+)
+
+//FakeMonit - the monit of a vm reached over ssh. it prints the summary of
+//its processes and stops and starts them, every command is recorded
+type FakeMonit struct {
+	Processes map[string]string
+	Commands  []string
+	//FailOn - the commands containing it fail with ErrFake
+	FailOn  string
+	ErrFake error
+	//Stuck - the process which never leaves its state
+	Stuck string
+}
+
+//NewFakeMonit - a monit of the processes, all of them running
+func NewFakeMonit(processes ...string) *FakeMonit {
+	monit := &FakeMonit{Processes: make(map[string]string)}
+	for _, process := range processes {
+		monit.Processes[process] = cfbackup.MonitStateRunning
+	}
+	return monit
+}
+
+//Execute --
+func (s *FakeMonit) Execute(dest io.Writer, cmd string) (err error) {
+	s.Commands = append(s.Commands, cmd)
+	if s.FailOn != "" && strings.Contains(cmd, s.FailOn) {
+		return s.ErrFake
+	}
+	fields := strings.Fields(cmd)
+	switch {
+	case len(fields) == 3 && fields[2] == "summary":
+		var processes []string
+		for process := range s.Processes {
+			processes = append(processes, process)
+		}
+		sort.Strings(processes)
+		fmt.Fprintln(dest, "The Monit daemon 5.2.5 uptime: 1d 2h 3m")
+		fmt.Fprintln(dest)
+		for _, process := range processes {
+			fmt.Fprintf(dest, "Process '%s'%s%s\n", process, strings.Repeat(" ", 30-len(process)), s.Processes[process])
+		}
+		fmt.Fprintf(dest, "System 'system_localhost'%s%s\n", strings.Repeat(" ", 12), cfbackup.MonitStateRunning)
+	case len(fields) == 4 && fields[3] != s.Stuck && fields[2] == "stop":
+		s.Processes[fields[3]] = cfbackup.MonitStateNotMonitored
+	case len(fields) == 4 && fields[3] != s.Stuck && fields[2] == "start":
+		s.Processes[fields[3]] = cfbackup.MonitStateRunning
+	}
+	return
+}
+
+//Stopped - the processes which are not monitored
+func (s *FakeMonit) Stopped() (stopped []string) {
+	for process, state := range s.Processes {
+		if state == cfbackup.MonitStateNotMonitored {
+			stopped = append(stopped, process)
+		}
+	}
+	sort.Strings(stopped)
+	return
+}
@@ -0,0 +1,153 @@
+package fakes
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"encoding/binary"
+	"fmt"
+	"io"
+	"net"
+	"strconv"
+	"sync"
+
+	"golang.org/x/crypto/ssh"
+)
+
+//FakeSSHBastion - an in-process ssh server taking a single user and password
+//which only forwards tcp connections, the way a jump host is used. every
+//address it forwards to is recorded
+type FakeSSHBastion struct {
+	Username string
+	Password string
+
+	listener net.Listener
+	config   *ssh.ServerConfig
+	mutex    sync.Mutex
+	dialed   []string
+}
+
+//NewFakeSSHBastion - starts a bastion on a random local port
+func NewFakeSSHBastion(username, password string) *FakeSSHBastion {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	if err != nil {
+		panic(fmt.Sprintf("fake bastion failed generating its host key: %v", err))
+	}
+	signer, err := ssh.NewSignerFromKey(key)
+	if err != nil {
+		panic(fmt.Sprintf("fake bastion failed using its host key: %v", err))
+	}
+
+	s := &FakeSSHBastion{Username: username, Password: password}
+	s.config = &ssh.ServerConfig{
+		PasswordCallback: func(conn ssh.ConnMetadata, password []byte) (*ssh.Permissions, error) {
+			if conn.User() == s.Username && string(password) == s.Password {
+				return nil, nil
+			}
+			return nil, fmt.Errorf("password rejected for %s", conn.User())
+		},
+	}
+	s.config.AddHostKey(signer)
+
+	if s.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		panic(fmt.Sprintf("fake bastion failed listening: %v", err))
+	}
+	go s.serve()
+	return s
+}
+
+//Address - the host and port of the bastion
+func (s *FakeSSHBastion) Address() (string, int) {
+	addr := s.listener.Addr().(*net.TCPAddr)
+	return addr.IP.String(), addr.Port
+}
+
+//Dialed - every address forwarded to so far
+func (s *FakeSSHBastion) Dialed() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.dialed...)
+}
+
+//Close - stops the bastion
+func (s *FakeSSHBastion) Close() {
+	s.listener.Close()
+}
+
+func (s *FakeSSHBastion) serve() {
+	for {
+		conn, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go s.handle(conn)
+	}
+}
+
+func (s *FakeSSHBastion) handle(conn net.Conn) {
+	serverConn, channels, requests, err := ssh.NewServerConn(conn, s.config)
+	if err != nil {
+		conn.Close()
+		return
+	}
+	defer serverConn.Close()
+	go ssh.DiscardRequests(requests)
+
+	for newChannel := range channels {
+		if newChannel.ChannelType() != "direct-tcpip" {
+			newChannel.Reject(ssh.UnknownChannelType, "the bastion only forwards connections")
+			continue
+		}
+		go s.forward(newChannel)
+	}
+}
+
+//forward - the direct-tcpip payload is the target host and port followed by
+//the originating host and port
+func (s *FakeSSHBastion) forward(newChannel ssh.NewChannel) {
+	target, ok := directTCPIPTarget(newChannel.ExtraData())
+	if !ok {
+		newChannel.Reject(ssh.ConnectionFailed, "malformed forwarding request")
+		return
+	}
+	s.mutex.Lock()
+	s.dialed = append(s.dialed, target)
+	s.mutex.Unlock()
+
+	remote, err := net.Dial("tcp", target)
+	if err != nil {
+		newChannel.Reject(ssh.ConnectionFailed, err.Error())
+		return
+	}
+	defer remote.Close()
+
+	channel, requests, err := newChannel.Accept()
+	if err != nil {
+		return
+	}
+	defer channel.Close()
+	go ssh.DiscardRequests(requests)
+
+	done := make(chan struct{}, 2)
+	go func() {
+		io.Copy(remote, channel)
+		done <- struct{}{}
+	}()
+	go func() {
+		io.Copy(channel, remote)
+		done <- struct{}{}
+	}()
+	<-done
+}
+
+func directTCPIPTarget(payload []byte) (string, bool) {
+	if len(payload) < 4 {
+		return "", false
+	}
+	length := binary.BigEndian.Uint32(payload)
+	if uint32(len(payload)-4) < length+4 {
+		return "", false
+	}
+	host := string(payload[4 : 4+length])
+	port := binary.BigEndian.Uint32(payload[4+length:])
+	return net.JoinHostPort(host, strconv.Itoa(int(port))), true
+}
@@ -24,7 +24,9 @@ var (
 	//SuccessString --
 	SuccessString = `{"state":"done"}`
 	//FailureString --
-	FailureString     = `{"state":"notdone"}`
+	FailureString = `{"state":"notdone"}`
+	//FakeDirectorUUID -- uuid reported by the fake director's info endpoint
+	FakeDirectorUUID  = "31631ff9-ac41-4eba-a944-04c820633e7f"
 	successWaitCalled int
 	failureWaitCalled int
 	restSuccessCalled int
@@ -32,7 +34,7 @@ var (
 )
 
 //NewFakeDirector ---
-func NewFakeNoStoresDirector(ip, username, password string, port int) (cfbackup.Bosh, error) {
+func NewFakeNoStoresDirector(ip, username, password string, port int, tlsConfig cfbackup.TLSConfig) (cfbackup.Bosh, error) {
 	return &mockDirector{
 		noStores:                true,
 		getManifest:             true,
@@ -45,7 +47,7 @@ func NewFakeNoStoresDirector(ip, username, password string, port int) (cfbackup.
 }
 
 //NewFakeDirector ---
-func NewFakeDirector(ip, username, password string, port int) (cfbackup.Bosh, error) {
+func NewFakeDirector(ip, username, password string, port int, tlsConfig cfbackup.TLSConfig) (cfbackup.Bosh, error) {
 	return &mockDirector{
 		getManifest:             true,
 		manifest:                ioutil.NopCloser(strings.NewReader("manifest")),
@@ -56,7 +58,9 @@ func NewFakeDirector(ip, username, password string, port int) (cfbackup.Bosh, er
 	}, nil
 }
 
+//mockDirector - the typed director calls fall through to a FakeBosh
 type mockDirector struct {
+	FakeBosh
 	noStores                bool
 	fakeErr                 error
 	getManifest             bool
@@ -68,7 +72,7 @@ type mockDirector struct {
 }
 
 func (s *mockDirector) GetInfo() (io.ReadCloser, error) {
-	return nil, nil
+	return ioutil.NopCloser(strings.NewReader(fmt.Sprintf(`{"name":"p-bosh","uuid":"%s","version":"1.3262.0.0 (00000000)","user_authentication":{"type":"basic","options":{}}}`, FakeDirectorUUID))), nil
 }
 
 func (s *mockDirector) GetCloudControllerVMSet(name string) (io.ReadCloser, error) {
@@ -298,6 +302,29 @@ var GetNfs = func(lf io.Writer, cmdexec command.Executer) *cfbackup.NFSBackup {
 	}
 }
 
+//GetRedis ---
+var GetRedis = func(lf io.Writer, cmdexec command.Executer) *cfbackup.RedisBackup {
+	return &cfbackup.RedisBackup{
+		Caller: cmdexec,
+		RemoteOps: &mockRemoteOps{
+			Writer: lf,
+		},
+		InstanceDir:   "/var/vcap/store/redis",
+		BGSaveCommand: "bgsave-secret",
+		ConfigCommand: "config-secret",
+	}
+}
+
+//GetBlobstore ---
+var GetBlobstore = func(lf io.Writer, cmdexec command.Executer) *cfbackup.BlobstoreBackup {
+	return &cfbackup.BlobstoreBackup{
+		Caller: cmdexec,
+		RemoteOps: &mockRemoteOps{
+			Writer: lf,
+		},
+	}
+}
+
 var logger = getLogger("debug")
 
 //Logger ---
@@ -5,24 +5,50 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
-	"strings"
+	"regexp"
 )
 
 type (
 	//VMObject - a struct representing a vm
 	VMObject struct {
-		Job   string
-		Index int
+		Job   string   `json:"job"`
+		Index int      `json:"index"`
+		AZ    string   `json:"az"`
+		State string   `json:"job_state"`
+		IPs   []string `json:"ips,omitempty"`
 	}
 	//CloudControllerDeploymentParser - a struct which will handle the parsing of deployments
 	CloudControllerDeploymentParser struct {
 		vms []CCJob
+		//Patterns - job name patterns to quiesce, in the order they are
+		//stopped. defaults to DefaultCCJobPatterns when empty
+		Patterns []string
 	}
 )
 
-//GetCCVMs - a function to get a list of ccjobs
+//DefaultCCJobPatterns - the jobs which write to the ccdb and blobstore, in the
+//order they are stopped: the scheduler first, then the workers and finally the
+//api. they are started in the reverse order. the ERT versions pick theirs with
+//InstallationSettings.GetCCJobPatterns, those without a list of their own get
+//these. the job suffix changes across versions (`-partition-<guid>` before
+//1.10, `-<guid>` after) which the matching takes care of
+var DefaultCCJobPatterns = []string{
+	"clock_global",
+	"cloud_controller_worker",
+	"cloud_controller",
+}
+
+//GetCCVMs - a function to get a list of ccjobs using the default job patterns
 func GetCCVMs(jsonObj []VMObject) ([]CCJob, error) {
-	parser := &CloudControllerDeploymentParser{}
+	return GetCCVMsForPatterns(jsonObj, DefaultCCJobPatterns)
+}
+
+//GetCCVMsForPatterns - a function to get a list of ccjobs matching the given
+//job patterns, grouped and ordered by pattern
+func GetCCVMsForPatterns(jsonObj []VMObject, patterns []string) ([]CCJob, error) {
+	parser := &CloudControllerDeploymentParser{
+		Patterns: patterns,
+	}
 	return parser.Parse(jsonObj)
 }
 
@@ -32,22 +58,39 @@ func (s *CloudControllerDeploymentParser) Parse(jsonObj []VMObject) ([]CCJob, er
 	return s.vms, err
 }
 
-func isValidCCJobName(jobName string) bool {
-	return strings.Contains(jobName, "cloud_controller") &&
-		!strings.Contains(jobName, "cloud_controller_worker")
+//jobNameMatcher - a pattern matches the job name up to its partition/guid
+//suffix, so `cloud_controller` does not match `cloud_controller_worker-...`
+func jobNameMatcher(pattern string) (*regexp.Regexp, error) {
+	return regexp.Compile(fmt.Sprintf("^(?:%s)(-.*)?$", pattern))
 }
 
 func (s *CloudControllerDeploymentParser) setupAndRun(jsonObj []VMObject) (err error) {
+	patterns := s.Patterns
+	if len(patterns) == 0 {
+		patterns = DefaultCCJobPatterns
+	}
 
 	var ccjobs = make([]CCJob, 0)
+	var grouped = make(map[int]bool)
+
+	for _, pattern := range patterns {
+		var matcher *regexp.Regexp
+		if matcher, err = jobNameMatcher(pattern); err != nil {
+			return fmt.Errorf("invalid job pattern %s: %v", pattern, err)
+		}
 
-	for _, vmObject := range jsonObj {
-		if isValidCCJobName(vmObject.Job) {
-			ccJob := CCJob{
-				Job:   vmObject.Job,
-				Index: vmObject.Index,
+		for i, vmObject := range jsonObj {
+			if !grouped[i] && matcher.MatchString(vmObject.Job) {
+				grouped[i] = true
+				ccJob := CCJob{
+					Job:   vmObject.Job,
+					Index: vmObject.Index,
+					AZ:    vmObject.AZ,
+					State: vmObject.State,
+					Group: pattern,
+				}
+				ccjobs = append(ccjobs, ccJob)
 			}
-			ccjobs = append(ccjobs, ccJob)
 		}
 	}
 
@@ -58,6 +101,26 @@ func (s *CloudControllerDeploymentParser) setupAndRun(jsonObj []VMObject) (err e
 	return nil
 }
 
+//UnmarshalJSON - accepts both the plain vms listing and the full format
+//listing, which names the job `job_name`
+func (s *VMObject) UnmarshalJSON(b []byte) error {
+	type vmObject VMObject
+	var vm struct {
+		vmObject
+		JobName string `json:"job_name"`
+	}
+
+	if err := json.Unmarshal(b, &vm); err != nil {
+		return err
+	}
+	*s = VMObject(vm.vmObject)
+
+	if s.Job == "" {
+		s.Job = vm.JobName
+	}
+	return nil
+}
+
 //ReadAndUnmarshalVMObjects - read the io.reader and unmarshal its contents into an vmobject array
 func ReadAndUnmarshalVMObjects(src io.Reader) (jsonObj []VMObject, err error) {
 	var contents []byte
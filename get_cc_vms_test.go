@@ -3,6 +3,7 @@ package cfbackup_test
 import (
 	"fmt"
 	"os"
+	"strings"
 
 	. "github.com/pivotalservices/cfbackup"
 
@@ -17,6 +18,32 @@ var _ = Describe("get_cc_vms", func() {
 	Describe("version >= 1.9", func() {
 		versionedVMsDataSet("fixtures/deployment_vms-1_10.json")
 	})
+
+	Describe("given a full format vm listing", func() {
+		var vms []CCJob
+		BeforeEach(func() {
+			jsonObj, err := ReadAndUnmarshalVMObjects(strings.NewReader(`[
+				{"job_name":"cloud_controller-partition-abc","index":0,"az":"z1","job_state":"running"},
+				{"job_name":"cloud_controller-partition-abc","index":1,"az":"z2","job_state":"stopped"}
+			]`))
+			Ω(err).ShouldNot(HaveOccurred())
+			vms, err = GetCCVMs(jsonObj)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("then it should read the job name, az and job state of each vm", func() {
+			Ω(vms).Should(Equal([]CCJob{
+				CCJob{Job: "cloud_controller-partition-abc", Index: 0, AZ: "z1", State: "running"},
+				CCJob{Job: "cloud_controller-partition-abc", Index: 1, AZ: "z2", State: "stopped"},
+			}))
+		})
+
+		It("then only the running jobs should be restarted", func() {
+			running := CloudControllerJobs(vms).Running()
+			Ω(running).Should(HaveLen(1))
+			Ω(running[0].Index).Should(Equal(0))
+		})
+	})
 })
 
 func versionedVMsDataSet(fixturePath string) {
@@ -37,13 +64,34 @@ func versionedVMsDataSet(fixturePath string) {
 				Ω(err).Should(BeNil())
 			})
 
-			It("Should have 4 cc correct jobs", func() {
+			It("Should have 6 correct jobs to quiesce in stop order", func() {
 				vms, _ := GetCCVMs(jsonObj)
+				Ω(vms).Should(HaveLen(6))
+				Ω(vms[0].Job).Should(HavePrefix("clock_global-"))
+				Ω(vms[0].Group).Should(Equal("clock_global"))
+				Ω(vms[1].Job).Should(HavePrefix("cloud_controller_worker-"))
+				Ω(vms[1].Group).Should(Equal("cloud_controller_worker"))
+				for i, index := range []int{0, 1, 0, 1} {
+					Ω(vms[i+2].Group).Should(Equal("cloud_controller"))
+					Ω(vms[i+2].Index).Should(Equal(index))
+				}
+			})
+
+			It("Should only return the jobs matching the given patterns", func() {
+				vms, err := GetCCVMsForPatterns(jsonObj, []string{"cloud_controller"})
+				Ω(err).ShouldNot(HaveOccurred())
 				Ω(vms).Should(HaveLen(4))
-				Ω(vms[0].Index).Should(Equal(0))
-				Ω(vms[1].Index).Should(Equal(1))
-				Ω(vms[2].Index).Should(Equal(0))
-				Ω(vms[3].Index).Should(Equal(1))
+			})
+
+			It("Should support regex patterns", func() {
+				vms, err := GetCCVMsForPatterns(jsonObj, []string{"cloud_controller(_worker)?"})
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(vms).Should(HaveLen(5))
+			})
+
+			It("Should fail on an invalid pattern", func() {
+				_, err := GetCCVMsForPatterns(jsonObj, []string{"cloud_controller("})
+				Ω(err).Should(HaveOccurred())
 			})
 
 			It("Should not panic on complete real world dataset", func() {
@@ -92,9 +140,9 @@ func versionedVMsDataSet(fixturePath string) {
 				Ω(err).Should(BeNil())
 			})
 
-			It("Should return 4 jobs", func() {
+			It("Should return 6 jobs", func() {
 				vms, _ := parser.Parse(jsonObj)
-				Ω(vms).Should(HaveLen(4))
+				Ω(vms).Should(HaveLen(6))
 			})
 
 			It("Should not panic on complete real world dataset", func() {
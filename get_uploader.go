@@ -5,6 +5,9 @@ import (
 )
 
 //GetUploader - returns an uploader from a given backup context
+//
+//Deprecated: the ops manager tile no longer uploads with it, it sends its
+//uploads with a content length itself
 func GetUploader(backupContext BackupContext) (uploader httpUploader) {
 	uploader = ghttp.LargeMultiPartUpload
 
@@ -0,0 +1,103 @@
+package cfbackup
+
+import (
+	"fmt"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//NewCredentialsInstallation - wraps the installation info so credentials it
+//does not contain are fetched from the credentials api
+func NewCredentialsInstallation(info InstallationInfo, api CredentialsAPI) *CredentialsInstallation {
+	return &CredentialsInstallation{
+		InstallationInfo: info,
+		API:              api,
+	}
+}
+
+//CredentialReference - the credentials api reference of a job's credential
+func CredentialReference(jobName, identifier string) string {
+	return fmt.Sprintf(".%s.%s", jobName, identifier)
+}
+
+//FindPropertyValues - returns the property values from the installation json,
+//fetching them from the credentials api when the json holds no password
+func (s *CredentialsInstallation) FindPropertyValues(productName, jobName, identifier string) (propertyMap map[string]string, err error) {
+	if propertyMap, err = s.InstallationInfo.FindPropertyValues(productName, jobName, identifier); err == nil && propertyMap["password"] == "" {
+		var credential Credential
+		if credential, err = s.getCredential(productName, jobName, identifier); err == nil {
+			propertyMap = credential.Value
+		}
+	}
+	return
+}
+
+//FindVMCredentialsByProductAndJob - returns the vm credentials from the
+//installation json, fetching them from the credentials api when the json holds
+//no password
+func (s *CredentialsInstallation) FindVMCredentialsByProductAndJob(productName, jobName string) (vmCredentials VMCredentials, err error) {
+	if vmCredentials, err = s.InstallationInfo.FindVMCredentialsByProductAndJob(productName, jobName); err == nil && vmCredentials.Password == "" {
+		var credential Credential
+		if credential, err = s.getCredential(productName, jobName, "vm_credentials"); err == nil {
+			vmCredentials.UserID = credential.Value["identity"]
+			vmCredentials.Password = credential.Value["password"]
+		}
+	}
+	return
+}
+
+func (s *CredentialsInstallation) getCredential(productName, jobName, identifier string) (credential Credential, err error) {
+	var product Products
+	if product, err = s.FindByProductID(productName); err != nil {
+		return
+	}
+	productGUID := product.GUID
+	if productGUID == "" {
+		productGUID = s.resolveProductGUID(product)
+	}
+	reference := CredentialReference(jobName, identifier)
+
+	lo.G.Debug("fetching credential from the credentials api", productGUID, reference)
+	if credential, err = s.API.GetCredential(productGUID, reference); err != nil {
+		err = errwrap.Wrap(err, fmt.Sprintf("failed fetching %s credential for %s", reference, productName))
+	}
+	return
+}
+
+//resolveProductGUID - the guid of a product the installation json has none
+//for, from the deployed products when the api lists them and the
+//installation name otherwise
+func (s *CredentialsInstallation) resolveProductGUID(product Products) string {
+	if resolver, ok := s.API.(ProductGUIDResolver); ok {
+		guid, err := resolver.FindProductGUID(product.Identifier)
+		if err == nil {
+			return guid
+		}
+		lo.G.Warning("failed resolving the product guid, using the installation name", product.Identifier, err)
+	}
+	return product.InstallationName
+}
+
+//FindDirectorInfo - the ip and credentials of the director, for the service
+//tiles which stop and start their jobs through it
+func FindDirectorInfo(installationInfo InstallationInfo, boshName string) (director *SystemInfo, err error) {
+	var (
+		ips         []string
+		credentials map[string]string
+	)
+	if ips, err = installationInfo.FindIPsByProductAndJob(boshName, "director"); err != nil || len(ips) == 0 {
+		return nil, fmt.Errorf("failed finding the director ip: %v", err)
+	}
+	if credentials, err = installationInfo.FindPropertyValues(boshName, "director", "director_credentials"); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the director credentials")
+	}
+	return &SystemInfo{
+		Product:    boshName,
+		Component:  "director",
+		Identifier: "director_credentials",
+		Ip:         ips[0],
+		User:       credentials["identity"],
+		Pass:       credentials["password"],
+	}, nil
+}
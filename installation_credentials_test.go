@@ -0,0 +1,118 @@
+package cfbackup_test
+
+import (
+	"errors"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup"
+)
+
+type fakeCredentialsAPI struct {
+	credentials map[string]Credential
+	requests    []string
+}
+
+func (s *fakeCredentialsAPI) GetCredential(productGUID, reference string) (credential Credential, err error) {
+	s.requests = append(s.requests, productGUID+"/"+reference)
+	var ok bool
+	if credential, ok = s.credentials[productGUID+"/"+reference]; !ok {
+		err = errors.New("credential not found")
+	}
+	return
+}
+
+type fakeResolvingCredentialsAPI struct {
+	fakeCredentialsAPI
+	guids map[string]string
+}
+
+func (s *fakeResolvingCredentialsAPI) FindProductGUID(productType string) (guid string, err error) {
+	var ok bool
+	if guid, ok = s.guids[productType]; !ok {
+		err = errors.New("product not deployed")
+	}
+	return
+}
+
+var _ = Describe("CredentialsInstallation", func() {
+	var (
+		api          *fakeCredentialsAPI
+		installation InstallationInfo
+	)
+
+	BeforeEach(func() {
+		api = &fakeCredentialsAPI{
+			credentials: map[string]Credential{
+				"cf-f21eea2dbdb8555f89fb/.ccdb.credentials":    {Type: "simple_credentials", Value: map[string]string{"identity": "admin", "password": "ccdb-pass"}},
+				"cf-f21eea2dbdb8555f89fb/.ccdb.vm_credentials": {Type: "simple_credentials", Value: map[string]string{"identity": "vcap", "password": "vcap-pass"}},
+			},
+		}
+	})
+
+	Context("when the installation json no longer contains the credentials", func() {
+		BeforeEach(func() {
+			settings := NewConfigurationParser("./fixtures/installation-settings-1-7-redacted.json").InstallationSettings
+			installation = NewCredentialsInstallation(&settings, api)
+		})
+
+		It("then it should fetch db credentials from the credentials api", func() {
+			propertyMap, err := installation.FindPropertyValues("cf", "ccdb", "credentials")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(propertyMap["identity"]).Should(Equal("admin"))
+			Ω(propertyMap["password"]).Should(Equal("ccdb-pass"))
+		})
+
+		It("then it should fetch vm credentials from the credentials api", func() {
+			vmCredentials, err := installation.FindVMCredentialsByProductAndJob("cf", "ccdb")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(vmCredentials.UserID).Should(Equal("vcap"))
+			Ω(vmCredentials.Password).Should(Equal("vcap-pass"))
+		})
+
+		It("then it should fail when the credentials api does not have the credential", func() {
+			_, err := installation.FindVMCredentialsByProductAndJob("cf", "nfs_server")
+			Ω(err).Should(HaveOccurred())
+			Ω(api.requests).Should(ConsistOf("cf-f21eea2dbdb8555f89fb/.nfs_server.vm_credentials"))
+		})
+	})
+
+	Context("when the installation json has no product guid", func() {
+		var settings InstallationSettings
+
+		BeforeEach(func() {
+			settings = InstallationSettings{
+				Products: []Products{{Identifier: "cf", InstallationName: "cf-legacy", Jobs: []Jobs{{Identifier: "ccdb"}}}},
+			}
+		})
+
+		It("then it should resolve the guid from the deployed products", func() {
+			resolver := &fakeResolvingCredentialsAPI{fakeCredentialsAPI: *api, guids: map[string]string{"cf": "cf-f21eea2dbdb8555f89fb"}}
+			vmCredentials, err := NewCredentialsInstallation(&settings, resolver).FindVMCredentialsByProductAndJob("cf", "ccdb")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(vmCredentials.Password).Should(Equal("vcap-pass"))
+		})
+
+		It("then it should fall back to the installation name when the api does not resolve guids", func() {
+			NewCredentialsInstallation(&settings, api).FindVMCredentialsByProductAndJob("cf", "ccdb")
+			Ω(api.requests).Should(ConsistOf("cf-legacy/.ccdb.vm_credentials"))
+		})
+	})
+
+	Context("when the installation json contains the credentials", func() {
+		BeforeEach(func() {
+			settings := NewConfigurationParser("./fixtures/installation-settings-1-7.json").InstallationSettings
+			installation = NewCredentialsInstallation(&settings, api)
+		})
+
+		It("then it should not call the credentials api", func() {
+			propertyMap, err := installation.FindPropertyValues("cf", "ccdb", "credentials")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(propertyMap["password"]).ShouldNot(BeEmpty())
+
+			_, err = installation.FindVMCredentialsByProductAndJob("cf", "ccdb")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(api.requests).Should(BeEmpty())
+		})
+	})
+})
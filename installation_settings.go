@@ -4,6 +4,7 @@ import (
 	"fmt"
 
 	"github.com/pivotalservices/gtils/persistence"
+	"github.com/xchapter7x/lo"
 )
 
 var (
@@ -24,6 +25,15 @@ var (
 		"1.4": legacyPGRestoreBin,
 		"":    legacyPGRestoreBin,
 	}
+	//ccJobPatterns - the jobs quiesced by default for each ERT version, the
+	//versions not listed get DefaultCCJobPatterns
+	ccJobPatterns = map[string][]string{
+		"1.7": DefaultCCJobPatterns,
+		"1.6": DefaultCCJobPatterns,
+		"1.5": DefaultCCJobPatterns,
+		"1.4": DefaultCCJobPatterns,
+		"":    DefaultCCJobPatterns,
+	}
 )
 
 const (
@@ -40,6 +50,12 @@ const (
 
 )
 
+const (
+	directorJobName        = "director"
+	directorSSLProperty    = "director_ssl"
+	directorCertificateKey = "cert_pem"
+)
+
 //FindPropertyValues - returns a map of property values for a given product, job and identifier
 func (s *InstallationSettings) FindPropertyValues(productName, jobName, identifier string) (propertyMap map[string]string, err error) {
 	var product Products
@@ -198,6 +214,32 @@ func (s *InstallationSettings) GetBoshName() (boshName string) {
 	return
 }
 
+//FindDirectorCA - returns the certificate of the director, a product
+//property since 1.7 and a property of the director job before. empty when
+//the installation has none
+func (s *InstallationSettings) FindDirectorCA() (certificate string) {
+	product, err := s.FindByProductID(s.GetBoshName())
+	if err != nil {
+		return
+	}
+	if certificate = product.DirectorSSL[directorCertificateKey]; certificate == "" {
+		if job, err := product.GetJob(directorJobName); err == nil {
+			certificate = product.GetPropertyValues(job, directorSSLProperty)[directorCertificateKey]
+		}
+	}
+	return
+}
+
+//DirectorTLS - the tls config of a tile for the director, also trusting the
+//director certificate when the installation has one
+func (s *InstallationSettings) DirectorTLS(tlsConfig TLSConfig) TLSConfig {
+	if certificate := s.FindDirectorCA(); certificate != "" {
+		lo.G.Debug("trusting the director certificate from the installation settings")
+		return tlsConfig.Trust(certificate)
+	}
+	return tlsConfig
+}
+
 //SetPGDumpUtilVersions - initializes the correct dump commands
 func (s *InstallationSettings) SetPGDumpUtilVersions() {
 	var ok bool
@@ -213,6 +255,16 @@ func (s *InstallationSettings) SetPGDumpUtilVersions() {
 	persistence.PGDmpRestoreBin = pgRestore
 }
 
+//GetCCJobPatterns - the jobs quiesced by default for the ERT version of the
+//installation
+func (s *InstallationSettings) GetCCJobPatterns() (patterns []string) {
+	var ok bool
+	if patterns, ok = ccJobPatterns[s.Version]; !ok {
+		patterns = DefaultCCJobPatterns
+	}
+	return
+}
+
 // FindJobInstanceCount find how many instances of a particular job
 func (s *InstallationSettings) FindJobInstanceCount(productID string, jobID string) int {
 	count := 0
@@ -53,6 +53,15 @@ var _ = Describe("given a InstallationSettings object", func() {
 		checkInstallationSettingsFindMethods("./fixtures/installation-settings-1-4.json", []string{"cf", "microbosh"})
 		checkInstallationSettingsFindMethods("./fixtures/installation-settings-1-4-variant.json", []string{"cf", "microbosh"})
 
+		checkCCJobPatterns("./fixtures/installation-settings-1-7.json")
+		checkCCJobPatterns("./fixtures/installation-settings-1-6.json")
+		checkCCJobPatterns("./fixtures/installation-settings-1-5.json")
+		checkCCJobPatterns("./fixtures/installation-settings-1-4.json")
+
+		checkDirectorCA("./fixtures/installation-settings-1-7.json")
+		checkDirectorCA("./fixtures/installation-settings-1-6.json")
+		checkDirectorCA("./fixtures/installation-settings-1-4.json")
+
 		checkInstallationSettingsFindMethodsWithInvalidProducts("./fixtures/installation-settings-1-7.json")
 		checkInstallationSettingsFindMethodsWithInvalidProducts("./fixtures/installation-settings-1-7-pgsql.json")
 		checkInstallationSettingsFindMethodsWithInvalidProducts("./fixtures/installation-settings-1-7-multiaz-unbalanced.json")
@@ -100,6 +109,39 @@ func checkBoshName(fixturePath string, expectedBoshName string) {
 	})
 }
 
+func checkCCJobPatterns(fixturePath string) {
+	Context(fmt.Sprintf("when called with a given %s fixture", fixturePath), func() {
+		var installationSettings InstallationSettings
+		BeforeEach(func() {
+			installationSettings = NewConfigurationParser(fixturePath).InstallationSettings
+		})
+		It("then it should return the cc jobs the installation runs", func() {
+			patterns := installationSettings.GetCCJobPatterns()
+			Ω(patterns).ShouldNot(BeEmpty())
+			for _, pattern := range patterns {
+				_, err := installationSettings.FindJobByProductAndJobName("cf", pattern)
+				Ω(err).ShouldNot(HaveOccurred())
+			}
+		})
+	})
+}
+
+func checkDirectorCA(fixturePath string) {
+	Context(fmt.Sprintf("when called with a given %s fixture", fixturePath), func() {
+		var installationSettings InstallationSettings
+		BeforeEach(func() {
+			configParser := NewConfigurationParser(fixturePath)
+			installationSettings = configParser.InstallationSettings
+		})
+		It("then it should return the director certificate", func() {
+			Ω(installationSettings.FindDirectorCA()).Should(HavePrefix("-----BEGIN CERTIFICATE-----"))
+		})
+		It("then it should verify the director against its certificate", func() {
+			Ω(installationSettings.DirectorTLS(TLSConfig{}).Verify()).Should(BeTrue())
+		})
+	})
+}
+
 func checkInstallationSettingsIPMethods(fixturePath string, productName string, jobName string, ipsCount int) {
 	Context(fmt.Sprintf("when called with a given %s fixture", fixturePath), func() {
 		var installationSettings InstallationSettings
@@ -1,14 +1,14 @@
 package cfbackup
 
 import (
-	"crypto/tls"
 	"fmt"
 	"net/http"
 )
 
-func invoke(method string, connectionURL string, username string, password string, isYaml bool) (*http.Response, error) {
-	tr := &http.Transport{
-		TLSClientConfig: &tls.Config{InsecureSkipVerify: true},
+func invoke(tlsConfig TLSConfig, method string, connectionURL string, username string, password string, isYaml bool) (*http.Response, error) {
+	tr, err := tlsConfig.Transport()
+	if err != nil {
+		return nil, err
 	}
 
 	req, err := http.NewRequest(method, connectionURL, nil)
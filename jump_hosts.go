@@ -0,0 +1,217 @@
+package cfbackup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pivotalservices/gtils/command"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+	"golang.org/x/crypto/ssh"
+)
+
+//NewSSHTunnels - the tunnels through the chain of jump hosts, the first hop
+//is dialed directly and every other hop through the one before it. an empty
+//chain dials the vms directly
+func NewSSHTunnels(jumpHosts []command.SshConfig) *SSHTunnels {
+	return &SSHTunnels{
+		JumpHosts: jumpHosts,
+		tunnels:   make(map[string]*sshTunnel),
+	}
+}
+
+//TunnelSSHConfig - the ssh config to hand to the gtils executers and remote
+//operations for the given vm. with jump hosts configured it points at a local
+//tunnel through the chain, a target which is itself one of the hops is
+//reached through the hops before it. nil tunnels dial the vm directly
+func (s *SSHTunnels) TunnelSSHConfig(config command.SshConfig) (command.SshConfig, error) {
+	if s == nil {
+		return config, nil
+	}
+	chain := s.jumpHostsTo(config)
+	if len(chain) == 0 {
+		return config, nil
+	}
+
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	target := sshAddress(config.Host, config.Port)
+	tunnel, ok := s.tunnels[target]
+	if !ok {
+		var err error
+		if tunnel, err = openSSHTunnel(chain, target); err != nil {
+			return config, err
+		}
+		s.tunnels[target] = tunnel
+	}
+
+	config.Host = "127.0.0.1"
+	config.Port = tunnel.listener.Addr().(*net.TCPAddr).Port
+	return config, nil
+}
+
+//Close - closes every tunnel opened through the jump hosts
+func (s *SSHTunnels) Close() {
+	if s == nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for target, tunnel := range s.tunnels {
+		tunnel.close()
+		delete(s.tunnels, target)
+	}
+}
+
+func (s *SSHTunnels) jumpHostsTo(config command.SshConfig) []command.SshConfig {
+	target := sshAddress(config.Host, config.Port)
+	for i, hop := range s.JumpHosts {
+		if sshAddress(hop.Host, hop.Port) == target {
+			return s.JumpHosts[:i]
+		}
+	}
+	return s.JumpHosts
+}
+
+func openSSHTunnel(chain []command.SshConfig, target string) (tunnel *sshTunnel, err error) {
+	tunnel = new(sshTunnel)
+	defer func() {
+		if err != nil {
+			tunnel.close()
+		}
+	}()
+
+	var client *ssh.Client
+	for _, hop := range chain {
+		if client, err = dialJumpHost(client, hop); err != nil {
+			return
+		}
+		tunnel.clients = append(tunnel.clients, client)
+	}
+
+	if tunnel.listener, err = net.Listen("tcp", "127.0.0.1:0"); err != nil {
+		err = errwrap.Wrap(err, "failed listening for the ssh tunnel")
+		return
+	}
+	lo.G.Infof("tunneling ssh to %s through %d jump hosts on %s", target, len(chain), tunnel.listener.Addr())
+	go tunnel.serve(client, target)
+	return
+}
+
+//dialJumpHost - dials the hop directly for the first hop, through the client
+//of the previous hop otherwise
+func dialJumpHost(previous *ssh.Client, hop command.SshConfig) (*ssh.Client, error) {
+	config, err := jumpHostClientConfig(hop)
+	if err != nil {
+		return nil, err
+	}
+	address := sshAddress(hop.Host, hop.Port)
+	lo.G.Debug("dialing jump host: ", address)
+
+	if previous == nil {
+		client, err := ssh.Dial("tcp", address, config)
+		if err != nil {
+			return nil, errwrap.Wrap(err, fmt.Sprintf("failed connecting to jump host %s", address))
+		}
+		return client, nil
+	}
+
+	conn, err := previous.Dial("tcp", address)
+	if err != nil {
+		return nil, errwrap.Wrap(err, fmt.Sprintf("failed reaching jump host %s", address))
+	}
+	clientConn, channels, requests, err := ssh.NewClientConn(conn, address, config)
+	if err != nil {
+		conn.Close()
+		return nil, errwrap.Wrap(err, fmt.Sprintf("failed connecting to jump host %s", address))
+	}
+	return ssh.NewClient(clientConn, channels, requests), nil
+}
+
+//jumpHostClientConfig - host keys are not verified, the same as the gtils
+//executers do for the vms themselves
+func jumpHostClientConfig(hop command.SshConfig) (*ssh.ClientConfig, error) {
+	config := &ssh.ClientConfig{
+		User: hop.Username,
+		HostKeyCallback: func(hostname string, remote net.Addr, key ssh.PublicKey) error {
+			return nil
+		},
+	}
+	if hop.SSLKey != "" {
+		key, err := readSSHKey(hop.SSLKey)
+		if err != nil {
+			return nil, err
+		}
+		signer, err := ssh.ParsePrivateKey([]byte(key))
+		if err != nil {
+			return nil, errwrap.Wrap(err, "failed parsing the jump host key")
+		}
+		config.Auth = append(config.Auth, ssh.PublicKeys(signer))
+	}
+	if hop.Password != "" {
+		config.Auth = append(config.Auth, ssh.Password(hop.Password))
+	}
+	return config, nil
+}
+
+//readSSHKey - the key is either pem itself or the path of a pem file
+func readSSHKey(key string) (string, error) {
+	if strings.Contains(key, "-----BEGIN") {
+		return key, nil
+	}
+	pem, err := ioutil.ReadFile(key)
+	if err != nil {
+		return "", errwrap.Wrap(err, "failed reading the jump host key")
+	}
+	return string(pem), nil
+}
+
+func sshAddress(host string, port int) string {
+	if port == 0 {
+		port = 22
+	}
+	return net.JoinHostPort(host, strconv.Itoa(port))
+}
+
+func (s *sshTunnel) serve(client *ssh.Client, target string) {
+	for {
+		local, err := s.listener.Accept()
+		if err != nil {
+			return
+		}
+		go func() {
+			defer local.Close()
+			remote, err := client.Dial("tcp", target)
+			if err != nil {
+				lo.G.Error("failed reaching through the jump hosts: ", target, err)
+				return
+			}
+			defer remote.Close()
+
+			done := make(chan struct{}, 2)
+			go func() {
+				io.Copy(remote, local)
+				done <- struct{}{}
+			}()
+			go func() {
+				io.Copy(local, remote)
+				done <- struct{}{}
+			}()
+			<-done
+		}()
+	}
+}
+
+func (s *sshTunnel) close() {
+	if s.listener != nil {
+		s.listener.Close()
+	}
+	for i := len(s.clients) - 1; i >= 0; i-- {
+		s.clients[i].Close()
+	}
+}
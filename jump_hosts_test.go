@@ -0,0 +1,143 @@
+package cfbackup_test
+
+import (
+	"bufio"
+	"net"
+	"strconv"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/fakes"
+	"github.com/pivotalservices/gtils/command"
+)
+
+var _ = Describe("SSHTunnels", func() {
+	var (
+		vm      net.Listener
+		vmAddr  string
+		config  command.SshConfig
+		tunnels *SSHTunnels
+	)
+
+	BeforeEach(func() {
+		var err error
+		vm, err = net.Listen("tcp", "127.0.0.1:0")
+		Ω(err).ShouldNot(HaveOccurred())
+		vmAddr = vm.Addr().String()
+		go func() {
+			for {
+				conn, err := vm.Accept()
+				if err != nil {
+					return
+				}
+				go func() {
+					defer conn.Close()
+					line, _ := bufio.NewReader(conn).ReadString('\n')
+					conn.Write([]byte("vm: " + line))
+				}()
+			}
+		}()
+		host, port, _ := net.SplitHostPort(vmAddr)
+		config = command.SshConfig{Username: "vcap", Password: "vcap-pass", Host: host}
+		config.Port, _ = strconv.Atoi(port)
+	})
+
+	AfterEach(func() {
+		tunnels.Close()
+		vm.Close()
+	})
+
+	roundTrip := func(config command.SshConfig) string {
+		conn, err := net.Dial("tcp", net.JoinHostPort(config.Host, strconv.Itoa(config.Port)))
+		Ω(err).ShouldNot(HaveOccurred())
+		defer conn.Close()
+		conn.Write([]byte("ping\n"))
+		reply, err := bufio.NewReader(conn).ReadString('\n')
+		Ω(err).ShouldNot(HaveOccurred())
+		return reply
+	}
+
+	Context("when there are no jump hosts", func() {
+		BeforeEach(func() {
+			tunnels = NewSSHTunnels(nil)
+		})
+
+		It("then it should leave the config alone", func() {
+			tunneled, err := tunnels.TunnelSSHConfig(config)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tunneled).Should(Equal(config))
+		})
+
+		It("then it should dial directly without tunnels", func() {
+			tunneled, err := (*SSHTunnels)(nil).TunnelSSHConfig(config)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tunneled).Should(Equal(config))
+		})
+	})
+
+	Context("when the vms are behind a chain of jump hosts", func() {
+		var (
+			opsManager *fakes.FakeSSHBastion
+			bastion    *fakes.FakeSSHBastion
+		)
+
+		BeforeEach(func() {
+			opsManager = fakes.NewFakeSSHBastion("ubuntu", "ubuntu-pass")
+			bastion = fakes.NewFakeSSHBastion("jump", "jump-pass")
+			opsManagerHost, opsManagerPort := opsManager.Address()
+			bastionHost, bastionPort := bastion.Address()
+			tunnels = NewSSHTunnels([]command.SshConfig{
+				{Username: "ubuntu", Password: "ubuntu-pass", Host: opsManagerHost, Port: opsManagerPort},
+				{Username: "jump", Password: "jump-pass", Host: bastionHost, Port: bastionPort},
+			})
+		})
+
+		AfterEach(func() {
+			opsManager.Close()
+			bastion.Close()
+		})
+
+		It("then it should reach the vm through every hop", func() {
+			tunneled, err := tunnels.TunnelSSHConfig(config)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tunneled.Host).Should(Equal("127.0.0.1"))
+			Ω(tunneled.Port).ShouldNot(Equal(config.Port))
+			Ω(tunneled.Username).Should(Equal("vcap"))
+			Ω(roundTrip(tunneled)).Should(Equal("vm: ping\n"))
+
+			bastionHost, bastionPort := bastion.Address()
+			Ω(opsManager.Dialed()).Should(Equal([]string{net.JoinHostPort(bastionHost, strconv.Itoa(bastionPort))}))
+			Ω(bastion.Dialed()).Should(Equal([]string{vmAddr}))
+		})
+
+		It("then it should reuse the tunnel of a vm", func() {
+			first, err := tunnels.TunnelSSHConfig(config)
+			Ω(err).ShouldNot(HaveOccurred())
+			second, err := tunnels.TunnelSSHConfig(config)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(second.Port).Should(Equal(first.Port))
+			Ω(opsManager.Dialed()).Should(HaveLen(1))
+		})
+
+		It("then it should dial the first hop directly", func() {
+			tunneled, err := tunnels.TunnelSSHConfig(tunnels.JumpHosts[0])
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(tunneled).Should(Equal(tunnels.JumpHosts[0]))
+		})
+
+		It("then it should close the tunnels", func() {
+			tunneled, err := tunnels.TunnelSSHConfig(config)
+			Ω(err).ShouldNot(HaveOccurred())
+			tunnels.Close()
+			_, err = net.Dial("tcp", net.JoinHostPort(tunneled.Host, strconv.Itoa(tunneled.Port)))
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("then it should fail for a rejected hop", func() {
+			tunnels.JumpHosts[1].Password = "wrong"
+			_, err := tunnels.TunnelSSHConfig(config)
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
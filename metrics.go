@@ -0,0 +1,165 @@
+package cfbackup
+
+import (
+	"io"
+	"path"
+	"time"
+
+	"github.com/pivotalservices/cfbackup/metrics"
+)
+
+//NewMetrics - the metrics of backups and restores, registered with the
+//registry. they are kept by the tile specs and backup contexts they are set
+//on
+func NewMetrics(registry *metrics.Registry) *Metrics {
+	return &Metrics{
+		Registry:          registry,
+		TileLastSuccess:   registry.NewGauge("cfbackup_tile_last_success_timestamp_seconds", "When the tile was last backed up or restored.", "tile", "action"),
+		TileDuration:      registry.NewGauge("cfbackup_tile_duration_seconds", "How long the last backup or restore of the tile took.", "tile", "action"),
+		TileRuns:          registry.NewCounter("cfbackup_tile_runs_total", "The backups and restores of the tile by status.", "tile", "action", "status"),
+		ComponentBytes:    registry.NewGauge("cfbackup_component_bytes", "The bytes of the last artifact of the component.", "tile", "component"),
+		ComponentDuration: registry.NewGauge("cfbackup_component_duration_seconds", "How long the last run of the component took.", "tile", "component"),
+		CCDowntime:        registry.NewGauge("cfbackup_cc_downtime_seconds", "How long the cloud controller was last stopped."),
+		BOSHTaskWait:      registry.NewCounter("cfbackup_bosh_task_wait_seconds_total", "The time spent waiting on bosh tasks.", "state"),
+		BOSHTasks:         registry.NewCounter("cfbackup_bosh_tasks_total", "The bosh tasks waited on by state and status.", "state", "status"),
+		Errors:            registry.NewCounter("cfbackup_errors_total", "The errors of backups and restores by type.", "type"),
+		Warnings:          registry.NewCounter("cfbackup_warnings_total", "The warnings of backups and restores."),
+		StorageBytes:      registry.NewCounter("cfbackup_storage_bytes_total", "The bytes written to and read from the storage provider.", "provider", "direction"),
+		NFSDuration:       registry.NewGauge("cfbackup_nfs_duration_seconds", "How long the last nfs dump or import took.", "action"),
+		started:           make(map[string]time.Time),
+	}
+}
+
+//Report - keeps the metrics of the event
+func (s *Metrics) Report(event ProgressEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch event.Type {
+	case ProgressTileStarted:
+		s.tile, s.component = event.Step, ""
+	case ProgressTileFinished:
+		s.tile, s.component = "", ""
+	case ProgressStepStarted:
+		s.component = event.Step
+		s.started[event.Step] = event.Time
+	case ProgressStepFinished:
+		if started, ok := s.started[event.Step]; ok {
+			s.ComponentDuration.Set(event.Time.Sub(started).Seconds(), s.tile, event.Step)
+			delete(s.started, event.Step)
+		}
+		if event.Error != "" {
+			s.Errors.Inc(MetricsErrorComponent)
+		}
+		s.component = ""
+	case ProgressBytesTransferred:
+		if event.Done {
+			component := s.component
+			if component == "" {
+				component = path.Base(event.Artifact)
+			}
+			s.ComponentBytes.Set(float64(event.Bytes), s.tile, component)
+		}
+	case ProgressCCStopped:
+		s.ccStopped = event.Time
+	case ProgressCCStarted:
+		if !s.ccStopped.IsZero() {
+			s.CCDowntime.Set(event.Time.Sub(s.ccStopped).Seconds())
+			s.ccStopped = time.Time{}
+		}
+	case ProgressBOSHTask:
+		status := "succeeded"
+		if event.Error != "" {
+			status = "failed"
+			s.Errors.Inc(MetricsErrorBOSHTask)
+		}
+		s.BOSHTasks.Inc(event.State, status)
+		s.BOSHTaskWait.Add(event.Wait.Seconds(), event.State)
+	case ProgressWarning:
+		s.Warnings.Inc()
+	}
+}
+
+//ObserveTile - keeps the metrics of a backup or restore of the tile
+func (s *Metrics) ObserveTile(action, tile string, duration time.Duration, err error) {
+	status := "succeeded"
+	if err != nil {
+		status = "failed"
+		s.Errors.Inc(MetricsErrorTile)
+	} else {
+		s.TileLastSuccess.Set(float64(time.Now().Unix()), tile, action)
+	}
+	s.TileRuns.Inc(tile, action, status)
+	s.TileDuration.Set(duration.Seconds(), tile, action)
+}
+
+//Report - passes the event on to each of the reporters
+func (s ProgressReporters) Report(event ProgressEvent) {
+	for _, reporter := range s {
+		reporter.Report(event)
+	}
+}
+
+//Writer - the file of the storage provider, counting the bytes written to
+//it and the files it could not create into the metrics of the context
+func (s BackupContext) Writer(path ...string) (io.WriteCloser, error) {
+	writer, err := s.StorageProvider.Writer(path...)
+	if s.Metrics == nil {
+		return writer, err
+	}
+	if err != nil {
+		s.Metrics.Errors.Inc(MetricsErrorStorage)
+		return writer, err
+	}
+	return &storageWriter{WriteCloser: writer, provider: s.storageProvider(), metrics: s.Metrics}, nil
+}
+
+//Reader - the file of the storage provider, counting the bytes read from it
+//and the files it could not open into the metrics of the context
+func (s BackupContext) Reader(path ...string) (io.ReadCloser, error) {
+	reader, err := s.StorageProvider.Reader(path...)
+	if s.Metrics == nil {
+		return reader, err
+	}
+	if err != nil {
+		s.Metrics.Errors.Inc(MetricsErrorStorage)
+		return reader, err
+	}
+	return &storageReader{ReadCloser: reader, provider: s.storageProvider(), metrics: s.Metrics}, nil
+}
+
+//storageProvider - the storage provider label of the context
+func (s BackupContext) storageProvider() string {
+	if s.IsS3 {
+		return MetricsProviderS3
+	}
+	return MetricsProviderDisk
+}
+
+//Write - writes and counts the bytes written
+func (s *storageWriter) Write(p []byte) (n int, err error) {
+	n, err = s.WriteCloser.Write(p)
+	s.metrics.StorageBytes.Add(float64(n), s.provider, "write")
+	return
+}
+
+//Read - reads and counts the bytes read
+func (s *storageReader) Read(p []byte) (n int, err error) {
+	n, err = s.ReadCloser.Read(p)
+	s.metrics.StorageBytes.Add(float64(n), s.provider, "read")
+	return
+}
+
+//observe - keeps the duration of the nfs action and counts its failure,
+//once the nfs backup has metrics
+func (s *NFSBackup) observe(action string, started time.Time, err error) {
+	if s.Metrics != nil {
+		s.Metrics.NFSDuration.Set(time.Since(started).Seconds(), action)
+		if err != nil {
+			s.Metrics.Errors.Inc(MetricsErrorNFS)
+		}
+	}
+}
@@ -0,0 +1,15 @@
+package metrics
+
+const (
+	//Counter - a metric which only goes up
+	Counter = "counter"
+	//Gauge - a metric which is set to its value
+	Gauge = "gauge"
+	//Path - the path the metrics are served on
+	Path = "/metrics"
+	//TextContentType - the content type of the prometheus text format
+	TextContentType = "text/plain; version=0.0.4; charset=utf-8"
+)
+
+//Default - the registry the metrics of cfbackup are kept in
+var Default = NewRegistry()
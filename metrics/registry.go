@@ -0,0 +1,192 @@
+package metrics
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"net/http"
+	"os"
+	"sort"
+	"strconv"
+	"strings"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//NewRegistry - an empty registry
+func NewRegistry() *Registry {
+	return new(Registry)
+}
+
+//NewCounter - registers a counter, the counter already registered under the
+//name is returned as is
+func (s *Registry) NewCounter(name, help string, labelNames ...string) *Metric {
+	return s.register(name, help, Counter, labelNames)
+}
+
+//NewGauge - registers a gauge, the gauge already registered under the name
+//is returned as is
+func (s *Registry) NewGauge(name, help string, labelNames ...string) *Metric {
+	return s.register(name, help, Gauge, labelNames)
+}
+
+func (s *Registry) register(name, help, metricType string, labelNames []string) *Metric {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for _, metric := range s.metrics {
+		if metric.Name == name {
+			return metric
+		}
+	}
+	metric := &Metric{
+		Name:       name,
+		Help:       help,
+		Type:       metricType,
+		LabelNames: labelNames,
+		registry:   s,
+		samples:    make(map[string]*sample),
+	}
+	s.metrics = append(s.metrics, metric)
+	return metric
+}
+
+//Set - sets the gauge of the label values to the value
+func (s *Metric) Set(value float64, labelValues ...string) {
+	if s.Type != Gauge {
+		lo.G.Errorf("metric %s is a %s, it can not be set", s.Name, s.Type)
+		return
+	}
+	s.update(labelValues, func(current float64) float64 { return value })
+}
+
+//Add - adds the value to the metric of the label values, a counter can not
+//go down
+func (s *Metric) Add(value float64, labelValues ...string) {
+	if s.Type == Counter && value < 0 {
+		lo.G.Errorf("counter %s can not go down by %v", s.Name, value)
+		return
+	}
+	s.update(labelValues, func(current float64) float64 { return current + value })
+}
+
+//Inc - adds one to the metric of the label values
+func (s *Metric) Inc(labelValues ...string) {
+	s.Add(1, labelValues...)
+}
+
+//Value - the value of the metric of the label values, zero when it has none
+func (s *Metric) Value(labelValues ...string) float64 {
+	s.registry.mutex.Lock()
+	defer s.registry.mutex.Unlock()
+	if sample, ok := s.samples[strings.Join(labelValues, "\xff")]; ok {
+		return sample.value
+	}
+	return 0
+}
+
+func (s *Metric) update(labelValues []string, value func(float64) float64) {
+	if len(labelValues) != len(s.LabelNames) {
+		lo.G.Errorf("metric %s has the labels %v, got the values %v", s.Name, s.LabelNames, labelValues)
+		return
+	}
+	s.registry.mutex.Lock()
+	defer s.registry.mutex.Unlock()
+	key := strings.Join(labelValues, "\xff")
+	current, ok := s.samples[key]
+	if !ok {
+		current = &sample{labelValues: append([]string(nil), labelValues...)}
+		s.samples[key] = current
+	}
+	current.value = value(current.value)
+}
+
+//WriteTo - writes the metrics in the prometheus text format, metrics
+//without values are left out
+func (s *Registry) WriteTo(writer io.Writer) (n int64, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	var buffered bytes.Buffer
+	for _, metric := range s.metrics {
+		if len(metric.samples) == 0 {
+			continue
+		}
+		fmt.Fprintf(&buffered, "# HELP %s %s\n", metric.Name, escape(metric.Help, false))
+		fmt.Fprintf(&buffered, "# TYPE %s %s\n", metric.Name, metric.Type)
+		for _, sample := range metric.sortedSamples() {
+			fmt.Fprintf(&buffered, "%s%s %s\n", metric.Name, metric.labels(sample), strconv.FormatFloat(sample.value, 'g', -1, 64))
+		}
+	}
+	return buffered.WriteTo(writer)
+}
+
+func (s *Metric) sortedSamples() (samples []*sample) {
+	keys := make([]string, 0, len(s.samples))
+	for key := range s.samples {
+		keys = append(keys, key)
+	}
+	sort.Strings(keys)
+	for _, key := range keys {
+		samples = append(samples, s.samples[key])
+	}
+	return
+}
+
+func (s *Metric) labels(sample *sample) string {
+	if len(s.LabelNames) == 0 {
+		return ""
+	}
+	pairs := make([]string, len(s.LabelNames))
+	for i, name := range s.LabelNames {
+		pairs[i] = fmt.Sprintf(`%s="%s"`, name, escape(sample.labelValues[i], true))
+	}
+	return "{" + strings.Join(pairs, ",") + "}"
+}
+
+func escape(value string, quoted bool) string {
+	value = strings.Replace(value, `\`, `\\`, -1)
+	value = strings.Replace(value, "\n", `\n`, -1)
+	if quoted {
+		value = strings.Replace(value, `"`, `\"`, -1)
+	}
+	return value
+}
+
+//ServeHTTP - serves the metrics in the prometheus text format
+func (s *Registry) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	w.Header().Set("Content-Type", TextContentType)
+	if _, err := s.WriteTo(w); err != nil {
+		lo.G.Error("failed writing the metrics: ", err)
+	}
+}
+
+//ListenAndServe - serves the metrics on Path of the address until the
+//server fails
+func (s *Registry) ListenAndServe(address string) error {
+	mux := http.NewServeMux()
+	mux.Handle(Path, s)
+	return http.ListenAndServe(address, mux)
+}
+
+//WriteTextfile - writes the metrics to the file for the textfile collector
+//of the node exporter, replacing it at once so it is never read half written
+func (s *Registry) WriteTextfile(filepath string) (err error) {
+	tmpfile := filepath + ".tmp"
+	var file *os.File
+	if file, err = os.Create(tmpfile); err != nil {
+		return errwrap.Wrap(err, "failed creating the metrics textfile")
+	}
+	if _, err = s.WriteTo(file); err != nil {
+		file.Close()
+		os.Remove(tmpfile)
+		return errwrap.Wrap(err, "failed writing the metrics textfile")
+	}
+	if err = file.Close(); err != nil {
+		os.Remove(tmpfile)
+		return errwrap.Wrap(err, "failed writing the metrics textfile")
+	}
+	if err = os.Rename(tmpfile, filepath); err != nil {
+		return errwrap.Wrap(err, "failed replacing the metrics textfile")
+	}
+	return
+}
@@ -0,0 +1,122 @@
+package metrics_test
+
+import (
+	"bytes"
+	"io/ioutil"
+	"net/http/httptest"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup/metrics"
+)
+
+var _ = Describe("Registry", func() {
+	var (
+		registry *Registry
+		runs     *Metric
+		duration *Metric
+	)
+
+	BeforeEach(func() {
+		registry = NewRegistry()
+		runs = registry.NewCounter("cfbackup_tile_runs_total", "The runs of the tile.", "tile", "status")
+		duration = registry.NewGauge("cfbackup_tile_duration_seconds", "How long the tile took.", "tile")
+	})
+
+	Describe("NewCounter", func() {
+		It("should return the metric already registered under the name", func() {
+			Ω(registry.NewCounter("cfbackup_tile_runs_total", "") == runs).Should(BeTrue())
+		})
+	})
+
+	Describe("Add", func() {
+		It("should add to the value of the label values", func() {
+			runs.Inc("elastic-runtime", "succeeded")
+			runs.Add(2, "elastic-runtime", "succeeded")
+			runs.Inc("elastic-runtime", "failed")
+			Ω(runs.Value("elastic-runtime", "succeeded")).Should(Equal(float64(3)))
+			Ω(runs.Value("elastic-runtime", "failed")).Should(Equal(float64(1)))
+		})
+
+		It("should not let a counter go down", func() {
+			runs.Inc("elastic-runtime", "succeeded")
+			runs.Add(-1, "elastic-runtime", "succeeded")
+			Ω(runs.Value("elastic-runtime", "succeeded")).Should(Equal(float64(1)))
+		})
+
+		It("should ignore values with the wrong number of labels", func() {
+			runs.Inc("elastic-runtime")
+			Ω(runs.Value("elastic-runtime")).Should(Equal(float64(0)))
+		})
+	})
+
+	Describe("Set", func() {
+		It("should set the gauge", func() {
+			duration.Set(12.5, "ops-manager")
+			duration.Set(3, "ops-manager")
+			Ω(duration.Value("ops-manager")).Should(Equal(float64(3)))
+		})
+
+		It("should not set a counter", func() {
+			runs.Set(5, "elastic-runtime", "succeeded")
+			Ω(runs.Value("elastic-runtime", "succeeded")).Should(Equal(float64(0)))
+		})
+	})
+
+	Describe("WriteTo", func() {
+		It("should write the metrics with values in the text format", func() {
+			registry.NewGauge("cfbackup_unused", "Never set.")
+			runs.Inc("ops-manager", "succeeded")
+			runs.Inc("elastic-runtime", "succeeded")
+			duration.Set(1.5, `my "tile"\`)
+			var buffer bytes.Buffer
+			_, err := registry.WriteTo(&buffer)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(buffer.String()).Should(Equal(`# HELP cfbackup_tile_runs_total The runs of the tile.
+# TYPE cfbackup_tile_runs_total counter
+cfbackup_tile_runs_total{tile="elastic-runtime",status="succeeded"} 1
+cfbackup_tile_runs_total{tile="ops-manager",status="succeeded"} 1
+# HELP cfbackup_tile_duration_seconds How long the tile took.
+# TYPE cfbackup_tile_duration_seconds gauge
+cfbackup_tile_duration_seconds{tile="my \"tile\"\\"} 1.5
+`))
+		})
+	})
+
+	Describe("ServeHTTP", func() {
+		It("should serve the metrics as text", func() {
+			duration.Set(2, "ops-manager")
+			recorder := httptest.NewRecorder()
+			registry.ServeHTTP(recorder, httptest.NewRequest("GET", Path, nil))
+			Ω(recorder.Header().Get("Content-Type")).Should(Equal(TextContentType))
+			Ω(recorder.Body.String()).Should(ContainSubstring(`cfbackup_tile_duration_seconds{tile="ops-manager"} 2`))
+		})
+	})
+
+	Describe("WriteTextfile", func() {
+		var dir string
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "metrics")
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should write the metrics to the file", func() {
+			duration.Set(2, "ops-manager")
+			textfile := path.Join(dir, "cfbackup.prom")
+			Ω(registry.WriteTextfile(textfile)).Should(Succeed())
+			written, _ := ioutil.ReadFile(textfile)
+			Ω(string(written)).Should(ContainSubstring(`cfbackup_tile_duration_seconds{tile="ops-manager"} 2`))
+			Ω(path.Join(dir, "cfbackup.prom.tmp")).ShouldNot(BeAnExistingFile())
+		})
+
+		It("should fail when the directory does not exist", func() {
+			Ω(registry.WriteTextfile(path.Join(dir, "missing", "cfbackup.prom"))).ShouldNot(Succeed())
+		})
+	})
+})
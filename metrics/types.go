@@ -0,0 +1,27 @@
+package metrics
+
+import "sync"
+
+type (
+	//Registry - an in process registry of metrics, exposed in the prometheus
+	//text format
+	Registry struct {
+		mutex   sync.Mutex
+		metrics []*Metric
+	}
+
+	//Metric - a counter or gauge, with a value for every set of label values
+	Metric struct {
+		Name       string
+		Help       string
+		Type       string
+		LabelNames []string
+		registry   *Registry
+		samples    map[string]*sample
+	}
+
+	sample struct {
+		labelValues []string
+		value       float64
+	}
+)
@@ -0,0 +1,122 @@
+package cfbackup_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/metrics"
+)
+
+var _ = Describe("Metrics", func() {
+	var observed *Metrics
+
+	at := func(seconds int) time.Time {
+		return time.Date(2016, 5, 1, 10, 0, seconds, 0, time.UTC)
+	}
+
+	BeforeEach(func() {
+		observed = NewMetrics(metrics.NewRegistry())
+	})
+
+	Describe("Report", func() {
+		BeforeEach(func() {
+			for _, event := range []ProgressEvent{
+				{Type: ProgressTileStarted, Step: "elastic-runtime", Time: at(0)},
+				{Type: ProgressBOSHTask, State: "stopped", Wait: 4 * time.Second},
+				{Type: ProgressCCStopped, Time: at(5)},
+				{Type: ProgressStepStarted, Step: "ccdb", Time: at(5)},
+				{Type: ProgressBytesTransferred, Artifact: "/backups/ccdb.backup", Bytes: 1024, Done: true},
+				{Type: ProgressStepFinished, Step: "ccdb", Time: at(8)},
+				{Type: ProgressStepStarted, Step: "uaadb", Time: at(8)},
+				{Type: ProgressStepFinished, Step: "uaadb", Time: at(9), Error: "connection refused"},
+				{Type: ProgressCCStarted, Time: at(12)},
+				{Type: ProgressBOSHTask, State: "started", Wait: 6 * time.Second, Error: "task failed"},
+				{Type: ProgressWarning, Message: "skipping the nfs server"},
+				{Type: ProgressTileFinished, Step: "elastic-runtime", Time: at(12)},
+			} {
+				observed.Report(event)
+			}
+		})
+
+		It("should keep the bytes and duration of each component", func() {
+			Ω(observed.ComponentBytes.Value("elastic-runtime", "ccdb")).Should(Equal(float64(1024)))
+			Ω(observed.ComponentDuration.Value("elastic-runtime", "ccdb")).Should(Equal(float64(3)))
+			Ω(observed.ComponentDuration.Value("elastic-runtime", "uaadb")).Should(Equal(float64(1)))
+		})
+
+		It("should keep the cloud controller downtime", func() {
+			Ω(observed.CCDowntime.Value()).Should(Equal(float64(7)))
+		})
+
+		It("should keep the bosh tasks and the time waited on them", func() {
+			Ω(observed.BOSHTaskWait.Value("stopped")).Should(Equal(float64(4)))
+			Ω(observed.BOSHTaskWait.Value("started")).Should(Equal(float64(6)))
+			Ω(observed.BOSHTasks.Value("stopped", "succeeded")).Should(Equal(float64(1)))
+			Ω(observed.BOSHTasks.Value("started", "failed")).Should(Equal(float64(1)))
+		})
+
+		It("should count the errors by type and the warnings", func() {
+			Ω(observed.Errors.Value(MetricsErrorComponent)).Should(Equal(float64(1)))
+			Ω(observed.Errors.Value(MetricsErrorBOSHTask)).Should(Equal(float64(1)))
+			Ω(observed.Warnings.Value()).Should(Equal(float64(1)))
+		})
+	})
+
+	Describe("ObserveTile", func() {
+		It("should keep the last success of the tile", func() {
+			observed.ObserveTile("backup", "ops-manager", 3*time.Second, nil)
+			Ω(observed.TileLastSuccess.Value("ops-manager", "backup")).Should(BeNumerically("~", time.Now().Unix(), 5))
+			Ω(observed.TileDuration.Value("ops-manager", "backup")).Should(Equal(float64(3)))
+			Ω(observed.TileRuns.Value("ops-manager", "backup", "succeeded")).Should(Equal(float64(1)))
+		})
+
+		It("should count a failure without a last success", func() {
+			observed.ObserveTile("restore", "ops-manager", time.Second, errors.New("failed"))
+			Ω(observed.TileLastSuccess.Value("ops-manager", "restore")).Should(Equal(float64(0)))
+			Ω(observed.TileRuns.Value("ops-manager", "restore", "failed")).Should(Equal(float64(1)))
+			Ω(observed.Errors.Value(MetricsErrorTile)).Should(Equal(float64(1)))
+		})
+	})
+
+	Describe("BackupContext", func() {
+		var (
+			dir     string
+			context BackupContext
+		)
+
+		BeforeEach(func() {
+			dir, _ = ioutil.TempDir("", "metrics")
+			context = NewBackupContext(dir, map[string]string{}, "")
+			context.Metrics = observed
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(dir)
+		})
+
+		It("should count the bytes written to and read from the disk", func() {
+			writer, err := context.Writer(dir, "ccdb.backup")
+			Ω(err).ShouldNot(HaveOccurred())
+			writer.Write([]byte("ccdb dump"))
+			writer.Close()
+			reader, err := context.Reader(dir, "ccdb.backup")
+			Ω(err).ShouldNot(HaveOccurred())
+			ioutil.ReadAll(reader)
+			reader.Close()
+			Ω(observed.StorageBytes.Value(MetricsProviderDisk, "write")).Should(Equal(float64(9)))
+			Ω(observed.StorageBytes.Value(MetricsProviderDisk, "read")).Should(Equal(float64(9)))
+		})
+
+		It("should count the files it could not open", func() {
+			_, err := context.Reader(path.Join(dir, "missing"))
+			Ω(err).Should(HaveOccurred())
+			Ω(observed.Errors.Value(MetricsErrorStorage)).Should(Equal(float64(1)))
+		})
+	})
+})
@@ -4,6 +4,7 @@ import (
 	"fmt"
 	"io"
 	"io/ioutil"
+	"time"
 
 	"github.com/pivotalservices/gtils/command"
 	"github.com/pivotalservices/gtils/osutils"
@@ -16,8 +17,13 @@ const (
 	NFSBackupTypeBP   = "bp"
 )
 
-//NewNFSBackup - constructor for an nfsbackup object
+//NewNFSBackup - constructor for an nfsbackup object, the nfs server is dialed
+//directly
 func NewNFSBackup(username, password, ip, sslKey, remoteArchivePath, backupType string) (nfs *NFSBackup, err error) {
+	return newNFSBackup(nil, username, password, ip, sslKey, remoteArchivePath, backupType)
+}
+
+func newNFSBackup(tunnels *SSHTunnels, username, password, ip, sslKey, remoteArchivePath, backupType string) (nfs *NFSBackup, err error) {
 	config := command.SshConfig{
 		Username: username,
 		Password: password,
@@ -27,6 +33,9 @@ func NewNFSBackup(username, password, ip, sslKey, remoteArchivePath, backupType
 	}
 	var remoteExecuter command.Executer
 
+	if config, err = tunnels.TunnelSSHConfig(config); err != nil {
+		return
+	}
 	if remoteExecuter, err = NfsNewRemoteExecuter(config); err == nil {
 		nfs = &NFSBackup{
 			Caller:     remoteExecuter,
@@ -39,12 +48,18 @@ func NewNFSBackup(username, password, ip, sslKey, remoteArchivePath, backupType
 
 //Dump - will dump the output of a executed command to the given writer
 func (s *NFSBackup) Dump(dest io.Writer) (err error) {
+	defer func(started time.Time) {
+		s.observe("dump", started, err)
+	}(time.Now())
 	err = s.Caller.Execute(dest, s.getDumpCommand())
 	return
 }
 
 //Import - will upload the contents of the given io.reader to the remote execution target and execute the restore command against the uploaded file.
 func (s *NFSBackup) Import(lfile io.Reader) (err error) {
+	defer func(started time.Time) {
+		s.observe("import", started, err)
+	}(time.Now())
 	lo.G.Debug("uploading file for backup")
 	if err = s.RemoteOps.UploadFile(lfile); err == nil {
 		lo.G.Debug("starting backup from %s", s.RemoteOps.Path())
@@ -0,0 +1,173 @@
+package cfbackup
+
+import (
+	"crypto/sha256"
+	"encoding/hex"
+	"fmt"
+	"io"
+	"os"
+	"time"
+
+	"github.com/xchapter7x/lo"
+)
+
+//Report - calls the function
+func (s ProgressFunc) Report(event ProgressEvent) {
+	s(event)
+}
+
+//Report - sends the event on the channel
+func (s ProgressChannel) Report(event ProgressEvent) {
+	s <- event
+}
+
+//Report - sends the event to the progress reporter of the context, stamped
+//with the time it is sent
+func (s BackupContext) Report(event ProgressEvent) {
+	if s.Progress == nil {
+		return
+	}
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	s.Progress.Report(event)
+}
+
+//StepStarted - reports the step started
+func (s BackupContext) StepStarted(step string) {
+	s.Report(ProgressEvent{Type: ProgressStepStarted, Step: step})
+}
+
+//StepFinished - reports the step finished, failed with a non nil error
+func (s BackupContext) StepFinished(step string, err error) {
+	event := ProgressEvent{Type: ProgressStepFinished, Step: step}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.Report(event)
+}
+
+//TileStarted - reports backing up or restoring the tile started
+func (s BackupContext) TileStarted(tile string) {
+	s.Report(ProgressEvent{Type: ProgressTileStarted, Step: tile})
+}
+
+//TileFinished - reports backing up or restoring the tile finished, failed
+//with a non nil error
+func (s BackupContext) TileFinished(tile string, err error) {
+	event := ProgressEvent{Type: ProgressTileFinished, Step: tile}
+	if err != nil {
+		event.Error = err.Error()
+	}
+	s.Report(event)
+}
+
+//Warningf - logs the warning and reports it
+func (s BackupContext) Warningf(format string, args ...interface{}) {
+	message := fmt.Sprintf(format, args...)
+	lo.G.Warning(message)
+	s.Report(ProgressEvent{Type: ProgressWarning, Message: message})
+}
+
+//NewProgressWriter - the writer, counting the bytes written to the artifact
+//for the progress reporter of the context. the total is the expected size of
+//the artifact, zero when it is not known
+func (s BackupContext) NewProgressWriter(writer io.Writer, artifact string, total int64) *ProgressWriter {
+	return &ProgressWriter{
+		Writer:          writer,
+		ProgressCounter: NewProgressCounter(s, artifact, total),
+	}
+}
+
+//NewProgressReader - the reader, counting the bytes read from the artifact
+//for the progress reporter of the context. the total is the expected size of
+//the artifact, zero when it is not known
+func (s BackupContext) NewProgressReader(reader io.Reader, artifact string, total int64) *ProgressReader {
+	return &ProgressReader{
+		Reader:          reader,
+		ProgressCounter: NewProgressCounter(s, artifact, total),
+	}
+}
+
+//NewProgressCounter - a counter of the bytes of the artifact, reporting to
+//the reporter
+func NewProgressCounter(reporter ProgressReporter, artifact string, total int64) *ProgressCounter {
+	now := time.Now()
+	return &ProgressCounter{
+		Artifact: artifact,
+		Total:    total,
+		reporter: reporter,
+		hash:     sha256.New(),
+		started:  now,
+		reported: now,
+	}
+}
+
+//Write - writes and counts the bytes written
+func (s *ProgressWriter) Write(p []byte) (n int, err error) {
+	n, err = s.Writer.Write(p)
+	s.Add(p[:n])
+	return
+}
+
+//Read - reads and counts the bytes read
+func (s *ProgressReader) Read(p []byte) (n int, err error) {
+	n, err = s.Reader.Read(p)
+	s.Add(p[:n])
+	return
+}
+
+//Add - counts and checksums the bytes, reporting them once ProgressInterval
+//passed since the last report
+func (s *ProgressCounter) Add(p []byte) {
+	s.bytes += int64(len(p))
+	s.hash.Write(p)
+	if now := time.Now(); now.Sub(s.reported) >= ProgressInterval {
+		s.reported = now
+		s.reporter.Report(s.event(now, false))
+	}
+}
+
+//Bytes - the bytes counted so far
+func (s *ProgressCounter) Bytes() int64 {
+	return s.bytes
+}
+
+//Done - reports the bytes counted once the artifact is completely
+//transferred
+func (s *ProgressCounter) Done() {
+	s.reporter.Report(s.event(time.Now(), true))
+}
+
+func (s *ProgressCounter) event(now time.Time, done bool) (event ProgressEvent) {
+	event = ProgressEvent{
+		Type:     ProgressBytesTransferred,
+		Artifact: s.Artifact,
+		Bytes:    s.bytes,
+		Total:    s.Total,
+		Done:     done,
+	}
+	if done {
+		event.SHA256 = hex.EncodeToString(s.hash.Sum(nil))
+	}
+	if elapsed := now.Sub(s.started).Seconds(); elapsed > 0 {
+		event.Rate = float64(s.bytes) / elapsed
+	}
+	if !done && s.Total > s.bytes && event.Rate > 0 {
+		event.ETA = time.Duration(float64(s.Total-s.bytes) / event.Rate * float64(time.Second))
+	}
+	return
+}
+
+//ArtifactSize - the size of the artifact on disk, zero when it is kept in s3
+//or can not be read
+func (s BackupContext) ArtifactSize(filepath string) int64 {
+	if s.IsS3 {
+		return 0
+	}
+	info, err := os.Stat(filepath)
+	if err != nil {
+		return 0
+	}
+	return info.Size()
+}
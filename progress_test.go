@@ -0,0 +1,101 @@
+package cfbackup_test
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/hex"
+	"errors"
+	"io/ioutil"
+	"strings"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup"
+)
+
+var _ = Describe("Progress", func() {
+	var (
+		events                   []ProgressEvent
+		backupContext            BackupContext
+		originalProgressInterval = ProgressInterval
+	)
+
+	BeforeEach(func() {
+		events = nil
+		backupContext = BackupContext{Progress: ProgressFunc(func(event ProgressEvent) {
+			events = append(events, event)
+		})}
+	})
+
+	AfterEach(func() {
+		ProgressInterval = originalProgressInterval
+	})
+
+	Describe("StepStarted and StepFinished", func() {
+		It("then it should report the steps stamped with the time", func() {
+			backupContext.StepStarted("ccdb")
+			backupContext.StepFinished("ccdb", errors.New("pg_dump failed"))
+			Ω(events).Should(HaveLen(2))
+			Ω(events[0].Type).Should(Equal(ProgressStepStarted))
+			Ω(events[0].Step).Should(Equal("ccdb"))
+			Ω(events[0].Time.IsZero()).Should(BeFalse())
+			Ω(events[1].Type).Should(Equal(ProgressStepFinished))
+			Ω(events[1].Error).Should(Equal("pg_dump failed"))
+		})
+
+		It("then it should report nothing without a progress reporter", func() {
+			Ω(func() { BackupContext{}.StepStarted("ccdb") }).ShouldNot(Panic())
+		})
+	})
+
+	Describe("ProgressChannel", func() {
+		It("then it should send the events on the channel", func() {
+			channel := make(chan ProgressEvent, 1)
+			backupContext.Progress = ProgressChannel(channel)
+			backupContext.Report(ProgressEvent{Type: ProgressCCStopped})
+			Ω((<-channel).Type).Should(Equal(ProgressCCStopped))
+		})
+	})
+
+	Describe("NewProgressWriter", func() {
+		It("then it should write through and report the bytes once done", func() {
+			var b bytes.Buffer
+			writer := backupContext.NewProgressWriter(&b, "nfs.backup", 0)
+			writer.Write([]byte("nfs "))
+			writer.Write([]byte("archive"))
+			writer.Done()
+			Ω(b.String()).Should(Equal("nfs archive"))
+			Ω(events).Should(HaveLen(1))
+			Ω(events[0].Type).Should(Equal(ProgressBytesTransferred))
+			Ω(events[0].Artifact).Should(Equal("nfs.backup"))
+			Ω(events[0].Bytes).Should(Equal(int64(11)))
+			Ω(events[0].Done).Should(BeTrue())
+			sum := sha256.Sum256([]byte("nfs archive"))
+			Ω(events[0].SHA256).Should(Equal(hex.EncodeToString(sum[:])))
+		})
+
+		It("then it should report every interval with the rate and eta", func() {
+			ProgressInterval = 0
+			writer := backupContext.NewProgressWriter(ioutil.Discard, "nfs.backup", 100)
+			time.Sleep(10 * time.Millisecond)
+			writer.Write(make([]byte, 25))
+			Ω(events).Should(HaveLen(1))
+			Ω(events[0].Bytes).Should(Equal(int64(25)))
+			Ω(events[0].Total).Should(Equal(int64(100)))
+			Ω(events[0].Rate).Should(BeNumerically(">", 0))
+			Ω(events[0].ETA).Should(BeNumerically(">", 0))
+			Ω(events[0].Done).Should(BeFalse())
+		})
+	})
+
+	Describe("NewProgressReader", func() {
+		It("then it should read through and count the bytes", func() {
+			reader := backupContext.NewProgressReader(strings.NewReader("ccdb dump"), "ccdb.backup", 9)
+			read, err := ioutil.ReadAll(reader)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(string(read)).Should(Equal("ccdb dump"))
+			Ω(reader.Bytes()).Should(Equal(int64(9)))
+		})
+	})
+})
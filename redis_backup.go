@@ -0,0 +1,114 @@
+package cfbackup
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+
+	"github.com/pivotalservices/gtils/command"
+	"github.com/pivotalservices/gtils/osutils"
+	"github.com/xchapter7x/lo"
+)
+
+//GetPersistanceBackup - the constructor for a new RedisInfo object
+func (s *RedisInfo) GetPersistanceBackup() (dumper PersistanceBackup, err error) {
+	return s.GetRedisBackup()
+}
+
+//GetRedisBackup - the rdb backup of the instance, which can also reload the
+//instance after a restore
+func (s *RedisInfo) GetRedisBackup() (*RedisBackup, error) {
+	return newRedisBackup(s.SSHTunnels, s.VcapUser, s.VcapPass, s.Ip, s.SSHPrivateKey, s.RemoteArchivePath, s.InstanceDir, s.BGSaveCommand, s.ConfigCommand)
+}
+
+//NewRedisBackup - constructor for an rdb backup of the redis instance in the
+//instance dir of the vm, which is dialed directly
+func NewRedisBackup(username, password, ip, sslKey, remoteArchivePath, instanceDir, bgSaveCommand, configCommand string) (redis *RedisBackup, err error) {
+	return newRedisBackup(nil, username, password, ip, sslKey, remoteArchivePath, instanceDir, bgSaveCommand, configCommand)
+}
+
+func newRedisBackup(tunnels *SSHTunnels, username, password, ip, sslKey, remoteArchivePath, instanceDir, bgSaveCommand, configCommand string) (redis *RedisBackup, err error) {
+	config := command.SshConfig{
+		Username: username,
+		Password: password,
+		Host:     ip,
+		Port:     22,
+		SSLKey:   sslKey,
+	}
+	var remoteExecuter command.Executer
+
+	if config, err = tunnels.TunnelSSHConfig(config); err != nil {
+		return
+	}
+	if remoteExecuter, err = NfsNewRemoteExecuter(config); err == nil {
+		redis = &RedisBackup{
+			Caller:        remoteExecuter,
+			RemoteOps:     osutils.NewRemoteOperationsWithPath(config, remoteArchivePath),
+			InstanceDir:   instanceDir,
+			BGSaveCommand: bgSaveCommand,
+			ConfigCommand: configCommand,
+		}
+	}
+	return
+}
+
+//Dump - triggers a background save on the instance, waits for it to
+//complete and writes the resulting rdb to the given writer
+func (s *RedisBackup) Dump(dest io.Writer) (err error) {
+	lo.G.Debug("saving redis instance ", s.InstanceDir)
+	return s.Caller.Execute(dest, s.getDumpCommand())
+}
+
+//Import - will upload the given rdb to the stopped instance and put it in
+//place of its rdb. the append only file is turned off, or the instance would
+//start from it instead of the rdb, until Reload turns it back on
+func (s *RedisBackup) Import(lfile io.Reader) (err error) {
+	lo.G.Debug("uploading rdb for redis instance ", s.InstanceDir)
+	if err = s.RemoteOps.UploadFile(lfile); err == nil {
+		err = s.Caller.Execute(ioutil.Discard, s.getRestoreCommand())
+	}
+	s.RemoteOps.RemoveRemoteFile()
+	return
+}
+
+//Reload - waits for the restarted instance to answer and rewrites its append
+//only file from the restored rdb when Import turned it off
+func (s *RedisBackup) Reload() (err error) {
+	lo.G.Debug("reloading redis instance ", s.InstanceDir)
+	return s.Caller.Execute(ioutil.Discard, s.getReloadCommand())
+}
+
+//redisConfig - reads the data dir, rdb file name, port and password of the
+//instance from its redis.conf and sets up the cli with them
+func (s *RedisBackup) redisConfig() string {
+	return fmt.Sprintf(`cd %s && `+
+		`data=$(awk '$1=="dir"{gsub(/"/,"",$2); print $2}' redis.conf) && data=${data:-.} && `+
+		`rdb=$(awk '$1=="dbfilename"{gsub(/"/,"",$2); print $2}' redis.conf) && rdb=${rdb:-dump.rdb} && `+
+		`port=$(awk '$1=="port"{gsub(/"/,"",$2); print $2}' redis.conf) && `+
+		`pass=$(awk '$1=="requirepass"{gsub(/"/,"",$2); print $2}' redis.conf) && `+
+		`cli="%s -p ${port:-6379} ${pass:+-a $pass}"`, s.InstanceDir, RedisCLIPath)
+}
+
+func (s *RedisBackup) getDumpCommand() string {
+	return fmt.Sprintf(`%s && `+
+		`$cli %s > /dev/null && `+
+		`while $cli INFO persistence | grep -q '^rdb_bgsave_in_progress:1'; do sleep 1; done && `+
+		`$cli INFO persistence | grep -q '^rdb_last_bgsave_status:ok' && `+
+		`cat "$data/$rdb"`, s.redisConfig(), s.BGSaveCommand)
+}
+
+func (s *RedisBackup) getRestoreCommand() string {
+	return fmt.Sprintf(`%s && `+
+		`if grep -q '^appendonly yes' redis.conf; then sed -i 's/^appendonly yes/appendonly no/' redis.conf && touch %s; fi && `+
+		`cp %s "$data/$rdb" && rm -f "$data/appendonly.aof"`, s.redisConfig(), RedisAppendOnlyMarker, s.RemoteOps.Path())
+}
+
+func (s *RedisBackup) getReloadCommand() string {
+	return fmt.Sprintf(`%s && `+
+		`n=0 && until $cli PING | grep -q PONG; do n=$((n+1)); [ $n -lt %d ] || exit 1; sleep 1; done && `+
+		`if [ -f %s ]; then `+
+		`$cli %s SET appendonly yes > /dev/null && `+
+		`while $cli INFO persistence | grep -Eq '^aof_rewrite_(in_progress|scheduled):1'; do sleep 1; done && `+
+		`sed -i 's/^appendonly no/appendonly yes/' redis.conf && rm -f %s; fi`,
+		s.redisConfig(), RedisReloadAttempts, RedisAppendOnlyMarker, s.ConfigCommand, RedisAppendOnlyMarker)
+}
@@ -0,0 +1,92 @@
+package cfbackup_test
+
+import (
+	"bytes"
+	"strings"
+
+	. "github.com/pivotalservices/cfbackup"
+
+	"github.com/pivotalservices/cfbackup/fakes"
+	"github.com/pivotalservices/gtils/mock"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/gbytes"
+)
+
+var _ = Describe("redis", func() {
+	var (
+		redis    *RedisBackup
+		executer *fakes.SuccessMockNFSExecuter
+		buffer   *gbytes.Buffer
+	)
+
+	BeforeEach(func() {
+		executer = &fakes.SuccessMockNFSExecuter{}
+		buffer = gbytes.NewBuffer()
+		redis = fakes.GetRedis(buffer, executer)
+	})
+
+	Describe("Dump", func() {
+		It("should write the rdb of the instance to the writer", func() {
+			var b bytes.Buffer
+			Ω(redis.Dump(&b)).Should(Succeed())
+			Ω(b.String()).Should(Equal(fakes.NfsSuccessString))
+		})
+
+		It("should run the renamed bgsave in the instance dir and wait for it", func() {
+			var b bytes.Buffer
+			Ω(redis.Dump(&b)).Should(Succeed())
+			Ω(executer.ActualCommand).Should(HavePrefix("cd /var/vcap/store/redis && "))
+			Ω(executer.ActualCommand).Should(ContainSubstring("$cli bgsave-secret > /dev/null"))
+			Ω(executer.ActualCommand).Should(ContainSubstring("rdb_bgsave_in_progress:1"))
+			Ω(executer.ActualCommand).Should(ContainSubstring("rdb_last_bgsave_status:ok"))
+			Ω(executer.ActualCommand).Should(HaveSuffix(`cat "$data/$rdb"`))
+		})
+
+		Context("when the save fails", func() {
+			BeforeEach(func() {
+				redis.Caller = &fakes.FailureMockNFSExecuter{}
+			})
+
+			It("should return the execution error", func() {
+				var b bytes.Buffer
+				Ω(redis.Dump(&b)).Should(Equal(fakes.ErrMockNfsCommand))
+			})
+		})
+	})
+
+	Describe("Import", func() {
+		var controlString = "REDIS0006 rdb"
+
+		It("should upload the rdb and put it in place of the instance's", func() {
+			Ω(redis.Import(strings.NewReader(controlString))).Should(Succeed())
+			Ω(buffer).Should(gbytes.Say(controlString))
+			Ω(executer.ActualCommand).Should(ContainSubstring(`cp ` + redis.RemoteOps.Path() + ` "$data/$rdb"`))
+		})
+
+		It("should turn the append only file off", func() {
+			Ω(redis.Import(strings.NewReader(controlString))).Should(Succeed())
+			Ω(executer.ActualCommand).Should(ContainSubstring("s/^appendonly yes/appendonly no/"))
+			Ω(executer.ActualCommand).Should(ContainSubstring("touch " + RedisAppendOnlyMarker))
+			Ω(executer.ActualCommand).Should(ContainSubstring(`rm -f "$data/appendonly.aof"`))
+		})
+
+		Context("when the upload fails", func() {
+			It("should not touch the instance", func() {
+				err := redis.Import(mock.NewReadWriteCloser(mock.ErrReadFailure, nil, nil))
+				Ω(err).Should(Equal(mock.ErrReadFailure))
+				Ω(executer.ActualCommand).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Reload", func() {
+		It("should wait for the instance and turn the append only file back on", func() {
+			Ω(redis.Reload()).Should(Succeed())
+			Ω(executer.ActualCommand).Should(ContainSubstring("PING"))
+			Ω(executer.ActualCommand).Should(ContainSubstring("$cli config-secret SET appendonly yes"))
+			Ω(executer.ActualCommand).Should(ContainSubstring("s/^appendonly no/appendonly yes/"))
+		})
+	})
+})
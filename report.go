@@ -0,0 +1,184 @@
+package cfbackup
+
+import (
+	"encoding/json"
+	"io"
+	"strings"
+	"time"
+
+	errwrap "github.com/pkg/errors"
+)
+
+//NewRunReport - a report of the action, passing the events it collects on to
+//the reporter. a nil reporter gets nothing
+func NewRunReport(action string, forward ProgressReporter) *RunReport {
+	return &RunReport{
+		Action:  action,
+		Started: time.Now().UTC(),
+		forward: forward,
+	}
+}
+
+//Report - collects the event into the report
+func (s *RunReport) Report(event ProgressEvent) {
+	if event.Time.IsZero() {
+		event.Time = time.Now().UTC()
+	}
+	s.collect(event)
+	if s.forward != nil {
+		s.forward.Report(event)
+	}
+}
+
+func (s *RunReport) collect(event ProgressEvent) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	switch event.Type {
+	case ProgressTileStarted:
+		s.tile = &ReportTile{Name: event.Step, Started: event.Time}
+		s.component = nil
+		s.Tiles = append(s.Tiles, s.tile)
+	case ProgressTileFinished:
+		tile := s.currentTile(event)
+		tile.Error = event.Error
+		tile.Finished = event.Time
+		tile.Duration = tile.Finished.Sub(tile.Started).Seconds()
+		s.tile, s.component = nil, nil
+	case ProgressStepStarted:
+		tile := s.currentTile(event)
+		s.component = &ReportComponent{Name: event.Step, Started: event.Time}
+		tile.Components = append(tile.Components, s.component)
+	case ProgressStepFinished:
+		for _, component := range s.currentTile(event).Components {
+			if component.Name == event.Step && component.Finished.IsZero() {
+				component.Error = event.Error
+				component.Finished = event.Time
+				component.Duration = component.Finished.Sub(component.Started).Seconds()
+			}
+		}
+		s.component = nil
+	case ProgressBytesTransferred:
+		if !event.Done {
+			return
+		}
+		artifact := ReportArtifact{Path: event.Artifact, Bytes: event.Bytes, SHA256: event.SHA256, Rate: event.Rate}
+		if s.component != nil {
+			artifact.Component = s.component.Name
+		}
+		tile := s.currentTile(event)
+		tile.Artifacts = append(tile.Artifacts, artifact)
+	case ProgressCCStopped:
+		s.ccStopped = event.Time
+	case ProgressCCStarted:
+		s.ccStarted(event.Time)
+	case ProgressBOSHTask:
+		s.BOSHTasks = append(s.BOSHTasks, ReportBOSHTask{
+			TaskID:     event.TaskID,
+			Deployment: event.Deployment,
+			Job:        event.Job,
+			State:      event.State,
+			Wait:       event.Wait.Seconds(),
+			Error:      event.Error,
+		})
+	case ProgressWarning:
+		s.Warnings = append(s.Warnings, event.Message)
+	case ProgressFoundation:
+		s.Foundation.Deployment = event.Deployment
+		s.Foundation.DirectorName = event.DirectorName
+		s.Foundation.DirectorUUID = event.DirectorUUID
+	}
+}
+
+//currentTile - the tile being run, an unnamed one for events outside of a
+//tile
+func (s *RunReport) currentTile(event ProgressEvent) *ReportTile {
+	if s.tile == nil {
+		s.tile = &ReportTile{Started: event.Time}
+		s.Tiles = append(s.Tiles, s.tile)
+	}
+	return s.tile
+}
+
+func (s *RunReport) ccStarted(started time.Time) {
+	if !s.ccStopped.IsZero() {
+		s.CCDowntime += started.Sub(s.ccStopped).Seconds()
+		s.ccStopped = time.Time{}
+	}
+}
+
+//Finish - records the end of the run and its error, a cloud controller
+//which was never started again is down until now
+func (s *RunReport) Finish(err error) *RunReport {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.Finished = time.Now().UTC()
+	s.Duration = s.Finished.Sub(s.Started).Seconds()
+	s.ccStarted(s.Finished)
+	if err != nil {
+		s.Error = err.Error()
+		s.ErrorChain = ErrorChain(err)
+	}
+	return s
+}
+
+//Write - writes the report to the file of the storage and to the writer,
+//either is left out when empty. the file is only written once it is closed,
+//so failing to close it fails the write
+func (s *RunReport) Write(storage StorageProvider, filepath string, writer io.Writer) (err error) {
+	var report []byte
+	s.mutex.Lock()
+	report, err = json.MarshalIndent(s, "", "  ")
+	s.mutex.Unlock()
+	if err != nil {
+		return errwrap.Wrap(err, "failed marshalling the run report")
+	}
+
+	if storage != nil && filepath != "" {
+		var stored io.WriteCloser
+		if stored, err = storage.Writer(filepath); err != nil {
+			return errwrap.Wrap(err, "failed creating the run report")
+		}
+		if _, err = stored.Write(report); err != nil {
+			stored.Close()
+			return errwrap.Wrap(err, "failed writing the run report")
+		}
+		if err = stored.Close(); err != nil {
+			return errwrap.Wrap(err, "failed closing the run report")
+		}
+	}
+	if writer != nil {
+		if _, err = writer.Write(report); err != nil {
+			return errwrap.Wrap(err, "failed writing the run report")
+		}
+	}
+	return
+}
+
+//ErrorChain - the message of the error and of every error it wraps, each
+//without the messages of the errors it wraps
+func ErrorChain(err error) (chain []string) {
+	for err != nil {
+		cause := errorCause(err)
+		message := err.Error()
+		if cause != nil {
+			if message == cause.Error() {
+				err = cause
+				continue
+			}
+			message = strings.TrimSuffix(message, ": "+cause.Error())
+		}
+		chain = append(chain, message)
+		err = cause
+	}
+	return
+}
+
+func errorCause(err error) error {
+	if causer, ok := err.(interface {
+		Cause() error
+	}); ok {
+		return causer.Cause()
+	}
+	return nil
+}
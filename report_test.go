@@ -0,0 +1,129 @@
+package cfbackup_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/fakes"
+	errwrap "github.com/pkg/errors"
+)
+
+var _ = Describe("RunReport", func() {
+	var (
+		report    *RunReport
+		forwarded []ProgressEvent
+	)
+
+	at := func(seconds int) time.Time {
+		return time.Date(2016, 5, 1, 10, 0, seconds, 0, time.UTC)
+	}
+
+	BeforeEach(func() {
+		forwarded = nil
+		report = NewRunReport("backup", ProgressFunc(func(event ProgressEvent) {
+			forwarded = append(forwarded, event)
+		}))
+	})
+
+	Describe("Report", func() {
+		BeforeEach(func() {
+			for _, event := range []ProgressEvent{
+				{Type: ProgressTileStarted, Step: "elastic-runtime", Time: at(0)},
+				{Type: ProgressFoundation, Deployment: "cf-f21eea2dbdb8555f89fb", DirectorUUID: "uuid-1"},
+				{Type: ProgressBOSHTask, TaskID: 42, Deployment: "cf-f21eea2dbdb8555f89fb", Job: "cloud_controller-partition/0", State: "stopped"},
+				{Type: ProgressCCStopped, Time: at(5)},
+				{Type: ProgressStepStarted, Step: "ccdb", Time: at(6)},
+				{Type: ProgressBytesTransferred, Artifact: "/backups/ccdb.backup", Bytes: 10, Time: at(7)},
+				{Type: ProgressBytesTransferred, Artifact: "/backups/ccdb.backup", Bytes: 20, SHA256: "abc", Done: true, Time: at(8)},
+				{Type: ProgressStepFinished, Step: "ccdb", Time: at(9)},
+				{Type: ProgressWarning, Message: "director version differs"},
+				{Type: ProgressCCStarted, Time: at(15)},
+				{Type: ProgressTileFinished, Step: "elastic-runtime", Error: "nfs failed", Time: at(20)},
+			} {
+				report.Report(event)
+			}
+		})
+
+		It("then it should collect the tiles, their components and artifacts", func() {
+			Ω(report.Tiles).Should(HaveLen(1))
+			tile := report.Tiles[0]
+			Ω(tile.Name).Should(Equal("elastic-runtime"))
+			Ω(tile.Error).Should(Equal("nfs failed"))
+			Ω(tile.Duration).Should(Equal(20.0))
+			Ω(tile.Components).Should(HaveLen(1))
+			Ω(tile.Components[0].Name).Should(Equal("ccdb"))
+			Ω(tile.Components[0].Duration).Should(Equal(3.0))
+			Ω(tile.Artifacts).Should(Equal([]ReportArtifact{{Path: "/backups/ccdb.backup", Component: "ccdb", Bytes: 20, SHA256: "abc"}}))
+		})
+
+		It("then it should collect the foundation, the bosh tasks, the cc downtime and the warnings", func() {
+			Ω(report.Foundation.Deployment).Should(Equal("cf-f21eea2dbdb8555f89fb"))
+			Ω(report.Foundation.DirectorUUID).Should(Equal("uuid-1"))
+			Ω(report.BOSHTasks).Should(Equal([]ReportBOSHTask{{TaskID: 42, Deployment: "cf-f21eea2dbdb8555f89fb", Job: "cloud_controller-partition/0", State: "stopped"}}))
+			Ω(report.CCDowntime).Should(Equal(10.0))
+			Ω(report.Warnings).Should(Equal([]string{"director version differs"}))
+		})
+
+		It("then it should pass the events on", func() {
+			Ω(forwarded).Should(HaveLen(11))
+		})
+	})
+
+	Describe("Finish", func() {
+		It("then it should record the error chain", func() {
+			err := errwrap.Wrap(errwrap.Wrap(errors.New("connection refused"), "failed dumping ccdb"), "backup of tile elastic-runtime failed")
+			report.Finish(err)
+			Ω(report.Error).Should(Equal(err.Error()))
+			Ω(report.ErrorChain).Should(Equal([]string{"backup of tile elastic-runtime failed", "failed dumping ccdb", "connection refused"}))
+			Ω(report.Finished.IsZero()).Should(BeFalse())
+		})
+
+		It("then it should count a cloud controller which was not started again as down", func() {
+			report.Report(ProgressEvent{Type: ProgressCCStopped, Time: time.Now().UTC().Add(-time.Minute)})
+			report.Finish(nil)
+			Ω(report.CCDowntime).Should(BeNumerically(">=", 60))
+		})
+	})
+
+	Describe("Write", func() {
+		var tmpDir string
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "cfbackup-report")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		It("then it should write the json report to the storage and the writer", func() {
+			var b bytes.Buffer
+			reportPath := path.Join(tmpDir, "backup-report.json")
+			Ω(report.Finish(nil).Write(NewDiskProvider(), reportPath, &b)).Should(Succeed())
+			stored, err := ioutil.ReadFile(reportPath)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stored).Should(Equal(b.Bytes()))
+			var read RunReport
+			Ω(json.Unmarshal(stored, &read)).Should(Succeed())
+			Ω(read.Action).Should(Equal("backup"))
+		})
+
+		It("then it should fail when the stored report can not be closed", func() {
+			storage := fakes.NewMockStringStorageProvider()
+			storage.ErrFakeCloseResponse = errors.New("upload failed")
+			err := report.Finish(nil).Write(storage, "backup-report.json", nil)
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("upload failed"))
+		})
+	})
+})
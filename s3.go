@@ -1,10 +1,13 @@
 package cfbackup
 
 import (
+	"errors"
 	"io"
+	"net/http"
+	"os"
 	"strings"
 
-	"github.com/pivotalservices/gtils/storage"
+	"github.com/rlmcpherson/s3gof3r"
 )
 
 // S3Provider is a storage provider that allows backups
@@ -14,6 +17,9 @@ type S3Provider struct {
 	BucketName      string
 	AccessKeyID     string
 	SecretAccessKey string
+	//TLS - the ca bundle and proxy the blobstore is reached with. its
+	//certificates are always verified
+	TLS TLSConfig
 }
 
 // NewS3Provider creates a new instance of the S3 storage provider
@@ -26,24 +32,69 @@ func NewS3Provider(domain, key, secret, bucket string) StorageProvider {
 	}
 }
 
+//SetTLSConfig - reaches the blobstore with the ca bundle and through the
+//proxy of the config
+func (s *S3Provider) SetTLSConfig(config TLSConfig) {
+	s.TLS = config
+}
+
 // Writer for writing to an S3 bucket
 func (s *S3Provider) Writer(path ...string) (io.WriteCloser, error) {
-	s3FilePath := strings.Join(path, "/")
-	s3, err := storage.SafeCreateS3Bucket(s.S3Domain, s.BucketName, s.AccessKeyID, s.SecretAccessKey)
+	bucket, config, err := s.bucket()
 
 	if err != nil {
 		return nil, err
 	}
-	return s3.NewWriter(s3FilePath)
+	return bucket.PutWriter(strings.Join(path, "/"), nil, config)
 }
 
 // Reader for reading from an S3 bucket
 func (s *S3Provider) Reader(path ...string) (io.ReadCloser, error) {
-	s3FilePath := strings.Join(path, "/")
-	s3, err := storage.SafeCreateS3Bucket(s.S3Domain, s.BucketName, s.AccessKeyID, s.SecretAccessKey)
+	bucket, config, err := s.bucket()
 
 	if err != nil {
 		return nil, err
 	}
-	return s3.NewReader(s3FilePath)
+	reader, _, err := bucket.GetReader(strings.Join(path, "/"), config)
+	return reader, err
+}
+
+//IsNotExist - whether the error of a storage provider says the file is not
+//there, on disk or in the bucket
+func IsNotExist(err error) bool {
+	if respErr, ok := err.(*s3gof3r.RespError); ok {
+		return respErr.StatusCode == http.StatusNotFound
+	}
+	return os.IsNotExist(err)
+}
+
+//bucket - the bucket along with the config its files are read and written
+//with, the keys are taken from the environment when the provider has none
+func (s *S3Provider) bucket() (bucket *s3gof3r.Bucket, config *s3gof3r.Config, err error) {
+	if s.BucketName == "" {
+		return nil, nil, errors.New("bucket name is undefined")
+	}
+
+	keys := s3gof3r.Keys{AccessKey: s.AccessKeyID, SecretKey: s.SecretAccessKey}
+	if keys.AccessKey == "" || keys.SecretKey == "" {
+		if keys, err = s3gof3r.EnvKeys(); err != nil {
+			return
+		}
+	}
+
+	tls := s.TLS
+	tls.Strict = true
+	var transport *http.Transport
+	if transport, err = tls.Transport(); err != nil {
+		return
+	}
+	defaults := *s3gof3r.DefaultConfig
+	if defaultTransport, ok := defaults.Client.Transport.(*http.Transport); ok {
+		transport.Dial = defaultTransport.Dial
+		transport.ResponseHeaderTimeout = defaultTransport.ResponseHeaderTimeout
+	}
+	config = &defaults
+	config.Client = &http.Client{Transport: transport}
+	bucket = s3gof3r.New(s.S3Domain, keys).Bucket(s.BucketName)
+	return
 }
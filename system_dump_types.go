@@ -17,45 +17,98 @@ func (s *SystemInfo) Set(name string, val string) {
 	s.GetSet.Set(s, name, val)
 }
 
+//SetSSHTunnels - reaches the vm of the system through the ssh tunnels
+func (s *SystemInfo) SetSSHTunnels(tunnels *SSHTunnels) {
+	s.SSHTunnels = tunnels
+}
+
+//SetMetrics - keeps the metrics of the nfs backup in the metrics
+func (s *NfsInfo) SetMetrics(metrics *Metrics) {
+	s.Metrics = metrics
+}
+
 //GetPersistanceBackup - the constructor for a new nfsinfo object
 func (s *NfsInfo) GetPersistanceBackup() (dumper PersistanceBackup, err error) {
-	return NewNFSBackup(s.User, s.Pass, s.Ip, s.SSHPrivateKey, s.RemoteArchivePath, s.BackupType)
+	var nfs *NFSBackup
+	if nfs, err = newNFSBackup(s.SSHTunnels, s.User, s.Pass, s.Ip, s.SSHPrivateKey, s.RemoteArchivePath, s.BackupType); err != nil {
+		return
+	}
+	nfs.Metrics = s.Metrics
+	return nfs, nil
 }
 
 //GetPersistanceBackup - the constructor for a new DirectorInfo object
 func (s *DirectorInfo) GetPersistanceBackup() (dumper PersistanceBackup, err error) {
-	sshConfig := command.SshConfig{
-		Username: s.VcapUser,
-		Password: s.VcapPass,
-		Host:     s.Ip,
-		Port:     22,
-		SSLKey:   s.SSHPrivateKey,
+	sshConfig, err := s.sshConfig()
+	if err != nil {
+		return
+	}
+	return persistence.NewPgRemoteDumpWithPath(BOSHDirectorDBPort, s.Database, s.User, s.Pass, sshConfig, s.RemoteArchivePath)
+}
+
+//ArchiveName - the director databases all live on the director job, so they
+//are archived under their database name
+func (s *DirectorInfo) ArchiveName() string {
+	return fmt.Sprintf("%s-%s", s.Component, s.Database)
+}
+
+//GetPersistanceBackup - the constructor for a new DirectorBlobstoreInfo object
+func (s *DirectorBlobstoreInfo) GetPersistanceBackup() (dumper PersistanceBackup, err error) {
+	return newBlobstoreBackup(s.SSHTunnels, s.VcapUser, s.VcapPass, s.Ip, s.SSHPrivateKey, s.RemoteArchivePath)
+}
+
+//GetDirectorProcesses - the processes of the director job the blobstore
+//lives on, reached with the vm credentials of the blobstore
+func (s *DirectorBlobstoreInfo) GetDirectorProcesses() (processes *DirectorProcesses, err error) {
+	var (
+		config   command.SshConfig
+		executer command.Executer
+	)
+	if config, err = s.sshConfig(); err != nil {
+		return
+	}
+	if executer, err = NfsNewRemoteExecuter(config); err == nil {
+		processes = &DirectorProcesses{
+			Caller:   executer,
+			Patterns: DirectorProcessPatterns,
+		}
 	}
-	return persistence.NewPgRemoteDumpWithPath(2544, s.Database, s.User, s.Pass, sshConfig, s.RemoteArchivePath)
+	return
+}
+
+//ArchiveName - the blobstore lives on the director job along with its databases
+func (s *DirectorBlobstoreInfo) ArchiveName() string {
+	return fmt.Sprintf("%s-%s", s.Component, BlobstoreArchiveDir)
 }
 
 //GetPersistanceBackup - the constructor for a new mysqlinfo object
 func (s *MysqlInfo) GetPersistanceBackup() (dumper PersistanceBackup, err error) {
-	sshConfig := command.SshConfig{
-		Username: s.VcapUser,
-		Password: s.VcapPass,
-		Host:     s.Ip,
-		Port:     22,
-		SSLKey:   s.SSHPrivateKey,
+	sshConfig, err := s.sshConfig()
+	if err != nil {
+		return
 	}
 	return persistence.NewRemoteMysqlDumpWithPath(s.User, s.Pass, sshConfig, s.RemoteArchivePath)
 }
 
 //GetPersistanceBackup - the constructor for a new pginfo object
 func (s *PgInfo) GetPersistanceBackup() (dumper PersistanceBackup, err error) {
-	sshConfig := command.SshConfig{
+	sshConfig, err := s.sshConfig()
+	if err != nil {
+		return
+	}
+	return persistence.NewPgRemoteDump(2544, s.Database, s.User, s.Pass, sshConfig)
+}
+
+//sshConfig - the ssh config of the vm of the system, through the jump hosts
+//when there are any
+func (s *SystemInfo) sshConfig() (command.SshConfig, error) {
+	return s.SSHTunnels.TunnelSSHConfig(command.SshConfig{
 		Username: s.VcapUser,
 		Password: s.VcapPass,
 		Host:     s.Ip,
 		Port:     22,
 		SSLKey:   s.SSHPrivateKey,
-	}
-	return persistence.NewPgRemoteDump(2544, s.Database, s.User, s.Pass, sshConfig)
+	})
 }
 
 //GetPersistanceBackup - the constructor for a systeminfo object
@@ -95,9 +95,10 @@ func NewSystemsInfo(installationSettingsFile string, sshKey string, nfs string)
 }
 
 // PersistentSystems returns a slice of all the
-// jobs that need to be backed up
+// jobs that need to be backed up, the director
+// ones are only there once EnableDirectorBackup ran
 func (s SystemsInfo) PersistentSystems() []SystemDump {
-	ps := []string{ERCc, ERUaa, ERConsole, ERNfs, ERMySQL}
+	ps := []string{ERCc, ERUaa, ERConsole, ERNfs, ERMySQL, ERDirectorDB, ERDirectorCredentialStore, ERDirectorBlobstore}
 	jobs := []SystemDump{}
 
 	for _, info := range ps {
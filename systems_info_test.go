@@ -36,6 +36,27 @@ var _ = Describe("SystemsInfo", func() {
 			})
 		})
 
+		Context("Using a 1.6 vsphere installation settings file with director backup enabled", func() {
+			systemsInfo := NewSystemsInfo(installationSettingsPre16UpgradeVsphereFile, sshKey, NFSBackupTypeFull)
+			systemsInfo.EnableDirectorBackup(sshKey)
+			systemDumps := systemsInfo.SystemDumps
+
+			It("should include the director database, credential store and blobstore", func() {
+				Ω(systemDumps[ERDirectorDB]).ShouldNot(BeNil())
+				Ω(systemDumps[ERDirectorCredentialStore]).ShouldNot(BeNil())
+				Ω(systemDumps[ERDirectorBlobstore]).ShouldNot(BeNil())
+				Ω(len(systemsInfo.PersistentSystems())).Should(Equal(8))
+			})
+			It("should archive each director system under its own name", func() {
+				Ω(systemDumps[ERDirectorDB].(ArchiveNamer).ArchiveName()).Should(Equal("director-bosh"))
+				Ω(systemDumps[ERDirectorCredentialStore].(ArchiveNamer).ArchiveName()).Should(Equal("director-credhub"))
+				Ω(systemDumps[ERDirectorBlobstore].(ArchiveNamer).ArchiveName()).Should(Equal("director-blobstore"))
+			})
+			It("should point the director systems at the bosh product", func() {
+				Ω(systemDumps[ERDirectorDB].Get(SDProduct)).Should(Equal(systemDumps[ERDirector].Get(SDProduct)))
+			})
+		})
+
 		Context("Using a 1.6 vsphere installation settings file from a default mysql/ha upgrade", func() {
 			systemsInfo := NewSystemsInfo(installationSettingsDefaultUpgradeVsphereFile, sshKey, NFSBackupTypeFull)
 			systemDumps := systemsInfo.SystemDumps
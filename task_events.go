@@ -0,0 +1,89 @@
+package cfbackup
+
+import (
+	"fmt"
+	"io/ioutil"
+	"strings"
+
+	"github.com/xchapter7x/lo"
+)
+
+//TaskEventsInError - how many of the last events of a failed task are
+//included in the returned error
+var TaskEventsInError = 5
+
+//taskEvents - follows the event output of a task, logging each event once.
+//the offset is how far into the event output we have read
+type taskEvents struct {
+	taskID   int
+	director Bosh
+	events   []BoshTaskEvent
+	offset   int64
+}
+
+//follow - streams the task events, when we are following the task at all
+func (s *taskEvents) follow() {
+	if s != nil {
+		s.stream()
+	}
+}
+
+//stream - logs the events the task logged since we last looked
+func (s *taskEvents) stream() {
+	events, offset, err := s.director.GetTaskEvents(s.taskID, s.offset)
+	if err != nil {
+		lo.G.Debugf("unable to fetch events for task %d: %v", s.taskID, err)
+		return
+	}
+
+	for _, event := range events {
+		if event.Error != nil {
+			lo.G.Errorf("task %d: %s", s.taskID, formatTaskEvent(event))
+		} else {
+			lo.G.Infof("task %d: %s", s.taskID, formatTaskEvent(event))
+		}
+	}
+	s.events = append(s.events, events...)
+	s.offset = offset
+}
+
+//failure - builds the error for a failed task from its last events, the
+//debug output is only logged as it can be very long
+func (s *taskEvents) failure(task *Task) error {
+	s.stream()
+
+	if debug, err := s.director.GetTaskOutput(s.taskID, BOSHTaskOutputDebug); err == nil && debug != nil {
+		b, _ := ioutil.ReadAll(debug)
+		debug.Close()
+		lo.G.Debugf("task %d debug output:\n%s", s.taskID, b)
+	}
+
+	lastEvents := s.events
+	if len(lastEvents) > TaskEventsInError {
+		lastEvents = lastEvents[len(lastEvents)-TaskEventsInError:]
+	}
+	lines := make([]string, 0, len(lastEvents))
+	for _, event := range lastEvents {
+		lines = append(lines, formatTaskEvent(event))
+	}
+
+	msg := fmt.Sprintf("Task %d process failed", s.taskID)
+	if task.Result != "" {
+		msg = fmt.Sprintf("%s: %s", msg, task.Result)
+	}
+	if len(lines) > 0 {
+		msg = fmt.Sprintf("%s\nlast task events:\n%s", msg, strings.Join(lines, "\n"))
+	}
+	return fmt.Errorf("%s", msg)
+}
+
+func formatTaskEvent(event BoshTaskEvent) string {
+	line := fmt.Sprintf("%s > %s (%d/%d) %s", event.Stage, event.Task, event.Index, event.Total, event.State)
+	if len(event.Tags) > 0 {
+		line = fmt.Sprintf("%s [%s]", line, strings.Join(event.Tags, ", "))
+	}
+	if event.Error != nil {
+		line = fmt.Sprintf("%s: error %d: %s", line, event.Error.Code, event.Error.Message)
+	}
+	return line
+}
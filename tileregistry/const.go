@@ -1,6 +1,79 @@
 package tileregistry
 
+import "errors"
+
 var (
 	//Repo -- repo holds the registered sku interfaces
 	Repo = make(map[string]TileGenerator)
+	//Dependencies -- the tiles every registered tile is backed up and
+	//restored after
+	Dependencies = make(map[string][]string)
+)
+
+const (
+	//OrchestratorManifestFileName - the manifest of a backup id, listing its
+	//tiles and their results
+	OrchestratorManifestFileName = "manifest.json"
+	//OrchestratorBackupIDFormat - the time format of generated backup ids
+	OrchestratorBackupIDFormat = "20060102T150405Z"
+	//TileStatusSucceeded - the tile was backed up or restored
+	TileStatusSucceeded = "succeeded"
+	//TileStatusFailed - backing up or restoring the tile failed
+	TileStatusFailed = "failed"
+	//TileStatusSkipped - the tile was not run since a tile before it failed
+	TileStatusSkipped = "skipped"
+	//HookPreBackup - hooks run before a tile is backed up
+	HookPreBackup = "pre-backup"
+	//HookPostStopCC - hooks run once the cloud controller is stopped, before
+	//the elastic runtime databases are dumped or restored
+	HookPostStopCC = "post-stop-cc"
+	//HookPostDump - hooks run once the elastic runtime databases are dumped,
+	//while the cloud controller is still stopped
+	HookPostDump = "post-dump"
+	//HookPostBackup - hooks run once a tile is backed up
+	HookPostBackup = "post-backup"
+	//HookPreRestore - hooks run before a tile is restored
+	HookPreRestore = "pre-restore"
+	//HookPostRestore - hooks run once a tile is restored
+	HookPostRestore = "post-restore"
+	//HookFailureAbort - a failing hook fails the backup or restore
+	HookFailureAbort = "abort"
+	//HookFailureWarn - a failing hook is logged and the backup or restore
+	//goes on
+	HookFailureWarn = "warn"
+	//HookEnvPoint - the environment variable an executable hook gets the
+	//point it is run at in
+	HookEnvPoint = "CFBACKUP_HOOK_POINT"
+	//HookEnvTile - the environment variable an executable hook gets the tile
+	//it is run for in
+	HookEnvTile = "CFBACKUP_HOOK_TILE"
+	//HookEnvArchiveDirectory - the environment variable an executable hook
+	//gets the archive directory of the tile in
+	HookEnvArchiveDirectory = "CFBACKUP_ARCHIVE_DIRECTORY"
+)
+
+//HookPoints - the points of the lifecycle hooks can be run at
+var HookPoints = []string{HookPreBackup, HookPostStopCC, HookPostDump, HookPostBackup, HookPreRestore, HookPostRestore}
+
+var (
+	//ErrNoOpsManagerAuth - the tile spec has neither a token, a uaa client nor an admin user and password
+	ErrNoOpsManagerAuth = errors.New("no ops manager credentials given, provide a token, a client id and secret or an admin user and password")
+	//ErrClientSecretMissing - a client id was given without its secret
+	ErrClientSecretMissing = errors.New("a client id needs a client secret")
+	//ErrClientIDMissing - a client secret was given without a client id
+	ErrClientIDMissing = errors.New("a client secret needs a client id")
+	//ErrTileDependencyCycle - the tiles to run depend on each other
+	ErrTileDependencyCycle = errors.New("the tiles depend on each other in a cycle")
+	//ErrNoBackupID - a restore was asked for without the backup id to restore
+	ErrNoBackupID = errors.New("a restore needs the id of the backup to restore")
+	//ErrTileNotRegistered - a tile to run is not in the registry
+	ErrTileNotRegistered = errors.New("tile is not registered")
+	//ErrNoTiles - there are no tiles to run
+	ErrNoTiles = errors.New("no tiles to back up or restore")
+	//ErrUnknownHookPoint - a hook is given for a point the lifecycle does not have
+	ErrUnknownHookPoint = errors.New("unknown hook point")
+	//ErrUnknownHookFailure - a hook is given a failure policy other than abort or warn
+	ErrUnknownHookFailure = errors.New("a hook failure is either abort or warn")
+	//ErrInvalidHookSpec - a hook is not given as point[@tile,...][:abort|warn]=executable [args]
+	ErrInvalidHookSpec = errors.New("a hook is given as point[@tile,...][:abort|warn]=executable [args]")
 )
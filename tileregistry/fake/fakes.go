@@ -8,9 +8,10 @@ import (
 //TileGenerator --
 type TileGenerator struct {
 	tileregistry.TileGenerator
-	TileSpy tileregistry.Tile
-	ErrFake error
-	Closer  tileregistry.Closer
+	TileSpy   tileregistry.Tile
+	ErrFake   error
+	Closer    tileregistry.Closer
+	TileSpecs []tileregistry.TileSpec
 }
 
 //Closer --
@@ -25,6 +26,7 @@ func (closer *Closer) Close() {
 
 //New --
 func (s *TileGenerator) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	s.TileSpecs = append(s.TileSpecs, tileSpec)
 	tileCloser := struct {
 		tileregistry.Tile
 		tileregistry.Closer
@@ -40,12 +42,16 @@ type Tile struct {
 	ErrFake          error
 	BackupCallCount  int
 	RestoreCallCount int
+	//Name and Calls - when set every call is recorded as "backup|restore name"
+	Name  string
+	Calls *[]string
 }
 
 //Backup --
 func (s *Tile) Backup() error {
 	lo.G.Debug("we fake backed up")
 	s.BackupCallCount++
+	s.record("backup")
 	return s.ErrFake
 }
 
@@ -53,5 +59,12 @@ func (s *Tile) Backup() error {
 func (s *Tile) Restore() error {
 	lo.G.Debug("we fake restored")
 	s.RestoreCallCount++
+	s.record("restore")
 	return s.ErrFake
 }
+
+func (s *Tile) record(call string) {
+	if s.Calls != nil {
+		*s.Calls = append(*s.Calls, call+" "+s.Name)
+	}
+}
@@ -0,0 +1,164 @@
+package tileregistry
+
+import (
+	"fmt"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//Run - calls the function
+func (s HookFunc) Run(event HookEvent) error {
+	return s(event)
+}
+
+//Run - runs the executable with the event in its environment, logging what
+//it prints
+func (s *ExecHook) Run(event HookEvent) error {
+	cmd := exec.Command(s.Path, s.Args...)
+	cmd.Env = append(os.Environ(),
+		HookEnvPoint+"="+event.Point,
+		HookEnvTile+"="+event.Tile,
+		HookEnvArchiveDirectory+"="+event.ArchiveDirectory,
+	)
+	output, err := cmd.CombinedOutput()
+	lo.G.Debugf("%s hook %s for tile %s: %s", event.Point, s.Path, event.Tile, output)
+	if err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("hook %s failed: %s", s.Path, strings.TrimSpace(string(output))))
+	}
+	return nil
+}
+
+//For - the hooks run for the tile
+func (s Hooks) For(tile string) (hooks Hooks) {
+	for _, hook := range s {
+		if hook.runsFor(tile) {
+			hooks = append(hooks, hook)
+		}
+	}
+	return
+}
+
+//Run - runs the hooks of the point for the tile of the event in order. an
+//aborting hook which fails stops the run and returns its error, a warning
+//one is logged and reported to the progress reporter when there is one
+func (s Hooks) Run(event HookEvent, progress cfbackup.ProgressReporter) error {
+	for _, hook := range s {
+		if hook.Point != event.Point || !hook.runsFor(event.Tile) {
+			continue
+		}
+		lo.G.Infof("running %s hook for tile %s", event.Point, event.Tile)
+		if err := hook.Hook.Run(event); err != nil {
+			if hook.OnFailure == HookFailureWarn {
+				message := fmt.Sprintf("%s hook for tile %s failed, going on: %v", event.Point, event.Tile, err)
+				lo.G.Warning(message)
+				if progress != nil {
+					progress.Report(cfbackup.ProgressEvent{Type: cfbackup.ProgressWarning, Message: message})
+				}
+				continue
+			}
+			return errwrap.Wrap(err, fmt.Sprintf("%s hook for tile %s failed", event.Point, event.Tile))
+		}
+	}
+	return nil
+}
+
+func (s HookSpec) runsFor(tile string) bool {
+	if len(s.Tiles) == 0 {
+		return true
+	}
+	for _, name := range s.Tiles {
+		if name == tile {
+			return true
+		}
+	}
+	return false
+}
+
+//ParseHookSpec - an executable hook given as
+//point[@tile,...][:abort|warn]=executable [args]
+func ParseHookSpec(spec string) (hook HookSpec, err error) {
+	parts := strings.SplitN(spec, "=", 2)
+	if len(parts) != 2 {
+		return hook, errwrap.Wrap(ErrInvalidHookSpec, spec)
+	}
+	command := strings.Fields(parts[1])
+	if len(command) == 0 {
+		return hook, errwrap.Wrap(ErrInvalidHookSpec, spec)
+	}
+	hook.Hook = &ExecHook{Path: command[0], Args: command[1:]}
+
+	target := strings.TrimSpace(parts[0])
+	if i := strings.LastIndex(target, ":"); i >= 0 {
+		hook.OnFailure, target = target[i+1:], target[:i]
+		if hook.OnFailure != HookFailureAbort && hook.OnFailure != HookFailureWarn {
+			return hook, errwrap.Wrap(ErrUnknownHookFailure, spec)
+		}
+	}
+	if i := strings.Index(target, "@"); i >= 0 {
+		for _, tile := range strings.Split(target[i+1:], ",") {
+			if tile = strings.TrimSpace(tile); tile != "" {
+				hook.Tiles = append(hook.Tiles, tile)
+			}
+		}
+		target = target[:i]
+	}
+	hook.Point = target
+	if !isHookPoint(hook.Point) {
+		return hook, errwrap.Wrap(ErrUnknownHookPoint, hook.Point)
+	}
+	return
+}
+
+func isHookPoint(point string) bool {
+	for _, hookPoint := range HookPoints {
+		if point == hookPoint {
+			return true
+		}
+	}
+	return false
+}
+
+//NewHookedTile - the tile, running the hooks of its name around its backup
+//and restore. the builders hook their tiles through TileSpec.Build, so the
+//hooks run whether a tile is run by the Orchestrator or on its own
+func NewHookedTile(name string, tile TileCloser, hooks Hooks, archiveDirectory string) *HookedTile {
+	return &HookedTile{
+		TileCloser:       tile,
+		Name:             name,
+		Hooks:            hooks.For(name),
+		ArchiveDirectory: archiveDirectory,
+	}
+}
+
+//Backup - runs the pre-backup hooks, backs up the tile and runs the
+//post-backup hooks
+func (s *HookedTile) Backup() (err error) {
+	if err = s.run(HookPreBackup); err != nil {
+		return
+	}
+	if err = s.TileCloser.Backup(); err != nil {
+		return
+	}
+	return s.run(HookPostBackup)
+}
+
+//Restore - runs the pre-restore hooks, restores the tile and runs the
+//post-restore hooks
+func (s *HookedTile) Restore() (err error) {
+	if err = s.run(HookPreRestore); err != nil {
+		return
+	}
+	if err = s.TileCloser.Restore(); err != nil {
+		return
+	}
+	return s.run(HookPostRestore)
+}
+
+func (s *HookedTile) run(point string) error {
+	return s.Hooks.Run(HookEvent{Point: point, Tile: s.Name, ArchiveDirectory: s.ArchiveDirectory}, s.Progress)
+}
@@ -0,0 +1,210 @@
+package tileregistry_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tileregistry/fake"
+)
+
+var _ = Describe("Hooks", func() {
+	var (
+		calls []string
+		tile  *fake.Tile
+	)
+
+	recorder := func(name string, err error) HookFunc {
+		return func(event HookEvent) error {
+			calls = append(calls, name+" "+event.Point+" "+event.Tile)
+			return err
+		}
+	}
+
+	hooked := func(hooks Hooks) *HookedTile {
+		return NewHookedTile("p-mysql", struct {
+			Tile
+			Closer
+		}{tile, new(fake.Closer)}, hooks, "/backups/p-mysql")
+	}
+
+	BeforeEach(func() {
+		calls = nil
+		tile = &fake.Tile{Name: "p-mysql", Calls: &calls}
+	})
+
+	Describe("HookedTile", func() {
+		var hooks Hooks
+
+		BeforeEach(func() {
+			hooks = Hooks{
+				{Point: HookPreBackup, Hook: recorder("drain", nil)},
+				{Point: HookPostBackup, Hook: recorder("notify", nil)},
+				{Point: HookPreRestore, Hook: recorder("drain", nil)},
+				{Point: HookPostRestore, Hook: recorder("notify", nil)},
+			}
+		})
+
+		It("then it should run the backup hooks around the backup", func() {
+			Ω(hooked(hooks).Backup()).Should(Succeed())
+			Ω(calls).Should(Equal([]string{"drain pre-backup p-mysql", "backup p-mysql", "notify post-backup p-mysql"}))
+		})
+
+		It("then it should run the restore hooks around the restore", func() {
+			Ω(hooked(hooks).Restore()).Should(Succeed())
+			Ω(calls).Should(Equal([]string{"drain pre-restore p-mysql", "restore p-mysql", "notify post-restore p-mysql"}))
+		})
+
+		It("then it should pass the archive directory of the tile", func() {
+			var event HookEvent
+			hooked(Hooks{{Point: HookPreBackup, Hook: HookFunc(func(e HookEvent) error {
+				event = e
+				return nil
+			})}}).Backup()
+			Ω(event).Should(Equal(HookEvent{Point: HookPreBackup, Tile: "p-mysql", ArchiveDirectory: "/backups/p-mysql"}))
+		})
+
+		It("then it should only run the hooks of its tile", func() {
+			hooks = Hooks{
+				{Point: HookPreBackup, Tiles: []string{"p-redis"}, Hook: recorder("redis", nil)},
+				{Point: HookPreBackup, Tiles: []string{"p-redis", "p-mysql"}, Hook: recorder("both", nil)},
+			}
+			Ω(hooked(hooks).Backup()).Should(Succeed())
+			Ω(calls).Should(Equal([]string{"both pre-backup p-mysql", "backup p-mysql"}))
+		})
+
+		Context("when an aborting hook fails", func() {
+			It("then it should fail without backing up", func() {
+				hooks[0].Hook = recorder("drain", errors.New("lb unreachable"))
+				err := hooked(hooks).Backup()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("lb unreachable"))
+				Ω(calls).Should(Equal([]string{"drain pre-backup p-mysql"}))
+			})
+		})
+
+		Context("when a warning hook fails", func() {
+			It("then it should go on", func() {
+				hooks[0].Hook = recorder("drain", errors.New("lb unreachable"))
+				hooks[0].OnFailure = HookFailureWarn
+				Ω(hooked(hooks).Backup()).Should(Succeed())
+				Ω(calls).Should(Equal([]string{"drain pre-backup p-mysql", "backup p-mysql", "notify post-backup p-mysql"}))
+			})
+		})
+
+		Context("when the tile fails", func() {
+			It("then it should not run the post hooks", func() {
+				tile.ErrFake = errors.New("dump failed")
+				Ω(hooked(hooks).Backup()).ShouldNot(Succeed())
+				Ω(calls).Should(Equal([]string{"drain pre-backup p-mysql", "backup p-mysql"}))
+			})
+		})
+	})
+
+	Describe("TileSpec.Hooked", func() {
+		var closer TileCloser
+
+		BeforeEach(func() {
+			closer = struct {
+				Tile
+				Closer
+			}{tile, new(fake.Closer)}
+		})
+
+		It("then it should run the hooks of the tile spec when the tile is run on its own", func() {
+			tileSpec := TileSpec{ArchiveDirectory: "/backups/p-mysql", Hooks: Hooks{{Point: HookPreBackup, Hook: recorder("drain", nil)}}}
+			Ω(tileSpec.Hooked("p-mysql", closer).Backup()).Should(Succeed())
+			Ω(calls).Should(Equal([]string{"drain pre-backup p-mysql", "backup p-mysql"}))
+		})
+
+		It("then it should return the tile itself when no hook is for it", func() {
+			tileSpec := TileSpec{Hooks: Hooks{{Point: HookPreBackup, Tiles: []string{"p-redis"}, Hook: recorder("drain", nil)}}}
+			Ω(tileSpec.Hooked("p-mysql", closer)).Should(Equal(closer))
+		})
+
+		It("then it should not hook a hooked tile twice", func() {
+			tileSpec := TileSpec{Hooks: Hooks{{Point: HookPreBackup, Hook: recorder("drain", nil)}}}
+			hookedTile := tileSpec.Hooked("p-mysql", closer)
+			Ω(tileSpec.Hooked("p-mysql", hookedTile)).Should(Equal(hookedTile))
+			Ω(hookedTile.Backup()).Should(Succeed())
+			Ω(calls).Should(Equal([]string{"drain pre-backup p-mysql", "backup p-mysql"}))
+		})
+	})
+
+	Describe("ExecHook", func() {
+		var tmpDir string
+
+		BeforeEach(func() {
+			var err error
+			tmpDir, err = ioutil.TempDir("", "cfbackup-hooks")
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			os.RemoveAll(tmpDir)
+		})
+
+		It("then it should run the executable with the event in its environment", func() {
+			script := path.Join(tmpDir, "hook.sh")
+			Ω(ioutil.WriteFile(script, []byte("#!/bin/sh\necho \"$1 $CFBACKUP_HOOK_POINT $CFBACKUP_HOOK_TILE $CFBACKUP_ARCHIVE_DIRECTORY\" > \"$2\"\n"), 0755)).Should(Succeed())
+			out := path.Join(tmpDir, "out")
+			hook := &ExecHook{Path: script, Args: []string{"quiesce", out}}
+			Ω(hook.Run(HookEvent{Point: HookPostBackup, Tile: "p-mysql", ArchiveDirectory: "/backups"})).Should(Succeed())
+			Ω(ioutil.ReadFile(out)).Should(Equal([]byte("quiesce post-backup p-mysql /backups\n")))
+		})
+
+		It("then it should fail with what a failing executable prints", func() {
+			script := path.Join(tmpDir, "hook.sh")
+			Ω(ioutil.WriteFile(script, []byte("#!/bin/sh\necho webhook refused\nexit 3\n"), 0755)).Should(Succeed())
+			err := (&ExecHook{Path: script}).Run(HookEvent{Point: HookPreBackup})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("webhook refused"))
+		})
+	})
+
+	Describe("ParseHookSpec", func() {
+		It("then it should parse the point, tiles, failure and executable", func() {
+			hook, err := ParseHookSpec("post-stop-cc@elastic-runtime,p-mysql:warn=/usr/local/bin/snapshot --disk data")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(hook.Point).Should(Equal(HookPostStopCC))
+			Ω(hook.Tiles).Should(Equal([]string{"elastic-runtime", "p-mysql"}))
+			Ω(hook.OnFailure).Should(Equal(HookFailureWarn))
+			Ω(hook.Hook).Should(Equal(&ExecHook{Path: "/usr/local/bin/snapshot", Args: []string{"--disk", "data"}}))
+		})
+
+		It("then it should accept the post-dump point", func() {
+			hook, err := ParseHookSpec("post-dump@elastic-runtime=/usr/local/bin/notify")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(hook.Point).Should(Equal(HookPostDump))
+		})
+
+		It("then it should run for every tile and abort by default", func() {
+			hook, err := ParseHookSpec("pre-backup=/usr/local/bin/drain")
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(hook.Tiles).Should(BeEmpty())
+			Ω(hook.OnFailure).Should(BeEmpty())
+		})
+
+		It("then it should fail for an unknown point", func() {
+			_, err := ParseHookSpec("mid-backup=/usr/local/bin/drain")
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(ErrUnknownHookPoint.Error()))
+		})
+
+		It("then it should fail for an unknown failure", func() {
+			_, err := ParseHookSpec("pre-backup:ignore=/usr/local/bin/drain")
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(ErrUnknownHookFailure.Error()))
+		})
+
+		It("then it should fail without an executable", func() {
+			_, err := ParseHookSpec("pre-backup=")
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(ErrInvalidHookSpec.Error()))
+		})
+	})
+})
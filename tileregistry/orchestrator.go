@@ -0,0 +1,246 @@
+package tileregistry
+
+import (
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/pivotalservices/cfbackup"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//NewOrchestrator - an orchestrator of the tiles under the backup id, a
+//backup generates an id when none is given. a restore without tiles restores
+//those in the manifest of the backup id
+func NewOrchestrator(tileSpec TileSpec, tiles []string, backupID string) *Orchestrator {
+	context := cfbackup.NewBackupContext(tileSpec.ArchiveDirectory, cfenv.CurrentEnv(), tileSpec.CryptKey)
+	context.Progress = tileSpec.Progress
+	context.Metrics = tileSpec.Metrics
+	return &Orchestrator{
+		TileSpec: tileSpec,
+		Tiles:    tiles,
+		BackupID: backupID,
+		Context:  context,
+	}
+}
+
+//Backup - backs up the tiles in dependency order, stopping at the first
+//failure, and writes the manifest of the backup id
+func (s *Orchestrator) Backup() (manifest *BackupManifest, err error) {
+	if s.BackupID == "" {
+		s.BackupID = time.Now().UTC().Format(OrchestratorBackupIDFormat)
+	}
+	if manifest, err = s.run(cfbackup.ExportArchive, s.Tiles); manifest == nil {
+		return
+	}
+	if writeErr := s.writeManifest(manifest); writeErr != nil && err == nil {
+		err = writeErr
+	}
+	return
+}
+
+//Restore - restores the tiles in dependency order, stopping at the first
+//failure
+func (s *Orchestrator) Restore() (manifest *BackupManifest, err error) {
+	if s.BackupID == "" {
+		return nil, ErrNoBackupID
+	}
+	tiles := s.Tiles
+	if len(tiles) == 0 {
+		var backup *BackupManifest
+		if backup, err = s.readManifest(); err != nil {
+			s.writeReport(s.newReport(cfbackup.ImportArchive).Finish(err))
+			return
+		}
+		for _, tile := range backup.Tiles {
+			if tile.Status == TileStatusSucceeded {
+				tiles = append(tiles, tile.Name)
+			}
+		}
+	}
+	return s.run(cfbackup.ImportArchive, tiles)
+}
+
+//TileDir - where the tile is kept in the backup id
+func (s *Orchestrator) TileDir(tile string) string {
+	return path.Join(s.TileSpec.ArchiveDirectory, s.BackupID, tile)
+}
+
+func (s *Orchestrator) run(action int, tiles []string) (manifest *BackupManifest, err error) {
+	report := s.newReport(action)
+	defer func() {
+		if reportErr := s.writeReport(report.Finish(err)); reportErr != nil && err == nil {
+			err = reportErr
+		}
+		s.writeMetrics()
+	}()
+
+	var ordered []string
+	if ordered, err = OrderTiles(tiles); err != nil {
+		return
+	}
+	manifest = &BackupManifest{
+		BackupID: s.BackupID,
+		Action:   actionName(action),
+		Started:  time.Now().UTC(),
+	}
+
+	for _, tile := range ordered {
+		if err != nil {
+			manifest.Tiles = append(manifest.Tiles, TileResult{Name: tile, Status: TileStatusSkipped})
+			continue
+		}
+		result := TileResult{Name: tile, Started: time.Now().UTC()}
+		lo.G.Infof("%s of tile %s in backup %s started", manifest.Action, tile, s.BackupID)
+		s.Context.TileStarted(tile)
+		err = s.runTile(action, tile)
+		s.Context.TileFinished(tile, err)
+		observeTile(s.TileSpec.Metrics, manifest.Action, tile, result.Started, err)
+		if err != nil {
+			result.Status, result.Error = TileStatusFailed, err.Error()
+			err = errwrap.Wrap(err, fmt.Sprintf("%s of tile %s failed", manifest.Action, tile))
+		} else {
+			result.Status = TileStatusSucceeded
+		}
+		result.Finished = time.Now().UTC()
+		lo.G.Infof("%s of tile %s in backup %s %s", manifest.Action, tile, s.BackupID, result.Status)
+		manifest.Tiles = append(manifest.Tiles, result)
+	}
+	manifest.Finished = time.Now().UTC()
+	return
+}
+
+func (s *Orchestrator) runTile(action int, name string) (err error) {
+	var tile TileCloser
+	tileSpec := s.TileSpec
+	tileSpec.ArchiveDirectory = s.TileDir(name)
+	tileSpec.Progress = s.Context.Progress
+
+	if tile, err = Repo[name].New(tileSpec); err != nil {
+		return
+	}
+	defer tile.Close()
+	tile = tileSpec.Hooked(name, tile)
+
+	switch action {
+	case cfbackup.ExportArchive:
+		err = tile.Backup()
+	case cfbackup.ImportArchive:
+		err = tile.Restore()
+	}
+	return
+}
+
+//newReport - a report of the run, which the tiles report their progress to
+//while it runs, passed on to the progress reporter and the metrics of the
+//tile spec
+func (s *Orchestrator) newReport(action int) *cfbackup.RunReport {
+	s.Report = newRunReport(action, s.TileSpec, s.TileSpec.Progress)
+	s.Report.BackupID = s.BackupID
+	s.Context.Metrics = s.TileSpec.Metrics
+	s.Context.Progress = s.Report
+	return s.Report
+}
+
+//writeReport - keeps the report with the backup id and hands it to the
+//report writer, the progress reporter of the tile spec gets the events again
+func (s *Orchestrator) writeReport(report *cfbackup.RunReport) (err error) {
+	s.Context.Progress = s.TileSpec.Progress
+	reportPath := path.Join(s.TileSpec.ArchiveDirectory, s.BackupID, fmt.Sprintf(cfbackup.RunReportFileName, report.Action))
+	if err = report.Write(s.Context, reportPath, s.ReportWriter); err != nil {
+		lo.G.Error("failed writing the run report: ", err)
+	}
+	return
+}
+
+//writeMetrics - writes the metrics of the tile spec to the textfile, when
+//there is one and the tile spec has metrics
+func (s *Orchestrator) writeMetrics() {
+	if metrics := s.TileSpec.Metrics; metrics != nil && s.MetricsTextfile != "" {
+		if err := metrics.Registry.WriteTextfile(s.MetricsTextfile); err != nil {
+			lo.G.Error("failed writing the metrics textfile: ", err)
+		}
+	}
+}
+
+func (s *Orchestrator) manifestPath() string {
+	return path.Join(s.TileSpec.ArchiveDirectory, s.BackupID, OrchestratorManifestFileName)
+}
+
+func (s *Orchestrator) writeManifest(manifest *BackupManifest) (err error) {
+	var writer io.WriteCloser
+	if writer, err = s.Context.Writer(s.manifestPath()); err != nil {
+		return errwrap.Wrap(err, "failed creating the backup manifest")
+	}
+	defer writer.Close()
+	if err = json.NewEncoder(writer).Encode(manifest); err != nil {
+		return errwrap.Wrap(err, "failed writing the backup manifest")
+	}
+	return
+}
+
+func (s *Orchestrator) readManifest() (manifest *BackupManifest, err error) {
+	var reader io.ReadCloser
+	if reader, err = s.Context.Reader(s.manifestPath()); err != nil {
+		return nil, errwrap.Wrap(err, "failed opening the backup manifest")
+	}
+	defer reader.Close()
+	manifest = new(BackupManifest)
+	if err = json.NewDecoder(reader).Decode(manifest); err != nil {
+		return nil, errwrap.Wrap(err, "unable to unmarshal the backup manifest")
+	}
+	return
+}
+
+//OrderTiles - the tiles ordered so every tile comes after the tiles it
+//depends on, otherwise keeping the given order. dependencies outside of the
+//tiles are left alone
+func OrderTiles(tiles []string) (ordered []string, err error) {
+	if len(tiles) == 0 {
+		return nil, ErrNoTiles
+	}
+	wanted := make(map[string]bool)
+	for _, tile := range tiles {
+		if _, ok := Repo[tile]; !ok {
+			return nil, errwrap.Wrap(ErrTileNotRegistered, tile)
+		}
+		wanted[tile] = true
+	}
+
+	done := make(map[string]bool)
+	for len(ordered) < len(wanted) {
+		progressed := false
+		for _, tile := range tiles {
+			if done[tile] || !dependenciesDone(tile, wanted, done) {
+				continue
+			}
+			done[tile] = true
+			ordered = append(ordered, tile)
+			progressed = true
+		}
+		if !progressed {
+			return nil, ErrTileDependencyCycle
+		}
+	}
+	return
+}
+
+func dependenciesDone(tile string, wanted, done map[string]bool) bool {
+	for _, dependency := range Dependencies[tile] {
+		if wanted[dependency] && !done[dependency] {
+			return false
+		}
+	}
+	return true
+}
+
+func actionName(action int) string {
+	if action == cfbackup.ImportArchive {
+		return "restore"
+	}
+	return "backup"
+}
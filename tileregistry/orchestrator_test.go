@@ -0,0 +1,314 @@
+package tileregistry_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/metrics"
+	. "github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tileregistry/fake"
+)
+
+var _ = Describe("Orchestrator", func() {
+	var (
+		tmpDir     string
+		calls      []string
+		generators map[string]*fake.TileGenerator
+		tiles      map[string]*fake.Tile
+		closers    map[string]*fake.Closer
+	)
+
+	register := func(name string, dependsOn ...string) {
+		tiles[name] = &fake.Tile{Name: name, Calls: &calls}
+		closers[name] = new(fake.Closer)
+		generators[name] = &fake.TileGenerator{TileSpy: tiles[name], Closer: closers[name]}
+		Register(name, generators[name], dependsOn...)
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cfbackup-orchestrator")
+		Ω(err).ShouldNot(HaveOccurred())
+		calls = nil
+		generators = make(map[string]*fake.TileGenerator)
+		tiles = make(map[string]*fake.Tile)
+		closers = make(map[string]*fake.Closer)
+
+		register("ops-manager")
+		register("elastic-runtime", "ops-manager")
+		register("p-mysql", "ops-manager", "elastic-runtime")
+		register("p-redis", "elastic-runtime")
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+		Repo = make(map[string]TileGenerator)
+		Dependencies = make(map[string][]string)
+	})
+
+	Describe("OrderTiles", func() {
+		It("then it should put every tile after its dependencies", func() {
+			ordered, err := OrderTiles([]string{"p-redis", "p-mysql", "elastic-runtime", "ops-manager"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ordered).Should(Equal([]string{"ops-manager", "elastic-runtime", "p-redis", "p-mysql"}))
+		})
+
+		It("then it should ignore dependencies outside of the tiles", func() {
+			ordered, err := OrderTiles([]string{"p-mysql", "elastic-runtime"})
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(ordered).Should(Equal([]string{"elastic-runtime", "p-mysql"}))
+		})
+
+		It("then it should fail for a tile which is not registered", func() {
+			_, err := OrderTiles([]string{"ops-manager", "p-unknown"})
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring(ErrTileNotRegistered.Error()))
+		})
+
+		It("then it should fail without tiles", func() {
+			_, err := OrderTiles(nil)
+			Ω(err).Should(Equal(ErrNoTiles))
+		})
+
+		Context("when the tiles depend on each other", func() {
+			BeforeEach(func() {
+				Dependencies["ops-manager"] = []string{"p-redis"}
+			})
+
+			It("then it should fail", func() {
+				_, err := OrderTiles([]string{"ops-manager", "elastic-runtime", "p-redis"})
+				Ω(err).Should(Equal(ErrTileDependencyCycle))
+			})
+		})
+	})
+
+	Describe("Backup", func() {
+		var orchestrator *Orchestrator
+
+		BeforeEach(func() {
+			orchestrator = NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, []string{"p-mysql", "elastic-runtime", "ops-manager"}, "backup-1")
+		})
+
+		It("then it should back up the tiles in dependency order", func() {
+			_, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(calls).Should(Equal([]string{"backup ops-manager", "backup elastic-runtime", "backup p-mysql"}))
+		})
+
+		It("then it should give every tile its own dir below the backup id", func() {
+			_, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(generators["p-mysql"].TileSpecs).Should(HaveLen(1))
+			Ω(generators["p-mysql"].TileSpecs[0].ArchiveDirectory).Should(Equal(path.Join(tmpDir, "backup-1", "p-mysql")))
+		})
+
+		It("then it should close every tile", func() {
+			_, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			for _, name := range []string{"ops-manager", "elastic-runtime", "p-mysql"} {
+				Ω(closers[name].Executions).Should(Equal(1))
+			}
+		})
+
+		It("then it should write the manifest of the backup id", func() {
+			manifest, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(manifest.BackupID).Should(Equal("backup-1"))
+			Ω(manifest.Action).Should(Equal("backup"))
+
+			written, err := ioutil.ReadFile(path.Join(tmpDir, "backup-1", OrchestratorManifestFileName))
+			Ω(err).ShouldNot(HaveOccurred())
+			var read BackupManifest
+			Ω(json.Unmarshal(written, &read)).Should(Succeed())
+			Ω(read.Tiles).Should(HaveLen(3))
+			for _, result := range read.Tiles {
+				Ω(result.Status).Should(Equal(TileStatusSucceeded))
+			}
+		})
+
+		It("then it should generate a backup id when there is none", func() {
+			orchestrator.BackupID = ""
+			manifest, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(manifest.BackupID).ShouldNot(BeEmpty())
+			_, err = os.Stat(path.Join(tmpDir, manifest.BackupID, OrchestratorManifestFileName))
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("then it should report the progress of every tile", func() {
+			var events []string
+			orchestrator.TileSpec.Progress = cfbackup.ProgressFunc(func(event cfbackup.ProgressEvent) {
+				events = append(events, event.Type+" "+event.Step)
+			})
+			_, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(events).Should(Equal([]string{
+				"tile-started ops-manager", "tile-finished ops-manager",
+				"tile-started elastic-runtime", "tile-finished elastic-runtime",
+				"tile-started p-mysql", "tile-finished p-mysql",
+			}))
+			Ω(generators["p-mysql"].TileSpecs[0].Progress).Should(Equal(orchestrator.Report))
+		})
+
+		It("then it should write the report of the run with the backup id and to the report writer", func() {
+			var b bytes.Buffer
+			orchestrator.ReportWriter = &b
+			_, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+
+			written, err := ioutil.ReadFile(path.Join(tmpDir, "backup-1", "backup-report.json"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(b.Bytes()).Should(Equal(written))
+			var report cfbackup.RunReport
+			Ω(json.Unmarshal(written, &report)).Should(Succeed())
+			Ω(report.Action).Should(Equal("backup"))
+			Ω(report.BackupID).Should(Equal("backup-1"))
+			Ω(report.Tiles).Should(HaveLen(3))
+			Ω(report.Tiles[2].Name).Should(Equal("p-mysql"))
+			Ω(report.Error).Should(BeEmpty())
+		})
+
+		It("then it should run the hooks of every tile around its backup", func() {
+			orchestrator.TileSpec.Hooks = Hooks{{
+				Point: HookPostBackup,
+				Tiles: []string{"elastic-runtime"},
+				Hook: HookFunc(func(event HookEvent) error {
+					calls = append(calls, event.Point+" "+event.Tile+" "+event.ArchiveDirectory)
+					return nil
+				}),
+			}}
+			_, err := orchestrator.Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(calls).Should(Equal([]string{
+				"backup ops-manager",
+				"backup elastic-runtime",
+				"post-backup elastic-runtime " + path.Join(tmpDir, "backup-1", "elastic-runtime"),
+				"backup p-mysql",
+			}))
+		})
+
+		Context("when the tile spec has metrics", func() {
+			var observed *cfbackup.Metrics
+
+			BeforeEach(func() {
+				observed = cfbackup.NewMetrics(metrics.NewRegistry())
+				orchestrator.TileSpec.Metrics = observed
+				orchestrator.MetricsTextfile = path.Join(tmpDir, "cfbackup.prom")
+				tiles["elastic-runtime"].ErrFake = errors.New("director unreachable")
+			})
+
+			It("then it should keep the metrics of every tile run", func() {
+				orchestrator.Backup()
+				Ω(observed.TileLastSuccess.Value("ops-manager", "backup")).ShouldNot(BeZero())
+				Ω(observed.TileRuns.Value("ops-manager", "backup", "succeeded")).Should(Equal(float64(1)))
+				Ω(observed.TileRuns.Value("elastic-runtime", "backup", "failed")).Should(Equal(float64(1)))
+				Ω(observed.TileRuns.Value("p-mysql", "backup", "succeeded")).Should(BeZero())
+				Ω(observed.Errors.Value(cfbackup.MetricsErrorTile)).Should(Equal(float64(1)))
+			})
+
+			It("then it should write the metrics textfile", func() {
+				orchestrator.Backup()
+				written, err := ioutil.ReadFile(orchestrator.MetricsTextfile)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(string(written)).Should(ContainSubstring(`cfbackup_tile_runs_total{tile="elastic-runtime",action="backup",status="failed"} 1`))
+			})
+		})
+
+		Context("when a tile fails", func() {
+			BeforeEach(func() {
+				tiles["elastic-runtime"].ErrFake = errors.New("director unreachable")
+			})
+
+			It("then it should stop and report the result of every tile", func() {
+				manifest, err := orchestrator.Backup()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("director unreachable"))
+				Ω(calls).Should(Equal([]string{"backup ops-manager", "backup elastic-runtime"}))
+				Ω(manifest.Tiles).Should(HaveLen(3))
+				Ω(manifest.Tiles[0].Status).Should(Equal(TileStatusSucceeded))
+				Ω(manifest.Tiles[1].Status).Should(Equal(TileStatusFailed))
+				Ω(manifest.Tiles[1].Error).Should(Equal("director unreachable"))
+				Ω(manifest.Tiles[2]).Should(Equal(TileResult{Name: "p-mysql", Status: TileStatusSkipped}))
+			})
+
+			It("then it should report the error of the run", func() {
+				orchestrator.Backup()
+				Ω(orchestrator.Report.Error).Should(ContainSubstring("director unreachable"))
+				Ω(orchestrator.Report.Tiles[1].Error).Should(Equal("director unreachable"))
+				_, err := os.Stat(path.Join(tmpDir, "backup-1", "backup-report.json"))
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+
+			It("then it should still write the manifest", func() {
+				orchestrator.Backup()
+				_, err := os.Stat(path.Join(tmpDir, "backup-1", OrchestratorManifestFileName))
+				Ω(err).ShouldNot(HaveOccurred())
+			})
+		})
+
+		Context("when a tile can not be created", func() {
+			BeforeEach(func() {
+				generators["ops-manager"].ErrFake = errors.New("no credentials")
+			})
+
+			It("then it should run no tile", func() {
+				_, err := orchestrator.Backup()
+				Ω(err).Should(HaveOccurred())
+				Ω(calls).Should(BeEmpty())
+			})
+		})
+	})
+
+	Describe("Restore", func() {
+		BeforeEach(func() {
+			_, err := NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, []string{"p-redis", "ops-manager", "elastic-runtime"}, "backup-1").Backup()
+			Ω(err).ShouldNot(HaveOccurred())
+			calls = nil
+		})
+
+		It("then it should restore the tiles of the manifest in dependency order", func() {
+			manifest, err := NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, nil, "backup-1").Restore()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(manifest.Action).Should(Equal("restore"))
+			Ω(calls).Should(Equal([]string{"restore ops-manager", "restore elastic-runtime", "restore p-redis"}))
+		})
+
+		It("then it should restore only the given tiles", func() {
+			_, err := NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, []string{"p-redis"}, "backup-1").Restore()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(calls).Should(Equal([]string{"restore p-redis"}))
+			Ω(generators["p-redis"].TileSpecs[1].ArchiveDirectory).Should(Equal(path.Join(tmpDir, "backup-1", "p-redis")))
+		})
+
+		It("then it should fail without a backup id", func() {
+			_, err := NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, nil, "").Restore()
+			Ω(err).Should(Equal(ErrNoBackupID))
+		})
+
+		It("then it should fail for a backup id without a manifest", func() {
+			_, err := NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, nil, "backup-2").Restore()
+			Ω(err).Should(HaveOccurred())
+		})
+
+		Context("when a tile fails", func() {
+			BeforeEach(func() {
+				tiles["ops-manager"].ErrFake = errors.New("import failed")
+			})
+
+			It("then it should not restore the tiles depending on it", func() {
+				manifest, err := NewOrchestrator(TileSpec{ArchiveDirectory: tmpDir}, nil, "backup-1").Restore()
+				Ω(err).Should(HaveOccurred())
+				Ω(calls).Should(Equal([]string{"restore ops-manager"}))
+				Ω(manifest.Tiles[1].Status).Should(Equal(TileStatusSkipped))
+				Ω(manifest.Tiles[2].Status).Should(Equal(TileStatusSkipped))
+			})
+		})
+	})
+})
@@ -0,0 +1,114 @@
+package tileregistry
+
+import (
+	"fmt"
+	"path"
+	"time"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/pivotalservices/cfbackup"
+	"github.com/xchapter7x/lo"
+)
+
+//Build - builds the tile of the name with the build func, hooked and, when
+//it is run on its own, reporting every backup and restore. a tile spec which
+//reports to a run report already, as the one the Orchestrator builds its
+//tiles with, leaves the report to it
+func (s TileSpec) Build(name string, build func(tileSpec TileSpec) (TileCloser, error)) (tile TileCloser, err error) {
+	if _, reported := s.Progress.(*cfbackup.RunReport); reported {
+		if tile, err = build(s); err == nil {
+			tile = s.Hooked(name, tile)
+		}
+		return
+	}
+	progress := &reportProgress{forward: s.Progress}
+	s.Progress = progress
+	if tile, err = build(s); err != nil {
+		return
+	}
+	tile = &ReportedTile{
+		TileCloser: s.Hooked(name, tile),
+		Name:       name,
+		TileSpec:   s,
+		progress:   progress,
+	}
+	return
+}
+
+//Backup - backs up the tile and writes the report of the backup
+func (s *ReportedTile) Backup() error {
+	return s.run(cfbackup.ExportArchive, s.TileCloser.Backup)
+}
+
+//Restore - restores the tile and writes the report of the restore
+func (s *ReportedTile) Restore() error {
+	return s.run(cfbackup.ImportArchive, s.TileCloser.Restore)
+}
+
+func (s *ReportedTile) run(action int, run func() error) (err error) {
+	context := cfbackup.NewBackupContext(s.TileSpec.ArchiveDirectory, cfenv.CurrentEnv(), s.TileSpec.CryptKey)
+	s.Report = newRunReport(action, s.TileSpec, s.progress.forward)
+	context.Progress = s.Report
+	context.Metrics = s.TileSpec.Metrics
+	s.progress.reportTo(s.Report)
+	defer s.progress.reportTo(nil)
+
+	started := time.Now()
+	context.TileStarted(s.Name)
+	err = run()
+	context.TileFinished(s.Name, err)
+	observeTile(s.TileSpec.Metrics, s.Report.Action, s.Name, started, err)
+
+	reportPath := path.Join(s.TileSpec.ArchiveDirectory, fmt.Sprintf(cfbackup.RunReportFileName, s.Report.Action))
+	if reportErr := s.Report.Finish(err).Write(context, reportPath, s.TileSpec.ReportWriter); reportErr != nil {
+		lo.G.Error("failed writing the run report: ", reportErr)
+		if err == nil {
+			err = reportErr
+		}
+	}
+	return
+}
+
+//Report - passes the event on to the report of the run under way, to the
+//progress reporter of the tile spec otherwise
+func (s *reportProgress) Report(event cfbackup.ProgressEvent) {
+	s.mutex.Lock()
+	reporter := cfbackup.ProgressReporter(s.report)
+	if s.report == nil {
+		reporter = s.forward
+	}
+	s.mutex.Unlock()
+	if reporter != nil {
+		reporter.Report(event)
+	}
+}
+
+func (s *reportProgress) reportTo(report *cfbackup.RunReport) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.report = report
+}
+
+//newRunReport - a report of the action on the foundation of the tile spec,
+//passing the events on to the progress reporter and the metrics of the tile
+//spec
+func newRunReport(action int, tileSpec TileSpec, progress cfbackup.ProgressReporter) (report *cfbackup.RunReport) {
+	var forward cfbackup.ProgressReporters
+	if progress != nil {
+		forward = append(forward, progress)
+	}
+	if tileSpec.Metrics != nil {
+		forward = append(forward, tileSpec.Metrics)
+	}
+	report = cfbackup.NewRunReport(actionName(action), forward)
+	report.Foundation.OpsManagerHost = tileSpec.OpsManagerHost
+	return
+}
+
+//observeTile - keeps the metrics of the backup or restore of the tile, when
+//there are metrics
+func observeTile(metrics *cfbackup.Metrics, action, tile string, started time.Time, err error) {
+	if metrics != nil {
+		metrics.ObserveTile(action, tile, time.Since(started), err)
+	}
+}
@@ -0,0 +1,119 @@
+package tileregistry_test
+
+import (
+	"bytes"
+	"encoding/json"
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/metrics"
+	. "github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tileregistry/fake"
+)
+
+type reportingTile struct {
+	*fake.Tile
+	progress cfbackup.ProgressReporter
+}
+
+func (s reportingTile) Backup() error {
+	s.progress.Report(cfbackup.ProgressEvent{Type: cfbackup.ProgressWarning, Message: "dumping"})
+	return s.Tile.Backup()
+}
+
+var _ = Describe("ReportedTile", func() {
+	var (
+		tmpDir    string
+		tile      *fake.Tile
+		forwarded []cfbackup.ProgressEvent
+		written   bytes.Buffer
+		tileSpec  TileSpec
+	)
+
+	build := func(tileSpec TileSpec) (TileCloser, error) {
+		return struct {
+			Tile
+			Closer
+		}{reportingTile{tile, tileSpec.Progress}, new(fake.Closer)}, nil
+	}
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cfbackup-reported")
+		Ω(err).ShouldNot(HaveOccurred())
+		tile = &fake.Tile{Name: "p-mysql"}
+		forwarded = nil
+		written.Reset()
+		tileSpec = TileSpec{
+			ArchiveDirectory: tmpDir,
+			OpsManagerHost:   "opsman.example.com",
+			ReportWriter:     &written,
+			Progress: cfbackup.ProgressFunc(func(event cfbackup.ProgressEvent) {
+				forwarded = append(forwarded, event)
+			}),
+		}
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Context("when a tile is built to run on its own", func() {
+		It("then it should keep the report of the backup in the archive directory", func() {
+			reported, err := tileSpec.Build("p-mysql", build)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(reported.Backup()).Should(Succeed())
+			stored, err := ioutil.ReadFile(path.Join(tmpDir, "backup-report.json"))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(stored).Should(Equal(written.Bytes()))
+			var report cfbackup.RunReport
+			Ω(json.Unmarshal(stored, &report)).Should(Succeed())
+			Ω(report.Action).Should(Equal("backup"))
+			Ω(report.Foundation.OpsManagerHost).Should(Equal("opsman.example.com"))
+			Ω(report.Tiles).Should(HaveLen(1))
+			Ω(report.Tiles[0].Name).Should(Equal("p-mysql"))
+			Ω(report.Warnings).Should(Equal([]string{"dumping"}))
+		})
+
+		It("then it should pass the progress of the tile on to the tile spec", func() {
+			reported, _ := tileSpec.Build("p-mysql", build)
+			reported.Backup()
+			Ω(forwarded).Should(HaveLen(3))
+			Ω(forwarded[0].Type).Should(Equal(cfbackup.ProgressTileStarted))
+			Ω(forwarded[1].Message).Should(Equal("dumping"))
+			Ω(forwarded[2].Type).Should(Equal(cfbackup.ProgressTileFinished))
+		})
+
+		It("then it should keep the metrics of the tile in the metrics of the tile spec", func() {
+			tileSpec.Metrics = cfbackup.NewMetrics(metrics.NewRegistry())
+			reported, _ := tileSpec.Build("p-mysql", build)
+			Ω(reported.Backup()).Should(Succeed())
+			Ω(tileSpec.Metrics.TileLastSuccess.Value("p-mysql", "backup")).ShouldNot(BeZero())
+			Ω(tileSpec.Metrics.TileRuns.Value("p-mysql", "backup", "succeeded")).Should(Equal(float64(1)))
+			Ω(tileSpec.Metrics.Warnings.Value()).Should(Equal(float64(1)))
+		})
+
+		It("then it should report the failure of the tile", func() {
+			tile.ErrFake = errors.New("dump failed")
+			reported, _ := tileSpec.Build("p-mysql", build)
+			Ω(reported.Restore()).Should(Equal(tile.ErrFake))
+			Ω(reported.(*ReportedTile).Report.Error).Should(Equal("dump failed"))
+			Ω(path.Join(tmpDir, "restore-report.json")).Should(BeAnExistingFile())
+		})
+	})
+
+	Context("when the tile spec reports to a run report already", func() {
+		It("then it should leave the report to it", func() {
+			tileSpec.Progress = cfbackup.NewRunReport("backup", nil)
+			built, err := tileSpec.Build("p-mysql", build)
+			Ω(err).ShouldNot(HaveOccurred())
+			_, reported := built.(*ReportedTile)
+			Ω(reported).Should(BeFalse())
+		})
+	})
+})
@@ -3,3 +3,10 @@ package tileregistry
 //Close - This is an empty closer that do nothing
 func (closer *DoNothingCloser) Close() {
 }
+
+//Close - closes every one of the closers
+func (s Closers) Close() {
+	for _, closer := range s {
+		closer.Close()
+	}
+}
@@ -0,0 +1,134 @@
+package tileregistry
+
+import (
+	"fmt"
+	"net"
+	"strconv"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/gtils/command"
+)
+
+//ValidateAuth - checks the tile spec carries a usable set of ops manager
+//credentials: a token, a uaa client and its secret, or an admin user and
+//password. a client takes precedence over the admin user, which is how an
+//ops manager using saml is reached
+func (s TileSpec) ValidateAuth() error {
+	switch {
+	case s.AdminToken != "":
+		return nil
+	case s.ClientID != "" && s.ClientSecret == "":
+		return ErrClientSecretMissing
+	case s.ClientID == "" && s.ClientSecret != "":
+		return ErrClientIDMissing
+	case s.ClientID != "":
+		return nil
+	case s.AdminUser == "" || s.AdminPass == "":
+		return ErrNoOpsManagerAuth
+	}
+	return nil
+}
+
+//TLSConfig - the certificate verification asked for by the tile spec
+func (s TileSpec) TLSConfig() cfbackup.TLSConfig {
+	return cfbackup.TLSConfig{
+		CABundle: s.CABundle,
+		Strict:   s.StrictTLS,
+		Proxy:    s.Proxy,
+	}
+}
+
+//ConfigureContext - points the connections of the backup context at the
+//proxy, the certificates and the ssh jump hosts of the tile spec and its
+//progress at the progress reporter of the tile spec. the tunnels through the
+//jump hosts are opened as the vms are reached, the tile closes them
+func (s TileSpec) ConfigureContext(context *cfbackup.BackupContext) (err error) {
+	var chain []command.SshConfig
+	if chain, err = s.JumpHostChain(); err != nil {
+		return
+	}
+	context.TLS = s.TLSConfig()
+	context.DirectorTLS = context.TLS
+	context.Progress = s.Progress
+	context.Metrics = s.Metrics
+	context.SSHTunnels = cfbackup.NewSSHTunnels(chain)
+	if storage, ok := context.StorageProvider.(cfbackup.TLSConfigured); ok {
+		storage.SetTLSConfig(context.TLS)
+	}
+	return
+}
+
+//Hooked - the tile, running the hooks of the tile spec for the tile name
+//around its backup and restore. a tile without hooks, or one which is hooked
+//already, is returned as it is
+func (s TileSpec) Hooked(name string, tile TileCloser) TileCloser {
+	if _, hooked := tile.(*HookedTile); hooked {
+		return tile
+	}
+	hooks := s.Hooks.For(name)
+	if len(hooks) == 0 {
+		return tile
+	}
+	hookedTile := NewHookedTile(name, tile, hooks, s.ArchiveDirectory)
+	hookedTile.Progress = s.Progress
+	return hookedTile
+}
+
+//JumpHostChain - the ssh jump hosts the vms are reached through. the
+//JumpHosts of the spec, the ops manager vm when JumpViaOpsManager is set and
+//none otherwise, the vms are dialed directly
+func (s TileSpec) JumpHostChain() (chain []command.SshConfig, err error) {
+	if s.JumpHosts == "" {
+		if s.JumpViaOpsManager {
+			chain = append(chain, command.SshConfig{
+				Username: s.OpsManagerUser,
+				Password: s.OpsManagerPass,
+				Host:     hostOnly(s.OpsManagerHost),
+				Port:     22,
+				SSLKey:   s.JumpHostKey,
+			})
+		}
+		return
+	}
+
+	for _, hop := range strings.Split(s.JumpHosts, ",") {
+		var config command.SshConfig
+		if config, err = parseJumpHost(strings.TrimSpace(hop)); err != nil {
+			return nil, err
+		}
+		config.SSLKey = s.JumpHostKey
+		chain = append(chain, config)
+	}
+	return
+}
+
+//parseJumpHost - parses a user[:password]@host[:port] jump host
+func parseJumpHost(hop string) (config command.SshConfig, err error) {
+	at := strings.LastIndex(hop, "@")
+	if at < 1 || at == len(hop)-1 {
+		return config, fmt.Errorf("invalid jump host %q, expected user[:password]@host[:port]", hop)
+	}
+	userInfo, address := hop[:at], hop[at+1:]
+
+	config.Username = userInfo
+	if i := strings.Index(userInfo, ":"); i >= 0 {
+		config.Username, config.Password = userInfo[:i], userInfo[i+1:]
+	}
+
+	config.Host, config.Port = address, 22
+	if host, port, splitErr := net.SplitHostPort(address); splitErr == nil {
+		config.Host = host
+		if config.Port, err = strconv.Atoi(port); err != nil {
+			return config, fmt.Errorf("invalid port in jump host %q", hop)
+		}
+	}
+	return
+}
+
+func hostOnly(address string) string {
+	if host, _, err := net.SplitHostPort(address); err == nil {
+		return host
+	}
+	return address
+}
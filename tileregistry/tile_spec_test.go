@@ -0,0 +1,120 @@
+package tileregistry_test
+
+import (
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup"
+	. "github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/gtils/command"
+)
+
+var _ = Describe("TileSpec", func() {
+	Describe("given: a ValidateAuth() method", func() {
+		It("then: it should accept a token", func() {
+			Ω(TileSpec{AdminToken: "token"}.ValidateAuth()).Should(Succeed())
+		})
+
+		It("then: it should accept a client id and secret without an admin user", func() {
+			Ω(TileSpec{ClientID: "backup-client", ClientSecret: "backup-secret"}.ValidateAuth()).Should(Succeed())
+		})
+
+		It("then: it should accept an admin user and password", func() {
+			Ω(TileSpec{AdminUser: "admin", AdminPass: "admin-password"}.ValidateAuth()).Should(Succeed())
+		})
+
+		It("then: it should reject a client id without its secret", func() {
+			Ω(TileSpec{ClientID: "backup-client", AdminUser: "admin", AdminPass: "admin-password"}.ValidateAuth()).Should(Equal(ErrClientSecretMissing))
+		})
+
+		It("then: it should reject a client secret without a client id", func() {
+			Ω(TileSpec{ClientSecret: "backup-secret"}.ValidateAuth()).Should(Equal(ErrClientIDMissing))
+		})
+
+		It("then: it should reject a spec without credentials", func() {
+			Ω(TileSpec{}.ValidateAuth()).Should(Equal(ErrNoOpsManagerAuth))
+			Ω(TileSpec{AdminUser: "admin"}.ValidateAuth()).Should(Equal(ErrNoOpsManagerAuth))
+		})
+	})
+
+	Describe("given: a TLSConfig() method", func() {
+		It("then: it should carry the ca bundle and strict flag", func() {
+			config := TileSpec{CABundle: "/etc/ssl/ca.pem", StrictTLS: true}.TLSConfig()
+			Ω(config.CABundle).Should(Equal("/etc/ssl/ca.pem"))
+			Ω(config.Strict).Should(BeTrue())
+		})
+
+		It("then it should carry the proxy", func() {
+			Ω(TileSpec{Proxy: "socks5://proxy.example.com:1080"}.TLSConfig().Proxy).Should(Equal("socks5://proxy.example.com:1080"))
+		})
+	})
+
+	Describe("given: a ConfigureContext() method", func() {
+		It("then: it should give the context the tls config and jump hosts of the spec", func() {
+			var context cfbackup.BackupContext
+			Ω(TileSpec{OpsManagerHost: "opsman.example.com", OpsManagerUser: "ubuntu", JumpViaOpsManager: true, StrictTLS: true}.ConfigureContext(&context)).Should(Succeed())
+			Ω(context.TLS.Strict).Should(BeTrue())
+			Ω(context.DirectorTLS.Strict).Should(BeTrue())
+			Ω(context.SSHTunnels.JumpHosts).Should(Equal([]command.SshConfig{
+				{Username: "ubuntu", Host: "opsman.example.com", Port: 22},
+			}))
+		})
+
+		It("then it should reach s3 through the proxy of the spec", func() {
+			s3 := cfbackup.NewS3Provider("s3.amazonaws.com", "key", "secret", "backups").(*cfbackup.S3Provider)
+			encrypted, err := cfbackup.NewEncryptedStorageProvider(s3, "0123456789abcdef")
+			Ω(err).ShouldNot(HaveOccurred())
+			context := cfbackup.BackupContext{IsS3: true, StorageProvider: encrypted}
+			Ω(TileSpec{Proxy: "http://proxy.example.com:3128", CABundle: "/etc/ssl/ca.pem"}.ConfigureContext(&context)).Should(Succeed())
+			Ω(s3.TLS.Proxy).Should(Equal("http://proxy.example.com:3128"))
+			Ω(s3.TLS.CABundle).Should(Equal("/etc/ssl/ca.pem"))
+		})
+
+		It("then: it should fail for an invalid jump host", func() {
+			var context cfbackup.BackupContext
+			Ω(TileSpec{JumpHosts: "10.0.0.1"}.ConfigureContext(&context)).ShouldNot(Succeed())
+		})
+	})
+
+	Describe("given: a JumpHostChain() method", func() {
+		It("then: it should dial the vms directly by default", func() {
+			chain, err := TileSpec{
+				OpsManagerHost: "opsman.example.com",
+				OpsManagerUser: "ubuntu",
+				OpsManagerPass: "ubuntu-pass",
+			}.JumpHostChain()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(chain).Should(BeEmpty())
+		})
+
+		It("then: it should go through the ops manager vm as a bastion when asked to", func() {
+			chain, err := TileSpec{
+				OpsManagerHost:    "opsman.example.com:443",
+				OpsManagerUser:    "ubuntu",
+				OpsManagerPass:    "ubuntu-pass",
+				JumpHostKey:       "/home/backup/.ssh/opsman",
+				JumpViaOpsManager: true,
+			}.JumpHostChain()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(chain).Should(Equal([]command.SshConfig{
+				{Username: "ubuntu", Password: "ubuntu-pass", Host: "opsman.example.com", Port: 22, SSLKey: "/home/backup/.ssh/opsman"},
+			}))
+		})
+
+		It("then: it should parse a chain of jump hosts", func() {
+			chain, err := TileSpec{
+				JumpHosts:   "jump:jump-pass@10.0.0.1:2222, ubuntu@opsman.example.com",
+				JumpHostKey: "/home/backup/.ssh/id_rsa",
+			}.JumpHostChain()
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(chain).Should(Equal([]command.SshConfig{
+				{Username: "jump", Password: "jump-pass", Host: "10.0.0.1", Port: 2222, SSLKey: "/home/backup/.ssh/id_rsa"},
+				{Username: "ubuntu", Host: "opsman.example.com", Port: 22, SSLKey: "/home/backup/.ssh/id_rsa"},
+			}))
+		})
+
+		It("then: it should reject a jump host without a user", func() {
+			_, err := TileSpec{JumpHosts: "10.0.0.1"}.JumpHostChain()
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
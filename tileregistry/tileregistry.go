@@ -1,8 +1,10 @@
 package tileregistry
 
-//Register -- add a Sku interface object to the Repo
-func Register(name string, tile TileGenerator) {
+//Register -- add a Sku interface object to the Repo, along with the tiles it
+//is backed up and restored after
+func Register(name string, tile TileGenerator, dependsOn ...string) {
 	Repo[name] = tile
+	Dependencies[name] = dependsOn
 }
 
 //GetRegistry -- gets the map of all registered Sku interface objects
@@ -1,5 +1,13 @@
 package tileregistry
 
+import (
+	"io"
+	"sync"
+	"time"
+
+	"github.com/pivotalservices/cfbackup"
+)
+
 type (
 	//TileGenerator - interface for a tile creating object
 	TileGenerator interface {
@@ -16,6 +24,9 @@ type (
 		Close()
 	}
 
+	//Closers - closes every one of the closers, in order
+	Closers []Closer
+
 	//TileCloser - defines how to close a tile
 	TileCloser interface {
 		Tile
@@ -41,5 +52,148 @@ type (
 		ClearBoshManifest    bool
 		PluginArgs           string
 		NFS                  string
+		CCToggleStrategy     string
+		QuiesceJobs          string
+		DirectorBackup       bool
+		ForceDirectorRestore bool
+		ApplyChanges         bool
+		ImportTimeout        time.Duration
+		InstallTimeout       time.Duration
+		//CABundle - a pem file or inline pem trusted for ops manager, its uaa
+		//and the director, turns certificate verification on
+		CABundle string
+		//StrictTLS - verify certificates against the system roots and the
+		//CABundle
+		StrictTLS bool
+		//Proxy - the http, https or socks5 proxy url ops manager, its uaa,
+		//the director, the tile apis and s3 are called through
+		Proxy string
+		//JumpViaOpsManager - reach the vms over ssh through the ops manager
+		//vm, as the OpsManagerUser with the OpsManagerPass or JumpHostKey,
+		//rather than dialing them directly
+		JumpViaOpsManager bool
+		//JumpHosts - a comma separated chain of user[:password]@host[:port]
+		//jump hosts the vms are reached through instead
+		JumpHosts string
+		//JumpHostKey - a pem file or inline pem private key for the jump hosts
+		JumpHostKey string
+		//MnesiaSnapshot - also back up the mnesia database of every rabbitmq
+		//node over ssh
+		MnesiaSnapshot bool
+		//Progress - gets the progress events of the tiles, none are sent
+		//when nil. kept from plugins, as are the hooks
+		Progress cfbackup.ProgressReporter `json:"-"`
+		//Hooks - custom actions run at points of the lifecycle of the tiles
+		Hooks Hooks `json:"-"`
+		//ReportWriter - also gets the report of every backup and restore of
+		//a tile run on its own, the Orchestrator has its own
+		ReportWriter io.Writer `json:"-"`
+		//Metrics - keeps the metrics of the backups and restores of the
+		//tiles, their storage and nfs, none are kept when nil
+		Metrics *cfbackup.Metrics `json:"-"`
+	}
+
+	//Hook - a custom action run at a point of the lifecycle of a tile
+	Hook interface {
+		Run(event HookEvent) error
+	}
+
+	//HookFunc - a go function run as a hook
+	HookFunc func(event HookEvent) error
+
+	//ExecHook - an executable run as a hook, the event is passed to it in
+	//its environment
+	ExecHook struct {
+		Path string
+		Args []string
+	}
+
+	//HookEvent - the point of the lifecycle of a tile a hook is run at
+	HookEvent struct {
+		Point            string
+		Tile             string
+		ArchiveDirectory string
+	}
+
+	//HookSpec - a hook, the point and tiles it is run for and what its
+	//failure does
+	HookSpec struct {
+		Point string
+		//Tiles - the tiles the hook is run for, all of them when empty
+		Tiles []string
+		Hook  Hook
+		//OnFailure - HookFailureAbort or HookFailureWarn, aborting when empty
+		OnFailure string
+	}
+
+	//Hooks - the hooks of a tile spec
+	Hooks []HookSpec
+
+	//HookedTile - runs the hooks of a tile around its backup and restore
+	HookedTile struct {
+		TileCloser
+		Name             string
+		Hooks            Hooks
+		ArchiveDirectory string
+		//Progress - gets the warnings of the hooks, none are sent when nil
+		Progress cfbackup.ProgressReporter
+	}
+
+	//ReportedTile - reports every backup and restore of a tile run on its
+	//own, keeping the run report in the archive directory of the tile
+	ReportedTile struct {
+		TileCloser
+		Name     string
+		TileSpec TileSpec
+		//Report - the report of the last run
+		Report   *cfbackup.RunReport
+		progress *reportProgress
+	}
+
+	//reportProgress - the progress reporter of a tile run on its own, passing
+	//the events on to the report of the run under way and to the progress
+	//reporter of the tile spec between runs
+	reportProgress struct {
+		mutex   sync.Mutex
+		report  *cfbackup.RunReport
+		forward cfbackup.ProgressReporter
+	}
+
+	//Orchestrator - backs up or restores a set of registered tiles in the
+	//order of their dependencies, every tile in its own dir below the dir of
+	//the backup id
+	Orchestrator struct {
+		TileSpec TileSpec
+		Tiles    []string
+		BackupID string
+		//Context - writes and reads the manifest and reports of the backup id
+		Context cfbackup.BackupContext
+		//ReportWriter - also gets the report of every run, left nil the
+		//report is only kept with the backup id
+		ReportWriter io.Writer
+		//Report - the report of the last run
+		Report *cfbackup.RunReport
+		//MetricsTextfile - where the metrics of the tile spec are written for
+		//the textfile collector of the node exporter after every run, when it
+		//has metrics
+		MetricsTextfile string
+	}
+
+	//BackupManifest - the tiles of a backup id and how running them went
+	BackupManifest struct {
+		BackupID string       `json:"backup_id"`
+		Action   string       `json:"action"`
+		Started  time.Time    `json:"started"`
+		Finished time.Time    `json:"finished"`
+		Tiles    []TileResult `json:"tiles"`
+	}
+
+	//TileResult - how backing up or restoring a tile went
+	TileResult struct {
+		Name     string    `json:"name"`
+		Status   string    `json:"status"`
+		Error    string    `json:"error,omitempty"`
+		Started  time.Time `json:"started"`
+		Finished time.Time `json:"finished"`
 	}
 )
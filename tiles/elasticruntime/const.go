@@ -6,6 +6,9 @@ import (
 )
 
 const (
+	//ElasticRuntimeTileName - the name the elastic runtime tile is registered
+	//and run its hooks under
+	ElasticRuntimeTileName = "elastic-runtime"
 	//ERDefaultSystemUser - default user for system vms
 	ERDefaultSystemUser = "vcap"
 	//ERDirectorInfoURL - url format for a director info endpoint
@@ -28,6 +31,8 @@ const (
 	ERNfs = "NfsInfo"
 	//ERBackupFileFormat -- format of archive filename
 	ERBackupFileFormat = "%s.backup"
+	//ERMetadataFileName -- name of the file holding the backup metadata
+	ERMetadataFileName = "metadata.json"
 	//ERInvalidDirectorCredsMsg -- error message for invalid creds on director
 	ERInvalidDirectorCredsMsg = "invalid director credentials"
 	//ERNoPersistenceArchives -- error message for persistence stores
@@ -36,6 +41,8 @@ const (
 	ERFileDoesNotExist = "file does not exist"
 	//ErrERDBBackupFailure -- error message for backup failure
 	ErrERDBBackupFailure = "failed to backup database"
+	//ERDirectorMismatchMsg -- error message for restoring onto another director
+	ERDirectorMismatchMsg = "backup was taken from a different director"
 )
 
 var (
@@ -47,4 +54,6 @@ var (
 	ErrERInvalidPath = &os.PathError{Err: errors.New(ERFileDoesNotExist)}
 	//ErrERDBBackup - error for db backup failures
 	ErrERDBBackup = errors.New(ErrERDBBackupFailure)
+	//ErrERDirectorMismatch - error for restoring a director backup onto another director
+	ErrERDirectorMismatch = errors.New(ERDirectorMismatchMsg)
 )
@@ -7,9 +7,11 @@ import (
 	"io/ioutil"
 	"os"
 	"path"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
 	"github.com/xchapter7x/lo"
 
 	errwrap "github.com/pkg/errors"
@@ -33,6 +35,29 @@ var NewElasticRuntime = func(jsonFile string, target string, sshKey string, cryp
 	return context
 }
 
+//NewDirectorProcesses - the processes of the director job the director
+//blobstore lives on, reached through the ssh tunnels
+var NewDirectorProcesses = func(blobstore cfbackup.SystemDump, tunnels *cfbackup.SSHTunnels) (processes DirectorProcessController, err error) {
+	info, ok := blobstore.(*cfbackup.DirectorBlobstoreInfo)
+	if !ok {
+		return nil, fmt.Errorf("the director blobstore does not describe the director vm")
+	}
+	info.SetSSHTunnels(tunnels)
+	var directorProcesses *cfbackup.DirectorProcesses
+	if directorProcesses, err = info.GetDirectorProcesses(); err != nil {
+		return nil, err
+	}
+	return directorProcesses, nil
+}
+
+//EnableDirectorBackup - backs the director database, blobstore and credential
+//store up along with the runtime
+func (context *ElasticRuntime) EnableDirectorBackup() {
+	context.SystemsInfo.EnableDirectorBackup(context.SSHPrivateKey)
+	context.PersistentSystems = context.SystemsInfo.PersistentSystems()
+	context.DirectorBackup = true
+}
+
 // Backup performs a backup of a Pivotal Elastic Runtime deployment
 func (context *ElasticRuntime) Backup() (err error) {
 	return context.backupRestore(cfbackup.ExportArchive)
@@ -59,7 +84,21 @@ func (context *ElasticRuntime) backupRestore(action int) (err error) {
 		return errwrap.Wrap(err, "failed on check for valid director credentials")
 	}
 
+	if directorCredentialsValid && context.DirectorBackup {
+		if err = context.checkDirector(action); err != nil {
+			return errwrap.Wrap(err, "failed checking director")
+		}
+	}
+
 	if directorCredentialsValid {
+		context.reportFoundation()
+		directorSystems, runtimeSystems := context.splitDirectorSystems(context.persistentSystems())
+		if len(directorSystems) > 0 {
+			if err = context.runDirectorAction(directorSystems, action); err != nil {
+				return errwrap.Wrap(err, "failed backing up or restoring the director")
+			}
+		}
+
 		lo.G.Debug("Retrieving All CC VMs")
 		if ccJobs, err = context.getAllCloudControllerVMs(); err == nil {
 			directorInfo := context.SystemsInfo.SystemDumps[cfbackup.ERDirector]
@@ -68,8 +107,16 @@ func (context *ElasticRuntime) backupRestore(action int) (err error) {
 			if err != nil {
 				return errwrap.Wrap(err, "failed creating new cloud controller")
 			}
+			cloudController.SetToggleStrategy(context.CCToggleStrategy)
+			cloudController.SetDirectorTLS(context.DirectorTLS)
+			cloudController.SetProgressReporter(context.BackupContext)
 
 			lo.G.Debug("Stopping CC jobs")
+			if err := cloudController.Stop(); err != nil {
+				return errwrap.Wrap(err, "failed to stop cloud controller")
+			}
+			context.Report(cfbackup.ProgressEvent{Type: cfbackup.ProgressCCStopped})
+
 			defer func() {
 				lo.G.Debug("Starting CC jobs")
 				startingError := cloudController.Start()
@@ -79,19 +126,31 @@ func (context *ElasticRuntime) backupRestore(action int) (err error) {
 					} else {
 						err = startingError
 					}
+				} else {
+					context.Report(cfbackup.ProgressEvent{Type: cfbackup.ProgressCCStarted})
 				}
-			}()
 
-			if err := cloudController.Stop(); err != nil {
-				return errwrap.Wrap(err, "failed to stop cloud controller")
-			}
+				if action == cfbackup.ExportArchive {
+					if metadataError := context.writeMetadata(ccJobs); metadataError != nil {
+						if err != nil {
+							err = errwrap.Wrapf(err, "Writing metadata also failed: %v", metadataError)
+						} else {
+							err = metadataError
+						}
+					}
+				}
+			}()
 		} else {
 			return errwrap.Wrap(err, "failed getting VMs")
 		}
 
+		if err = context.Hooks.Run(tileregistry.HookEvent{Point: tileregistry.HookPostStopCC, Tile: ElasticRuntimeTileName, ArchiveDirectory: context.TargetDir}, context.BackupContext); err != nil {
+			return
+		}
+
 		lo.G.Debug("Running db action")
-		if len(context.PersistentSystems) > 0 {
-			err = context.RunDbAction(context.PersistentSystems, action)
+		if len(runtimeSystems) > 0 {
+			err = context.RunDbAction(runtimeSystems, action)
 			if err != nil {
 				lo.G.Error("Error backing up db", err)
 				err = ErrERDBBackup
@@ -99,19 +158,161 @@ func (context *ElasticRuntime) backupRestore(action int) (err error) {
 		} else {
 			lo.G.Info("There is no internal persistent system used by ERT, skip db action")
 		}
+
+		if err == nil && action == cfbackup.ExportArchive {
+			err = context.Hooks.Run(tileregistry.HookEvent{Point: tileregistry.HookPostDump, Tile: ElasticRuntimeTileName, ArchiveDirectory: context.TargetDir}, context.BackupContext)
+		}
 	} else if err == nil {
 		err = cfbackup.ErrERDirectorCreds
 	}
 	return
 }
 
+//reportFoundation - reports the installation and, once it is captured, the
+//director being backed up or restored
+func (context *ElasticRuntime) reportFoundation() {
+	event := cfbackup.ProgressEvent{Type: cfbackup.ProgressFoundation, Deployment: context.InstallationName}
+	if context.boshInfo != nil {
+		event.DirectorName = context.boshInfo.Name
+		event.DirectorUUID = context.boshInfo.UUID
+	}
+	context.Report(event)
+}
+
+//persistentSystems - the credential store is left out on directors which do
+//not run one
+func (context *ElasticRuntime) persistentSystems() (persistentSystems []cfbackup.SystemDump) {
+	credentialStore := context.SystemsInfo.SystemDumps[cfbackup.ERDirectorCredentialStore]
+
+	for _, system := range context.PersistentSystems {
+		if system == credentialStore && (context.boshInfo == nil || !context.boshInfo.Features.ConfigServer.Status) {
+			lo.G.Debug("director has no credential store, skipping it")
+			continue
+		}
+		persistentSystems = append(persistentSystems, system)
+	}
+	return
+}
+
+//splitDirectorSystems - the director database, credential store and
+//blobstore apart from the systems of the runtime
+func (context *ElasticRuntime) splitDirectorSystems(systems []cfbackup.SystemDump) (directorSystems, runtimeSystems []cfbackup.SystemDump) {
+	dumps := context.SystemsInfo.SystemDumps
+	for _, system := range systems {
+		switch system {
+		case dumps[cfbackup.ERDirectorDB], dumps[cfbackup.ERDirectorCredentialStore], dumps[cfbackup.ERDirectorBlobstore]:
+			directorSystems = append(directorSystems, system)
+		default:
+			runtimeSystems = append(runtimeSystems, system)
+		}
+	}
+	return
+}
+
+//runDirectorAction - dumps or restores the director systems as a step of
+//their own, before the cloud controller is stopped through the director,
+//with the processes of the director which write them stopped. the director
+//is started again and waited on until it answers
+func (context *ElasticRuntime) runDirectorAction(systems []cfbackup.SystemDump, action int) (err error) {
+	var processes DirectorProcessController
+	if processes, err = NewDirectorProcesses(context.SystemsInfo.SystemDumps[cfbackup.ERDirectorBlobstore], context.SSHTunnels); err != nil {
+		return errwrap.Wrap(err, "failed reaching the director processes")
+	}
+
+	lo.G.Info("Stopping the director processes")
+	if err = processes.Stop(); err != nil {
+		if startErr := processes.Start(); startErr != nil {
+			return errwrap.Wrapf(err, "failed stopping the director processes, starting them again also failed: %v", startErr)
+		}
+		return errwrap.Wrap(err, "failed stopping the director processes")
+	}
+
+	if err = context.RunDbAction(systems, action); err != nil {
+		lo.G.Error("Error backing up director db", err)
+	}
+
+	lo.G.Info("Starting the director processes")
+	if startErr := context.startDirector(processes); startErr != nil {
+		if err != nil {
+			err = errwrap.Wrapf(err, "Starting the director also failed: %v", startErr)
+		} else {
+			err = startErr
+		}
+	}
+	return
+}
+
+//startDirector - starts the director processes and waits until the director
+//answers again
+func (context *ElasticRuntime) startDirector(processes DirectorProcessController) (err error) {
+	if err = processes.Start(); err != nil {
+		return errwrap.Wrap(err, "failed starting the director processes")
+	}
+	deadline := time.Now().Add(cfbackup.DirectorProcessTimeout)
+	for {
+		if _, err = context.directorCredentialsValid(); err == nil {
+			return
+		}
+		if time.Now().After(deadline) {
+			return errwrap.Wrap(err, "the director did not answer once it was started")
+		}
+		time.Sleep(cfbackup.DirectorProcessPollInterval)
+	}
+}
+
+//checkDirector - captures the director we are about to back up. a restore
+//of a director backup is refused when the director's uuid does not match
+//the one recorded in the backup, unless it is forced
+func (context *ElasticRuntime) checkDirector(action int) (err error) {
+	if context.boshInfo, err = context.getBoshInfo(); err != nil || action != cfbackup.ImportArchive {
+		return
+	}
+
+	var metadata *Metadata
+	if metadata, err = context.readMetadata(); err != nil {
+		return errwrap.Wrap(err, "failed reading backup metadata")
+	}
+
+	if metadata.Director == nil {
+		return fmt.Errorf("backup metadata does not describe a director, was the backup taken with the director?")
+	}
+
+	if metadata.Director.UUID != context.boshInfo.UUID {
+		if !context.ForceDirectorRestore {
+			return errwrap.Wrapf(ErrERDirectorMismatch, "backup director uuid %s, target director uuid %s", metadata.Director.UUID, context.boshInfo.UUID)
+		}
+		context.Warningf("forcing restore of director %s onto director %s", metadata.Director.UUID, context.boshInfo.UUID)
+	}
+
+	if metadata.Director.Version != context.boshInfo.Version {
+		context.Warningf("backup director version %s differs from target director version %s", metadata.Director.Version, context.boshInfo.Version)
+	}
+	return
+}
+
+func (context *ElasticRuntime) getBoshInfo() (*cfbackup.BoshInfo, error) {
+	directorInfo := context.SystemsInfo.SystemDumps[cfbackup.ERDirector]
+
+	director, err := cfbackup.NewDirector(directorInfo.Get(cfbackup.SDIP), directorInfo.Get(cfbackup.SDUser), directorInfo.Get(cfbackup.SDPass), 25555, context.DirectorTLS)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed creating new director")
+	}
+
+	body, err := director.GetInfo()
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed to get info from director")
+	}
+	defer body.Close()
+	return cfbackup.ReadBoshInfo(body)
+}
+
 func (context *ElasticRuntime) getAllCloudControllerVMs() (ccvms []cfbackup.CCJob, err error) {
 
 	var jsonObj []cfbackup.VMObject
 	lo.G.Debug("Entering getAllCloudControllerVMs() function")
 	directorInfo := context.SystemsInfo.SystemDumps[cfbackup.ERDirector]
 
-	director, err := cfbackup.NewDirector(directorInfo.Get(cfbackup.SDIP), directorInfo.Get(cfbackup.SDUser), directorInfo.Get(cfbackup.SDPass), 25555)
+	director, err := cfbackup.NewDirector(directorInfo.Get(cfbackup.SDIP), directorInfo.Get(cfbackup.SDUser), directorInfo.Get(cfbackup.SDPass), 25555, context.DirectorTLS)
 	if err != nil {
 		return nil, errwrap.Wrap(err, "failed creating new director")
 	}
@@ -135,7 +336,58 @@ func (context *ElasticRuntime) getAllCloudControllerVMs() (ccvms []cfbackup.CCJo
 	if len(jsonObj) <= 0 {
 		return nil, fmt.Errorf("no vm objects found: %v bytes: %v", jsonObj, string(bodyBytes))
 	}
-	return cfbackup.GetCCVMs(jsonObj)
+	patterns := context.QuiesceJobs
+	if len(patterns) == 0 {
+		patterns = cfbackup.NewConfigurationParser(context.JSONFile).InstallationSettings.GetCCJobPatterns()
+	}
+	return cfbackup.GetCCVMsForPatterns(jsonObj, patterns)
+}
+
+//writeMetadata - records the cc job states captured before the backup along
+//with the states the director reports once the jobs have been started again
+func (context *ElasticRuntime) writeMetadata(ccJobsBefore []cfbackup.CCJob) (err error) {
+	metadata := Metadata{
+		CCJobsBefore: ccJobsBefore,
+	}
+
+	if context.boshInfo != nil {
+		metadata.Director = &DirectorMetadata{
+			Name:    context.boshInfo.Name,
+			UUID:    context.boshInfo.UUID,
+			Version: context.boshInfo.Version,
+		}
+	}
+
+	if metadata.CCJobsAfter, err = context.getAllCloudControllerVMs(); err != nil {
+		lo.G.Error("unable to capture cc job states after backup: ", err)
+	}
+
+	var b []byte
+	if b, err = json.MarshalIndent(metadata, "", "  "); err != nil {
+		return errwrap.Wrap(err, "failed to marshal backup metadata")
+	}
+
+	var metadataWriter io.WriteCloser
+	if metadataWriter, err = context.Writer(path.Join(context.TargetDir, ERMetadataFileName)); err != nil {
+		return errwrap.Wrap(err, "failed to create backup metadata file")
+	}
+	defer metadataWriter.Close()
+	_, err = metadataWriter.Write(b)
+	return
+}
+
+func (context *ElasticRuntime) readMetadata() (metadata *Metadata, err error) {
+	var metadataReader io.ReadCloser
+	if metadataReader, err = context.Reader(path.Join(context.TargetDir, ERMetadataFileName)); err != nil {
+		return nil, errwrap.Wrap(err, "failed to open backup metadata file")
+	}
+	defer metadataReader.Close()
+
+	metadata = &Metadata{}
+	if err = json.NewDecoder(metadataReader).Decode(metadata); err != nil {
+		return nil, errwrap.Wrap(err, "failed to unmarshal backup metadata")
+	}
+	return
 }
 
 //RunDbAction - run a db action dump/import against a list of systemdump types
@@ -156,11 +408,26 @@ func (context *ElasticRuntime) RunDbAction(dbInfoList []cfbackup.SystemDump, act
 }
 
 func (context *ElasticRuntime) readWriterArchive(dbInfo cfbackup.SystemDump, databaseDir string, action int) (err error) {
-	filename := fmt.Sprintf(ERBackupFileFormat, dbInfo.Get(cfbackup.SDComponent))
+	archiveName := dbInfo.Get(cfbackup.SDComponent)
+	if namer, ok := dbInfo.(cfbackup.ArchiveNamer); ok {
+		archiveName = namer.ArchiveName()
+	}
+	filename := fmt.Sprintf(ERBackupFileFormat, archiveName)
 	filepath := path.Join(databaseDir, filename)
 
 	var pb cfbackup.PersistanceBackup
 
+	context.StepStarted(archiveName)
+	defer func() {
+		context.StepFinished(archiveName, err)
+	}()
+
+	if tunneled, ok := dbInfo.(cfbackup.SSHTunneled); ok {
+		tunneled.SetSSHTunnels(context.SSHTunnels)
+	}
+	if metered, ok := dbInfo.(cfbackup.Metered); ok {
+		metered.SetMetrics(context.Metrics)
+	}
 	if pb, err = dbInfo.GetPersistanceBackup(); err == nil {
 		switch action {
 		case cfbackup.ImportArchive:
@@ -168,7 +435,10 @@ func (context *ElasticRuntime) readWriterArchive(dbInfo cfbackup.SystemDump, dat
 			var backupReader io.ReadCloser
 			if backupReader, err = context.Reader(filepath); err == nil {
 				defer backupReader.Close()
-				err = pb.Import(backupReader)
+				progressReader := context.NewProgressReader(backupReader, filepath, context.ArtifactSize(filepath))
+				if err = pb.Import(progressReader); err == nil {
+					progressReader.Done()
+				}
 				lo.G.Debug("Done restoring %s", dbInfo.Get(cfbackup.SDComponent))
 			}
 		case cfbackup.ExportArchive:
@@ -176,7 +446,10 @@ func (context *ElasticRuntime) readWriterArchive(dbInfo cfbackup.SystemDump, dat
 			var backupWriter io.WriteCloser
 			if backupWriter, err = context.Writer(filepath); err == nil {
 				defer backupWriter.Close()
-				err = pb.Dump(backupWriter)
+				progressWriter := context.NewProgressWriter(backupWriter, filepath, 0)
+				if err = pb.Dump(progressWriter); err == nil {
+					progressWriter.Done()
+				}
 				lo.G.Debug("Done backing up ", dbInfo.Get(cfbackup.SDComponent), err)
 			}
 		}
@@ -184,15 +457,20 @@ func (context *ElasticRuntime) readWriterArchive(dbInfo cfbackup.SystemDump, dat
 	return
 }
 
-//ReadAllUserCredentials - get all user creds from the installation json
+//ReadAllUserCredentials - get all user creds from the installation json,
+//falling back to the credentials api for those the json does not contain
 func (context *ElasticRuntime) ReadAllUserCredentials() (err error) {
 	configParser := cfbackup.NewConfigurationParser(context.JSONFile)
-	installationSettings := configParser.InstallationSettings
-	err = context.assignCredentialsAndInstallationName(installationSettings)
+	var installationInfo cfbackup.InstallationInfo = &configParser.InstallationSettings
+
+	if context.CredentialsAPI != nil {
+		installationInfo = cfbackup.NewCredentialsInstallation(installationInfo, context.CredentialsAPI)
+	}
+	err = context.assignCredentialsAndInstallationName(installationInfo)
 	return
 }
 
-func (context *ElasticRuntime) assignCredentialsAndInstallationName(installationSettings cfbackup.InstallationSettings) (err error) {
+func (context *ElasticRuntime) assignCredentialsAndInstallationName(installationSettings cfbackup.InstallationInfo) (err error) {
 
 	if err = context.assignCredentials(installationSettings); err == nil {
 		context.InstallationName, err = context.getDeploymentName(installationSettings)
@@ -200,7 +478,7 @@ func (context *ElasticRuntime) assignCredentialsAndInstallationName(installation
 	return
 }
 
-func (context *ElasticRuntime) assignCredentials(installationSettings cfbackup.InstallationSettings) (err error) {
+func (context *ElasticRuntime) assignCredentials(installationSettings cfbackup.InstallationInfo) (err error) {
 
 	for name, sysInfo := range context.SystemsInfo.SystemDumps {
 		var (
@@ -237,7 +515,7 @@ func (context *ElasticRuntime) directorCredentialsValid() (ok bool, err error) {
 		return false, ErrERDirectorCreds
 	}
 
-	director, err := cfbackup.NewDirector(directorInfo.Get(cfbackup.SDIP), directorInfo.Get(cfbackup.SDUser), directorInfo.Get(cfbackup.SDPass), 25555)
+	director, err := cfbackup.NewDirector(directorInfo.Get(cfbackup.SDIP), directorInfo.Get(cfbackup.SDUser), directorInfo.Get(cfbackup.SDPass), 25555, context.DirectorTLS)
 	if err != nil {
 		return false, errwrap.Wrap(err, "failed creating new director")
 	}
@@ -249,7 +527,7 @@ func (context *ElasticRuntime) directorCredentialsValid() (ok bool, err error) {
 	return true, nil
 }
 
-func (context *ElasticRuntime) getDeploymentName(installationSettings cfbackup.InstallationSettings) (deploymentName string, err error) {
+func (context *ElasticRuntime) getDeploymentName(installationSettings cfbackup.InstallationInfo) (deploymentName string, err error) {
 	var product cfbackup.Products
 	if product, err = installationSettings.FindByProductID("cf"); err == nil {
 		deploymentName = product.InstallationName
@@ -257,7 +535,7 @@ func (context *ElasticRuntime) getDeploymentName(installationSettings cfbackup.I
 	return
 }
 
-func (context *ElasticRuntime) getUserIDPasswordForIdentifier(installationSettings cfbackup.InstallationSettings, product, component, identifier string) (userID, password string, err error) {
+func (context *ElasticRuntime) getUserIDPasswordForIdentifier(installationSettings cfbackup.InstallationInfo, product, component, identifier string) (userID, password string, err error) {
 	var propertyMap map[string]string
 	if propertyMap, err = installationSettings.FindPropertyValues(product, component, identifier); err == nil {
 		userID = propertyMap["identity"]
@@ -266,7 +544,7 @@ func (context *ElasticRuntime) getUserIDPasswordForIdentifier(installationSettin
 	return
 }
 
-func (context *ElasticRuntime) getVMUserIDPasswordAndIP(installationSettings cfbackup.InstallationSettings, product, component string) (userID, password, ip string, err error) {
+func (context *ElasticRuntime) getVMUserIDPasswordAndIP(installationSettings cfbackup.InstallationInfo, product, component string) (userID, password, ip string, err error) {
 	var ips []string
 	if ips, err = installationSettings.FindIPsByProductAndJob(product, component); err == nil {
 		if len(ips) > 0 {
@@ -2,6 +2,7 @@ package elasticruntime
 
 import (
 	"io"
+	"strings"
 
 	"github.com/pivotalservices/cfbackup"
 	"github.com/pivotalservices/cfbackup/tileregistry"
@@ -9,15 +10,23 @@ import (
 )
 
 //New -- method to generate an initialized elastic runtime
-func (s *ElasticRuntimeBuilder) New(tileSpec tileregistry.TileSpec) (elasticRuntimeCloser tileregistry.TileCloser, err error) {
+func (s *ElasticRuntimeBuilder) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	return tileSpec.Build(ElasticRuntimeTileName, s.build)
+}
+
+func (s *ElasticRuntimeBuilder) build(tileSpec tileregistry.TileSpec) (elasticRuntimeCloser tileregistry.TileCloser, err error) {
 	var (
+		installationAPI      InstallationAPI
 		installationSettings io.Reader
 		tmpfile              *TempFile
 		sshKey               = ""
 	)
+	if installationAPI, err = NewInstallationAPI(tileSpec); err != nil {
+		return
+	}
 	if tmpfile, err = NewTempFile(opsmanager.OpsMgrInstallationSettingsFilename); err == nil {
 
-		if installationSettings, err = GetInstallationSettings(tileSpec); err == nil {
+		if installationSettings, err = installationAPI.GetInstallationSettings(); err == nil {
 			io.Copy(tmpfile.FileRef, installationSettings)
 			config := cfbackup.NewConfigurationParser(tmpfile.FileRef.Name())
 
@@ -25,26 +34,49 @@ func (s *ElasticRuntimeBuilder) New(tileSpec tileregistry.TileSpec) (elasticRunt
 				sshKey = iaas.SSHPrivateKey
 			}
 			elasticRuntime := NewElasticRuntime(tmpfile.FileRef.Name(), tileSpec.ArchiveDirectory, sshKey, tileSpec.CryptKey, tileSpec.NFS)
+			if err = tileSpec.ConfigureContext(&elasticRuntime.BackupContext); err != nil {
+				tmpfile.Close()
+				return
+			}
+			elasticRuntime.DirectorTLS = config.InstallationSettings.DirectorTLS(elasticRuntime.TLS)
+			elasticRuntime.CCToggleStrategy = cfbackup.ToggleStrategy(tileSpec.CCToggleStrategy)
+			elasticRuntime.QuiesceJobs = parseQuiesceJobs(tileSpec.QuiesceJobs)
+			elasticRuntime.ForceDirectorRestore = tileSpec.ForceDirectorRestore
+			elasticRuntime.Hooks = tileSpec.Hooks.For(ElasticRuntimeTileName)
+			elasticRuntime.CredentialsAPI = installationAPI
+			if tileSpec.DirectorBackup {
+				elasticRuntime.EnableDirectorBackup()
+			}
 			elasticRuntimeCloser = struct {
 				tileregistry.Tile
 				tileregistry.Closer
 			}{
 				elasticRuntime,
-				tmpfile,
+				tileregistry.Closers{tmpfile, elasticRuntime.SSHTunnels},
 			}
 		}
 	}
 	return
 }
 
-//GetInstallationSettings - makes a call to ops manager and returns a io.reader containing the contents of the installation settings file.
-var GetInstallationSettings = func(tileSpec tileregistry.TileSpec) (settings io.Reader, err error) {
-	var (
-		opsManager *opsmanager.OpsManager
-	)
+//parseQuiesceJobs - splits a comma separated list of job patterns, an empty
+//list leaves the defaults in place
+func parseQuiesceJobs(quiesceJobs string) (patterns []string) {
+	for _, pattern := range strings.Split(quiesceJobs, ",") {
+		if pattern = strings.TrimSpace(pattern); pattern != "" {
+			patterns = append(patterns, pattern)
+		}
+	}
+	return
+}
 
-	if opsManager, err = opsmanager.NewOpsManager(tileSpec.OpsManagerHost, tileSpec.AdminUser, tileSpec.AdminPass, tileSpec.AdminToken, tileSpec.OpsManagerUser, tileSpec.OpsManagerPass, tileSpec.OpsManagerPassphrase, tileSpec.ClientID, tileSpec.ClientSecret, tileSpec.ArchiveDirectory, tileSpec.CryptKey); err == nil {
-		settings, err = opsManager.GetInstallationSettings()
+//NewInstallationAPI - builds the one ops manager the installation settings
+//and the credentials of the elastic runtime are fetched from, so they share
+//its token and api version
+var NewInstallationAPI = func(tileSpec tileregistry.TileSpec) (api InstallationAPI, err error) {
+	var opsManager *opsmanager.OpsManager
+	if opsManager, err = opsmanager.NewFromTileSpec(tileSpec); err == nil {
+		api = opsManager
 	}
 	return
 }
@@ -1,6 +1,7 @@
 package elasticruntime_test
 
 import (
+	"encoding/json"
 	"errors"
 	"fmt"
 	"io"
@@ -12,7 +13,10 @@ import (
 	cfenv "github.com/cloudfoundry-community/go-cfenv"
 	"github.com/pivotalservices/cfbackup"
 	"github.com/pivotalservices/cfbackup/fakes"
+	"github.com/pivotalservices/cfbackup/tileregistry"
 	. "github.com/pivotalservices/cfbackup/tiles/elasticruntime"
+	"github.com/pivotalservices/cfbackup/tiles/opsmanager"
+	opsfakes "github.com/pivotalservices/cfbackup/tiles/opsmanager/fakes"
 	"github.com/pivotalservices/gtils/osutils"
 
 	errwrap "github.com/pkg/errors"
@@ -50,6 +54,16 @@ func (s *DBInfoMock) Error() error {
 	return s.ErrState
 }
 
+type directorInfoMock struct {
+	*DBInfoMock
+	onGetPersistanceBackup func()
+}
+
+func (s *directorInfoMock) GetPersistanceBackup() (dumper cfbackup.PersistanceBackup, err error) {
+	s.onGetPersistanceBackup()
+	return s.DBInfoMock.GetPersistanceBackup()
+}
+
 type mockDumper struct {
 	failImport bool
 	failDump   bool
@@ -96,6 +110,41 @@ var _ = Describe("ElasticRuntime", func() {
 			})
 		})
 
+		Context("when: calling ReadAllUserCredentials with an installation json without credentials", func() {
+			var (
+				er     *ElasticRuntime
+				server *opsfakes.FakeOpsManager
+				err    error
+			)
+			BeforeEach(func() {
+				server = opsfakes.NewFakeOpsManager("admin", "admin-password", "passphrase")
+				for _, job := range []string{"ccdb", "uaadb", "consoledb", "mysql", "nfs_server"} {
+					for _, identifier := range []string{"credentials", "mysql_admin_credentials", "vm_credentials"} {
+						server.SetCredential("cf-f21eea2dbdb8555f89fb", cfbackup.CredentialReference(job, identifier), "simple_credentials", map[string]string{"identity": job + "-user", "password": job + "-" + identifier})
+					}
+				}
+				for _, identifier := range []string{"director_credentials", "vm_credentials"} {
+					server.SetCredential("p-bosh-8e44e8bbe3f1da7c082f", cfbackup.CredentialReference("director", identifier), "simple_credentials", map[string]string{"identity": "director-user", "password": "director-" + identifier})
+				}
+				opsManager, _ := opsmanager.NewOpsManager(server.Host(), "admin", "admin-password", "", "ubuntu", "ssh-password", "passphrase", "", "", "./", "")
+
+				er = NewElasticRuntime("../../fixtures/installation-settings-1-7-redacted.json", "./", ".", "", cfbackup.NFSBackupTypeFull)
+				er.CredentialsAPI = opsManager
+				err = er.ReadAllUserCredentials()
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("then it should resolve the credentials through the credentials api", func() {
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(er.SystemsInfo.SystemDumps[cfbackup.ERNfs].Get(cfbackup.SDVcapPass)).Should(Equal("nfs_server-vm_credentials"))
+				Ω(er.SystemsInfo.SystemDumps[cfbackup.ERCc].Get(cfbackup.SDPass)).Should(Equal("ccdb-credentials"))
+				Ω(er.SystemsInfo.SystemDumps[cfbackup.ERDirector].Get(cfbackup.SDPass)).Should(Equal("director-director_credentials"))
+			})
+		})
+
 		Context("when: called with an invalid file path to installation settings", func() {
 			It("then it should panic", func() {
 				Ω(func() {
@@ -588,6 +637,16 @@ func testERWithVersionSpecificFile(installationSettingsFilePath string, boshName
 						err := er.Backup()
 						Ω(err).Should(BeNil())
 					})
+
+					It("Should record the cc job states in the backup metadata", func() {
+						er.Backup()
+						b, err := ioutil.ReadFile(path.Join(target, ERMetadataFileName))
+						Ω(err).ShouldNot(HaveOccurred())
+						var metadata Metadata
+						Ω(json.Unmarshal(b, &metadata)).Should(Succeed())
+						Ω(metadata.CCJobsBefore).Should(HaveLen(6))
+						Ω(metadata.CCJobsAfter).Should(HaveLen(6))
+					})
 				})
 
 				Context("Restore", func() {
@@ -606,6 +665,188 @@ func testERWithVersionSpecificFile(installationSettingsFilePath string, boshName
 						err := er.Restore()
 						Ω(err).Should(BeNil())
 					})
+
+					It("Should not run the post-dump hooks", func() {
+						var ran bool
+						er.Hooks = tileregistry.Hooks{{
+							Point: tileregistry.HookPostDump,
+							Hook: tileregistry.HookFunc(func(event tileregistry.HookEvent) error {
+								ran = true
+								return nil
+							}),
+						}}
+						Ω(er.Restore()).Should(Succeed())
+						Ω(ran).Should(BeFalse())
+					})
+				})
+			})
+
+			Context("against a fake director", func() {
+				var server *fakes.FakeDirectorServer
+
+				BeforeEach(func() {
+					server = fakes.NewFakeDirectorServer("", "")
+					Ω(er.ReadAllUserCredentials()).Should(Succeed())
+					server.AddDeployment(er.InstallationName, "manifest", []cfbackup.VMObject{
+						cfbackup.VMObject{Job: "cloud_controller-partition-abc", Index: 0, State: cfbackup.BOSHJobStateRunning},
+						cfbackup.VMObject{Job: "cloud_controller-partition-abc", Index: 1, State: cfbackup.BOSHJobStateStopped},
+						cfbackup.VMObject{Job: "router-partition-abc", Index: 0, State: cfbackup.BOSHJobStateRunning},
+					})
+					cfbackup.NewDirector = server.DirectorCreator(oldNewDirector)
+				})
+
+				AfterEach(func() {
+					server.Close()
+				})
+
+				It("Should leave the cloud controllers in the state it found them in", func() {
+					Ω(er.Backup()).Should(Succeed())
+					Ω(server.JobState(er.InstallationName, "cloud_controller-partition-abc", 0)).Should(Equal(cfbackup.BOSHJobStateRunning))
+					Ω(server.JobState(er.InstallationName, "cloud_controller-partition-abc", 1)).Should(Equal(cfbackup.BOSHJobStateStopped))
+					Ω(server.Requests()).Should(ContainElement(fmt.Sprintf("PUT /deployments/%s/jobs/cloud_controller-partition-abc/0", er.InstallationName)))
+				})
+
+				It("Should run the post-dump hooks once the databases are dumped, while the cloud controller is still stopped", func() {
+					var stateDuringHook string
+					er.Hooks = tileregistry.Hooks{{
+						Point: tileregistry.HookPostDump,
+						Hook: tileregistry.HookFunc(func(event tileregistry.HookEvent) error {
+							stateDuringHook = server.JobState(er.InstallationName, "cloud_controller-partition-abc", 0)
+							return nil
+						}),
+					}}
+					Ω(er.Backup()).Should(Succeed())
+					Ω(stateDuringHook).Should(Equal(cfbackup.BOSHJobStateStopped))
+					Ω(server.JobState(er.InstallationName, "cloud_controller-partition-abc", 0)).Should(Equal(cfbackup.BOSHJobStateRunning))
+				})
+
+				It("Should fail the backup when a cloud controller can not be stopped", func() {
+					server.FailJobState("cloud_controller-partition-abc", 0, "stopped", "disk full")
+					Ω(er.Backup()).ShouldNot(Succeed())
+					Ω(server.JobState(er.InstallationName, "cloud_controller-partition-abc", 0)).Should(Equal(cfbackup.BOSHJobStateRunning))
+				})
+			})
+
+			Context("With director backup enabled", func() {
+				var filename = fmt.Sprintf("%s.backup", "mysql")
+
+				BeforeEach(func() {
+					er.DirectorBackup = true
+					file, _ := os.Create(path.Join(target, filename))
+					file.Close()
+				})
+
+				AfterEach(func() {
+					os.Remove(path.Join(target, filename))
+					os.Remove(path.Join(target, ERMetadataFileName))
+				})
+
+				writeMetadata := func(uuid string) {
+					b, _ := json.Marshal(Metadata{Director: &DirectorMetadata{UUID: uuid}})
+					ioutil.WriteFile(path.Join(target, ERMetadataFileName), b, 0644)
+				}
+
+				It("Should record the director in the backup metadata", func() {
+					Ω(er.Backup()).Should(Succeed())
+					b, err := ioutil.ReadFile(path.Join(target, ERMetadataFileName))
+					Ω(err).ShouldNot(HaveOccurred())
+					var metadata Metadata
+					Ω(json.Unmarshal(b, &metadata)).Should(Succeed())
+					Ω(metadata.Director).ShouldNot(BeNil())
+					Ω(metadata.Director.UUID).Should(Equal(fakes.FakeDirectorUUID))
+					Ω(metadata.Director.Version).Should(Equal("1.3262.0.0 (00000000)"))
+				})
+
+				It("Should restore onto the director the backup was taken from", func() {
+					writeMetadata(fakes.FakeDirectorUUID)
+					Ω(er.Restore()).Should(Succeed())
+				})
+
+				It("Should refuse to restore onto a different director", func() {
+					writeMetadata("another-director")
+					err := er.Restore()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring(ERDirectorMismatchMsg))
+				})
+
+				It("Should restore onto a different director when forced", func() {
+					writeMetadata("another-director")
+					er.ForceDirectorRestore = true
+					Ω(er.Restore()).Should(Succeed())
+				})
+
+				It("Should refuse to restore a backup without director metadata", func() {
+					err := er.Restore()
+					Ω(err).Should(HaveOccurred())
+				})
+			})
+
+			Context("With the director database in the backup", func() {
+				var (
+					monit                   *fakes.FakeMonit
+					directorDB              *directorInfoMock
+					stoppedDuringAction     []string
+					oldNewDirectorProcesses = NewDirectorProcesses
+				)
+
+				BeforeEach(func() {
+					oldNewDirectorProcesses = NewDirectorProcesses
+					monit = fakes.NewFakeMonit("director", "worker_1", "director_scheduler", "postgres")
+					NewDirectorProcesses = func(cfbackup.SystemDump, *cfbackup.SSHTunnels) (DirectorProcessController, error) {
+						return &cfbackup.DirectorProcesses{Caller: monit, Patterns: cfbackup.DirectorProcessPatterns}, nil
+					}
+					stoppedDuringAction = nil
+					directorDB = &directorInfoMock{
+						DBInfoMock: &DBInfoMock{
+							SystemInfo: cfbackup.SystemInfo{
+								Product:    boshName,
+								Component:  "director",
+								Identifier: "postgres_credentials",
+							},
+						},
+						onGetPersistanceBackup: func() {
+							stoppedDuringAction = monit.Stopped()
+						},
+					}
+					er.SystemsInfo.SystemDumps[cfbackup.ERDirectorDB] = directorDB
+					er.PersistentSystems = append([]cfbackup.SystemDump{directorDB}, ps...)
+					file, _ := os.Create(path.Join(target, "director.backup"))
+					file.Close()
+					file, _ = os.Create(path.Join(target, "mysql.backup"))
+					file.Close()
+				})
+
+				AfterEach(func() {
+					NewDirectorProcesses = oldNewDirectorProcesses
+					delete(info.SystemDumps, cfbackup.ERDirectorDB)
+					os.Remove(path.Join(target, "director.backup"))
+					os.Remove(path.Join(target, "mysql.backup"))
+					os.Remove(path.Join(target, ERMetadataFileName))
+				})
+
+				It("Should dump the director with its api, workers and scheduler stopped", func() {
+					Ω(er.Backup()).Should(Succeed())
+					Ω(stoppedDuringAction).Should(Equal([]string{"director", "director_scheduler", "worker_1"}))
+					Ω(monit.Stopped()).Should(BeEmpty())
+				})
+
+				It("Should restore the director before the cloud controller is stopped through it", func() {
+					Ω(er.Restore()).Should(Succeed())
+					Ω(stoppedDuringAction).Should(Equal([]string{"director", "director_scheduler", "worker_1"}))
+					Ω(monit.Processes["postgres"]).Should(Equal(cfbackup.MonitStateRunning))
+				})
+
+				It("Should start the director again when its restore fails", func() {
+					directorDB.failImport = true
+					Ω(er.Restore()).ShouldNot(Succeed())
+					Ω(monit.Stopped()).Should(BeEmpty())
+				})
+
+				It("Should not touch the director when its processes can not be stopped", func() {
+					monit.FailOn, monit.ErrFake = "stop worker_1", errors.New("monit unreachable")
+					Ω(er.Backup()).ShouldNot(Succeed())
+					Ω(stoppedDuringAction).Should(BeNil())
+					Ω(monit.Stopped()).Should(BeEmpty())
 				})
 			})
 
@@ -1,13 +1,28 @@
 package elasticruntime
 
 import (
+	"io"
 	"os"
 
 	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
 	ghttp "github.com/pivotalservices/gtils/http"
 )
 
 type (
+	//InstallationAPI - the ops manager api the elastic runtime is built from:
+	//its installation settings and the credentials they no longer contain
+	InstallationAPI interface {
+		GetInstallationSettings() (settings io.Reader, err error)
+		cfbackup.CredentialsAPI
+	}
+
+	//DirectorProcessController - stops and starts the processes of the
+	//director job which write its database and blobstore
+	DirectorProcessController interface {
+		Stop() error
+		Start() error
+	}
 
 	//ElasticRuntime contains information about a Pivotal Elastic Runtime deployment
 	ElasticRuntime struct {
@@ -19,6 +34,33 @@ type (
 		InstallationName  string
 		SSHPrivateKey     string
 		NFS               string
+		CCToggleStrategy  cfbackup.ToggleStrategy
+		QuiesceJobs       []string
+		//DirectorBackup -- the director is backed up along with the runtime
+		DirectorBackup bool
+		//ForceDirectorRestore -- restore a director backup onto a director
+		//other than the one it was taken from
+		ForceDirectorRestore bool
+		//CredentialsAPI -- resolves credentials missing from the installation
+		//json, left nil they all have to be in the json
+		CredentialsAPI cfbackup.CredentialsAPI
+		//Hooks -- the hooks run once the cloud controller is stopped
+		Hooks    tileregistry.Hooks
+		boshInfo *cfbackup.BoshInfo
+	}
+
+	//Metadata -- information about the foundation recorded alongside a backup
+	Metadata struct {
+		CCJobsBefore cfbackup.CloudControllerJobs `json:"cc_jobs_before"`
+		CCJobsAfter  cfbackup.CloudControllerJobs `json:"cc_jobs_after"`
+		Director     *DirectorMetadata            `json:"director,omitempty"`
+	}
+
+	//DirectorMetadata -- identifies the director a backup was taken from
+	DirectorMetadata struct {
+		Name    string `json:"name"`
+		UUID    string `json:"uuid"`
+		Version string `json:"version"`
 	}
 
 	//ElasticRuntimeBuilder -- an object that can build an elastic runtime pre-initialized
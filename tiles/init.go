@@ -1,12 +1,28 @@
 package cfbackup
 
 import (
+	"os"
+
+	"github.com/pivotalservices/cfbackup/tileregistry"
 	"github.com/pivotalservices/cfbackup/tiles/elasticruntime"
+	"github.com/pivotalservices/cfbackup/tiles/mysql"
 	"github.com/pivotalservices/cfbackup/tiles/opsmanager"
-	"github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tiles/plugin"
+	"github.com/pivotalservices/cfbackup/tiles/rabbitmq"
+	"github.com/pivotalservices/cfbackup/tiles/redis"
+	"github.com/xchapter7x/lo"
 )
 
 func init() {
-	tileregistry.Register("ops-manager", new(opsmanager.OpsManagerBuilder))
-	tileregistry.Register("elastic-runtime", new(elasticruntime.ElasticRuntimeBuilder))
+	tileregistry.Register(opsmanager.OpsManagerTileName, new(opsmanager.OpsManagerBuilder))
+	tileregistry.Register(elasticruntime.ElasticRuntimeTileName, new(elasticruntime.ElasticRuntimeBuilder), opsmanager.OpsManagerTileName)
+	tileregistry.Register(mysql.MySQLTileName, new(mysql.MySQLBuilder), opsmanager.OpsManagerTileName, elasticruntime.ElasticRuntimeTileName)
+	tileregistry.Register(rabbitmq.RabbitMQTileName, new(rabbitmq.RabbitMQBuilder), opsmanager.OpsManagerTileName, elasticruntime.ElasticRuntimeTileName)
+	tileregistry.Register(redis.RedisTileName, new(redis.RedisBuilder), opsmanager.OpsManagerTileName, elasticruntime.ElasticRuntimeTileName)
+
+	if dir := os.Getenv(plugin.PluginDirVarname); dir != "" {
+		if _, err := plugin.Discover(dir); err != nil {
+			lo.G.Error("failed discovering tile plugins: ", err)
+		}
+	}
 }
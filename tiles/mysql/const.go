@@ -0,0 +1,46 @@
+package mysql
+
+import "errors"
+
+//MySQL constants
+const (
+	//MySQLProductID - the product identifier of the mysql service tile
+	MySQLProductID = "p-mysql"
+	//MySQLTileName - the name the mysql tile is registered and run its hooks
+	//under
+	MySQLTileName = "p-mysql"
+	//MySQLJob - the job of the galera cluster nodes
+	MySQLJob = "mysql"
+	//MySQLProxyJob - the job of the switchboard proxies in front of the nodes
+	MySQLProxyJob = "proxy"
+	//MySQLAdminCredentials - the mysql job property holding the admin user
+	MySQLAdminCredentials = "mysql_admin_password"
+	//MySQLProxyCredentials - the proxy job property holding the credentials
+	//of the switchboard api
+	MySQLProxyCredentials = "dashboard_credentials"
+	//MySQLProxyAPIPort - the port of the switchboard api on the proxies
+	MySQLProxyAPIPort = 80
+	//MySQLProxyClusterURL - the switchboard endpoint turning the traffic to
+	//the backends off and on
+	MySQLProxyClusterURL = "http://%s:%d/v0/cluster?trafficEnabled=%t&message=%s"
+	//MySQLProxyDrainMessage - the reason shown by the proxies while drained
+	MySQLProxyDrainMessage = "cfbackup"
+	//MySQLBackupDir - where the tile is kept in the backup
+	MySQLBackupDir = "p-mysql"
+	//MySQLBackupFileName - the dump of the cluster
+	MySQLBackupFileName = "mysql.backup"
+	//MySQLRemoteArchivePath - where the dump is uploaded to on the seed node
+	MySQLRemoteArchivePath = "/var/vcap/store/mysql/archive.backup"
+	//MySQLStateFile - the state file mariadb_ctrl reads when the node starts
+	MySQLStateFile = "/var/vcap/store/mysql/state.txt"
+	//MySQLNeedsBootstrap - the state making a node bootstrap a new cluster
+	MySQLNeedsBootstrap = "NEEDS_BOOTSTRAP"
+)
+
+var (
+	//ErrMySQLNoNodes - the product has no mysql nodes to back up
+	ErrMySQLNoNodes = errors.New("no mysql nodes found in the p-mysql product")
+	//ErrMySQLSeedNotFound - the director does not list the node the dump is
+	//restored on
+	ErrMySQLSeedNotFound = errors.New("the seed node is not an instance of the p-mysql deployment")
+)
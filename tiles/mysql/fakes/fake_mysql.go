@@ -0,0 +1,150 @@
+package fakes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+//FakeSwitchboard - an in-process switchboard api of a mysql proxy, taking
+//basic auth and recording whether traffic is enabled
+type FakeSwitchboard struct {
+	*httptest.Server
+	Username string
+	Password string
+
+	mutex    sync.Mutex
+	traffic  bool
+	requests []string
+	failNext int
+}
+
+//NewFakeSwitchboard - starts a switchboard api with traffic enabled
+func NewFakeSwitchboard(username, password string) *FakeSwitchboard {
+	s := &FakeSwitchboard{Username: username, Password: password, traffic: true}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+//Address - the host and port of the api
+func (s *FakeSwitchboard) Address() (string, int) {
+	u, _ := url.Parse(s.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+//TrafficEnabled - whether the proxy sends traffic to the cluster
+func (s *FakeSwitchboard) TrafficEnabled() bool {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.traffic
+}
+
+//Requests - every request served so far as "METHOD /path?query"
+func (s *FakeSwitchboard) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+//FailNextRequest - answers the next request with the status code
+func (s *FakeSwitchboard) FailNextRequest(statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failNext = statusCode
+}
+
+func (s *FakeSwitchboard) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.RequestURI())
+
+	if username, password, ok := req.BasicAuth(); !ok || username != s.Username || password != s.Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if s.failNext != 0 {
+		w.WriteHeader(s.failNext)
+		s.failNext = 0
+		return
+	}
+	if req.Method != "PATCH" || req.URL.Path != "/v0/cluster" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+	enabled, err := strconv.ParseBool(req.URL.Query().Get("trafficEnabled"))
+	if err != nil {
+		w.WriteHeader(http.StatusBadRequest)
+		return
+	}
+	s.traffic = enabled
+	fmt.Fprintf(w, `{"trafficEnabled":%t}`, enabled)
+}
+
+//FakeMySQLDump - a dump of the cluster kept in memory, checking the traffic
+//of the proxies is off whenever it is used
+type FakeMySQLDump struct {
+	Data      []byte
+	Imported  []byte
+	Proxies   []*FakeSwitchboard
+	ErrFake   error
+	Undrained int
+}
+
+//Dump --
+func (s *FakeMySQLDump) Dump(dest io.Writer) (err error) {
+	s.checkDrained()
+	if s.ErrFake != nil {
+		return s.ErrFake
+	}
+	_, err = io.Copy(dest, bytes.NewReader(s.Data))
+	return
+}
+
+//Import --
+func (s *FakeMySQLDump) Import(src io.Reader) (err error) {
+	s.checkDrained()
+	if s.ErrFake != nil {
+		return s.ErrFake
+	}
+	s.Imported, err = ioutil.ReadAll(src)
+	return
+}
+
+func (s *FakeMySQLDump) checkDrained() {
+	for _, proxy := range s.Proxies {
+		if proxy.TrafficEnabled() {
+			s.Undrained++
+		}
+	}
+}
+
+//FakeExecuter - records the commands run on a node
+type FakeExecuter struct {
+	mutex    sync.Mutex
+	commands []string
+	ErrFake  error
+}
+
+//Execute --
+func (s *FakeExecuter) Execute(dest io.Writer, cmd string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands = append(s.commands, cmd)
+	return s.ErrFake
+}
+
+//Commands - every command run so far
+func (s *FakeExecuter) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.commands...)
+}
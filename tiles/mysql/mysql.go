@@ -0,0 +1,316 @@
+package mysql
+
+import (
+	"errors"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"path"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/gtils/command"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//NewRemoteExecuter - runs commands on the seed node
+var NewRemoteExecuter = command.NewRemoteExecutor
+
+//NewMySQLDump - dumps and imports the cluster through the seed node
+var NewMySQLDump = func(seed cfbackup.SystemDump) (cfbackup.PersistanceBackup, error) {
+	return seed.GetPersistanceBackup()
+}
+
+//NewMySQL - the mysql tile of the installation, with the admin credentials
+//and node ips of the p-mysql product and the credentials of the director
+func NewMySQL(installationInfo cfbackup.InstallationInfo, boshName string, backupContext cfbackup.BackupContext, sshKey string) (context *MySQL, err error) {
+	var (
+		product       cfbackup.Products
+		admin         map[string]string
+		proxy         map[string]string
+		vmCredentials cfbackup.VMCredentials
+	)
+	if product, err = installationInfo.FindByProductID(MySQLProductID); err != nil {
+		return
+	}
+	context = &MySQL{
+		BackupContext:  backupContext,
+		DeploymentName: product.InstallationName,
+		ProxyAPIPort:   MySQLProxyAPIPort,
+	}
+
+	if context.Nodes, err = installationInfo.FindIPsByProductAndJob(MySQLProductID, MySQLJob); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the mysql nodes")
+	}
+	if len(context.Nodes) == 0 {
+		return nil, ErrMySQLNoNodes
+	}
+	if admin, err = installationInfo.FindPropertyValues(MySQLProductID, MySQLJob, MySQLAdminCredentials); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the mysql admin credentials")
+	}
+	if vmCredentials, err = installationInfo.FindVMCredentialsByProductAndJob(MySQLProductID, MySQLJob); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the mysql vm credentials")
+	}
+	context.Seed = &cfbackup.MysqlInfo{
+		SystemInfo: cfbackup.SystemInfo{
+			Product:           MySQLProductID,
+			Component:         MySQLJob,
+			Identifier:        MySQLAdminCredentials,
+			Ip:                context.Nodes[0],
+			User:              admin["identity"],
+			Pass:              admin["password"],
+			VcapUser:          vmCredentials.UserID,
+			VcapPass:          vmCredentials.Password,
+			SSHPrivateKey:     sshKey,
+			RemoteArchivePath: MySQLRemoteArchivePath,
+			SSHTunnels:        backupContext.SSHTunnels,
+		},
+		Database: MySQLJob,
+	}
+
+	if context.Proxies, err = installationInfo.FindIPsByProductAndJob(MySQLProductID, MySQLProxyJob); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the mysql proxies")
+	}
+	if proxy, err = installationInfo.FindPropertyValues(MySQLProductID, MySQLProxyJob, MySQLProxyCredentials); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the mysql proxy credentials")
+	}
+	context.ProxyUser, context.ProxyPass = proxy["identity"], proxy["password"]
+
+	context.Director, err = cfbackup.FindDirectorInfo(installationInfo, boshName)
+	return
+}
+
+//Backup - dumps the cluster from the seed node while the proxies send no
+//traffic to it, so the dump is consistent
+func (context *MySQL) Backup() error {
+	return context.drained(func() error {
+		return context.transfer(cfbackup.ExportArchive)
+	})
+}
+
+//Restore - stops the cluster, bootstraps a new one from the seed node, loads
+//the dump into it and lets the other nodes join, all while the proxies send
+//no traffic to the cluster
+func (context *MySQL) Restore() error {
+	return context.drained(func() error {
+		return context.bootstrap(func() error {
+			return context.transfer(cfbackup.ImportArchive)
+		})
+	})
+}
+
+func (context *MySQL) transfer(action int) (err error) {
+	var pb cfbackup.PersistanceBackup
+	if pb, err = NewMySQLDump(context.Seed); err != nil {
+		return errwrap.Wrap(err, "failed connecting to the seed node")
+	}
+	filepath := path.Join(context.TargetDir, MySQLBackupDir, MySQLBackupFileName)
+
+	switch action {
+	case cfbackup.ExportArchive:
+		lo.G.Infof("dumping the mysql cluster from %s", context.Seed.Ip)
+		var backupWriter io.WriteCloser
+		if backupWriter, err = context.Writer(filepath); err == nil {
+			defer backupWriter.Close()
+			progressWriter := context.NewProgressWriter(backupWriter, filepath, 0)
+			if err = pb.Dump(progressWriter); err == nil {
+				progressWriter.Done()
+			}
+		}
+	case cfbackup.ImportArchive:
+		lo.G.Infof("loading the mysql dump into %s", context.Seed.Ip)
+		var backupReader io.ReadCloser
+		if backupReader, err = context.Reader(filepath); err == nil {
+			defer backupReader.Close()
+			progressReader := context.NewProgressReader(backupReader, filepath, context.ArtifactSize(filepath))
+			if err = pb.Import(progressReader); err == nil {
+				progressReader.Done()
+			}
+		}
+	}
+	return
+}
+
+//drained - runs the action with the traffic of every proxy turned off, the
+//traffic is turned back on for every proxy we attempted to drain
+func (context *MySQL) drained(action func() error) (err error) {
+	var drainedProxies []string
+	defer func() {
+		for _, proxy := range drainedProxies {
+			if enableErr := context.setTraffic(proxy, true); enableErr != nil {
+				if err != nil {
+					err = errwrap.Wrapf(err, "turning the traffic of proxy %s back on also failed: %v", proxy, enableErr)
+				} else {
+					err = enableErr
+				}
+			}
+		}
+	}()
+
+	for _, proxy := range context.Proxies {
+		drainedProxies = append(drainedProxies, proxy)
+		if err = context.setTraffic(proxy, false); err != nil {
+			return errwrap.Wrap(err, "failed draining the mysql proxies")
+		}
+	}
+	return action()
+}
+
+//setTraffic - turns the traffic of a proxy to the cluster off or on through
+//the switchboard api, tunneled through the jump hosts when there are any
+func (context *MySQL) setTraffic(proxy string, enabled bool) (err error) {
+	var (
+		address command.SshConfig
+		client  *http.Client
+		req     *http.Request
+		res     *http.Response
+	)
+	if address, err = context.SSHTunnels.TunnelSSHConfig(command.SshConfig{Host: proxy, Port: context.ProxyAPIPort}); err != nil {
+		return
+	}
+	if client, err = context.httpClient(); err != nil {
+		return
+	}
+
+	endpoint := fmt.Sprintf(MySQLProxyClusterURL, address.Host, address.Port, enabled, url.QueryEscape(MySQLProxyDrainMessage))
+	if req, err = http.NewRequest("PATCH", endpoint, nil); err != nil {
+		return errwrap.Wrap(err, "failed creating the switchboard request")
+	}
+	req.SetBasicAuth(context.ProxyUser, context.ProxyPass)
+
+	lo.G.Debugf("setting the traffic of mysql proxy %s to %t", proxy, enabled)
+	if res, err = client.Do(req); err != nil {
+		return errwrap.Wrap(err, "failed calling the switchboard api")
+	}
+	defer res.Body.Close()
+	if res.StatusCode != http.StatusOK && res.StatusCode != http.StatusNoContent {
+		body, _ := ioutil.ReadAll(res.Body)
+		return fmt.Errorf("switchboard api of proxy %s answered %d: %s", proxy, res.StatusCode, body)
+	}
+	return
+}
+
+func (context *MySQL) httpClient() (client *http.Client, err error) {
+	if context.client == nil {
+		context.client, err = context.TLS.HTTPClient()
+	}
+	return context.client, err
+}
+
+//bootstrap - stops every node, marks the seed to bootstrap a new cluster and
+//starts it alone for the action. the other nodes are started one at a time
+//afterwards and join the new cluster. the nodes are started again when any
+//step fails, so the cluster is not left down
+func (context *MySQL) bootstrap(action func() error) (err error) {
+	director, err := cfbackup.NewDirector(context.Director.Ip, context.Director.User, context.Director.Pass, 25555, context.DirectorTLS)
+	if err != nil {
+		return errwrap.Wrap(err, "failed creating new director")
+	}
+
+	seed, others, err := context.nodeInstances(director)
+	if err != nil {
+		return
+	}
+
+	for i, instance := range others {
+		lo.G.Infof("stopping mysql node %s/%d", instance.Job, instance.Index)
+		if err = cfbackup.ChangeJobStateAndReport(director, context.BackupContext, context.DeploymentName, instance.Job, "stopped", instance.Index); err != nil {
+			return context.startNodes(director, others[:i], errwrap.Wrap(err, "failed stopping the mysql nodes"))
+		}
+	}
+	lo.G.Infof("stopping mysql node %s/%d", seed.Job, seed.Index)
+	if err = cfbackup.ChangeJobStateAndReport(director, context.BackupContext, context.DeploymentName, seed.Job, "stopped", seed.Index); err != nil {
+		return context.startNodes(director, others, errwrap.Wrap(err, "failed stopping the mysql nodes"))
+	}
+
+	if err = context.markSeedForBootstrap(); err != nil {
+		return context.startNodes(director, append([]cfbackup.BoshInstance{seed}, others...), err)
+	}
+	lo.G.Infof("bootstrapping the mysql cluster on %s/%d", seed.Job, seed.Index)
+	if err = cfbackup.ChangeJobStateAndReport(director, context.BackupContext, context.DeploymentName, seed.Job, "started", seed.Index); err != nil {
+		return context.startNodes(director, append([]cfbackup.BoshInstance{seed}, others...), errwrap.Wrap(err, "failed bootstrapping the seed node"))
+	}
+
+	if err = action(); err != nil {
+		return context.startNodes(director, others, err)
+	}
+
+	lo.G.Info("joining the other mysql nodes to the cluster")
+	if err = context.startNodes(director, others, nil); err != nil {
+		return errwrap.Wrap(err, "failed starting the mysql nodes")
+	}
+	return
+}
+
+//startNodes - starts each node, going on past the ones failing to start.
+//with a cause, the error of a failed step the nodes are started again after,
+//it returns the cause along with any failure starting them
+func (context *MySQL) startNodes(director cfbackup.Bosh, instances []cfbackup.BoshInstance, cause error) error {
+	var failed []string
+	for _, instance := range instances {
+		lo.G.Infof("starting mysql node %s/%d", instance.Job, instance.Index)
+		if err := cfbackup.ChangeJobStateAndReport(director, context.BackupContext, context.DeploymentName, instance.Job, "started", instance.Index); err != nil {
+			lo.G.Errorf("failed starting mysql node %s/%d: %v", instance.Job, instance.Index, err)
+			failed = append(failed, fmt.Sprintf("%s/%d: %v", instance.Job, instance.Index, err))
+		}
+	}
+
+	switch {
+	case len(failed) == 0:
+		return cause
+	case cause == nil:
+		return errors.New(strings.Join(failed, ", "))
+	}
+	return errwrap.Wrapf(cause, "starting the mysql nodes again also failed: %s", strings.Join(failed, ", "))
+}
+
+//nodeInstances - the instance of the seed and those of the other nodes, as
+//listed by the director
+func (context *MySQL) nodeInstances(director cfbackup.Bosh) (seed cfbackup.BoshInstance, others []cfbackup.BoshInstance, err error) {
+	var instances []cfbackup.BoshInstance
+	if instances, err = director.GetInstances(context.DeploymentName); err != nil {
+		return seed, nil, errwrap.Wrap(err, "failed listing the p-mysql instances")
+	}
+
+	found := false
+	for _, instance := range instances {
+		if instance.Job != MySQLJob && !strings.HasPrefix(instance.Job, MySQLJob+"-partition-") {
+			continue
+		}
+		if !found && instance.HasIP(context.Seed.Ip) {
+			seed, found = instance, true
+			continue
+		}
+		others = append(others, instance)
+	}
+	if !found {
+		err = ErrMySQLSeedNotFound
+	}
+	return
+}
+
+func (context *MySQL) markSeedForBootstrap() (err error) {
+	var (
+		config   command.SshConfig
+		executer command.Executer
+	)
+	if config, err = context.SSHTunnels.TunnelSSHConfig(command.SshConfig{
+		Username: context.Seed.VcapUser,
+		Password: context.Seed.VcapPass,
+		Host:     context.Seed.Ip,
+		Port:     22,
+		SSLKey:   context.Seed.SSHPrivateKey,
+	}); err != nil {
+		return
+	}
+	if executer, err = NewRemoteExecuter(config); err != nil {
+		return errwrap.Wrap(err, "failed connecting to the seed node")
+	}
+	if err = executer.Execute(ioutil.Discard, fmt.Sprintf("echo -n %s > %s", MySQLNeedsBootstrap, MySQLStateFile)); err != nil {
+		return errwrap.Wrap(err, "failed marking the seed node for bootstrap")
+	}
+	return
+}
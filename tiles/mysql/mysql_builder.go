@@ -0,0 +1,26 @@
+package mysql
+
+import (
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tiles/opsmanager"
+)
+
+//New -- builds the mysql tile from the installation settings of ops manager
+func (s *MySQLBuilder) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	return tileSpec.Build(MySQLTileName, s.build)
+}
+
+func (s *MySQLBuilder) build(tileSpec tileregistry.TileSpec) (mysqlCloser tileregistry.TileCloser, err error) {
+	var (
+		installation *opsmanager.TileInstallation
+		mysql        *MySQL
+	)
+	if installation, err = opsmanager.NewTileInstallation(tileSpec); err != nil {
+		return
+	}
+	if mysql, err = NewMySQL(installation.InstallationInfo, installation.Settings.GetBoshName(), installation.BackupContext, installation.SSHKey); err != nil {
+		return
+	}
+	mysqlCloser = installation.TileCloser(mysql)
+	return
+}
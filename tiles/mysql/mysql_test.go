@@ -0,0 +1,272 @@
+package mysql_test
+
+import (
+	"errors"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/fakes"
+	. "github.com/pivotalservices/cfbackup/tiles/mysql"
+	mysqlfakes "github.com/pivotalservices/cfbackup/tiles/mysql/fakes"
+	"github.com/pivotalservices/gtils/command"
+)
+
+var _ = Describe("MySQL", func() {
+	var (
+		tmpDir         string
+		backupContext  cfbackup.BackupContext
+		mysql          *MySQL
+		proxies        []*mysqlfakes.FakeSwitchboard
+		dump           *mysqlfakes.FakeMySQLDump
+		executer       *mysqlfakes.FakeExecuter
+		server         *fakes.FakeDirectorServer
+		seedConfig     command.SshConfig
+		deploymentName = "p-mysql-ceca21ea01fef0dc61d1"
+
+		originalNewDirector       = cfbackup.NewDirector
+		originalNewMySQLDump      = NewMySQLDump
+		originalNewRemoteExecuter = NewRemoteExecuter
+		originalTaskPingFreq      = cfbackup.TaskPingFreq
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cfbackup-mysql")
+		Ω(err).ShouldNot(HaveOccurred())
+		backupContext = cfbackup.NewBackupContext(tmpDir, map[string]string{}, "")
+
+		config := cfbackup.NewConfigurationParser("../../fixtures/installation-settings-1-4-variant.json")
+		mysql, err = NewMySQL(&config.InstallationSettings, config.InstallationSettings.GetBoshName(), backupContext, "ssh-key")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("NewMySQL", func() {
+		It("then it should find the deployment of the p-mysql product", func() {
+			Ω(mysql.DeploymentName).Should(Equal(deploymentName))
+		})
+
+		It("then it should resolve the nodes and seed from the first one", func() {
+			Ω(mysql.Nodes).Should(HaveLen(3))
+			Ω(mysql.Seed.Ip).Should(Equal(mysql.Nodes[0]))
+			Ω(mysql.Seed.User).Should(Equal("root"))
+			Ω(mysql.Seed.Pass).ShouldNot(BeEmpty())
+			Ω(mysql.Seed.SSHPrivateKey).Should(Equal("ssh-key"))
+		})
+
+		It("then it should resolve the proxies and their credentials", func() {
+			Ω(mysql.Proxies).Should(HaveLen(2))
+			Ω(mysql.ProxyUser).Should(Equal("admin"))
+			Ω(mysql.ProxyAPIPort).Should(Equal(MySQLProxyAPIPort))
+		})
+
+		It("then it should resolve the director", func() {
+			Ω(mysql.Director.User).Should(Equal("director"))
+		})
+
+		Context("when the installation has no p-mysql product", func() {
+			It("then it should fail", func() {
+				config := cfbackup.NewConfigurationParser("../../fixtures/installation-settings-1-6.json")
+				_, err := NewMySQL(&config.InstallationSettings, config.InstallationSettings.GetBoshName(), backupContext, "")
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("against fake proxies and a fake director", func() {
+		BeforeEach(func() {
+			proxy := mysqlfakes.NewFakeSwitchboard(mysql.ProxyUser, mysql.ProxyPass)
+			host, port := proxy.Address()
+			proxies = []*mysqlfakes.FakeSwitchboard{proxy}
+			mysql.Proxies = []string{host}
+			mysql.ProxyAPIPort = port
+
+			dump = &mysqlfakes.FakeMySQLDump{Data: []byte("-- mysql dump"), Proxies: proxies}
+			NewMySQLDump = func(seed cfbackup.SystemDump) (cfbackup.PersistanceBackup, error) {
+				return dump, nil
+			}
+			executer = &mysqlfakes.FakeExecuter{}
+			NewRemoteExecuter = func(config command.SshConfig) (command.Executer, error) {
+				seedConfig = config
+				return executer, nil
+			}
+
+			cfbackup.TaskPingFreq = time.Millisecond
+			server = fakes.NewFakeDirectorServer(mysql.Director.User, mysql.Director.Pass)
+			server.AddDeployment(deploymentName, "manifest", []cfbackup.VMObject{
+				cfbackup.VMObject{Job: "mysql-partition-abc", Index: 0, IPs: []string{mysql.Nodes[1]}, State: cfbackup.BOSHJobStateRunning},
+				cfbackup.VMObject{Job: "mysql-partition-abc", Index: 1, IPs: []string{mysql.Nodes[0]}, State: cfbackup.BOSHJobStateRunning},
+				cfbackup.VMObject{Job: "mysql-partition-abc", Index: 2, IPs: []string{mysql.Nodes[2]}, State: cfbackup.BOSHJobStateRunning},
+				cfbackup.VMObject{Job: "proxy-partition-abc", Index: 0, IPs: []string{"10.0.0.1"}, State: cfbackup.BOSHJobStateRunning},
+			})
+			cfbackup.NewDirector = server.DirectorCreator(originalNewDirector)
+		})
+
+		AfterEach(func() {
+			for _, proxy := range proxies {
+				proxy.Close()
+			}
+			server.Close()
+			cfbackup.NewDirector = originalNewDirector
+			cfbackup.TaskPingFreq = originalTaskPingFreq
+			NewMySQLDump = originalNewMySQLDump
+			NewRemoteExecuter = originalNewRemoteExecuter
+		})
+
+		Describe("Backup", func() {
+			It("then it should dump the seed with the proxies drained", func() {
+				Ω(mysql.Backup()).Should(Succeed())
+				Ω(dump.Undrained).Should(Equal(0))
+				archive, err := ioutil.ReadFile(path.Join(tmpDir, MySQLBackupDir, MySQLBackupFileName))
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(archive).Should(Equal(dump.Data))
+			})
+
+			It("then it should enable traffic again", func() {
+				Ω(mysql.Backup()).Should(Succeed())
+				Ω(proxies[0].TrafficEnabled()).Should(BeTrue())
+				Ω(proxies[0].Requests()).Should(HaveLen(2))
+			})
+
+			It("then it should leave the cluster running", func() {
+				Ω(mysql.Backup()).Should(Succeed())
+				Ω(server.Requests()).ShouldNot(ContainElement(ContainSubstring("PUT")))
+			})
+
+			Context("when the dump fails", func() {
+				BeforeEach(func() {
+					dump.ErrFake = errors.New("dump failed")
+				})
+
+				It("then it should fail and still enable traffic again", func() {
+					Ω(mysql.Backup()).ShouldNot(Succeed())
+					Ω(proxies[0].TrafficEnabled()).Should(BeTrue())
+				})
+			})
+
+			Context("when a proxy can not be drained", func() {
+				BeforeEach(func() {
+					proxies[0].FailNextRequest(500)
+				})
+
+				It("then it should fail without dumping", func() {
+					Ω(mysql.Backup()).ShouldNot(Succeed())
+					Ω(dump.Undrained).Should(Equal(0))
+					Ω(proxies[0].TrafficEnabled()).Should(BeTrue())
+				})
+			})
+		})
+
+		Describe("Restore", func() {
+			BeforeEach(func() {
+				Ω(os.MkdirAll(path.Join(tmpDir, MySQLBackupDir), 0755)).Should(Succeed())
+				Ω(ioutil.WriteFile(path.Join(tmpDir, MySQLBackupDir, MySQLBackupFileName), dump.Data, 0644)).Should(Succeed())
+			})
+
+			It("then it should import the archive into the seed with the proxies drained", func() {
+				Ω(mysql.Restore()).Should(Succeed())
+				Ω(dump.Imported).Should(Equal(dump.Data))
+				Ω(dump.Undrained).Should(Equal(0))
+				Ω(proxies[0].TrafficEnabled()).Should(BeTrue())
+			})
+
+			It("then it should mark the seed for bootstrapping", func() {
+				Ω(mysql.Restore()).Should(Succeed())
+				Ω(seedConfig.Host).Should(Equal(mysql.Nodes[0]))
+				Ω(executer.Commands()).Should(ConsistOf(ContainSubstring(MySQLNeedsBootstrap)))
+			})
+
+			It("then it should stop every node and start the seed before the others", func() {
+				Ω(mysql.Restore()).Should(Succeed())
+				var changes []string
+				for _, request := range server.Requests() {
+					if len(request) > 3 && request[:3] == "PUT" {
+						changes = append(changes, request)
+					}
+				}
+				jobs := "PUT /deployments/" + deploymentName + "/jobs/mysql-partition-abc/"
+				Ω(changes).Should(HaveLen(6))
+				Ω(changes[2]).Should(HavePrefix(jobs + "1"))
+				Ω(changes[3]).Should(HavePrefix(jobs + "1"))
+				for _, index := range []int{0, 1, 2} {
+					Ω(server.JobState(deploymentName, "mysql-partition-abc", index)).Should(Equal(cfbackup.BOSHJobStateRunning))
+				}
+			})
+
+			Context("when a node can not be stopped", func() {
+				BeforeEach(func() {
+					server.FailJobState("mysql-partition-abc", 0, cfbackup.BOSHJobStateStopped, "disk full")
+				})
+
+				It("then it should fail without importing and enable traffic again", func() {
+					err := mysql.Restore()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("disk full"))
+					Ω(dump.Imported).Should(BeNil())
+					Ω(proxies[0].TrafficEnabled()).Should(BeTrue())
+				})
+			})
+
+			Context("when a node can not be stopped after others were", func() {
+				BeforeEach(func() {
+					server.FailJobState("mysql-partition-abc", 2, cfbackup.BOSHJobStateStopped, "disk full")
+				})
+
+				It("then it should fail and start the stopped nodes again", func() {
+					err := mysql.Restore()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("disk full"))
+					for _, index := range []int{0, 1} {
+						Ω(server.JobState(deploymentName, "mysql-partition-abc", index)).Should(Equal(cfbackup.BOSHJobStateRunning))
+					}
+				})
+			})
+
+			Context("when the seed can not be started", func() {
+				BeforeEach(func() {
+					server.FailJobState("mysql-partition-abc", 1, "started", "agent timed out")
+				})
+
+				It("then it should fail and try starting the seed again along with the other nodes", func() {
+					err := mysql.Restore()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("agent timed out"))
+					Ω(dump.Imported).Should(BeNil())
+					var seedChanges int
+					for _, request := range server.Requests() {
+						if request == "PUT /deployments/"+deploymentName+"/jobs/mysql-partition-abc/1" {
+							seedChanges++
+						}
+					}
+					Ω(seedChanges).Should(Equal(3))
+					for _, index := range []int{0, 2} {
+						Ω(server.JobState(deploymentName, "mysql-partition-abc", index)).Should(Equal(cfbackup.BOSHJobStateRunning))
+					}
+				})
+			})
+
+			Context("when the import fails", func() {
+				BeforeEach(func() {
+					dump.ErrFake = errors.New("import failed")
+				})
+
+				It("then it should fail and join the other nodes to the cluster again", func() {
+					err := mysql.Restore()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("import failed"))
+					for _, index := range []int{0, 1, 2} {
+						Ω(server.JobState(deploymentName, "mysql-partition-abc", index)).Should(Equal(cfbackup.BOSHJobStateRunning))
+					}
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,34 @@
+package mysql
+
+import (
+	"net/http"
+
+	"github.com/pivotalservices/cfbackup"
+)
+
+type (
+	//MySQL - the mysql service tile: a galera cluster behind switchboard
+	//proxies. it is backed up with a dump of a single node taken while the
+	//proxies send no traffic to the cluster, and restored by bootstrapping a
+	//new cluster from that node
+	MySQL struct {
+		cfbackup.BackupContext
+		DeploymentName string
+		//Seed - the node the dump is taken from and restored on
+		Seed *cfbackup.MysqlInfo
+		//Nodes - the ips of every node of the cluster, the seed first
+		Nodes []string
+		//Proxies - the ips of the switchboard proxies
+		Proxies      []string
+		ProxyUser    string
+		ProxyPass    string
+		ProxyAPIPort int
+		//Director - the director the nodes are stopped and started through
+		Director *cfbackup.SystemInfo
+		client   *http.Client
+	}
+
+	//MySQLBuilder - builds the mysql tile from the installation settings of
+	//ops manager
+	MySQLBuilder struct{}
+)
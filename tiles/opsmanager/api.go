@@ -0,0 +1,149 @@
+package opsmanager
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+
+	"github.com/pivotalservices/cfbackup"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//apiEndpoints - the url format of each endpoint by api version, endpoints
+//are named by their legacy url format
+var apiEndpoints = map[string]map[string]string{
+	OpsMgrAPIVersionLegacy: {
+		OpsMgrInstallationSettingsURL: OpsMgrInstallationSettingsURL,
+		OpsMgrInstallationAssetsURL:   OpsMgrInstallationAssetsURL,
+		OpsMgrInstallURL:              OpsMgrInstallURL,
+		OpsMgrInstallStatusURL:        OpsMgrInstallStatusURL,
+		OpsMgrInstallLogsURL:          OpsMgrInstallLogsURL,
+	},
+	OpsMgrAPIVersionV0: {
+		OpsMgrInstallationSettingsURL: OpsMgrV0InstallationSettingsURL,
+		OpsMgrInstallationAssetsURL:   OpsMgrV0InstallationAssetsURL,
+		OpsMgrInstallURL:              OpsMgrV0InstallURL,
+		OpsMgrInstallStatusURL:        OpsMgrV0InstallStatusURL,
+		OpsMgrInstallLogsURL:          OpsMgrV0InstallLogsURL,
+	},
+}
+
+func (s *HTTPStatusError) Error() string {
+	return s.Message
+}
+
+//GetDeployedProducts - returns the products deployed by ops manager, only
+//served by the v0 api
+func (context *OpsManager) GetDeployedProducts() (products []DeployedProduct, err error) {
+	err = context.getJSON(fmt.Sprintf(OpsMgrV0DeployedProductsURL, context.Hostname), &products)
+	return
+}
+
+//FindProductGUID - returns the guid of the deployed product of a type, for
+//installation settings without product guids
+func (context *OpsManager) FindProductGUID(productType string) (guid string, err error) {
+	var products []DeployedProduct
+	if products, err = context.GetDeployedProducts(); err != nil {
+		return
+	}
+	for _, product := range products {
+		if product.Type == productType {
+			return product.GUID, nil
+		}
+	}
+	return "", fmt.Errorf("no deployed product of type %s", productType)
+}
+
+//GetCredential - returns a credential of a deployed product from the v0
+//credentials api
+func (context *OpsManager) GetCredential(productGUID, reference string) (credential cfbackup.Credential, err error) {
+	var response struct {
+		Credential cfbackup.Credential `json:"credential"`
+	}
+	if err = context.getJSON(fmt.Sprintf(OpsMgrV0CredentialURL, context.Hostname, productGUID, reference), &response); err == nil {
+		credential = response.Credential
+	}
+	return
+}
+
+//apiURL - the url of an endpoint for the api version of the ops manager, the
+//legacy url until the version is detected
+func (context *OpsManager) apiURL(urlFormat string, args ...interface{}) string {
+	version := context.APIVersion
+	if version == "" {
+		version = OpsMgrAPIVersionLegacy
+	}
+	if versionedFormat, ok := apiEndpoints[version][urlFormat]; ok {
+		urlFormat = versionedFormat
+	}
+	return fmt.Sprintf(urlFormat, append([]interface{}{context.Hostname}, args...)...)
+}
+
+//withAPIVersion - runs an api call, detecting the api version on the first
+//one. an ops manager answering 404 on the legacy api only serves the v0 api,
+//so the call is retried against it
+func (context *OpsManager) withAPIVersion(call func() error) (err error) {
+	if err = call(); context.APIVersion != "" {
+		return
+	}
+
+	switch {
+	case err == nil:
+		context.APIVersion = OpsMgrAPIVersionLegacy
+
+	case isNotFound(err):
+		lo.G.Info("legacy ops manager api not found, switching to the v0 api")
+		context.APIVersion = OpsMgrAPIVersionV0
+		if err = call(); isNotFound(err) {
+			context.APIVersion = ""
+		}
+	}
+	lo.G.Debug("ops manager api version", context.APIVersion)
+	return
+}
+
+func (context *OpsManager) getJSON(url string, v interface{}) (err error) {
+	var body bytes.Buffer
+	if err = context.saveHTTPResponse(url, &body); err == nil {
+		if err = json.Unmarshal(body.Bytes(), v); err != nil {
+			err = errwrap.Wrap(err, "unable to unmarshal ops manager response")
+		}
+	}
+	return
+}
+
+//sendJSON - sends a json api request, decoding the response into v when
+//it is not nil
+func (context *OpsManager) sendJSON(method, url string, body, v interface{}) (err error) {
+	var payload []byte
+	if body != nil {
+		if payload, err = json.Marshal(body); err != nil {
+			return errwrap.Wrap(err, "unable to marshal ops manager request")
+		}
+	}
+
+	var resp *http.Response
+	if resp, err = context.sendHTTPRequest(method, url, payload); err != nil {
+		return
+	}
+	defer resp.Body.Close()
+
+	if resp.StatusCode != http.StatusOK {
+		errMsg, _ := ioutil.ReadAll(resp.Body)
+		return &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Message: string(errMsg)}
+	}
+	if v != nil {
+		if err = json.NewDecoder(resp.Body).Decode(v); err != nil {
+			err = errwrap.Wrap(err, "unable to unmarshal ops manager response")
+		}
+	}
+	return
+}
+
+func isNotFound(err error) bool {
+	statusErr, ok := errwrap.Cause(err).(*HTTPStatusError)
+	return ok && statusErr.StatusCode == http.StatusNotFound
+}
@@ -1,7 +1,15 @@
 package opsmanager
 
+import (
+	"errors"
+	"time"
+)
+
 //OpsManager constants
 const (
+	//OpsManagerTileName - the name the ops manager tile is registered and run
+	//its hooks under
+	OpsManagerTileName                    string = "ops-manager"
 	OpsMgrInstallationSettingsFilename    string = "installation.json"
 	OpsMgrInstallationAssetsFileName      string = "installation.zip"
 	OpsMgrInstallationAssetsPostFieldName string = "installation[file]"
@@ -13,4 +21,61 @@ const (
 	OpsMgrInstallationSettingsURL         string = "https://%s/api/installation_settings"
 	OpsMgrInstallationAssetsURL           string = "https://%s/api/installation_asset_collection"
 	OpsMgrDeploymentsFile                 string = "/var/tempest/workspaces/default/deployments/bosh-deployments.yml"
+	OpsMgrDirectorStateFile               string = "/var/tempest/workspaces/default/deployments/bosh-state.json"
+	OpsMgrWorkspaceDir                    string = "/var/tempest/workspaces/default"
+	OpsMgrWorkspaceOwner                  string = "tempest-web:tempest-web"
+	OpsMgrRemoteDeploymentsArchive        string = "/tmp/deployments.tar.gz"
+	OpsMgrV0InstallationSettingsURL       string = "https://%s/api/v0/installation_settings"
+	OpsMgrV0InstallationAssetsURL         string = "https://%s/api/v0/installation_asset_collection"
+	OpsMgrV0DeployedProductsURL           string = "https://%s/api/v0/deployed/products"
+	OpsMgrV0CredentialURL                 string = "https://%s/api/v0/deployed/products/%s/credentials/%s"
+	OpsMgrInstallURL                      string = "https://%s/api/installation?ignore_warnings=true"
+	OpsMgrInstallStatusURL                string = "https://%s/api/installation/%d"
+	OpsMgrInstallLogsURL                  string = "https://%s/api/installation/%d/logs"
+	OpsMgrV0InstallURL                    string = "https://%s/api/v0/installations"
+	OpsMgrV0InstallStatusURL              string = "https://%s/api/v0/installations/%d"
+	OpsMgrV0InstallLogsURL                string = "https://%s/api/v0/installations/%d/logs"
+	OpsMgrV0UnlockURL                     string = "https://%s/api/v0/unlock"
+	OpsMgrUAATokenURL                     string = "https://%s/uaa/oauth/token"
+	OpsMgrUAAClient                       string = "opsman"
+)
+
+//Ops Manager install states
+const (
+	OpsMgrInstallRunning   string = "running"
+	OpsMgrInstallSucceeded string = "succeeded"
+	OpsMgrInstallFailed    string = "failed"
+)
+
+//Ops Manager restore timeouts
+const (
+	OpsMgrDefaultImportTimeout  time.Duration = 30 * time.Minute
+	OpsMgrDefaultInstallTimeout time.Duration = 6 * time.Hour
+)
+
+//OpsMgrPollInterval - how long we wait between polls of an import or install
+var OpsMgrPollInterval = 10 * time.Second
+
+var (
+	//ErrOpsMgrImportTimeout - the import did not finish within the import timeout
+	ErrOpsMgrImportTimeout = errors.New("timed out waiting for ops manager to import the installation")
+	//ErrOpsMgrInstallTimeout - apply changes did not finish within the install timeout
+	ErrOpsMgrInstallTimeout = errors.New("timed out waiting for ops manager to apply changes")
+	//ErrOpsMgrInstallFailed - apply changes ended in failure
+	ErrOpsMgrInstallFailed = errors.New("ops manager failed to apply changes")
+	//ErrOpsMgrPassphraseRejected - the passphrase does not unlock the restored installation
+	ErrOpsMgrPassphraseRejected = errors.New("the passphrase does not unlock the restored installation")
+)
+
+//Ops Manager api versions
+const (
+	OpsMgrAPIVersionLegacy string = "legacy"
+	OpsMgrAPIVersionV0     string = "v0"
+)
+
+//Ops Manager auth modes, how api calls are authorized
+const (
+	OpsMgrAuthModeToken string = "token"
+	OpsMgrAuthModeUAA   string = "uaa"
+	OpsMgrAuthModeBasic string = "basic"
 )
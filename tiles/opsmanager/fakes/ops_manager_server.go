@@ -1,6 +1,21 @@
 package fakes
 
-import "github.com/onsi/gomega/ghttp"
+import (
+	"crypto/subtle"
+	"encoding/json"
+	"encoding/pem"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"regexp"
+	"strconv"
+	"strings"
+	"sync"
+
+	"github.com/onsi/gomega/ghttp"
+)
 
 //NewFakeOpsManagerServer - spins up a fake ops manager server
 func NewFakeOpsManagerServer(server *ghttp.Server, authStatusCode int, authResponseBody string, genericStatusCode int, genericResponseBody string) *ghttp.Server {
@@ -10,3 +25,495 @@ func NewFakeOpsManagerServer(server *ghttp.Server, authStatusCode int, authRespo
 	server.RouteToHandler("GET", "/api/installation_settings", genericHandler)
 	return server
 }
+
+//Endpoints and defaults of the FakeOpsManager
+const (
+	FakeOpsManagerTokenPath       = "/uaa/oauth/token"
+	FakeOpsManagerSettingsPath    = "/api/installation_settings"
+	FakeOpsManagerAssetsPath      = "/api/installation_asset_collection"
+	FakeOpsManagerV0Prefix        = "/api/v0"
+	FakeOpsManagerProductsPath    = "/api/v0/deployed/products"
+	FakeOpsManagerUnlockPath      = "/api/v0/unlock"
+	FakeOpsManagerAssetsFieldName = "installation[file]"
+	FakeOpsManagerDefaultClientID = "opsman"
+	FakeOpsManagerDefaultSettings = `{"infrastructure":{"type":"vsphere"},"products":[]}`
+	FakeOpsManagerDefaultAssets   = "installation assets"
+	FakeOpsManagerTokenLifetime   = 43199
+)
+
+const (
+	fakeOpsManagerMaxUploadMemory     = 32 << 20
+	fakeOpsManagerPassphraseMismatch  = "passphrase does not unlock the installation"
+	fakeOpsManagerUnauthorizedMessage = "unauthorized"
+	fakeOpsManagerImportingMessage    = "Ops Manager is importing the installation"
+)
+
+//fakeOpsManagerInstallPath - the apply changes endpoints, installation in the
+//legacy api and installations in the v0 api
+var fakeOpsManagerInstallPath = regexp.MustCompile(`^/api/installations?(?:/(\d+)(/logs)?)?$`)
+
+//FakeOpsManager - an in-process ops manager serving the installation
+//settings and asset collection over tls. with LegacyAuth set it behaves like
+//a pre 1.7 ops manager, no uaa and basic auth on the api, otherwise the api
+//takes bearer tokens issued by its uaa. a fresh ops manager, one without an
+//installation, accepts an import without authentication like the real one
+//does, as long as the passphrase matches. with V0Only set only the v0 api is
+//served, the way newer ops managers do. an import keeps ops manager busy for
+//ImportPolls requests, after which its uaa refuses the next
+//ImportUnauthorizedPolls token requests as it does until the restored users
+//are in place, apply changes runs for InstallPolls status polls and
+//FailExtract makes extracting an uploaded deployments archive fail. tokens
+//expire after TokenLifetime seconds, FakeOpsManagerTokenLifetime when zero,
+//and user tokens come with a refresh token. with SAML set the uaa has no
+//local users and only hands out tokens to the ClientID client
+type FakeOpsManager struct {
+	*httptest.Server
+	Username     string
+	Password     string
+	ClientID     string
+	ClientSecret string
+	Passphrase   string
+	LegacyAuth   bool
+	V0Only       bool
+	SAML         bool
+	ImportPolls  int
+	InstallPolls int
+	FailInstall  bool
+	FailExtract  bool
+	//TokenLifetime - the expires_in of the tokens issued, in seconds
+	TokenLifetime int
+	//ImportUnauthorizedPolls - the token requests refused once an import
+	//is done
+	ImportUnauthorizedPolls int
+
+	mutex       sync.Mutex
+	settings    []byte
+	assets      []byte
+	deployments []byte
+	setAside    []byte
+	upload      []byte
+	products    []fakeOpsManagerProduct
+	credentials map[string]interface{}
+	tokens      map[string]bool
+	refresh     map[string]string
+	grants      []string
+	lastToken   int
+	importing   int
+	refusing    int
+	installs    []*fakeOpsManagerInstall
+	failures    []fakeOpsManagerFailure
+	requests    []string
+	commands    []string
+}
+
+type fakeOpsManagerProduct struct {
+	InstallationName string `json:"installation_name"`
+	GUID             string `json:"guid"`
+	Type             string `json:"type"`
+	ProductVersion   string `json:"product_version"`
+}
+
+type fakeOpsManagerInstall struct {
+	polls  int
+	status string
+	logs   string
+}
+
+type fakeOpsManagerFailure struct {
+	method     string
+	path       string
+	statusCode int
+}
+
+//NewFakeOpsManager - starts a fake ops manager with an installation, whose
+//admin user is username/password and whose installation is unlocked by
+//passphrase
+func NewFakeOpsManager(username, password, passphrase string) *FakeOpsManager {
+	s := NewFreshFakeOpsManager(username, password, passphrase)
+	s.SetInstallation([]byte(FakeOpsManagerDefaultSettings), []byte(FakeOpsManagerDefaultAssets))
+	s.SetDeployments([]byte("deployments"))
+	return s
+}
+
+//NewFreshFakeOpsManager - starts a fake ops manager without an installation,
+//as a restore target
+func NewFreshFakeOpsManager(username, password, passphrase string) *FakeOpsManager {
+	s := &FakeOpsManager{
+		Username:    username,
+		Password:    password,
+		Passphrase:  passphrase,
+		tokens:      make(map[string]bool),
+		refresh:     make(map[string]string),
+		credentials: make(map[string]interface{}),
+	}
+	s.Server = httptest.NewTLSServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+//Host - the host:port to point an ops manager client at
+func (s *FakeOpsManager) Host() string {
+	u, _ := url.Parse(s.URL)
+	return u.Host
+}
+
+//SetInstallation - replaces the installation settings and assets served
+func (s *FakeOpsManager) SetInstallation(settings, assets []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.settings = settings
+	s.assets = assets
+}
+
+//Installation - the installation settings and assets currently held, nil
+//for a fresh ops manager
+func (s *FakeOpsManager) Installation() (settings, assets []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.settings, s.assets
+}
+
+//SetDeployments - replaces the contents of the deployments tarball served
+//over the ssh stand-in
+func (s *FakeOpsManager) SetDeployments(deployments []byte) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.deployments = deployments
+}
+
+//AddDeployedProduct - adds a product to the deployed products of the v0 api
+func (s *FakeOpsManager) AddDeployedProduct(installationName, guid, productType, productVersion string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.products = append(s.products, fakeOpsManagerProduct{
+		InstallationName: installationName,
+		GUID:             guid,
+		Type:             productType,
+		ProductVersion:   productVersion,
+	})
+}
+
+//SetCredential - sets a credential served by the v0 credentials api
+func (s *FakeOpsManager) SetCredential(productGUID, reference, credentialType string, value map[string]string) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.credentials[productGUID+"/"+reference] = map[string]interface{}{
+		"type":  credentialType,
+		"value": value,
+	}
+}
+
+//InstallStatus - the status of the last apply changes, empty when changes
+//were never applied
+func (s *FakeOpsManager) InstallStatus() string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	if len(s.installs) == 0 {
+		return ""
+	}
+	return s.installs[len(s.installs)-1].status
+}
+
+//FailNextRequest - answers the next request for method and path with the
+//given status code instead of serving it
+func (s *FakeOpsManager) FailNextRequest(method, path string, statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failures = append(s.failures, fakeOpsManagerFailure{method: method, path: path, statusCode: statusCode})
+}
+
+//TokenGrants - the grant type of every token request so far
+func (s *FakeOpsManager) TokenGrants() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.grants...)
+}
+
+//CertificatePEM - the self signed certificate the server presents, pem encoded
+func (s *FakeOpsManager) CertificatePEM() string {
+	return string(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: s.TLS.Certificates[0].Certificate[0]}))
+}
+
+//Requests - every request served so far as "METHOD /path"
+func (s *FakeOpsManager) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+func (s *FakeOpsManager) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+
+	for i, failure := range s.failures {
+		if failure.method == req.Method && failure.path == req.URL.Path {
+			s.failures = append(s.failures[:i], s.failures[i+1:]...)
+			http.Error(w, "scripted failure", failure.statusCode)
+			return
+		}
+	}
+
+	if s.importing > 0 {
+		s.importing--
+		http.Error(w, fakeOpsManagerImportingMessage, http.StatusServiceUnavailable)
+		return
+	}
+
+	apiPath := req.URL.Path
+	switch {
+	case strings.HasPrefix(apiPath, FakeOpsManagerV0Prefix+"/") && !s.LegacyAuth:
+		apiPath = "/api" + strings.TrimPrefix(apiPath, FakeOpsManagerV0Prefix)
+	case strings.HasPrefix(apiPath, "/api/") && s.V0Only:
+		apiPath = ""
+	}
+
+	switch {
+	case req.URL.Path == FakeOpsManagerTokenPath && req.Method == "POST":
+		s.serveToken(w, req)
+
+	case apiPath == FakeOpsManagerSettingsPath && req.Method == "GET":
+		s.serveDownload(w, req, s.settings, "application/json")
+
+	case apiPath == FakeOpsManagerAssetsPath && req.Method == "GET":
+		s.serveDownload(w, req, s.assets, "application/octet-stream")
+
+	case apiPath == FakeOpsManagerAssetsPath && req.Method == "POST":
+		s.serveImport(w, req)
+
+	case req.URL.Path == FakeOpsManagerProductsPath && !s.LegacyAuth && req.Method == "GET":
+		s.serveJSON(w, req, s.products)
+
+	case strings.HasPrefix(req.URL.Path, FakeOpsManagerProductsPath+"/") && !s.LegacyAuth && req.Method == "GET":
+		s.serveCredential(w, req)
+
+	case req.URL.Path == FakeOpsManagerUnlockPath && !s.LegacyAuth && req.Method == "PUT":
+		s.serveUnlock(w, req)
+
+	case fakeOpsManagerInstallPath.MatchString(apiPath):
+		s.serveInstall(w, req, fakeOpsManagerInstallPath.FindStringSubmatch(apiPath))
+
+	default:
+		http.NotFound(w, req)
+	}
+}
+
+//serveToken - the uaa token endpoint, taking the client either from basic
+//auth or the form like the uaa does
+func (s *FakeOpsManager) serveToken(w http.ResponseWriter, req *http.Request) {
+	if s.LegacyAuth {
+		http.NotFound(w, req)
+		return
+	}
+	req.ParseForm()
+
+	clientID, clientSecret, ok := req.BasicAuth()
+	if !ok {
+		clientID, clientSecret = req.PostForm.Get("client_id"), req.PostForm.Get("client_secret")
+	}
+
+	grantType := req.PostForm.Get("grant_type")
+	s.grants = append(s.grants, grantType)
+
+	var authorized bool
+	switch {
+	case s.refusing > 0:
+		s.refusing--
+	case grantType == "password":
+		authorized = !s.SAML && clientID == FakeOpsManagerDefaultClientID && clientSecret == "" &&
+			s.validUser(req.PostForm.Get("username"), req.PostForm.Get("password"))
+	case grantType == "client_credentials":
+		authorized = s.ClientID != "" && clientID == s.ClientID && secureEqual(clientSecret, s.ClientSecret)
+	case grantType == "refresh_token":
+		refreshClientID, ok := s.refresh[req.PostForm.Get("refresh_token")]
+		authorized = ok && clientID == refreshClientID
+		delete(s.refresh, req.PostForm.Get("refresh_token"))
+	}
+	if !authorized {
+		w.Header().Set("Content-Type", "application/json")
+		w.WriteHeader(http.StatusUnauthorized)
+		fmt.Fprint(w, `{"error":"unauthorized","error_description":"Bad credentials"}`)
+		return
+	}
+
+	lifetime := s.TokenLifetime
+	if lifetime == 0 {
+		lifetime = FakeOpsManagerTokenLifetime
+	}
+	s.lastToken++
+	token := fmt.Sprintf("fake-opsman-token-%d", s.lastToken)
+	s.tokens[token] = true
+	response := map[string]interface{}{
+		"access_token": token,
+		"token_type":   "bearer",
+		"expires_in":   lifetime,
+		"scope":        "opsman.admin",
+	}
+	if grantType != "client_credentials" {
+		refreshToken := fmt.Sprintf("fake-opsman-refresh-token-%d", s.lastToken)
+		s.refresh[refreshToken] = clientID
+		response["refresh_token"] = refreshToken
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(response)
+}
+
+func (s *FakeOpsManager) serveDownload(w http.ResponseWriter, req *http.Request, body []byte, contentType string) {
+	if !s.authorized(req) {
+		http.Error(w, fakeOpsManagerUnauthorizedMessage, http.StatusUnauthorized)
+		return
+	}
+	if body == nil {
+		http.Error(w, "no installation", http.StatusNotFound)
+		return
+	}
+	w.Header().Set("Content-Type", contentType)
+	w.Write(body)
+}
+
+func (s *FakeOpsManager) serveJSON(w http.ResponseWriter, req *http.Request, v interface{}) {
+	if !s.authorized(req) {
+		http.Error(w, fakeOpsManagerUnauthorizedMessage, http.StatusUnauthorized)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	json.NewEncoder(w).Encode(v)
+}
+
+//serveCredential - /api/v0/deployed/products/:guid/credentials/:reference
+func (s *FakeOpsManager) serveCredential(w http.ResponseWriter, req *http.Request) {
+	if !s.authorized(req) {
+		http.Error(w, fakeOpsManagerUnauthorizedMessage, http.StatusUnauthorized)
+		return
+	}
+	parts := strings.Split(strings.TrimPrefix(req.URL.Path, FakeOpsManagerProductsPath+"/"), "/")
+	if len(parts) != 3 || parts[1] != "credentials" {
+		http.NotFound(w, req)
+		return
+	}
+	credential, ok := s.credentials[parts[0]+"/"+parts[2]]
+	if !ok {
+		http.NotFound(w, req)
+		return
+	}
+	s.serveJSON(w, req, map[string]interface{}{"credential": credential})
+}
+
+//serveImport - imports an uploaded installation, the passphrase has to be
+//the one the installation was exported with
+func (s *FakeOpsManager) serveImport(w http.ResponseWriter, req *http.Request) {
+	if s.settings != nil && !s.authorized(req) {
+		http.Error(w, fakeOpsManagerUnauthorizedMessage, http.StatusUnauthorized)
+		return
+	}
+	if err := req.ParseMultipartForm(fakeOpsManagerMaxUploadMemory); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !secureEqual(req.FormValue("passphrase"), s.Passphrase) {
+		http.Error(w, fakeOpsManagerPassphraseMismatch, http.StatusUnprocessableEntity)
+		return
+	}
+
+	file, _, err := req.FormFile(FakeOpsManagerAssetsFieldName)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	defer file.Close()
+
+	assets, err := ioutil.ReadAll(file)
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	s.assets = assets
+	if s.settings == nil {
+		s.settings = []byte(FakeOpsManagerDefaultSettings)
+	}
+	s.importing = s.ImportPolls
+	s.refusing = s.ImportUnauthorizedPolls
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, "{}")
+}
+
+//serveUnlock - unlocks the installation, ops manager answers this one
+//without authentication
+func (s *FakeOpsManager) serveUnlock(w http.ResponseWriter, req *http.Request) {
+	var body struct {
+		Passphrase string `json:"passphrase"`
+	}
+	if err := json.NewDecoder(req.Body).Decode(&body); err != nil {
+		http.Error(w, err.Error(), http.StatusBadRequest)
+		return
+	}
+	if !secureEqual(body.Passphrase, s.Passphrase) {
+		http.Error(w, fakeOpsManagerPassphraseMismatch, http.StatusUnprocessableEntity)
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	fmt.Fprint(w, "{}")
+}
+
+//serveInstall - starts apply changes, reports its status or its log. every
+//status poll moves the install along
+func (s *FakeOpsManager) serveInstall(w http.ResponseWriter, req *http.Request, match []string) {
+	if !s.authorized(req) {
+		http.Error(w, fakeOpsManagerUnauthorizedMessage, http.StatusUnauthorized)
+		return
+	}
+
+	if match[1] == "" {
+		if req.Method != "POST" {
+			http.NotFound(w, req)
+			return
+		}
+		s.installs = append(s.installs, &fakeOpsManagerInstall{status: "running", logs: "Installing products\n"})
+		s.serveJSON(w, req, map[string]interface{}{"install": map[string]int{"id": len(s.installs)}})
+		return
+	}
+
+	id, _ := strconv.Atoi(match[1])
+	if id < 1 || id > len(s.installs) || req.Method != "GET" {
+		http.NotFound(w, req)
+		return
+	}
+	install := s.installs[id-1]
+
+	if match[2] != "" {
+		s.serveJSON(w, req, map[string]string{"logs": install.logs})
+		return
+	}
+
+	if install.status == "running" {
+		install.polls++
+		install.logs += fmt.Sprintf("Deploying, step %d\n", install.polls)
+		if install.polls > s.InstallPolls {
+			install.status = "succeeded"
+			if s.FailInstall {
+				install.status = "failed"
+			}
+			install.logs += fmt.Sprintf("Installation %s\n", install.status)
+		}
+	}
+	s.serveJSON(w, req, map[string]string{"status": install.status})
+}
+
+func (s *FakeOpsManager) authorized(req *http.Request) bool {
+	if s.LegacyAuth {
+		username, password, ok := req.BasicAuth()
+		return ok && s.validUser(username, password)
+	}
+	authorization := req.Header.Get("Authorization")
+	if !strings.HasPrefix(authorization, "Bearer ") {
+		return false
+	}
+	return s.tokens[strings.TrimPrefix(authorization, "Bearer ")]
+}
+
+func (s *FakeOpsManager) validUser(username, password string) bool {
+	return username == s.Username && secureEqual(password, s.Password)
+}
+
+func secureEqual(a, b string) bool {
+	return subtle.ConstantTimeCompare([]byte(a), []byte(b)) == 1
+}
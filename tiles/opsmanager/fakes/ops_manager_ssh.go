@@ -0,0 +1,110 @@
+package fakes
+
+import (
+	"fmt"
+	"io"
+	"io/ioutil"
+	"strings"
+
+	"github.com/pivotalservices/gtils/command"
+)
+
+//FakeOpsManagerRemoteArchive - where the ssh stand-in keeps an uploaded file
+const FakeOpsManagerRemoteArchive = "/tmp/deployments.tar.gz"
+
+//Executer - an ssh stand-in for the ops manager vm. it serves the
+//deployments tarball to `tar cz deployments`, extracts an uploaded one with
+//`tar zxf`, sets aside and restores the deployments directory and removes the
+//director state files. any other command fails
+func (s *FakeOpsManager) Executer() command.Executer {
+	return &fakeOpsManagerExecuter{opsManager: s}
+}
+
+//RemoteOps - the file upload half of the ssh stand-in
+func (s *FakeOpsManager) RemoteOps() *FakeOpsManagerRemoteOps {
+	return &FakeOpsManagerRemoteOps{opsManager: s}
+}
+
+//Commands - every command run through the ssh stand-in so far
+func (s *FakeOpsManager) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.commands...)
+}
+
+//Deployments - the contents of the deployments directory, as a tarball
+func (s *FakeOpsManager) Deployments() []byte {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return s.deployments
+}
+
+//FakeOpsManagerRemoteOps - uploads files to the ssh stand-in
+type FakeOpsManagerRemoteOps struct {
+	opsManager *FakeOpsManager
+}
+
+//UploadFile --
+func (s *FakeOpsManagerRemoteOps) UploadFile(lfile io.Reader) (err error) {
+	var upload []byte
+	if upload, err = ioutil.ReadAll(lfile); err == nil {
+		s.opsManager.mutex.Lock()
+		defer s.opsManager.mutex.Unlock()
+		s.opsManager.upload = upload
+	}
+	return
+}
+
+//Path --
+func (s *FakeOpsManagerRemoteOps) Path() string {
+	return FakeOpsManagerRemoteArchive
+}
+
+//RemoveRemoteFile --
+func (s *FakeOpsManagerRemoteOps) RemoveRemoteFile() (err error) {
+	s.opsManager.mutex.Lock()
+	defer s.opsManager.mutex.Unlock()
+	s.opsManager.upload = nil
+	return
+}
+
+type fakeOpsManagerExecuter struct {
+	opsManager *FakeOpsManager
+}
+
+//Execute --
+func (s *fakeOpsManagerExecuter) Execute(dest io.Writer, cmd string) (err error) {
+	s.opsManager.mutex.Lock()
+	defer s.opsManager.mutex.Unlock()
+	s.opsManager.commands = append(s.opsManager.commands, cmd)
+
+	switch {
+	case strings.HasSuffix(cmd, "tar cz deployments"):
+		if s.opsManager.deployments == nil {
+			return fmt.Errorf("tar: deployments: Cannot stat: No such file or directory")
+		}
+		_, err = dest.Write(s.opsManager.deployments)
+
+	case strings.Contains(cmd, "mv deployments.bak deployments"):
+		s.opsManager.deployments = s.opsManager.setAside
+
+	case strings.Contains(cmd, "mv deployments deployments.bak"):
+		s.opsManager.setAside = s.opsManager.deployments
+		s.opsManager.deployments = nil
+
+	case strings.Contains(cmd, "tar zxf "+FakeOpsManagerRemoteArchive):
+		if s.opsManager.upload == nil || s.opsManager.FailExtract {
+			return fmt.Errorf("gzip: stdin: not in gzip format")
+		}
+		s.opsManager.deployments = s.opsManager.upload
+
+	case strings.Contains(cmd, "rm -rf deployments.bak"):
+		s.opsManager.setAside = nil
+
+	case strings.Contains(cmd, "rm ") && (strings.Contains(cmd, "bosh-deployments.yml") || strings.Contains(cmd, "bosh-state.json")):
+
+	default:
+		err = fmt.Errorf("unexpected command: %s", cmd)
+	}
+	return
+}
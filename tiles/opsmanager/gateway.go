@@ -0,0 +1,66 @@
+package opsmanager
+
+import (
+	"io"
+	"net/http"
+
+	ghttp "github.com/pivotalservices/gtils/http"
+	errwrap "github.com/pkg/errors"
+)
+
+//tlsGateway - the http gateway of an ops manager, verifying certificates as
+//the TLS config of its backup context asks
+type tlsGateway struct {
+	opsManager *OpsManager
+}
+
+//Get --
+func (s *tlsGateway) Get(entity ghttp.HttpRequestEntity) ghttp.RequestAdaptor {
+	return s.request("GET", entity, nil)
+}
+
+//Post --
+func (s *tlsGateway) Post(entity ghttp.HttpRequestEntity, body io.Reader) ghttp.RequestAdaptor {
+	return s.request("POST", entity, body)
+}
+
+//Put --
+func (s *tlsGateway) Put(entity ghttp.HttpRequestEntity, body io.Reader) ghttp.RequestAdaptor {
+	return s.request("PUT", entity, body)
+}
+
+func (s *tlsGateway) request(method string, entity ghttp.HttpRequestEntity, body io.Reader) ghttp.RequestAdaptor {
+	return func() (*http.Response, error) {
+		client, err := s.opsManager.httpClient()
+		if err != nil {
+			return nil, err
+		}
+
+		req, err := http.NewRequest(method, entity.Url, body)
+		if err != nil {
+			return nil, errwrap.Wrap(err, "failed creating ops manager request")
+		}
+		if entity.Authorization != "" {
+			req.Header.Set("Authorization", entity.Authorization)
+		} else if entity.Username != "" {
+			req.SetBasicAuth(entity.Username, entity.Password)
+		}
+		if entity.ContentType != "" {
+			req.Header.Set("Content-Type", entity.ContentType)
+		}
+		return client.Do(req)
+	}
+}
+
+//httpClient - the client of every ops manager and uaa call, created on first
+//use from the TLS config
+func (context *OpsManager) httpClient() (*http.Client, error) {
+	if context.client == nil {
+		client, err := context.TLS.HTTPClient()
+		if err != nil {
+			return nil, errwrap.Wrap(err, "invalid ops manager tls config")
+		}
+		context.client = client
+	}
+	return context.client, nil
+}
@@ -0,0 +1,136 @@
+package opsmanager
+
+import (
+	"io/ioutil"
+	"net"
+	"net/http"
+	"strings"
+	"time"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//ApplyPendingChanges - triggers apply changes on ops manager and streams the
+//install log until it succeeds, fails or runs past the install timeout
+func (context *OpsManager) ApplyPendingChanges() (err error) {
+	var response struct {
+		Install struct {
+			ID int `json:"id"`
+		} `json:"install"`
+	}
+
+	lo.G.Info("applying changes on ops manager")
+	if err = context.sendJSON("POST", context.apiURL(OpsMgrInstallURL), map[string]interface{}{"ignore_warnings": true}, &response); err != nil {
+		return errwrap.Wrap(err, "failed to start apply changes")
+	}
+
+	installID := response.Install.ID
+	logged := 0
+	return poll(durationOrDefault(context.InstallTimeout, OpsMgrDefaultInstallTimeout), ErrOpsMgrInstallTimeout, func() (done bool, err error) {
+		var status InstallStatus
+		if err = context.sendJSON("GET", context.apiURL(OpsMgrInstallStatusURL, installID), nil, &status); err != nil {
+			return false, errwrap.Wrap(err, "failed fetching install status")
+		}
+		context.streamInstallLog(installID, &logged)
+
+		switch status.Status {
+		case OpsMgrInstallSucceeded:
+			lo.G.Info("ops manager applied changes")
+			return true, nil
+		case OpsMgrInstallFailed:
+			return true, ErrOpsMgrInstallFailed
+		}
+		return false, nil
+	})
+}
+
+//waitForImport - ops manager decrypts and imports an uploaded installation in
+//the background, it is done once the installation settings are served again
+//to the restored admin user. the import replaces the users of the uaa, so the
+//auth mode is detected again on every poll. errors other than those of an
+//import in progress fail the wait at once
+func (context *OpsManager) waitForImport() error {
+	lo.G.Info("waiting for ops manager to import the installation")
+	return poll(durationOrDefault(context.ImportTimeout, OpsMgrDefaultImportTimeout), ErrOpsMgrImportTimeout, func() (bool, error) {
+		context.tokens().reset()
+		if err := context.saveHTTPResponse(context.apiURL(OpsMgrInstallationSettingsURL), ioutil.Discard); err != nil {
+			if !importPending(err) {
+				return true, errwrap.Wrap(err, "failed waiting for ops manager to import the installation")
+			}
+			lo.G.Debug("installation not imported yet: ", err)
+			return false, nil
+		}
+		return true, nil
+	})
+}
+
+//importPending - whether an error is one of ops manager importing an
+//installation: it is unreachable while it restarts, answers 5xx while it is
+//busy and 401, from its api or its uaa, until the restored users are in place
+func importPending(err error) bool {
+	switch cause := errwrap.Cause(err).(type) {
+	case *HTTPStatusError:
+		return cause.StatusCode >= http.StatusInternalServerError || cause.StatusCode == http.StatusUnauthorized
+	case *AuthError:
+		return cause.StatusCode >= http.StatusInternalServerError || cause.StatusCode == http.StatusUnauthorized
+	case net.Error:
+		return true
+	}
+	return false
+}
+
+//verifyPassphrase - unlocks the restored installation with the passphrase,
+//only the v0 api lets us check it
+func (context *OpsManager) verifyPassphrase() (err error) {
+	if context.APIVersion != OpsMgrAPIVersionV0 {
+		lo.G.Debug("skipping the passphrase check on a legacy ops manager")
+		return
+	}
+
+	err = context.sendJSON("PUT", context.apiURL(OpsMgrV0UnlockURL), map[string]string{"passphrase": context.Passphrase}, nil)
+	if statusErr, ok := err.(*HTTPStatusError); ok {
+		err = errwrap.Wrap(ErrOpsMgrPassphraseRejected, statusErr.Message)
+	}
+	return
+}
+
+//streamInstallLog - logs the lines the install logged since we last looked
+func (context *OpsManager) streamInstallLog(installID int, logged *int) {
+	var response struct {
+		Logs string `json:"logs"`
+	}
+	if err := context.sendJSON("GET", context.apiURL(OpsMgrInstallLogsURL, installID), nil, &response); err != nil {
+		lo.G.Debug("unable to fetch the install log: ", err)
+		return
+	}
+
+	if len(response.Logs) > *logged {
+		for _, line := range strings.Split(strings.TrimRight(response.Logs[*logged:], "\n"), "\n") {
+			lo.G.Infof("install %d: %s", installID, line)
+		}
+		*logged = len(response.Logs)
+	}
+}
+
+//poll - calls check every OpsMgrPollInterval until it is done or fails,
+//giving up with timeoutErr once timeout has passed
+func poll(timeout time.Duration, timeoutErr error, check func() (bool, error)) error {
+	deadline := time.Now().Add(timeout)
+	for {
+		if done, err := check(); done || err != nil {
+			return err
+		}
+		if time.Now().After(deadline) {
+			return timeoutErr
+		}
+		time.Sleep(OpsMgrPollInterval)
+	}
+}
+
+func durationOrDefault(duration, defaultDuration time.Duration) time.Duration {
+	if duration == 0 {
+		return defaultDuration
+	}
+	return duration
+}
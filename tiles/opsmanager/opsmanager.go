@@ -3,12 +3,11 @@ package opsmanager
 import (
 	"bufio"
 	"bytes"
-	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
+	"mime/multipart"
 	"net/http"
-	urllib "net/url"
 	"path"
 
 	"github.com/cloudfoundry-community/go-cfenv"
@@ -16,7 +15,8 @@ import (
 	"github.com/pivotalservices/gtils/command"
 	ghttp "github.com/pivotalservices/gtils/http"
 	"github.com/pivotalservices/gtils/log"
-	"github.com/pivotalservices/gtils/uaa"
+	"github.com/pivotalservices/gtils/osutils"
+	errwrap "github.com/pkg/errors"
 	"github.com/xchapter7x/lo"
 )
 
@@ -34,16 +34,8 @@ var NewOpsManager = func(opsManagerHostname,
 	cryptKey string) (context *OpsManager, err error) {
 
 	backupContext := cfbackup.NewBackupContext(target, cfenv.CurrentEnv(), cryptKey)
-	settingsHTTPRequestor := ghttp.NewHttpGateway()
-	settingsMultiHTTPRequestor := httpUploader(cfbackup.GetUploader(backupContext))
-	assetsHTTPRequestor := ghttp.NewHttpGateway()
-	assetsMultiHTTPRequestor := httpUploader(cfbackup.GetUploader(backupContext))
 
 	context = &OpsManager{
-		SettingsUploader:    settingsMultiHTTPRequestor,
-		AssetsUploader:      assetsMultiHTTPRequestor,
-		SettingsRequestor:   settingsHTTPRequestor,
-		AssetsRequestor:     assetsHTTPRequestor,
 		DeploymentDir:       path.Join(target, OpsMgrBackupDir, OpsMgrDeploymentsDir),
 		Hostname:            opsManagerHostname,
 		Username:            adminUsername,
@@ -60,6 +52,10 @@ var NewOpsManager = func(opsManagerHostname,
 		SSHPort:             OpsMgrDefaultSSHPort,
 		ClearBoshManifest:   false,
 	}
+	context.SettingsRequestor = &tlsGateway{opsManager: context}
+	context.AssetsRequestor = &tlsGateway{opsManager: context}
+	context.SettingsUploader = context.multiPartUpload
+	context.AssetsUploader = context.multiPartUpload
 	err = context.createExecuter()
 	return
 }
@@ -72,13 +68,18 @@ func (context *OpsManager) SetSSHPrivateKey(key string) {
 }
 
 func (context *OpsManager) createExecuter() (err error) {
-	context.Executer, err = command.NewRemoteExecutor(command.SshConfig{
+	config := command.SshConfig{
 		Username: context.SSHUsername,
 		Password: context.SSHPassword,
 		Host:     context.Hostname,
 		Port:     context.SSHPort,
 		SSLKey:   context.SSHPrivateKey,
-	})
+	}
+	if config, err = context.SSHTunnels.TunnelSSHConfig(config); err != nil {
+		return
+	}
+	context.RemoteOps = osutils.NewRemoteOperationsWithPath(config, OpsMgrRemoteDeploymentsArchive)
+	context.Executer, err = command.NewRemoteExecutor(config)
 	return
 }
 
@@ -86,10 +87,14 @@ func (context *OpsManager) createExecuter() (err error) {
 // and returns them in a buffered reader
 func (context *OpsManager) GetInstallationSettings() (settings io.Reader, err error) {
 	var bytesBuffer = new(bytes.Buffer)
-	url := fmt.Sprintf(OpsMgrInstallationSettingsURL, context.Hostname)
-	lo.G.Debug(fmt.Sprintf("Exporting url '%s'", url))
 
-	if err = context.saveHTTPResponse(url, bytesBuffer); err == nil {
+	err = context.withAPIVersion(func() error {
+		url := context.apiURL(OpsMgrInstallationSettingsURL)
+		lo.G.Debug(fmt.Sprintf("Exporting url '%s'", url))
+		bytesBuffer.Reset()
+		return context.saveHTTPResponse(url, bytesBuffer)
+	})
+	if err == nil {
 		settings = bytesBuffer
 	}
 	return
@@ -106,11 +111,19 @@ func (context *OpsManager) Backup() (err error) {
 }
 
 func (context *OpsManager) saveDeployments() (err error) {
+	context.StepStarted(OpsMgrDeploymentsFileName)
+	defer func() {
+		context.StepFinished(OpsMgrDeploymentsFileName, err)
+	}()
+
 	var backupWriter io.WriteCloser
 	if backupWriter, err = context.Writer(context.TargetDir, context.OpsmanagerBackupDir, OpsMgrDeploymentsFileName); err == nil {
 		defer backupWriter.Close()
-		command := "cd /var/tempest/workspaces/default && tar cz deployments"
-		err = context.Executer.Execute(backupWriter, command)
+		command := fmt.Sprintf("cd %s && tar cz %s", OpsMgrWorkspaceDir, OpsMgrDeploymentsDir)
+		progressWriter := context.NewProgressWriter(backupWriter, context.artifactPath(OpsMgrDeploymentsFileName), 0)
+		if err = context.Executer.Execute(progressWriter, command); err == nil {
+			progressWriter.Done()
+		}
 	}
 	return
 }
@@ -127,26 +140,42 @@ func (context *OpsManager) saveInstallationSettingsAndAssets() (err error) {
 }
 
 func (context *OpsManager) exportFile(urlFormat string, filename string) (err error) {
-	url := fmt.Sprintf(urlFormat, context.Hostname)
-
-	lo.G.Debug("Exporting file", log.Data{"url": url, "filename": filename})
 	var backupWriter io.WriteCloser
+	context.StepStarted(filename)
+	defer func() {
+		context.StepFinished(filename, err)
+	}()
 
 	if backupWriter, err = context.Writer(context.TargetDir, context.OpsmanagerBackupDir, filename); err == nil {
 		defer backupWriter.Close()
-		err = context.saveHTTPResponse(url, backupWriter)
+		progressWriter := context.NewProgressWriter(backupWriter, context.artifactPath(filename), 0)
+		err = context.withAPIVersion(func() error {
+			url := context.apiURL(urlFormat)
+			lo.G.Debug("Exporting file", log.Data{"url": url, "filename": filename})
+			return context.saveHTTPResponse(url, progressWriter)
+		})
+		if err == nil {
+			progressWriter.Done()
+		}
 	}
 	return
 }
 
+//artifactPath - where the file of the ops manager backup is kept
+func (context *OpsManager) artifactPath(filename string) string {
+	return path.Join(context.TargetDir, context.OpsmanagerBackupDir, filename)
+}
+
 func (context *OpsManager) saveHTTPResponse(url string, dest io.Writer) (err error) {
 	var resp *http.Response
-	lo.G.Debug("attempting to auth against", url)
+	entity := ghttp.HttpRequestEntity{
+		Url:         url,
+		ContentType: "application/octet-stream",
+	}
 
-	if resp, err = context.oauthHTTPGet(url); err != nil {
-		lo.G.Info("falling back to basic auth for legacy system")
-		lo.G.Debug("error: ", err)
-		resp, err = context.legacyHTTPGet(url)
+	if err = context.tokens().authorizeEntity(&entity); err == nil {
+		resp, err = context.SettingsRequestor.Get(entity)()
+		lo.G.Debug("called ops manager", url, err)
 	}
 
 	if err == nil && resp.StatusCode == http.StatusOK {
@@ -155,7 +184,7 @@ func (context *OpsManager) saveHTTPResponse(url string, dest io.Writer) (err err
 
 	} else if resp != nil && resp.StatusCode != http.StatusOK {
 		errMsg, _ := ioutil.ReadAll(resp.Body)
-		err = errors.New(string(errMsg[:]))
+		err = &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Message: string(errMsg[:])}
 	}
 
 	if err != nil {
@@ -164,101 +193,141 @@ func (context *OpsManager) saveHTTPResponse(url string, dest io.Writer) (err err
 	return
 }
 
-func (context *OpsManager) legacyHTTPGet(url string) (resp *http.Response, err error) {
-	requestor := context.SettingsRequestor
-	resp, err = requestor.Get(ghttp.HttpRequestEntity{
+//sendHTTPRequest - sends a json request authorized by the token source
+func (context *OpsManager) sendHTTPRequest(method, url string, body []byte) (resp *http.Response, err error) {
+	entity := ghttp.HttpRequestEntity{
 		Url:         url,
-		Username:    context.Username,
-		Password:    context.Password,
-		ContentType: "application/octet-stream",
-	})()
-	lo.G.Debug("called basic auth on legacy ops manager", url, err)
-	return
-}
-
-func (context *OpsManager) oauthHTTPGet(urlString string) (resp *http.Response, err error) {
-	var token = context.Token
-	var uaaURL, _ = urllib.Parse(urlString)
-	var opsManagerUsername = context.Username
-	var opsManagerPassword = context.Password
-	var clientID string
-	var clientSecret = context.ClientSecret
-	var grantType string
-
-	if context.ClientID == "" {
-		clientID = "opsman"
-		grantType = "password"
-	} else {
-		clientID = context.ClientID
-		grantType = "client_credentials"
+		ContentType: "application/json",
 	}
-
-	lo.G.Debug("aquiring your token from: ", uaaURL, urlString)
-
-	if token == "" {
-		if token, err = uaa.GetToken("https://"+uaaURL.Host+"/uaa", opsManagerUsername, opsManagerPassword, clientID, clientSecret, grantType); err != nil {
-			return nil, err
-		}
-		lo.G.Debug("token acquired")
+	if err = context.tokens().authorizeEntity(&entity); err != nil {
+		return
 	}
 
 	requestor := context.SettingsRequestor
-	resp, err = requestor.Get(ghttp.HttpRequestEntity{
-		Url:           urlString,
-		ContentType:   "application/octet-stream",
-		Authorization: "Bearer " + token,
-	})()
-
+	switch method {
+	case "POST":
+		resp, err = requestor.Post(entity, bytes.NewReader(body))()
+	case "PUT":
+		resp, err = requestor.Put(entity, bytes.NewReader(body))()
+	default:
+		resp, err = requestor.Get(entity)()
+	}
+	lo.G.Debug("called ops manager", method, url, err)
 	return
 }
 
 //~ Restore Operations
 
-// Restore performs a restore of a Pivotal Ops Manager instance
+// Restore performs a restore of a Pivotal Ops Manager instance, waiting on
+// the import and optionally applying changes once it has finished
 func (context *OpsManager) Restore() (err error) {
 	lo.G.Info("Starting restore for Opsman")
-	err = context.importInstallation()
+	if err = context.importInstallation(); err == nil {
+		if err = context.waitForImport(); err == nil {
+			if err = context.restoreDeployments(); err == nil {
+				if err = context.verifyPassphrase(); err == nil && context.ApplyChanges {
+					err = context.ApplyPendingChanges()
+				}
+			}
+		}
+	}
 	return
 }
 
 func (context *OpsManager) importInstallation() (err error) {
-	defer func() {
-		if err == nil && context.ClearBoshManifest {
-			lo.G.Debug("removing deployment files")
-			err = context.removeExistingDeploymentFiles()
-		}
-	}()
-	installAssetsURL := fmt.Sprintf(OpsMgrInstallationAssetsURL, context.Hostname)
-	lo.G.Debug("uploading installation assets installAssetsURL: %s", installAssetsURL)
-	err = context.importInstallationPart(installAssetsURL, OpsMgrInstallationAssetsFileName, OpsMgrInstallationAssetsPostFieldName, context.AssetsUploader)
+	err = context.withAPIVersion(func() error {
+		installAssetsURL := context.apiURL(OpsMgrInstallationAssetsURL)
+		lo.G.Debug("uploading installation assets installAssetsURL: %s", installAssetsURL)
+		return context.importInstallationPart(installAssetsURL, OpsMgrInstallationAssetsFileName, OpsMgrInstallationAssetsPostFieldName, context.AssetsUploader)
+	})
 	return
 }
 
+//multiPartUpload - streams a multipart upload to ops manager, authorized by
+//the token source like every other api call. the multipart framing is built
+//up front, so with a known file size the upload carries a Content-Length
+//rather than going out chunked
+func (context *OpsManager) multiPartUpload(conn ghttp.ConnAuth, paramName, filename string, fileSize int64, fileRef io.Reader, params map[string]string) (res *http.Response, err error) {
+	var (
+		framing     bytes.Buffer
+		head        int
+		contentType string
+	)
+	if head, contentType, err = multiPartFraming(&framing, paramName, filename, params); err != nil {
+		return
+	}
+	body := io.MultiReader(bytes.NewReader(framing.Bytes()[:head]), fileRef, bytes.NewReader(framing.Bytes()[head:]))
+
+	var req *http.Request
+	if req, err = http.NewRequest("POST", conn.Url, body); err != nil {
+		return
+	}
+	if fileSize > 0 {
+		req.ContentLength = int64(framing.Len()) + fileSize
+	}
+	req.Header.Set("Content-Type", contentType)
+	if err = context.tokens().authorize(req); err != nil {
+		return
+	}
+
+	var client *http.Client
+	if client, err = context.httpClient(); err != nil {
+		return
+	}
+	return client.Do(req)
+}
+
+//multiPartFraming - writes the fields and the file part header of a
+//multipart body, followed by its closing boundary. head is where the file
+//content goes in between
+func multiPartFraming(framing *bytes.Buffer, paramName, filename string, params map[string]string) (head int, contentType string, err error) {
+	writer := multipart.NewWriter(framing)
+	for key, value := range params {
+		if err = writer.WriteField(key, value); err != nil {
+			return
+		}
+	}
+	if _, err = writer.CreateFormFile(paramName, path.Base(filename)); err != nil {
+		return
+	}
+	head = framing.Len()
+	err = writer.Close()
+	return head, writer.FormDataContentType(), err
+}
+
 func (context *OpsManager) importInstallationPart(url, filename, fieldname string, upload httpUploader) (err error) {
 	var backupReader io.ReadCloser
+	context.StepStarted(filename)
+	defer func() {
+		context.StepFinished(filename, err)
+	}()
 
 	if backupReader, err = context.Reader(context.TargetDir, context.OpsmanagerBackupDir, filename); err == nil {
 		defer backupReader.Close()
 		var resp *http.Response
 		conn := ghttp.ConnAuth{
-			Url:      url,
-			Username: context.Username,
-			Password: context.Password,
+			Url: url,
 		}
-		filePath := path.Join(context.TargetDir, context.OpsmanagerBackupDir, filename)
-		bufferedReader := bufio.NewReader(backupReader)
-		lo.G.Debug("upload request", log.Data{"fieldname": fieldname, "filePath": filePath})
 		creds := map[string]string{
-			"password":   context.Password,
 			"passphrase": context.Passphrase,
 		}
-		resp, err = upload(conn, fieldname, filePath, -1, bufferedReader, creds)
+		if context.ClientID == "" {
+			conn.Username = context.Username
+			conn.Password = context.Password
+			creds["password"] = context.Password
+		}
+		filePath := context.artifactPath(filename)
+		progressReader := context.NewProgressReader(backupReader, filePath, context.ArtifactSize(filePath))
+		bufferedReader := bufio.NewReader(progressReader)
+		lo.G.Debug("upload request", log.Data{"fieldname": fieldname, "filePath": filePath})
+		resp, err = upload(conn, fieldname, filePath, context.ArtifactSize(filePath), bufferedReader, creds)
 
 		if err == nil && resp.StatusCode == http.StatusOK {
 			lo.G.Debug("Request for %s succeeded with status: %s", url, resp.Status)
+			progressReader.Done()
 
 		} else if resp != nil && resp.StatusCode != http.StatusOK {
-			err = fmt.Errorf("Request for %s failed with status: %s", url, resp.Status)
+			err = &HTTPStatusError{URL: url, StatusCode: resp.StatusCode, Message: fmt.Sprintf("Request for %s failed with status: %s", url, resp.Status)}
 		}
 
 		if err != nil {
@@ -268,9 +337,68 @@ func (context *OpsManager) importInstallationPart(url, filename, fieldname strin
 	return
 }
 
+//restoreDeployments - puts the deployments directory of the backup back in
+//the ops manager workspace, keeping the existing one until the archive is
+//extracted. the director state comes from the backup unless ClearBoshManifest
+//asks for it to be regenerated
+func (context *OpsManager) restoreDeployments() (err error) {
+	defer func() {
+		if err == nil && context.ClearBoshManifest {
+			lo.G.Debug("removing deployment files")
+			err = context.removeExistingDeploymentFiles()
+		}
+	}()
+
+	var backupReader io.ReadCloser
+	if backupReader, err = context.Reader(context.TargetDir, context.OpsmanagerBackupDir, OpsMgrDeploymentsFileName); err != nil {
+		if cfbackup.IsNotExist(err) {
+			context.Warningf("no deployments archive in the backup, leaving the ops manager deployments as they are: %v", err)
+			return nil
+		}
+		return errwrap.Wrap(err, "failed reading the deployments archive")
+	}
+	defer backupReader.Close()
+	context.StepStarted(OpsMgrDeploymentsFileName)
+	defer func() {
+		context.StepFinished(OpsMgrDeploymentsFileName, err)
+	}()
+
+	lo.G.Info("restoring the ops manager deployments")
+	filePath := context.artifactPath(OpsMgrDeploymentsFileName)
+	progressReader := context.NewProgressReader(backupReader, filePath, context.ArtifactSize(filePath))
+	if err = context.RemoteOps.UploadFile(progressReader); err != nil {
+		return errwrap.Wrap(err, "failed uploading the deployments archive")
+	}
+	progressReader.Done()
+	defer context.RemoteOps.RemoveRemoteFile()
+
+	if err = context.executeInWorkspace("if [ -d %[1]s ]; then sudo rm -rf %[1]s.bak && sudo mv %[1]s %[1]s.bak; fi", OpsMgrDeploymentsDir); err != nil {
+		return errwrap.Wrap(err, "failed setting aside the existing deployments")
+	}
+
+	if err = context.executeInWorkspace("sudo tar zxf %s && sudo chown -R %s %s", context.RemoteOps.Path(), OpsMgrWorkspaceOwner, OpsMgrDeploymentsDir); err != nil {
+		lo.G.Error("extracting the deployments archive failed, rolling back: ", err)
+		if rollbackErr := context.executeInWorkspace("sudo rm -rf %[1]s && if [ -d %[1]s.bak ]; then sudo mv %[1]s.bak %[1]s; fi", OpsMgrDeploymentsDir); rollbackErr != nil {
+			lo.G.Error("rolling back the deployments failed: ", rollbackErr)
+		}
+		return errwrap.Wrap(err, "failed extracting the deployments archive")
+	}
+	return context.executeInWorkspace("sudo rm -rf %s.bak", OpsMgrDeploymentsDir)
+}
+
+func (context *OpsManager) executeInWorkspace(commandFormat string, args ...interface{}) error {
+	var w bytes.Buffer
+	command := fmt.Sprintf("cd %s && ", OpsMgrWorkspaceDir) + fmt.Sprintf(commandFormat, args...)
+	return context.Executer.Execute(&w, command)
+}
+
 func (context *OpsManager) removeExistingDeploymentFiles() (err error) {
 	var w bytes.Buffer
-	command := fmt.Sprintf("if [ -f %s ]; then sudo rm %s;fi", OpsMgrDeploymentsFile, OpsMgrDeploymentsFile)
-	err = context.Executer.Execute(&w, command)
+	for _, stateFile := range []string{OpsMgrDeploymentsFile, OpsMgrDirectorStateFile} {
+		command := fmt.Sprintf("if [ -f %s ]; then sudo rm %s;fi", stateFile, stateFile)
+		if err = context.Executer.Execute(&w, command); err != nil {
+			return
+		}
+	}
 	return
 }
@@ -7,21 +7,25 @@ import (
 )
 
 //New -- builds a new ops manager object pre initialized
-func (s *OpsManagerBuilder) New(tileSpec tileregistry.TileSpec) (opsManagerTileCloser tileregistry.TileCloser, err error) {
+func (s *OpsManagerBuilder) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	return tileSpec.Build(OpsManagerTileName, s.build)
+}
+
+func (s *OpsManagerBuilder) build(tileSpec tileregistry.TileSpec) (opsManagerTileCloser tileregistry.TileCloser, err error) {
 	var opsManager *OpsManager
-	opsManager, err = NewOpsManager(
-		tileSpec.OpsManagerHost,
-		tileSpec.AdminUser,
-		tileSpec.AdminPass,
-		tileSpec.AdminToken,
-		tileSpec.OpsManagerUser,
-		tileSpec.OpsManagerPass,
-		tileSpec.OpsManagerPassphrase,
-		tileSpec.ClientID,
-		tileSpec.ClientSecret,
-		tileSpec.ArchiveDirectory,
-		tileSpec.CryptKey)
+	if opsManager, err = NewFromTileSpec(tileSpec); err != nil {
+		return
+	}
+	if err = tileSpec.ConfigureContext(&opsManager.BackupContext); err != nil {
+		return
+	}
+	if err = opsManager.createExecuter(); err != nil {
+		return
+	}
 	opsManager.ClearBoshManifest = tileSpec.ClearBoshManifest
+	opsManager.ApplyChanges = tileSpec.ApplyChanges
+	opsManager.ImportTimeout = tileSpec.ImportTimeout
+	opsManager.InstallTimeout = tileSpec.InstallTimeout
 
 	if installationSettings, err := opsManager.GetInstallationSettings(); err == nil {
 		config := cfbackup.NewConfigurationParserFromReader(installationSettings)
@@ -39,7 +43,7 @@ func (s *OpsManagerBuilder) New(tileSpec tileregistry.TileSpec) (opsManagerTileC
 		tileregistry.Closer
 	}{
 		opsManager,
-		new(tileregistry.DoNothingCloser),
+		opsManager.SSHTunnels,
 	}
 	return
 }
@@ -12,7 +12,7 @@ var _ = Describe("OpsManagerBuilder", func() {
 		Context("when called with invalid tileSpec connection credentials", func() {
 			var controlTileSpec tileregistry.TileSpec
 			BeforeEach(func() {
-				controlTileSpec = tileregistry.TileSpec{}
+				controlTileSpec = tileregistry.TileSpec{AdminUser: "admin", AdminPass: "admin-password"}
 			})
 
 			It("then it should lazy load dial and NOT error", func() {
@@ -20,5 +20,12 @@ var _ = Describe("OpsManagerBuilder", func() {
 				Ω(err).ShouldNot(HaveOccurred())
 			})
 		})
+
+		Context("when called without a usable combination of credentials", func() {
+			It("then it should return the validation error", func() {
+				_, err := new(OpsManagerBuilder).New(tileregistry.TileSpec{ClientID: "backup-client"})
+				Ω(err).Should(Equal(tileregistry.ErrClientSecretMissing))
+			})
+		})
 	})
 })
@@ -1,6 +1,7 @@
 package opsmanager_test
 
 import (
+	"errors"
 	"fmt"
 	"io"
 	"io/ioutil"
@@ -8,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"path"
+	"time"
 
 	"github.com/cloudfoundry-community/go-cfenv"
 	. "github.com/onsi/ginkgo"
@@ -21,8 +23,24 @@ import (
 	ghttp "github.com/pivotalservices/gtils/http"
 	"github.com/pivotalservices/gtils/http/fake"
 	"github.com/pivotalservices/gtils/osutils"
+	errwrap "github.com/pkg/errors"
 )
 
+//failingStorage - fails reading the file, any other is read from the wrapped
+//storage provider
+type failingStorage struct {
+	cfbackup.StorageProvider
+	file string
+	err  error
+}
+
+func (s failingStorage) Reader(filePath ...string) (io.ReadCloser, error) {
+	if path.Base(path.Join(filePath...)) == s.file {
+		return nil, s.err
+	}
+	return s.StorageProvider.Reader(filePath...)
+}
+
 var _ = Describe("OpsManager object", func() {
 	var (
 		opsManager *OpsManager
@@ -31,7 +49,67 @@ var _ = Describe("OpsManager object", func() {
 	)
 	Describe("Given a GetInstallationSettings method", func() {
 		checkAuthorizationMechanismSupport("oauth", http.StatusOK, http.StatusOK, "Bearer")
-		checkAuthorizationMechanismSupport("oauth", http.StatusUnauthorized, http.StatusOK, "Basic")
+		checkAuthorizationMechanismSupport("basic auth", http.StatusNotFound, http.StatusOK, "Basic")
+		checkRoundTripAgainstFakeOpsManager("basic auth", true, false, "", "")
+		checkRoundTripAgainstFakeOpsManager("oauth", false, false, "", "")
+		checkRoundTripAgainstFakeOpsManager("oauth client credentials", false, false, "backup-client", "backup-secret")
+		checkRoundTripAgainstFakeOpsManager("the v0 api only", false, true, "", "")
+
+		Context("when calling the v0 api of a fake ops manager", func() {
+			var (
+				server     *opsfakes.FakeOpsManager
+				opsManager *OpsManager
+				tmpDir     string
+			)
+
+			BeforeEach(func() {
+				tmpDir, _ = ioutil.TempDir("/tmp", "test")
+				server = opsfakes.NewFakeOpsManager("admin", "admin-password", "opsPassphrase")
+				server.V0Only = true
+				server.AddDeployedProduct("cf-abc", "cf-abc", "cf", "1.9.0")
+				server.SetCredential("cf-abc", ".ccdb.credentials", "simple_credentials", map[string]string{"identity": "admin", "password": "ccdb-pass"})
+				opsManager, _ = NewOpsManager(server.Host(), "admin", "admin-password", "", "ubuntu", "ssh-password", "opsPassphrase", "", "", tmpDir, "")
+			})
+
+			AfterEach(func() {
+				server.Close()
+				os.RemoveAll(tmpDir)
+			})
+
+			It("then it should detect the api version from the installation settings call", func() {
+				_, err := opsManager.GetInstallationSettings()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(opsManager.APIVersion).Should(Equal(OpsMgrAPIVersionV0))
+				Ω(server.Requests()).Should(ContainElement("GET /api/v0/installation_settings"))
+			})
+
+			It("then it should list the deployed products", func() {
+				products, err := opsManager.GetDeployedProducts()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(products).Should(ConsistOf(DeployedProduct{InstallationName: "cf-abc", GUID: "cf-abc", Type: "cf", ProductVersion: "1.9.0"}))
+			})
+
+			It("then it should resolve the guid of a product type from the deployed products", func() {
+				guid, err := opsManager.FindProductGUID("cf")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(guid).Should(Equal("cf-abc"))
+
+				_, err = opsManager.FindProductGUID("p-mysql")
+				Ω(err).Should(HaveOccurred())
+			})
+
+			It("then it should fetch credentials from the credentials api", func() {
+				credential, err := opsManager.GetCredential("cf-abc", ".ccdb.credentials")
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(credential.Type).Should(Equal("simple_credentials"))
+				Ω(credential.Value).Should(Equal(map[string]string{"identity": "admin", "password": "ccdb-pass"}))
+			})
+
+			It("then it should fail for an unknown credential", func() {
+				_, err := opsManager.GetCredential("cf-abc", ".uaadb.credentials")
+				Ω(err).Should(BeAssignableToTypeOf(&HTTPStatusError{}))
+			})
+		})
 		Context("when calling against a ops manager with token override ", func() {
 			var err error
 			var httpTokenAcquiredManually = "foobar"
@@ -90,6 +168,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.SuccessExecuter{},
 					DeploymentDir:       "fixtures/encryptionkey",
@@ -132,6 +211,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.SuccessExecuter{},
 					DeploymentDir:       "fixtures/encryptionkey",
@@ -159,6 +239,186 @@ var _ = Describe("OpsManager object", func() {
 			})
 		})
 
+		Context("when authenticating against the uaa of a fake ops manager", func() {
+			var (
+				server            *opsfakes.FakeOpsManager
+				opsManager        *OpsManager
+				tmpDir            string
+				originalFrequency = OpsMgrPollInterval
+			)
+
+			BeforeEach(func() {
+				OpsMgrPollInterval = time.Millisecond
+				tmpDir, _ = ioutil.TempDir("/tmp", "test")
+				server = opsfakes.NewFakeOpsManager("admin", "admin-password", "opsPassphrase")
+				opsManager, _ = NewOpsManager(server.Host(), "admin", "admin-password", "", "ubuntu", "ssh-password", "opsPassphrase", "", "", tmpDir, "")
+				opsManager.Executer = server.Executer()
+				opsManager.RemoteOps = server.RemoteOps()
+			})
+
+			AfterEach(func() {
+				OpsMgrPollInterval = originalFrequency
+				server.Close()
+				os.RemoveAll(tmpDir)
+			})
+
+			It("then it should reuse the token across api calls", func() {
+				Ω(opsManager.Backup()).Should(Succeed())
+				Ω(server.TokenGrants()).Should(Equal([]string{"password"}))
+			})
+
+			It("then it should use the refresh token once the token is about to expire", func() {
+				server.TokenLifetime = 1
+				_, err := opsManager.GetInstallationSettings()
+				Ω(err).ShouldNot(HaveOccurred())
+				_, err = opsManager.GetInstallationSettings()
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(server.TokenGrants()).Should(Equal([]string{"password", "refresh_token"}))
+			})
+
+			It("then it should upload the installation with the token", func() {
+				f, _ := osutils.SafeCreate(tmpDir, OpsMgrBackupDir, OpsMgrInstallationAssetsFileName)
+				f.Write([]byte("installation zip"))
+				f.Close()
+
+				Ω(opsManager.Restore()).Should(Succeed())
+				_, assets := server.Installation()
+				Ω(string(assets)).Should(Equal("installation zip"))
+			})
+
+			It("then it should fail with an auth error instead of trying basic auth", func() {
+				opsManager.Password = "wrong-password"
+				_, err := opsManager.GetInstallationSettings()
+				Ω(errwrap.Cause(err)).Should(BeAssignableToTypeOf(&AuthError{}))
+				Ω(server.Requests()).Should(Equal([]string{"POST " + opsfakes.FakeOpsManagerTokenPath}))
+			})
+
+			It("then it should reject the ops manager certificate with strict verification", func() {
+				opsManager.TLS = cfbackup.TLSConfig{Strict: true}
+				_, err := opsManager.GetInstallationSettings()
+				Ω(err).Should(HaveOccurred())
+				Ω(server.Requests()).Should(BeEmpty())
+			})
+
+			It("then it should verify the ops manager certificate against a ca bundle", func() {
+				opsManager.TLS = cfbackup.TLSConfig{CABundle: server.CertificatePEM()}
+				Ω(opsManager.Backup()).Should(Succeed())
+			})
+
+			It("then it should restore an ops manager using saml with client credentials alone", func() {
+				server.SAML = true
+				server.ClientID = "backup-client"
+				server.ClientSecret = "backup-secret"
+				opsManager, _ = NewOpsManager(server.Host(), "", "", "", "ubuntu", "ssh-password", "opsPassphrase", "backup-client", "backup-secret", tmpDir, "")
+				opsManager.Executer = server.Executer()
+				opsManager.RemoteOps = server.RemoteOps()
+				f, _ := osutils.SafeCreate(tmpDir, OpsMgrBackupDir, OpsMgrInstallationAssetsFileName)
+				f.Write([]byte("installation zip"))
+				f.Close()
+
+				Ω(opsManager.Restore()).Should(Succeed())
+				_, assets := server.Installation()
+				Ω(string(assets)).Should(Equal("installation zip"))
+				Ω(server.TokenGrants()).Should(ContainElement("client_credentials"))
+				Ω(server.TokenGrants()).ShouldNot(ContainElement("password"))
+			})
+
+			It("then it should not fall back to basic auth for client credentials", func() {
+				server.LegacyAuth = true
+				opsManager, _ = NewOpsManager(server.Host(), "admin", "admin-password", "", "ubuntu", "ssh-password", "opsPassphrase", "backup-client", "backup-secret", tmpDir, "")
+				_, err := opsManager.GetInstallationSettings()
+				Ω(errwrap.Cause(err)).Should(BeAssignableToTypeOf(&AuthError{}))
+				Ω(server.Requests()).Should(Equal([]string{"POST " + opsfakes.FakeOpsManagerTokenPath}))
+			})
+		})
+
+		Context("when restoring into a fake ops manager", func() {
+			var (
+				server            *opsfakes.FakeOpsManager
+				opsManager        *OpsManager
+				tmpDir            string
+				originalFrequency = OpsMgrPollInterval
+			)
+
+			BeforeEach(func() {
+				OpsMgrPollInterval = time.Millisecond
+				tmpDir, _ = ioutil.TempDir("/tmp", "test")
+				server = opsfakes.NewFreshFakeOpsManager("admin", "admin-password", "opsPassphrase")
+				server.V0Only = true
+				server.ImportPolls = 3
+				server.InstallPolls = 2
+				opsManager, _ = NewOpsManager(server.Host(), "admin", "admin-password", "", "ubuntu", "ssh-password", "opsPassphrase", "", "", tmpDir, "")
+				opsManager.Executer = server.Executer()
+				opsManager.RemoteOps = server.RemoteOps()
+				f, _ := osutils.SafeCreate(tmpDir, OpsMgrBackupDir, OpsMgrInstallationAssetsFileName)
+				f.Write([]byte("installation zip"))
+				f.Close()
+			})
+
+			AfterEach(func() {
+				OpsMgrPollInterval = originalFrequency
+				server.Close()
+				os.RemoveAll(tmpDir)
+			})
+
+			It("then it should wait until ops manager has imported the installation", func() {
+				Ω(opsManager.Restore()).Should(Succeed())
+				requests := server.Requests()
+				Ω(requests).Should(ContainElement("GET /api/v0/installation_settings"))
+				Ω(requests[len(requests)-1]).Should(Equal("PUT /api/v0/unlock"))
+			})
+
+			It("then it should fail when the import does not finish in time", func() {
+				server.ImportPolls = 1000
+				opsManager.ImportTimeout = 5 * time.Millisecond
+				Ω(opsManager.Restore()).Should(Equal(ErrOpsMgrImportTimeout))
+			})
+
+			It("then it should wait while the uaa refuses the restored users", func() {
+				server.ImportUnauthorizedPolls = 2
+				opsManager.ImportTimeout = time.Hour
+				Ω(opsManager.Restore()).Should(Succeed())
+			})
+
+			It("then it should fail when the uaa refuses the restored users for too long", func() {
+				server.ImportUnauthorizedPolls = 1000
+				opsManager.ImportTimeout = 5 * time.Millisecond
+				Ω(opsManager.Restore()).Should(Equal(ErrOpsMgrImportTimeout))
+			})
+
+			It("then it should fail at once when ops manager rejects the installation settings call", func() {
+				opsManager.ImportTimeout = time.Hour
+				server.FailNextRequest("GET", "/api/v0/installation_settings", http.StatusForbidden)
+				err := opsManager.Restore()
+				Ω(err).Should(HaveOccurred())
+				Ω(errwrap.Cause(err).(*HTTPStatusError).StatusCode).Should(Equal(http.StatusForbidden))
+			})
+
+			It("then it should fail when the passphrase does not unlock the installation", func() {
+				server.FailNextRequest("PUT", "/api/v0/unlock", http.StatusUnprocessableEntity)
+				err := opsManager.Restore()
+				Ω(errwrap.Cause(err)).Should(Equal(ErrOpsMgrPassphraseRejected))
+			})
+
+			It("then it should not apply changes unless asked to", func() {
+				Ω(opsManager.Restore()).Should(Succeed())
+				Ω(server.InstallStatus()).Should(BeEmpty())
+			})
+
+			It("then it should apply changes and wait for them when asked to", func() {
+				opsManager.ApplyChanges = true
+				Ω(opsManager.Restore()).Should(Succeed())
+				Ω(server.InstallStatus()).Should(Equal(OpsMgrInstallSucceeded))
+			})
+
+			It("then it should fail when applying changes fails", func() {
+				opsManager.ApplyChanges = true
+				server.FailInstall = true
+				Ω(opsManager.Restore()).Should(Equal(ErrOpsMgrInstallFailed))
+				Ω(server.InstallStatus()).Should(Equal(OpsMgrInstallFailed))
+			})
+		})
+
 		Context("calling restore where a deployment manifest deletion is not possible", func() {
 
 			BeforeEach(func() {
@@ -174,6 +434,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.FailExecuter{},
 					DeploymentDir:       "fixtures/encryptionkey",
@@ -217,6 +478,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.SuccessExecuter{},
 					DeploymentDir:       "fixtures/encryptionkey",
@@ -248,6 +510,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.FailExecuter{},
 					DeploymentDir:       "fixtures/encryptionkey",
@@ -277,6 +540,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.FailExecuter{},
 					DeploymentDir:       "fixtures/encryptionkey",
@@ -310,6 +574,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.SuccessExecuter{},
 					LocalExecuter:       fakes.NewLocalMockExecuter(),
@@ -334,6 +599,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.FailExecuter{},
 					LocalExecuter:       fakes.NewLocalMockExecuter(),
@@ -369,6 +635,7 @@ var _ = Describe("OpsManager object", func() {
 					Hostname:            "localhost",
 					Username:            "user",
 					Password:            "password",
+					Token:               "token",
 					BackupContext:       fakes.NewFakeBackupContext(path.Join(tmpDir, "backup"), cfenv.CurrentEnv(), new(cfbackup.DiskProvider)),
 					Executer:            &fakes.SuccessExecuter{},
 					LocalExecuter:       fakes.NewLocalMockExecuter(),
@@ -394,6 +661,111 @@ var _ = Describe("OpsManager object", func() {
 	})
 })
 
+var checkRoundTripAgainstFakeOpsManager = func(mode string, legacyAuth, v0Only bool, clientID, clientSecret string) {
+	Context("when backing up and restoring against a fake ops manager using "+mode, func() {
+		var (
+			source        *opsfakes.FakeOpsManager
+			target        *opsfakes.FakeOpsManager
+			tmpDir        string
+			newOpsManager = func(opsManager *opsfakes.FakeOpsManager, passphrase string) *OpsManager {
+				context, err := NewOpsManager(opsManager.Host(), "admin", "admin-password", "", "ubuntu", "ssh-password", passphrase, clientID, clientSecret, tmpDir, "")
+				Ω(err).ShouldNot(HaveOccurred())
+				context.Executer = opsManager.Executer()
+				context.RemoteOps = opsManager.RemoteOps()
+				return context
+			}
+			newFakeOpsManager = func(create func(string, string, string) *opsfakes.FakeOpsManager) *opsfakes.FakeOpsManager {
+				opsManager := create("admin", "admin-password", "opsPassphrase")
+				opsManager.LegacyAuth = legacyAuth
+				opsManager.V0Only = v0Only
+				opsManager.ClientID = clientID
+				opsManager.ClientSecret = clientSecret
+				return opsManager
+			}
+		)
+
+		BeforeEach(func() {
+			tmpDir, _ = ioutil.TempDir("/tmp", "test")
+			source = newFakeOpsManager(opsfakes.NewFakeOpsManager)
+			source.SetInstallation([]byte(`{"guid":"abc"}`), []byte("installation zip"))
+			source.SetDeployments([]byte("deployments tarball"))
+			target = newFakeOpsManager(opsfakes.NewFreshFakeOpsManager)
+
+			Ω(newOpsManager(source, "opsPassphrase").Backup()).Should(Succeed())
+		})
+
+		AfterEach(func() {
+			source.Close()
+			target.Close()
+			os.RemoveAll(tmpDir)
+		})
+
+		It("then it should save the installation and deployments of the ops manager", func() {
+			settings, _ := ioutil.ReadFile(path.Join(tmpDir, OpsMgrBackupDir, OpsMgrInstallationSettingsFilename))
+			assets, _ := ioutil.ReadFile(path.Join(tmpDir, OpsMgrBackupDir, OpsMgrInstallationAssetsFileName))
+			deployments, _ := ioutil.ReadFile(path.Join(tmpDir, OpsMgrBackupDir, OpsMgrDeploymentsFileName))
+			Ω(string(settings)).Should(Equal(`{"guid":"abc"}`))
+			Ω(string(assets)).Should(Equal("installation zip"))
+			Ω(string(deployments)).Should(Equal("deployments tarball"))
+		})
+
+		It("then it should import the installation into a fresh ops manager", func() {
+			Ω(newOpsManager(target, "opsPassphrase").Restore()).Should(Succeed())
+
+			_, assets := target.Installation()
+			Ω(string(assets)).Should(Equal("installation zip"))
+			Ω(string(target.Deployments())).Should(Equal("deployments tarball"))
+			Ω(target.Commands()).ShouldNot(ContainElement(ContainSubstring(OpsMgrDirectorStateFile)))
+		})
+
+		It("then it should regenerate the director state when asked to", func() {
+			opsManager := newOpsManager(target, "opsPassphrase")
+			opsManager.ClearBoshManifest = true
+			Ω(opsManager.Restore()).Should(Succeed())
+
+			commands := target.Commands()
+			Ω(string(target.Deployments())).Should(Equal("deployments tarball"))
+			Ω(commands[len(commands)-2]).Should(ContainSubstring(OpsMgrDeploymentsFile))
+			Ω(commands[len(commands)-1]).Should(ContainSubstring(OpsMgrDirectorStateFile))
+		})
+
+		It("then it should roll back the deployments when extracting them fails", func() {
+			target.SetDeployments([]byte("existing deployments"))
+			target.FailExtract = true
+			Ω(newOpsManager(target, "opsPassphrase").Restore()).ShouldNot(Succeed())
+			Ω(string(target.Deployments())).Should(Equal("existing deployments"))
+		})
+
+		It("then it should leave the deployments as they are when the backup has none", func() {
+			target.SetDeployments([]byte("existing deployments"))
+			Ω(os.Remove(path.Join(tmpDir, OpsMgrBackupDir, OpsMgrDeploymentsFileName))).Should(Succeed())
+			Ω(newOpsManager(target, "opsPassphrase").Restore()).Should(Succeed())
+			Ω(string(target.Deployments())).Should(Equal("existing deployments"))
+		})
+
+		It("then it should fail the restore when the deployments archive can not be read", func() {
+			target.SetDeployments([]byte("existing deployments"))
+			opsManager := newOpsManager(target, "opsPassphrase")
+			opsManager.StorageProvider = failingStorage{
+				StorageProvider: opsManager.StorageProvider,
+				file:            OpsMgrDeploymentsFileName,
+				err:             errors.New("access denied"),
+			}
+			err := opsManager.Restore()
+			Ω(err).Should(HaveOccurred())
+			Ω(err.Error()).Should(ContainSubstring("access denied"))
+			Ω(string(target.Deployments())).Should(Equal("existing deployments"))
+		})
+
+		It("then it should refuse an import with the wrong passphrase", func() {
+			Ω(newOpsManager(target, "wrongPassphrase").Restore()).ShouldNot(Succeed())
+
+			_, assets := target.Installation()
+			Ω(assets).Should(BeNil())
+		})
+	})
+}
+
 var checkAuthorizationMechanismSupport = func(method string, oauthStatusCode, apiStatusCode int, authPrefix string) {
 	Context("when calling against a ops manager which uses "+method, func() {
 		var err error
@@ -402,7 +774,7 @@ var checkAuthorizationMechanismSupport = func(method string, oauthStatusCode, ap
 		tmpDir, _ := ioutil.TempDir("/tmp", "test")
 
 		BeforeEach(func() {
-			server = opsfakes.NewFakeOpsManagerServer(testhttp.NewTLSServer(), oauthStatusCode, `{"something":"as a auth response"}`, apiStatusCode, `{"something":"as an api call response"}`)
+			server = opsfakes.NewFakeOpsManagerServer(testhttp.NewTLSServer(), oauthStatusCode, `{"access_token":"abc","token_type":"bearer","expires_in":3600}`, apiStatusCode, `{"something":"as an api call response"}`)
 			urlString, _ := url.Parse(server.URL())
 			fmt.Println(server.URL())
 			opsManager, _ := NewOpsManager(urlString.Host, "user", "pass", "", "opsUser", "opsPass", "opsPassphrase", "", "", tmpDir, "")
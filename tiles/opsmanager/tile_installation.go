@@ -0,0 +1,71 @@
+package opsmanager
+
+import (
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+)
+
+//TileInstallation - what the service tiles are built from: the ops manager of
+//the tile spec, its installation settings and a backup context configured
+//from the tile spec
+type TileInstallation struct {
+	OpsManager       *OpsManager
+	Settings         *cfbackup.InstallationSettings
+	InstallationInfo cfbackup.InstallationInfo
+	BackupContext    cfbackup.BackupContext
+	SSHKey           string
+}
+
+//NewFromTileSpec - builds the ops manager of a tile spec, talking to it with
+//the tls config of the tile spec
+func NewFromTileSpec(tileSpec tileregistry.TileSpec) (opsManager *OpsManager, err error) {
+	if err = tileSpec.ValidateAuth(); err != nil {
+		return
+	}
+	if opsManager, err = NewOpsManager(tileSpec.OpsManagerHost, tileSpec.AdminUser, tileSpec.AdminPass, tileSpec.AdminToken, tileSpec.OpsManagerUser, tileSpec.OpsManagerPass, tileSpec.OpsManagerPassphrase, tileSpec.ClientID, tileSpec.ClientSecret, tileSpec.ArchiveDirectory, tileSpec.CryptKey); err == nil {
+		opsManager.TLS = tileSpec.TLSConfig()
+	}
+	return
+}
+
+//NewTileInstallation - fetches the installation settings of the ops manager
+//of a tile spec, and readies the backup context, trusting the director
+//certificate of the installation, for a service tile
+func NewTileInstallation(tileSpec tileregistry.TileSpec) (installation *TileInstallation, err error) {
+	var opsManager *OpsManager
+	if opsManager, err = NewFromTileSpec(tileSpec); err != nil {
+		return
+	}
+	installationSettings, err := opsManager.GetInstallationSettings()
+	if err != nil {
+		return
+	}
+	config := cfbackup.NewConfigurationParserFromReader(installationSettings)
+	installation = &TileInstallation{
+		OpsManager:       opsManager,
+		Settings:         &config.InstallationSettings,
+		InstallationInfo: cfbackup.NewCredentialsInstallation(&config.InstallationSettings, opsManager),
+		BackupContext:    cfbackup.NewBackupContext(tileSpec.ArchiveDirectory, cfenv.CurrentEnv(), tileSpec.CryptKey),
+	}
+	if iaas, hasKey := config.GetIaaS(); hasKey {
+		installation.SSHKey = iaas.SSHPrivateKey
+	}
+	if err = tileSpec.ConfigureContext(&installation.BackupContext); err != nil {
+		installation = nil
+		return
+	}
+	installation.BackupContext.DirectorTLS = installation.Settings.DirectorTLS(installation.BackupContext.TLS)
+	return
+}
+
+//TileCloser - the tile closing the ssh tunnels of the installation along with it
+func (s *TileInstallation) TileCloser(tile tileregistry.Tile) tileregistry.TileCloser {
+	return struct {
+		tileregistry.Tile
+		tileregistry.Closer
+	}{
+		tile,
+		s.BackupContext.SSHTunnels,
+	}
+}
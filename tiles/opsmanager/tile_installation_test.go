@@ -0,0 +1,61 @@
+package opsmanager_test
+
+import (
+	"io/ioutil"
+	"os"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	. "github.com/pivotalservices/cfbackup/tiles/opsmanager"
+	opsfakes "github.com/pivotalservices/cfbackup/tiles/opsmanager/fakes"
+)
+
+var _ = Describe("TileInstallation", func() {
+	Describe("given a NewTileInstallation() method", func() {
+		Context("when called against a fake ops manager", func() {
+			var (
+				server   *opsfakes.FakeOpsManager
+				tileSpec tileregistry.TileSpec
+				tmpDir   string
+			)
+
+			BeforeEach(func() {
+				tmpDir, _ = ioutil.TempDir("/tmp", "test")
+				server = opsfakes.NewFakeOpsManager("admin", "admin-password", "opsPassphrase")
+				tileSpec = tileregistry.TileSpec{
+					OpsManagerHost:   server.Host(),
+					AdminUser:        "admin",
+					AdminPass:        "admin-password",
+					ArchiveDirectory: tmpDir,
+				}
+			})
+
+			AfterEach(func() {
+				server.Close()
+				os.RemoveAll(tmpDir)
+			})
+
+			It("then it should read the installation settings of the ops manager", func() {
+				installation, err := NewTileInstallation(tileSpec)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(installation.Settings.Infrastructure.Type).Should(Equal("vsphere"))
+				Ω(installation.InstallationInfo).ShouldNot(BeNil())
+			})
+
+			It("then it should configure the backup context from the tile spec", func() {
+				installation, err := NewTileInstallation(tileSpec)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(installation.BackupContext.TargetDir).Should(Equal(tmpDir))
+				Ω(installation.BackupContext.SSHTunnels).ShouldNot(BeNil())
+			})
+		})
+
+		Context("when called without a usable combination of credentials", func() {
+			It("then it should return the validation error", func() {
+				_, err := NewTileInstallation(tileregistry.TileSpec{ClientID: "backup-client"})
+				Ω(err).Should(Equal(tileregistry.ErrClientSecretMissing))
+			})
+		})
+	})
+})
@@ -0,0 +1,161 @@
+package opsmanager
+
+import (
+	"fmt"
+	"net/http"
+	"net/url"
+	"sync"
+
+	"github.com/pivotalservices/cfbackup"
+	ghttp "github.com/pivotalservices/gtils/http"
+	"github.com/xchapter7x/lo"
+)
+
+//tokenSource - authorizes ops manager api calls. the auth mode is picked on
+//the first call: the token we were handed, a uaa token fetched with the
+//client or admin credentials, or basic auth on a legacy ops manager without
+//a uaa
+type tokenSource struct {
+	token    string
+	username string
+	password string
+	clientID string
+	uaa      *cfbackup.UAATokenSource
+
+	mutex sync.Mutex
+	mode  string
+}
+
+func (s *AuthError) Error() string {
+	return fmt.Sprintf("ops manager uaa rejected the credentials with status code %d: %s", s.StatusCode, s.Message)
+}
+
+//tokens - the token source of the ops manager, created on first use. with a
+//client the uaa grants a client credentials token, and a password token for
+//the admin user otherwise
+func (context *OpsManager) tokens() *tokenSource {
+	if context.auth == nil {
+		tokenURL := fmt.Sprintf(OpsMgrUAATokenURL, context.Hostname)
+		grant := cfbackup.UAAGrant{
+			ClientID: OpsMgrUAAClient,
+			Values: url.Values{
+				"grant_type": {cfbackup.UAAGrantTypePassword},
+				"username":   {context.Username},
+				"password":   {context.Password},
+			},
+		}
+		if context.ClientID != "" {
+			grant = cfbackup.UAAGrant{
+				ClientID:     context.ClientID,
+				ClientSecret: context.ClientSecret,
+				Values:       url.Values{"grant_type": {cfbackup.UAAGrantTypeClientCredentials}},
+			}
+		}
+		context.auth = &tokenSource{
+			token:    context.Token,
+			username: context.Username,
+			password: context.Password,
+			clientID: context.ClientID,
+			uaa: &cfbackup.UAATokenSource{
+				HTTPClient:  context.httpClient,
+				TokenURL:    tokenURL,
+				Grants:      []cfbackup.UAAGrant{grant},
+				StatusError: uaaStatusError(tokenURL),
+			},
+		}
+	}
+	return context.auth
+}
+
+//uaaStatusError - rejected credentials are an AuthError, any other status an
+//HTTPStatusError
+func uaaStatusError(tokenURL string) func(statusCode int, message string) error {
+	return func(statusCode int, message string) error {
+		switch statusCode {
+		case http.StatusBadRequest, http.StatusUnauthorized, http.StatusForbidden:
+			return &AuthError{URL: tokenURL, StatusCode: statusCode, Message: message}
+		}
+		return &HTTPStatusError{URL: tokenURL, StatusCode: statusCode, Message: message}
+	}
+}
+
+//authorizeEntity - sets the credentials of a gateway request
+func (s *tokenSource) authorizeEntity(entity *ghttp.HttpRequestEntity) error {
+	mode, token, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	if mode == OpsMgrAuthModeBasic {
+		entity.Username = s.username
+		entity.Password = s.password
+	} else {
+		entity.Authorization = "Bearer " + token
+	}
+	return nil
+}
+
+//authorize - sets the credentials of a request
+func (s *tokenSource) authorize(req *http.Request) error {
+	mode, token, err := s.credentials()
+	if err != nil {
+		return err
+	}
+	if mode == OpsMgrAuthModeBasic {
+		req.SetBasicAuth(s.username, s.password)
+	} else {
+		req.Header.Set("Authorization", "Bearer "+token)
+	}
+	return nil
+}
+
+//reset - forgets the auth mode and any cached token, the next call detects
+//them again
+func (s *tokenSource) reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.mode = ""
+	s.uaa.Reset()
+}
+
+func (s *tokenSource) credentials() (mode, token string, err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.mode == "" {
+		if err = s.detectMode(); err != nil {
+			return
+		}
+	}
+	switch s.mode {
+	case OpsMgrAuthModeUAA:
+		token, err = s.uaa.Token()
+	case OpsMgrAuthModeToken:
+		token = s.token
+	}
+	return s.mode, token, err
+}
+
+//detectMode - an ops manager without a uaa answers 404 on the token endpoint
+//and takes basic auth, unless we were given a uaa client, which only works
+//against a uaa. rejected credentials are an AuthError
+func (s *tokenSource) detectMode() (err error) {
+	switch {
+	case s.token != "":
+		s.mode = OpsMgrAuthModeToken
+
+	default:
+		if _, err = s.uaa.Token(); err == nil {
+			s.mode = OpsMgrAuthModeUAA
+		} else if isNotFound(err) && s.clientID != "" {
+			err = &AuthError{URL: s.uaa.TokenURL, StatusCode: http.StatusNotFound, Message: "ops manager has no uaa to take the client credentials"}
+		} else if isNotFound(err) {
+			s.mode = OpsMgrAuthModeBasic
+			err = nil
+		}
+	}
+
+	if err == nil {
+		lo.G.Infof("authorizing ops manager api calls with %s auth", s.mode)
+	}
+	return
+}
@@ -3,6 +3,7 @@ package opsmanager
 import (
 	"io"
 	"net/http"
+	"time"
 
 	"github.com/pivotalservices/cfbackup"
 	"github.com/pivotalservices/gtils/command"
@@ -35,7 +36,54 @@ type (
 		SSHUsername         string
 		SSHPassword         string
 		SSHPort             int
-		ClearBoshManifest   bool
+		//RemoteOps - uploads the deployments archive to the ops manager vm
+		RemoteOps remoteOperations
+		//ClearBoshManifest - regenerate the director state on restore rather
+		//than taking it from the backup, the state files are removed once
+		//the deployments are restored
+		ClearBoshManifest bool
+		//APIVersion - the api version of the ops manager, detected on the
+		//first api call when left empty
+		APIVersion string
+		//ImportTimeout - how long a restore waits on ops manager to import the
+		//installation, OpsMgrDefaultImportTimeout when zero
+		ImportTimeout time.Duration
+		//ApplyChanges - apply changes once the installation is imported
+		ApplyChanges bool
+		//InstallTimeout - how long a restore waits on apply changes,
+		//OpsMgrDefaultInstallTimeout when zero
+		InstallTimeout time.Duration
+
+		auth   *tokenSource
+		client *http.Client
+	}
+
+	//InstallStatus - the status of an ops manager install (apply changes)
+	InstallStatus struct {
+		Status string `json:"status"`
+	}
+
+	//DeployedProduct - a product deployed by ops manager
+	DeployedProduct struct {
+		InstallationName string `json:"installation_name"`
+		GUID             string `json:"guid"`
+		Type             string `json:"type"`
+		ProductVersion   string `json:"product_version"`
+	}
+
+	//HTTPStatusError - an ops manager api call answered with an unsuccessful
+	//status code
+	HTTPStatusError struct {
+		URL        string
+		StatusCode int
+		Message    string
+	}
+
+	//AuthError - the uaa of ops manager rejected our credentials
+	AuthError struct {
+		URL        string
+		StatusCode int
+		Message    string
 	}
 
 	//OpsManagerBuilder - an object that can build ops manager objects
@@ -43,6 +91,12 @@ type (
 
 	httpUploader func(conn ghttp.ConnAuth, paramName, filename string, fileSize int64, fileRef io.Reader, params map[string]string) (res *http.Response, err error)
 
+	remoteOperations interface {
+		UploadFile(lfile io.Reader) (err error)
+		Path() string
+		RemoveRemoteFile() (err error)
+	}
+
 	httpRequestor interface {
 		Get(ghttp.HttpRequestEntity) ghttp.RequestAdaptor
 		Post(ghttp.HttpRequestEntity, io.Reader) ghttp.RequestAdaptor
@@ -0,0 +1,20 @@
+package plugin
+
+//Plugin constants
+const (
+	//PluginDirVarname - the environment variable naming the plugin directory
+	PluginDirVarname = "CFBACKUP_PLUGIN_DIR"
+	//PluginExecutablePrefix - plugin executables are named cfbackup-<tile>
+	//and registered under the tile name
+	PluginExecutablePrefix = "cfbackup-"
+	//PluginStorageSocket - the name of the socket the storage of the host is
+	//served on while a plugin backs up or restores
+	PluginStorageSocket = "storage.sock"
+	//PluginServiceName - the rpc service a plugin serves on stdin/stdout
+	PluginServiceName = "Plugin"
+	//StorageServiceName - the rpc service the host serves on the storage
+	//socket
+	StorageServiceName = "Storage"
+	//StorageChunkSize - the largest chunk of archive data sent in one call
+	StorageChunkSize = 64 * 1024
+)
@@ -0,0 +1,40 @@
+package plugin
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//Discover - registers every cfbackup-<tile> executable in the directory as
+//the tile <tile>. a plugin never replaces a tile which is already registered
+func Discover(dir string) (names []string, err error) {
+	var files []os.FileInfo
+	if files, err = ioutil.ReadDir(dir); err != nil {
+		return nil, errwrap.Wrap(err, "failed reading the plugin directory")
+	}
+
+	registry := tileregistry.GetRegistry()
+	for _, file := range files {
+		name := strings.TrimPrefix(file.Name(), PluginExecutablePrefix)
+		if name == file.Name() || name == "" || file.IsDir() || file.Mode()&0111 == 0 {
+			continue
+		}
+		if _, registered := registry[name]; registered {
+			lo.G.Errorf("plugin %s skipped, the tile %s is already registered", file.Name(), name)
+			continue
+		}
+		lo.G.Debug("registering plugin: ", file.Name())
+		tileregistry.Register(name, &PluginBuilder{
+			Name: name,
+			Path: path.Join(dir, file.Name()),
+		})
+		names = append(names, name)
+	}
+	return
+}
@@ -0,0 +1,76 @@
+package fakes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"os"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+)
+
+//FakePluginArchive - the file the fake plugin tile backs up to
+const FakePluginArchive = "archive.dat"
+
+//FakePluginTile - a plugin tile which backs up a large archive made of the
+//ops manager host of the tile spec and the arguments of the plugin process,
+//and checks the archive it restores from is that same archive. passing the
+//argument --fail makes both fail
+type FakePluginTile struct{}
+
+//Archive - the archive the fake plugin tile backs up for a tile spec and the
+//arguments of the plugin process
+func (s *FakePluginTile) Archive(tileSpec tileregistry.TileSpec, args []string) []byte {
+	header := fmt.Sprintf("%s %s\n", tileSpec.OpsManagerHost, strings.Join(args, " "))
+	return append([]byte(header), bytes.Repeat([]byte("plugin archive data\n"), 10000)...)
+}
+
+//Backup --
+func (s *FakePluginTile) Backup(tileSpec tileregistry.TileSpec, storage cfbackup.StorageProvider) (err error) {
+	if err = s.failure(); err != nil {
+		return
+	}
+	var writer io.WriteCloser
+	if writer, err = storage.Writer(FakePluginArchive); err != nil {
+		return
+	}
+	if _, err = writer.Write(s.Archive(tileSpec, os.Args[1:])); err != nil {
+		writer.Close()
+		return
+	}
+	return writer.Close()
+}
+
+//Restore --
+func (s *FakePluginTile) Restore(tileSpec tileregistry.TileSpec, storage cfbackup.StorageProvider) (err error) {
+	if err = s.failure(); err != nil {
+		return
+	}
+	var (
+		reader  io.ReadCloser
+		archive []byte
+	)
+	if reader, err = storage.Reader(FakePluginArchive); err != nil {
+		return
+	}
+	defer reader.Close()
+	if archive, err = ioutil.ReadAll(reader); err != nil {
+		return
+	}
+	if !bytes.Equal(archive, s.Archive(tileSpec, os.Args[1:])) {
+		return fmt.Errorf("restored archive of %d bytes does not match the backup", len(archive))
+	}
+	return
+}
+
+func (s *FakePluginTile) failure() error {
+	for _, arg := range os.Args[1:] {
+		if arg == "--fail" {
+			return fmt.Errorf("fake plugin told to fail")
+		}
+	}
+	return nil
+}
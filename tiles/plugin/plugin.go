@@ -0,0 +1,79 @@
+package plugin
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/rpc"
+	"os"
+	"path"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//stdio - the stdout and stdin of the plugin as a single connection
+type stdio struct {
+	io.ReadCloser
+	io.WriteCloser
+}
+
+//Close - closes both halves, the plugin exits once its stdin is closed
+func (s *stdio) Close() error {
+	werr := s.WriteCloser.Close()
+	if rerr := s.ReadCloser.Close(); rerr != nil {
+		return rerr
+	}
+	return werr
+}
+
+//Backup - asks the plugin for a backup, its archives are written to the
+//plugin directory of the backup
+func (context *Plugin) Backup() error {
+	return context.call(PluginServiceName + ".Backup")
+}
+
+//Restore - asks the plugin for a restore from the archives in the plugin
+//directory of the backup
+func (context *Plugin) Restore() error {
+	return context.call(PluginServiceName + ".Restore")
+}
+
+//Close - disconnects from the plugin and waits for it to exit
+func (context *Plugin) Close() {
+	context.client.Close()
+	if err := context.cmd.Wait(); err != nil {
+		lo.G.Errorf("plugin %s exited with %s", context.Name, err)
+	}
+}
+
+//call - serves the storage on a local socket for the duration of the call
+func (context *Plugin) call(method string) (err error) {
+	socketDir, err := ioutil.TempDir("", "cfbackup-plugin")
+	if err != nil {
+		return errwrap.Wrap(err, "failed creating the storage socket directory")
+	}
+	defer os.RemoveAll(socketDir)
+
+	socket := path.Join(socketDir, PluginStorageSocket)
+	listener, err := net.Listen("unix", socket)
+	if err != nil {
+		return errwrap.Wrap(err, "failed listening on the storage socket")
+	}
+	defer listener.Close()
+
+	storage := newStorageService(context.BackupContext, context.TargetDir, context.Name)
+	defer storage.closeAll()
+	server := rpc.NewServer()
+	if err = server.RegisterName(StorageServiceName, storage); err != nil {
+		return errwrap.Wrap(err, "failed registering the storage service")
+	}
+	go serveStorage(server, listener)
+
+	lo.G.Infof("calling %s on plugin %s", method, context.Name)
+	request := PluginRequest{TileSpec: context.TileSpec, StorageAddress: socket}
+	if err = context.client.Call(method, request, new(PluginResponse)); err != nil {
+		return errwrap.Wrap(err, "plugin "+context.Name+" failed")
+	}
+	return storage.closeAll()
+}
@@ -0,0 +1,49 @@
+package plugin
+
+import (
+	"net/rpc/jsonrpc"
+	"os"
+	"os/exec"
+	"strings"
+
+	"github.com/cloudfoundry-community/go-cfenv"
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//New -- starts the plugin executable, with the PluginArgs of the tile spec as
+//its arguments, and connects to it
+func (s *PluginBuilder) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	return tileSpec.Build(s.Name, s.build)
+}
+
+func (s *PluginBuilder) build(tileSpec tileregistry.TileSpec) (pluginCloser tileregistry.TileCloser, err error) {
+	cmd := exec.Command(s.Path, strings.Fields(tileSpec.PluginArgs)...)
+	cmd.Stderr = os.Stderr
+	stdin, err := cmd.StdinPipe()
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed opening the plugin stdin")
+	}
+	stdout, err := cmd.StdoutPipe()
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed opening the plugin stdout")
+	}
+
+	lo.G.Debug("starting plugin: ", s.Path)
+	if err = cmd.Start(); err != nil {
+		return nil, errwrap.Wrap(err, "failed starting the plugin "+s.Name)
+	}
+
+	plugin := &Plugin{
+		BackupContext: cfbackup.NewBackupContext(tileSpec.ArchiveDirectory, cfenv.CurrentEnv(), tileSpec.CryptKey),
+		Name:          s.Name,
+		TileSpec:      tileSpec,
+		cmd:           cmd,
+		client:        jsonrpc.NewClient(&stdio{ReadCloser: stdout, WriteCloser: stdin}),
+	}
+	plugin.Metrics = tileSpec.Metrics
+	pluginCloser = plugin
+	return
+}
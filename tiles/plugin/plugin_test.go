@@ -0,0 +1,126 @@
+package plugin_test
+
+import (
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	. "github.com/pivotalservices/cfbackup/tiles/plugin"
+	"github.com/pivotalservices/cfbackup/tiles/plugin/fakes"
+)
+
+const fakePluginVarname = "CFBACKUP_FAKE_PLUGIN"
+
+//the test binary doubles as the plugin executable, started through a script
+//in the plugin directory
+func init() {
+	if os.Getenv(fakePluginVarname) != "" {
+		Serve(new(fakes.FakePluginTile))
+		os.Exit(0)
+	}
+}
+
+var _ = Describe("Plugin", func() {
+	var (
+		pluginDir  string
+		archiveDir string
+	)
+
+	BeforeEach(func() {
+		pluginDir, _ = ioutil.TempDir("", "plugins")
+		archiveDir, _ = ioutil.TempDir("", "archive")
+		script := "#!/bin/sh\n" + fakePluginVarname + "=1 exec " + os.Args[0] + " \"$@\"\n"
+		ioutil.WriteFile(path.Join(pluginDir, PluginExecutablePrefix+"fake"), []byte(script), 0755)
+		ioutil.WriteFile(path.Join(pluginDir, PluginExecutablePrefix+"not-executable"), []byte(script), 0644)
+		ioutil.WriteFile(path.Join(pluginDir, "README"), []byte("not a plugin"), 0755)
+	})
+
+	AfterEach(func() {
+		tileregistry.Repo = make(map[string]tileregistry.TileGenerator)
+		os.RemoveAll(pluginDir)
+		os.RemoveAll(archiveDir)
+	})
+
+	Describe("given: a Discover() method", func() {
+		It("then: it should register the plugin executables by tile name", func() {
+			names, err := Discover(pluginDir)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(names).Should(Equal([]string{"fake"}))
+			Ω(tileregistry.GetRegistry()["fake"]).Should(Equal(&PluginBuilder{
+				Name: "fake",
+				Path: path.Join(pluginDir, PluginExecutablePrefix+"fake"),
+			}))
+		})
+
+		It("then: it should not replace a registered tile", func() {
+			builtin := new(PluginBuilder)
+			tileregistry.Register("fake", builtin)
+			names, err := Discover(pluginDir)
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(names).Should(BeEmpty())
+			Ω(tileregistry.GetRegistry()["fake"]).Should(Equal(builtin))
+		})
+
+		It("then: it should fail for a missing directory", func() {
+			_, err := Discover(path.Join(pluginDir, "missing"))
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when a plugin backs up and restores a tile", func() {
+		var (
+			tileSpec tileregistry.TileSpec
+			tile     tileregistry.TileCloser
+		)
+
+		BeforeEach(func() {
+			Discover(pluginDir)
+			tileSpec = tileregistry.TileSpec{
+				OpsManagerHost:   "opsman.example.com",
+				ArchiveDirectory: archiveDir,
+				PluginArgs:       "--tile p-fake",
+			}
+		})
+
+		JustBeforeEach(func() {
+			var err error
+			tile, err = tileregistry.GetRegistry()["fake"].New(tileSpec)
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			tile.Close()
+		})
+
+		It("then: it should stream the archive into the plugin directory of the backup", func() {
+			Ω(tile.Backup()).Should(Succeed())
+			archive, err := ioutil.ReadFile(path.Join(archiveDir, "fake", fakes.FakePluginArchive))
+			Ω(err).ShouldNot(HaveOccurred())
+			Ω(archive).Should(Equal(new(fakes.FakePluginTile).Archive(tileSpec, []string{"--tile", "p-fake"})))
+		})
+
+		It("then: it should stream the archive back on restore", func() {
+			Ω(tile.Backup()).Should(Succeed())
+			Ω(tile.Restore()).Should(Succeed())
+		})
+
+		It("then: it should surface the errors of the plugin", func() {
+			os.MkdirAll(path.Join(archiveDir, "fake"), 0755)
+			ioutil.WriteFile(path.Join(archiveDir, "fake", fakes.FakePluginArchive), []byte("corrupt"), 0644)
+			Ω(tile.Restore()).ShouldNot(Succeed())
+		})
+
+		Context("when the plugin fails", func() {
+			BeforeEach(func() {
+				tileSpec.PluginArgs = "--fail"
+			})
+
+			It("then: it should fail the backup", func() {
+				Ω(tile.Backup()).ShouldNot(Succeed())
+			})
+		})
+	})
+})
@@ -0,0 +1,121 @@
+package plugin
+
+import (
+	"io"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"os"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	errwrap "github.com/pkg/errors"
+)
+
+//Serve - the main loop of a plugin executable: serves the tile over
+//json-rpc on stdin and stdout until the host closes stdin. anything the
+//plugin logs has to go to stderr
+func Serve(tile PluginTile) error {
+	return ServeConn(tile, &stdio{ReadCloser: os.Stdin, WriteCloser: os.Stdout})
+}
+
+//ServeConn - serves the tile over json-rpc on the given connection
+func ServeConn(tile PluginTile, conn io.ReadWriteCloser) error {
+	server := rpc.NewServer()
+	if err := server.RegisterName(PluginServiceName, &pluginService{tile: tile}); err != nil {
+		return errwrap.Wrap(err, "failed registering the plugin service")
+	}
+	server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	return nil
+}
+
+//Backup - backs the tile up into the storage of the host
+func (s *pluginService) Backup(request PluginRequest, response *PluginResponse) error {
+	return s.withStorage(request, s.tile.Backup)
+}
+
+//Restore - restores the tile from the storage of the host
+func (s *pluginService) Restore(request PluginRequest, response *PluginResponse) error {
+	return s.withStorage(request, s.tile.Restore)
+}
+
+func (s *pluginService) withStorage(request PluginRequest, action func(tileSpec tileregistry.TileSpec, storage cfbackup.StorageProvider) error) error {
+	client, err := jsonrpc.Dial("unix", request.StorageAddress)
+	if err != nil {
+		return errwrap.Wrap(err, "failed connecting to the storage of the host")
+	}
+	defer client.Close()
+	return action(request.TileSpec, &storageClient{client: client})
+}
+
+//Writer - creates a file in the plugin directory of the backup
+func (s *storageClient) Writer(path ...string) (io.WriteCloser, error) {
+	handle := StorageHandle{}
+	if err := s.client.Call(StorageServiceName+".Create", StorageFile{Path: path}, &handle); err != nil {
+		return nil, err
+	}
+	return &storageWriter{client: s.client, handle: handle}, nil
+}
+
+//Reader - opens a file in the plugin directory of the backup
+func (s *storageClient) Reader(path ...string) (io.ReadCloser, error) {
+	handle := StorageHandle{}
+	if err := s.client.Call(StorageServiceName+".Open", StorageFile{Path: path}, &handle); err != nil {
+		return nil, err
+	}
+	return &storageReader{client: s.client, handle: handle}, nil
+}
+
+//storageWriter - a file of the host storage, written in chunks of at most
+//StorageChunkSize
+type storageWriter struct {
+	client *rpc.Client
+	handle StorageHandle
+}
+
+func (s *storageWriter) Write(p []byte) (n int, err error) {
+	for len(p) > 0 {
+		chunk := p
+		if len(chunk) > StorageChunkSize {
+			chunk = chunk[:StorageChunkSize]
+		}
+		var written int
+		if err = s.client.Call(StorageServiceName+".Write", StorageChunk{ID: s.handle.ID, Data: chunk}, &written); err != nil {
+			return
+		}
+		n += written
+		p = p[len(chunk):]
+	}
+	return
+}
+
+func (s *storageWriter) Close() error {
+	return s.client.Call(StorageServiceName+".Close", s.handle, new(bool))
+}
+
+//storageReader - a file of the host storage, read a chunk at a time
+type storageReader struct {
+	client  *rpc.Client
+	handle  StorageHandle
+	pending []byte
+	eof     bool
+}
+
+func (s *storageReader) Read(p []byte) (int, error) {
+	for len(s.pending) == 0 {
+		if s.eof {
+			return 0, io.EOF
+		}
+		chunk := StorageChunk{}
+		if err := s.client.Call(StorageServiceName+".Read", s.handle, &chunk); err != nil {
+			return 0, err
+		}
+		s.pending, s.eof = chunk.Data, chunk.EOF
+	}
+	n := copy(p, s.pending)
+	s.pending = s.pending[n:]
+	return n, nil
+}
+
+func (s *storageReader) Close() error {
+	return s.client.Call(StorageServiceName+".Close", s.handle, new(bool))
+}
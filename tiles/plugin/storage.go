@@ -0,0 +1,153 @@
+package plugin
+
+import (
+	"fmt"
+	"io"
+	"net"
+	"net/rpc"
+	"net/rpc/jsonrpc"
+	"path"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/xchapter7x/lo"
+)
+
+func newStorageService(storage cfbackup.StorageProvider, root ...string) *storageService {
+	return &storageService{
+		storage: storage,
+		root:    root,
+		readers: make(map[int]io.ReadCloser),
+		writers: make(map[int]io.WriteCloser),
+	}
+}
+
+func serveStorage(server *rpc.Server, listener net.Listener) {
+	for {
+		conn, err := listener.Accept()
+		if err != nil {
+			return
+		}
+		go server.ServeCodec(jsonrpc.NewServerCodec(conn))
+	}
+}
+
+//Create - opens a file of the backup for writing
+func (s *storageService) Create(file StorageFile, handle *StorageHandle) (err error) {
+	var (
+		filePath []string
+		writer   io.WriteCloser
+	)
+	if filePath, err = s.path(file); err != nil {
+		return
+	}
+	lo.G.Debug("plugin writing: ", path.Join(filePath...))
+	if writer, err = s.storage.Writer(filePath...); err != nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastID++
+	s.writers[s.lastID] = writer
+	handle.ID = s.lastID
+	return
+}
+
+//Open - opens a file of the backup for reading
+func (s *storageService) Open(file StorageFile, handle *StorageHandle) (err error) {
+	var (
+		filePath []string
+		reader   io.ReadCloser
+	)
+	if filePath, err = s.path(file); err != nil {
+		return
+	}
+	lo.G.Debug("plugin reading: ", path.Join(filePath...))
+	if reader, err = s.storage.Reader(filePath...); err != nil {
+		return
+	}
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.lastID++
+	s.readers[s.lastID] = reader
+	handle.ID = s.lastID
+	return
+}
+
+//Write - writes a chunk to a file opened with Create
+func (s *storageService) Write(chunk StorageChunk, written *int) (err error) {
+	s.mutex.Lock()
+	writer, ok := s.writers[chunk.ID]
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no file open for writing with handle %d", chunk.ID)
+	}
+	*written, err = writer.Write(chunk.Data)
+	return
+}
+
+//Read - reads the next chunk of a file opened with Open, at most
+//StorageChunkSize bytes
+func (s *storageService) Read(handle StorageHandle, chunk *StorageChunk) (err error) {
+	s.mutex.Lock()
+	reader, ok := s.readers[handle.ID]
+	s.mutex.Unlock()
+	if !ok {
+		return fmt.Errorf("no file open for reading with handle %d", handle.ID)
+	}
+	buffer := make([]byte, StorageChunkSize)
+	n, err := io.ReadFull(reader, buffer)
+	chunk.ID = handle.ID
+	chunk.Data = buffer[:n]
+	if err == io.EOF || err == io.ErrUnexpectedEOF {
+		chunk.EOF, err = true, nil
+	}
+	return
+}
+
+//Close - closes a file opened with Create or Open
+func (s *storageService) Close(handle StorageHandle, closed *bool) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	*closed = true
+	if writer, ok := s.writers[handle.ID]; ok {
+		delete(s.writers, handle.ID)
+		return writer.Close()
+	}
+	if reader, ok := s.readers[handle.ID]; ok {
+		delete(s.readers, handle.ID)
+		return reader.Close()
+	}
+	return fmt.Errorf("no file open with handle %d", handle.ID)
+}
+
+//closeAll - closes whatever the plugin left open, a file left open for
+//writing is an error as it might be incomplete
+func (s *storageService) closeAll() (err error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	for id, writer := range s.writers {
+		writer.Close()
+		delete(s.writers, id)
+		err = fmt.Errorf("plugin left a file open for writing")
+	}
+	for id, reader := range s.readers {
+		reader.Close()
+		delete(s.readers, id)
+	}
+	return
+}
+
+//path - the path of a plugin file in the backup, plugins cannot reach out of
+//their own directory
+func (s *storageService) path(file StorageFile) ([]string, error) {
+	if len(file.Path) == 0 {
+		return nil, fmt.Errorf("no path given")
+	}
+	for _, element := range file.Path {
+		if element == "" || element == "." || element == ".." || strings.ContainsAny(element, `/\`) {
+			return nil, fmt.Errorf("invalid path element %q", element)
+		}
+	}
+	return append(append([]string{}, s.root...), file.Path...), nil
+}
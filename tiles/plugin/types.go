@@ -0,0 +1,87 @@
+package plugin
+
+import (
+	"io"
+	"net/rpc"
+	"os/exec"
+	"sync"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/tileregistry"
+)
+
+type (
+	//PluginBuilder - a TileGenerator starting the plugin executable at Path
+	//for each tile it builds
+	PluginBuilder struct {
+		Name string
+		Path string
+	}
+
+	//Plugin - a tile backed up and restored by a plugin process. the plugin
+	//is called over json-rpc on its stdin and stdout and streams its archives
+	//through the storage of the host, served on a local socket
+	Plugin struct {
+		cfbackup.BackupContext
+		Name     string
+		TileSpec tileregistry.TileSpec
+		cmd      *exec.Cmd
+		client   *rpc.Client
+	}
+
+	//PluginTile - what a plugin implements. the storage paths it is handed
+	//are relative to the plugin directory of the backup
+	PluginTile interface {
+		Backup(tileSpec tileregistry.TileSpec, storage cfbackup.StorageProvider) error
+		Restore(tileSpec tileregistry.TileSpec, storage cfbackup.StorageProvider) error
+	}
+
+	//PluginRequest - the arguments of the Plugin.Backup and Plugin.Restore
+	//calls
+	PluginRequest struct {
+		TileSpec       tileregistry.TileSpec
+		StorageAddress string
+	}
+
+	//PluginResponse - the reply of the Plugin.Backup and Plugin.Restore calls
+	PluginResponse struct{}
+
+	//StorageFile - the arguments of the Storage.Create and Storage.Open calls
+	StorageFile struct {
+		Path []string
+	}
+
+	//StorageHandle - a file opened through the Storage service
+	StorageHandle struct {
+		ID int
+	}
+
+	//StorageChunk - archive data written to or read from a file, EOF is set
+	//once a read reaches the end of the file
+	StorageChunk struct {
+		ID   int
+		Data []byte
+		EOF  bool
+	}
+
+	//storageService - the Storage rpc service, files opened through it are
+	//kept by handle until they are closed
+	storageService struct {
+		storage cfbackup.StorageProvider
+		root    []string
+		mutex   sync.Mutex
+		lastID  int
+		readers map[int]io.ReadCloser
+		writers map[int]io.WriteCloser
+	}
+
+	//pluginService - the Plugin rpc service served by a plugin
+	pluginService struct {
+		tile PluginTile
+	}
+
+	//storageClient - the storage of the host as seen by a plugin
+	storageClient struct {
+		client *rpc.Client
+	}
+)
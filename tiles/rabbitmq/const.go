@@ -0,0 +1,40 @@
+package rabbitmq
+
+import "errors"
+
+//RabbitMQ constants
+const (
+	//RabbitMQProductID - the product identifier of the rabbitmq service tile
+	RabbitMQProductID = "p-rabbitmq"
+	//RabbitMQTileName - the name the rabbitmq tile is registered and run its
+	//hooks under
+	RabbitMQTileName = "p-rabbitmq"
+	//RabbitMQServerJob - the job of the rabbitmq cluster nodes
+	RabbitMQServerJob = "rabbitmq-server"
+	//RabbitMQAdminCredentials - the server job property holding the admin
+	//user of the management api
+	RabbitMQAdminCredentials = "server_admin_credentials"
+	//RabbitMQManagementPort - the port of the management api on the nodes
+	RabbitMQManagementPort = 15672
+	//RabbitMQDefinitionsURL - the management endpoint exporting and importing
+	//the vhosts, users, permissions, policies, exchanges, queues and bindings
+	RabbitMQDefinitionsURL = "http://%s:%d/api/definitions"
+	//RabbitMQBackupDir - where the tile is kept in the backup
+	RabbitMQBackupDir = "p-rabbitmq"
+	//RabbitMQDefinitionsFileName - the broker definitions
+	RabbitMQDefinitionsFileName = "definitions.json"
+	//RabbitMQMnesiaFileName - the mnesia snapshot of the node with the index
+	RabbitMQMnesiaFileName = "mnesia-%d.tar.gz"
+	//RabbitMQStoreDir - the persistent disk of the nodes
+	RabbitMQStoreDir = "/var/vcap/store/rabbitmq"
+	//RabbitMQMnesiaDir - the mnesia database below the store dir
+	RabbitMQMnesiaDir = "mnesia"
+	//RabbitMQCtl - stops and starts the rabbit app of a node around its
+	//mnesia snapshot
+	RabbitMQCtl = "/var/vcap/packages/rabbitmq-server/bin/rabbitmqctl"
+)
+
+var (
+	//ErrRabbitMQNoServers - the product has no rabbitmq nodes to back up
+	ErrRabbitMQNoServers = errors.New("no rabbitmq servers found in the p-rabbitmq product")
+)
@@ -0,0 +1,113 @@
+package fakes
+
+import (
+	"io"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"strconv"
+	"sync"
+)
+
+//FakeManagementAPI - an in-process rabbitmq management api taking basic
+//auth, serving and storing the broker definitions
+type FakeManagementAPI struct {
+	*httptest.Server
+	Username    string
+	Password    string
+	Definitions []byte
+
+	mutex    sync.Mutex
+	requests []string
+	failNext int
+}
+
+//NewFakeManagementAPI - starts a management api serving the definitions
+func NewFakeManagementAPI(username, password string, definitions []byte) *FakeManagementAPI {
+	s := &FakeManagementAPI{Username: username, Password: password, Definitions: definitions}
+	s.Server = httptest.NewServer(http.HandlerFunc(s.serveHTTP))
+	return s
+}
+
+//Address - the host and port of the api
+func (s *FakeManagementAPI) Address() (string, int) {
+	u, _ := url.Parse(s.URL)
+	host, port, _ := net.SplitHostPort(u.Host)
+	p, _ := strconv.Atoi(port)
+	return host, p
+}
+
+//Requests - every request served so far as "METHOD /path"
+func (s *FakeManagementAPI) Requests() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.requests...)
+}
+
+//FailNextRequest - answers the next request with the status code
+func (s *FakeManagementAPI) FailNextRequest(statusCode int) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.failNext = statusCode
+}
+
+func (s *FakeManagementAPI) serveHTTP(w http.ResponseWriter, req *http.Request) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.requests = append(s.requests, req.Method+" "+req.URL.Path)
+
+	if username, password, ok := req.BasicAuth(); !ok || username != s.Username || password != s.Password {
+		w.WriteHeader(http.StatusUnauthorized)
+		return
+	}
+	if s.failNext != 0 {
+		w.WriteHeader(s.failNext)
+		s.failNext = 0
+		return
+	}
+	if req.URL.Path != "/api/definitions" {
+		w.WriteHeader(http.StatusNotFound)
+		return
+	}
+
+	switch req.Method {
+	case "GET":
+		w.Header().Set("Content-Type", "application/json")
+		w.Write(s.Definitions)
+	case "POST":
+		s.Definitions, _ = ioutil.ReadAll(req.Body)
+		w.WriteHeader(http.StatusNoContent)
+	default:
+		w.WriteHeader(http.StatusMethodNotAllowed)
+	}
+}
+
+//FakeExecuter - records the commands run on a node and answers them with
+//the output
+type FakeExecuter struct {
+	mutex    sync.Mutex
+	commands []string
+	Output   []byte
+	ErrFake  error
+}
+
+//Execute --
+func (s *FakeExecuter) Execute(dest io.Writer, cmd string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands = append(s.commands, cmd)
+	if s.ErrFake != nil {
+		return s.ErrFake
+	}
+	_, err := dest.Write(s.Output)
+	return err
+}
+
+//Commands - every command run so far
+func (s *FakeExecuter) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.commands...)
+}
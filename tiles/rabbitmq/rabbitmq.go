@@ -0,0 +1,221 @@
+package rabbitmq
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"net/http"
+	"path"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/gtils/command"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//NewRemoteExecuter - runs the mnesia snapshot on the nodes
+var NewRemoteExecuter = command.NewRemoteExecutor
+
+//NewRabbitMQ - the rabbitmq tile of the installation, with the admin
+//credentials and node ips of the p-rabbitmq product
+func NewRabbitMQ(installationInfo cfbackup.InstallationInfo, backupContext cfbackup.BackupContext, sshKey string) (context *RabbitMQ, err error) {
+	var (
+		product       cfbackup.Products
+		admin         map[string]string
+		vmCredentials cfbackup.VMCredentials
+	)
+	if product, err = installationInfo.FindByProductID(RabbitMQProductID); err != nil {
+		return
+	}
+	context = &RabbitMQ{
+		BackupContext:  backupContext,
+		DeploymentName: product.InstallationName,
+		ManagementPort: RabbitMQManagementPort,
+		SSHPrivateKey:  sshKey,
+	}
+
+	if context.Servers, err = installationInfo.FindIPsByProductAndJob(RabbitMQProductID, RabbitMQServerJob); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the rabbitmq servers")
+	}
+	if len(context.Servers) == 0 {
+		return nil, ErrRabbitMQNoServers
+	}
+	if admin, err = installationInfo.FindPropertyValues(RabbitMQProductID, RabbitMQServerJob, RabbitMQAdminCredentials); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the rabbitmq admin credentials")
+	}
+	context.AdminUser, context.AdminPass = admin["identity"], admin["password"]
+
+	if vmCredentials, err = installationInfo.FindVMCredentialsByProductAndJob(RabbitMQProductID, RabbitMQServerJob); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the rabbitmq vm credentials")
+	}
+	context.VcapUser, context.VcapPass = vmCredentials.UserID, vmCredentials.Password
+	return
+}
+
+//EnableMnesiaSnapshot - also backs up the mnesia database of every node
+func (context *RabbitMQ) EnableMnesiaSnapshot() {
+	context.MnesiaSnapshot = true
+}
+
+//Backup - exports the broker definitions, and the mnesia snapshots when
+//enabled
+func (context *RabbitMQ) Backup() (err error) {
+	if err = context.exportDefinitions(); err != nil {
+		return
+	}
+	if context.MnesiaSnapshot {
+		for index, server := range context.Servers {
+			if err = context.snapshotMnesia(index, server); err != nil {
+				return
+			}
+		}
+	}
+	return
+}
+
+//Restore - imports the broker definitions into the cluster. the mnesia
+//snapshots are only kept for recovering a lost node by hand
+func (context *RabbitMQ) Restore() (err error) {
+	var (
+		reader      io.ReadCloser
+		definitions []byte
+	)
+	if reader, err = context.Reader(context.definitionsPath()); err != nil {
+		return errwrap.Wrap(err, "failed opening the rabbitmq definitions")
+	}
+	defer reader.Close()
+	if definitions, err = ioutil.ReadAll(reader); err != nil {
+		return errwrap.Wrap(err, "failed reading the rabbitmq definitions")
+	}
+
+	lo.G.Infof("importing %d bytes of rabbitmq definitions", len(definitions))
+	_, err = context.callManagementAPI("POST", definitions)
+	return
+}
+
+func (context *RabbitMQ) exportDefinitions() (err error) {
+	var (
+		definitions []byte
+		writer      io.WriteCloser
+	)
+	lo.G.Info("exporting the rabbitmq definitions")
+	if definitions, err = context.callManagementAPI("GET", nil); err != nil {
+		return
+	}
+	if writer, err = context.Writer(context.definitionsPath()); err != nil {
+		return errwrap.Wrap(err, "failed creating the rabbitmq definitions")
+	}
+	defer writer.Close()
+	_, err = writer.Write(definitions)
+	return
+}
+
+func (context *RabbitMQ) definitionsPath() string {
+	return path.Join(context.TargetDir, RabbitMQBackupDir, RabbitMQDefinitionsFileName)
+}
+
+//callManagementAPI - calls the definitions endpoint on the first node which
+//can be reached, tunneled through the jump hosts when there are any
+func (context *RabbitMQ) callManagementAPI(method string, body []byte) (response []byte, err error) {
+	for _, server := range context.Servers {
+		if response, err = context.callServer(server, method, body); err == nil {
+			return
+		}
+		lo.G.Debugf("rabbitmq management api of %s failed: %v", server, err)
+	}
+	return nil, errwrap.Wrap(err, "failed calling the rabbitmq management api")
+}
+
+func (context *RabbitMQ) callServer(server, method string, body []byte) (response []byte, err error) {
+	var (
+		address command.SshConfig
+		client  *http.Client
+		req     *http.Request
+		res     *http.Response
+	)
+	if address, err = context.SSHTunnels.TunnelSSHConfig(command.SshConfig{Host: server, Port: context.ManagementPort}); err != nil {
+		return
+	}
+	if client, err = context.httpClient(); err != nil {
+		return
+	}
+
+	endpoint := fmt.Sprintf(RabbitMQDefinitionsURL, address.Host, address.Port)
+	if req, err = http.NewRequest(method, endpoint, bytes.NewReader(body)); err != nil {
+		return nil, errwrap.Wrap(err, "failed creating the management api request")
+	}
+	req.SetBasicAuth(context.AdminUser, context.AdminPass)
+	req.Header.Set("Content-Type", "application/json")
+
+	if res, err = client.Do(req); err != nil {
+		return
+	}
+	defer res.Body.Close()
+	if response, err = ioutil.ReadAll(res.Body); err != nil {
+		return
+	}
+	switch res.StatusCode {
+	case http.StatusOK, http.StatusCreated, http.StatusNoContent:
+		return
+	default:
+		return nil, fmt.Errorf("rabbitmq management api of %s answered %d: %s", server, res.StatusCode, response)
+	}
+}
+
+func (context *RabbitMQ) httpClient() (client *http.Client, err error) {
+	if context.client == nil {
+		context.client, err = context.TLS.HTTPClient()
+	}
+	return context.client, err
+}
+
+//snapshotMnesia - writes a tarball of the mnesia database of the node into
+//the backup. the rabbit app of the node is stopped for the tarball, so mnesia
+//is not written to while it is taken, and started again afterwards. the
+//nodes are snapshot one at a time, so the cluster keeps serving
+func (context *RabbitMQ) snapshotMnesia(index int, server string) (err error) {
+	var (
+		config   command.SshConfig
+		executer command.Executer
+		writer   io.WriteCloser
+	)
+	if config, err = context.SSHTunnels.TunnelSSHConfig(command.SshConfig{
+		Username: context.VcapUser,
+		Password: context.VcapPass,
+		Host:     server,
+		Port:     22,
+		SSLKey:   context.SSHPrivateKey,
+	}); err != nil {
+		return
+	}
+	if executer, err = NewRemoteExecuter(config); err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("failed connecting to rabbitmq server %s", server))
+	}
+
+	filepath := path.Join(context.TargetDir, RabbitMQBackupDir, fmt.Sprintf(RabbitMQMnesiaFileName, index))
+	if writer, err = context.Writer(filepath); err != nil {
+		return errwrap.Wrap(err, "failed creating the mnesia snapshot")
+	}
+	defer writer.Close()
+
+	lo.G.Infof("stopping the rabbit app of rabbitmq server %s", server)
+	if err = executer.Execute(ioutil.Discard, fmt.Sprintf("%s stop_app", RabbitMQCtl)); err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("failed stopping the rabbit app of %s", server))
+	}
+
+	lo.G.Infof("taking a mnesia snapshot of rabbitmq server %s", server)
+	snapshotErr := executer.Execute(writer, fmt.Sprintf("cd %s && tar cz %s", RabbitMQStoreDir, RabbitMQMnesiaDir))
+
+	lo.G.Infof("starting the rabbit app of rabbitmq server %s", server)
+	if err = executer.Execute(ioutil.Discard, fmt.Sprintf("%s start_app", RabbitMQCtl)); err != nil {
+		if snapshotErr != nil {
+			return errwrap.Wrapf(snapshotErr, "failed taking the mnesia snapshot of %s, starting the rabbit app again also failed: %v", server, err)
+		}
+		return errwrap.Wrap(err, fmt.Sprintf("failed starting the rabbit app of %s", server))
+	}
+	if snapshotErr != nil {
+		return errwrap.Wrap(snapshotErr, fmt.Sprintf("failed taking the mnesia snapshot of %s", server))
+	}
+	return
+}
@@ -0,0 +1,29 @@
+package rabbitmq
+
+import (
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tiles/opsmanager"
+)
+
+//New -- builds the rabbitmq tile from the installation settings of ops manager
+func (s *RabbitMQBuilder) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	return tileSpec.Build(RabbitMQTileName, s.build)
+}
+
+func (s *RabbitMQBuilder) build(tileSpec tileregistry.TileSpec) (rabbitMQCloser tileregistry.TileCloser, err error) {
+	var (
+		installation *opsmanager.TileInstallation
+		rabbitMQ     *RabbitMQ
+	)
+	if installation, err = opsmanager.NewTileInstallation(tileSpec); err != nil {
+		return
+	}
+	if rabbitMQ, err = NewRabbitMQ(installation.InstallationInfo, installation.BackupContext, installation.SSHKey); err != nil {
+		return
+	}
+	if tileSpec.MnesiaSnapshot {
+		rabbitMQ.EnableMnesiaSnapshot()
+	}
+	rabbitMQCloser = installation.TileCloser(rabbitMQ)
+	return
+}
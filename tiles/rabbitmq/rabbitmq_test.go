@@ -0,0 +1,208 @@
+package rabbitmq_test
+
+import (
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup"
+	. "github.com/pivotalservices/cfbackup/tiles/rabbitmq"
+	"github.com/pivotalservices/cfbackup/tiles/rabbitmq/fakes"
+	"github.com/pivotalservices/gtils/command"
+)
+
+var _ = Describe("RabbitMQ", func() {
+	var (
+		tmpDir        string
+		backupContext cfbackup.BackupContext
+		rabbitMQ      *RabbitMQ
+		definitions   = []byte(`{"rabbit_version":"3.5.6","vhosts":[{"name":"/"}],"queues":[]}`)
+
+		originalNewRemoteExecuter = NewRemoteExecuter
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cfbackup-rabbitmq")
+		Ω(err).ShouldNot(HaveOccurred())
+		backupContext = cfbackup.NewBackupContext(tmpDir, map[string]string{}, "")
+
+		config := cfbackup.NewConfigurationParser("../../fixtures/installation-settings-1-4-variant.json")
+		rabbitMQ, err = NewRabbitMQ(&config.InstallationSettings, backupContext, "ssh-key")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+		NewRemoteExecuter = originalNewRemoteExecuter
+	})
+
+	Describe("NewRabbitMQ", func() {
+		It("then it should find the deployment of the p-rabbitmq product", func() {
+			Ω(rabbitMQ.DeploymentName).Should(Equal("p-rabbitmq-a7e3f2e17f14a6fa4ae0"))
+		})
+
+		It("then it should resolve the servers and admin credentials", func() {
+			Ω(rabbitMQ.Servers).Should(ConsistOf("192.168.8.77", "192.168.8.78"))
+			Ω(rabbitMQ.AdminUser).Should(Equal("pcfrabbitadmin"))
+			Ω(rabbitMQ.AdminPass).Should(Equal("pcfrabbitadmin"))
+			Ω(rabbitMQ.ManagementPort).Should(Equal(RabbitMQManagementPort))
+		})
+
+		It("then it should resolve the vm credentials", func() {
+			Ω(rabbitMQ.VcapUser).Should(Equal("vcap"))
+			Ω(rabbitMQ.VcapPass).Should(Equal("7919e0f2abeb441c"))
+			Ω(rabbitMQ.SSHPrivateKey).Should(Equal("ssh-key"))
+		})
+
+		It("then it should not take mnesia snapshots by default", func() {
+			Ω(rabbitMQ.MnesiaSnapshot).Should(BeFalse())
+		})
+
+		Context("when the installation has no p-rabbitmq product", func() {
+			It("then it should fail", func() {
+				config := cfbackup.NewConfigurationParser("../../fixtures/installation-settings-1-6.json")
+				_, err := NewRabbitMQ(&config.InstallationSettings, backupContext, "")
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("against a fake management api", func() {
+		var (
+			api             *fakes.FakeManagementAPI
+			definitionsPath string
+		)
+
+		BeforeEach(func() {
+			api = fakes.NewFakeManagementAPI(rabbitMQ.AdminUser, rabbitMQ.AdminPass, definitions)
+			host, port := api.Address()
+			rabbitMQ.Servers = []string{host}
+			rabbitMQ.ManagementPort = port
+			definitionsPath = path.Join(tmpDir, RabbitMQBackupDir, RabbitMQDefinitionsFileName)
+		})
+
+		AfterEach(func() {
+			api.Close()
+		})
+
+		Describe("Backup", func() {
+			It("then it should export the definitions", func() {
+				Ω(rabbitMQ.Backup()).Should(Succeed())
+				Ω(ioutil.ReadFile(definitionsPath)).Should(Equal(definitions))
+				Ω(api.Requests()).Should(Equal([]string{"GET /api/definitions"}))
+			})
+
+			Context("when the management api fails", func() {
+				BeforeEach(func() {
+					api.FailNextRequest(500)
+				})
+
+				It("then it should fail", func() {
+					Ω(rabbitMQ.Backup()).ShouldNot(Succeed())
+				})
+			})
+
+			Context("when the credentials are rejected", func() {
+				BeforeEach(func() {
+					rabbitMQ.AdminPass = "wrong"
+				})
+
+				It("then it should fail", func() {
+					Ω(rabbitMQ.Backup()).ShouldNot(Succeed())
+				})
+			})
+
+			Context("when the first server can not be reached", func() {
+				BeforeEach(func() {
+					rabbitMQ.Servers = append([]string{"127.0.0.2"}, rabbitMQ.Servers...)
+				})
+
+				It("then it should export the definitions from the next one", func() {
+					Ω(rabbitMQ.Backup()).Should(Succeed())
+					Ω(ioutil.ReadFile(definitionsPath)).Should(Equal(definitions))
+				})
+			})
+
+			Context("when mnesia snapshots are enabled", func() {
+				var (
+					executer *fakes.FakeExecuter
+					configs  []command.SshConfig
+				)
+
+				BeforeEach(func() {
+					configs = nil
+					executer = &fakes.FakeExecuter{Output: []byte("mnesia tarball")}
+					NewRemoteExecuter = func(config command.SshConfig) (command.Executer, error) {
+						configs = append(configs, config)
+						return executer, nil
+					}
+					rabbitMQ.Servers = append(rabbitMQ.Servers, rabbitMQ.Servers[0])
+					rabbitMQ.EnableMnesiaSnapshot()
+				})
+
+				It("then it should snapshot every server", func() {
+					Ω(rabbitMQ.Backup()).Should(Succeed())
+					Ω(configs).Should(HaveLen(2))
+					Ω(configs[0].Username).Should(Equal("vcap"))
+					Ω(configs[0].SSLKey).Should(Equal("ssh-key"))
+					Ω(executer.Commands()).Should(HaveLen(6))
+					Ω(executer.Commands()[1]).Should(ContainSubstring(RabbitMQMnesiaDir))
+					for index := range rabbitMQ.Servers {
+						snapshot := path.Join(tmpDir, RabbitMQBackupDir, fmt.Sprintf(RabbitMQMnesiaFileName, index))
+						Ω(ioutil.ReadFile(snapshot)).Should(Equal(executer.Output))
+					}
+				})
+
+				It("then it should stop the rabbit app of each server around its snapshot", func() {
+					Ω(rabbitMQ.Backup()).Should(Succeed())
+					commands := executer.Commands()
+					Ω(commands[0]).Should(Equal(RabbitMQCtl + " stop_app"))
+					Ω(commands[2]).Should(Equal(RabbitMQCtl + " start_app"))
+					Ω(commands[3]).Should(Equal(RabbitMQCtl + " stop_app"))
+					Ω(commands[5]).Should(Equal(RabbitMQCtl + " start_app"))
+				})
+
+				Context("when a snapshot fails", func() {
+					BeforeEach(func() {
+						executer.ErrFake = errors.New("no space left")
+					})
+
+					It("then it should fail", func() {
+						Ω(rabbitMQ.Backup()).ShouldNot(Succeed())
+					})
+				})
+			})
+		})
+
+		Describe("Restore", func() {
+			var restored = []byte(`{"rabbit_version":"3.5.6","vhosts":[{"name":"restored"}]}`)
+
+			BeforeEach(func() {
+				Ω(os.MkdirAll(path.Dir(definitionsPath), 0755)).Should(Succeed())
+				Ω(ioutil.WriteFile(definitionsPath, restored, 0644)).Should(Succeed())
+			})
+
+			It("then it should import the definitions", func() {
+				Ω(rabbitMQ.Restore()).Should(Succeed())
+				Ω(api.Definitions).Should(Equal(restored))
+				Ω(api.Requests()).Should(Equal([]string{"POST /api/definitions"}))
+			})
+
+			Context("when there is no definitions file", func() {
+				BeforeEach(func() {
+					os.Remove(definitionsPath)
+				})
+
+				It("then it should fail without calling the api", func() {
+					Ω(rabbitMQ.Restore()).ShouldNot(Succeed())
+					Ω(api.Requests()).Should(BeEmpty())
+				})
+			})
+		})
+	})
+})
@@ -0,0 +1,34 @@
+package rabbitmq
+
+import (
+	"net/http"
+
+	"github.com/pivotalservices/cfbackup"
+)
+
+type (
+	//RabbitMQ - the rabbitmq service tile. it is backed up with the broker
+	//definitions exported through the management api of the cluster, and
+	//optionally a snapshot of the mnesia database of every node
+	RabbitMQ struct {
+		cfbackup.BackupContext
+		DeploymentName string
+		//Servers - the ips of the rabbitmq nodes, any of them serves the
+		//management api for the whole cluster
+		Servers        []string
+		AdminUser      string
+		AdminPass      string
+		ManagementPort int
+		VcapUser       string
+		VcapPass       string
+		SSHPrivateKey  string
+		//MnesiaSnapshot - also take a tarball of the mnesia database of every
+		//node over ssh, with the rabbit app of the node stopped
+		MnesiaSnapshot bool
+		client         *http.Client
+	}
+
+	//RabbitMQBuilder - builds the rabbitmq tile from the installation settings
+	//of ops manager
+	RabbitMQBuilder struct{}
+)
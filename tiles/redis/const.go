@@ -0,0 +1,44 @@
+package redis
+
+import "errors"
+
+//Redis constants
+const (
+	//RedisProductID - the product identifier of the redis service tile
+	RedisProductID = "p-redis"
+	//RedisTileName - the name the redis tile is registered and run its hooks
+	//under
+	RedisTileName = "p-redis"
+	//RedisSharedVMJob - the job of the shared vm, running the broker and a
+	//redis process for every shared-vm service instance
+	RedisSharedVMJob = "cf-redis-broker"
+	//RedisDedicatedNodeJob - the job of the nodes running a single redis
+	//process for a dedicated-vm service instance
+	RedisDedicatedNodeJob = "dedicated-node"
+	//RedisBGSaveCommand - the broker job property holding the name BGSAVE is
+	//renamed to on every instance
+	RedisBGSaveCommand = "redis_bg_save_command"
+	//RedisConfigCommand - the broker job property holding the name CONFIG is
+	//renamed to on every instance
+	RedisConfigCommand = "redis_config_command"
+	//RedisSharedVMDataDir - the dir holding a dir for every shared-vm
+	//service instance on the shared vm
+	RedisSharedVMDataDir = "/var/vcap/store/cf-redis-broker/redis-data"
+	//RedisDedicatedNodeDir - the dir of the instance on a dedicated node
+	RedisDedicatedNodeDir = "/var/vcap/store/redis"
+	//RedisRemoteArchivePath - where a restored rdb is uploaded to
+	RedisRemoteArchivePath = "/var/vcap/store/cfbackup-restore.rdb"
+	//RedisBackupDir - where the tile is kept in the backup
+	RedisBackupDir = "p-redis"
+	//RedisManifestFileName - the list of instances in the backup
+	RedisManifestFileName = "redis.json"
+	//RedisArchiveFileName - the rdb of an instance, named after the job and
+	//index of its vm and the name of its dir
+	RedisArchiveFileName = "%s-%d-%s.rdb"
+)
+
+var (
+	//ErrRedisInstanceNotFound - the director does not list a vm of the
+	//p-redis product
+	ErrRedisInstanceNotFound = errors.New("vm is not an instance of the p-redis deployment")
+)
@@ -0,0 +1,115 @@
+package fakes
+
+import (
+	"bytes"
+	"fmt"
+	"io"
+	"io/ioutil"
+	"sync"
+
+	"github.com/pivotalservices/cfbackup"
+)
+
+//FakeRedis - the redis instances of the vms kept in memory, recording every
+//dump, import and reload in order
+type FakeRedis struct {
+	//RDBs - the rdb of every instance by "ip:instance dir"
+	RDBs    map[string][]byte
+	ErrFake error
+	//FailOn - the "ip:instance dir" ErrFake is returned for, any when empty
+	FailOn string
+
+	mutex  sync.Mutex
+	events []string
+}
+
+//NewFakeRedis - instances without any rdb
+func NewFakeRedis() *FakeRedis {
+	return &FakeRedis{RDBs: make(map[string][]byte)}
+}
+
+//Instance - the instance of the redis info
+func (s *FakeRedis) Instance(info *cfbackup.RedisInfo) *FakeRedisInstance {
+	return &FakeRedisInstance{redis: s, key: fmt.Sprintf("%s:%s", info.Ip, info.InstanceDir)}
+}
+
+//Events - every "dump|import|reload ip:instance dir" so far
+func (s *FakeRedis) Events() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.events...)
+}
+
+func (s *FakeRedis) record(event, key string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.events = append(s.events, event+" "+key)
+	if s.ErrFake != nil && (s.FailOn == "" || s.FailOn == key) {
+		return s.ErrFake
+	}
+	return nil
+}
+
+//FakeRedisInstance - a single instance of a FakeRedis
+type FakeRedisInstance struct {
+	redis *FakeRedis
+	key   string
+}
+
+//Dump --
+func (s *FakeRedisInstance) Dump(dest io.Writer) (err error) {
+	if err = s.redis.record("dump", s.key); err != nil {
+		return
+	}
+	s.redis.mutex.Lock()
+	rdb := s.redis.RDBs[s.key]
+	s.redis.mutex.Unlock()
+	_, err = io.Copy(dest, bytes.NewReader(rdb))
+	return
+}
+
+//Import --
+func (s *FakeRedisInstance) Import(src io.Reader) (err error) {
+	if err = s.redis.record("import", s.key); err != nil {
+		return
+	}
+	var rdb []byte
+	if rdb, err = ioutil.ReadAll(src); err == nil {
+		s.redis.mutex.Lock()
+		s.redis.RDBs[s.key] = rdb
+		s.redis.mutex.Unlock()
+	}
+	return
+}
+
+//Reload --
+func (s *FakeRedisInstance) Reload() error {
+	return s.redis.record("reload", s.key)
+}
+
+//FakeExecuter - answers every command with the output
+type FakeExecuter struct {
+	mutex    sync.Mutex
+	commands []string
+	Output   string
+	ErrFake  error
+}
+
+//Execute --
+func (s *FakeExecuter) Execute(dest io.Writer, cmd string) error {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.commands = append(s.commands, cmd)
+	if s.ErrFake != nil {
+		return s.ErrFake
+	}
+	_, err := io.WriteString(dest, s.Output)
+	return err
+}
+
+//Commands - every command run so far
+func (s *FakeExecuter) Commands() []string {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	return append([]string{}, s.commands...)
+}
@@ -0,0 +1,361 @@
+package redis
+
+import (
+	"bytes"
+	"encoding/json"
+	"fmt"
+	"io"
+	"path"
+	"strings"
+
+	"github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/gtils/command"
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//NewRemoteExecuter - lists the instances of the shared vm
+var NewRemoteExecuter = command.NewRemoteExecutor
+
+//NewRedisDump - dumps, imports and reloads an instance
+var NewRedisDump = func(info *cfbackup.RedisInfo) (RedisDump, error) {
+	return info.GetRedisBackup()
+}
+
+//NewRedis - the redis tile of the installation, with the vms of the shared
+//vm and dedicated node jobs of the p-redis product and the credentials of the
+//director
+func NewRedis(installationInfo cfbackup.InstallationInfo, boshName string, backupContext cfbackup.BackupContext, sshKey string) (context *Redis, err error) {
+	var (
+		product       cfbackup.Products
+		bgSave        map[string]string
+		config        map[string]string
+		ips           []string
+		vmCredentials cfbackup.VMCredentials
+	)
+	if product, err = installationInfo.FindByProductID(RedisProductID); err != nil {
+		return
+	}
+	context = &Redis{
+		BackupContext:  backupContext,
+		DeploymentName: product.InstallationName,
+		SSHPrivateKey:  sshKey,
+	}
+
+	for _, job := range []string{RedisSharedVMJob, RedisDedicatedNodeJob} {
+		if ips, err = installationInfo.FindIPsByProductAndJob(RedisProductID, job); err != nil {
+			return nil, errwrap.Wrap(err, fmt.Sprintf("failed finding the %s vms", job))
+		}
+		if len(ips) == 0 {
+			continue
+		}
+		if vmCredentials, err = installationInfo.FindVMCredentialsByProductAndJob(RedisProductID, job); err != nil {
+			return nil, errwrap.Wrap(err, fmt.Sprintf("failed finding the %s vm credentials", job))
+		}
+		for _, ip := range ips {
+			context.VMs = append(context.VMs, RedisVM{Job: job, IP: ip, VcapUser: vmCredentials.UserID, VcapPass: vmCredentials.Password})
+		}
+	}
+
+	if bgSave, err = installationInfo.FindPropertyValues(RedisProductID, RedisSharedVMJob, RedisBGSaveCommand); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the redis bgsave command")
+	}
+	if config, err = installationInfo.FindPropertyValues(RedisProductID, RedisSharedVMJob, RedisConfigCommand); err != nil {
+		return nil, errwrap.Wrap(err, "failed finding the redis config command")
+	}
+	context.BGSaveCommand, context.ConfigCommand = bgSave["secret"], config["secret"]
+
+	context.Director, err = cfbackup.FindDirectorInfo(installationInfo, boshName)
+	return
+}
+
+//Backup - writes an rdb of every instance on every vm, and the list of them
+//the restore works from
+func (context *Redis) Backup() (err error) {
+	var (
+		instances []cfbackup.BoshInstance
+		artifacts []RedisArtifact
+	)
+	if _, instances, err = context.boshInstances(); err != nil {
+		return
+	}
+
+	for _, vm := range context.VMs {
+		var (
+			instance     cfbackup.BoshInstance
+			instanceDirs []string
+		)
+		if instance, err = findInstance(instances, vm); err != nil {
+			return
+		}
+		if instanceDirs, err = context.instanceDirs(vm); err != nil {
+			return
+		}
+		for _, instanceDir := range instanceDirs {
+			artifact := RedisArtifact{
+				Job:         instance.Job,
+				Index:       instance.Index,
+				InstanceDir: instanceDir,
+				File:        fmt.Sprintf(RedisArchiveFileName, instance.Job, instance.Index, path.Base(instanceDir)),
+			}
+			if err = context.dump(vm, artifact); err != nil {
+				return
+			}
+			artifacts = append(artifacts, artifact)
+		}
+	}
+	return context.writeManifest(artifacts)
+}
+
+//Restore - puts the rdb of every instance in the backup back on the bosh
+//instance with the same job and index
+func (context *Redis) Restore() (err error) {
+	var (
+		artifacts []RedisArtifact
+		instances []cfbackup.BoshInstance
+		director  cfbackup.Bosh
+	)
+	if artifacts, err = context.readManifest(); err != nil {
+		return
+	}
+	if director, instances, err = context.boshInstances(); err != nil {
+		return
+	}
+
+	for _, group := range groupByInstance(artifacts) {
+		var (
+			instance cfbackup.BoshInstance
+			vm       RedisVM
+		)
+		if instance, vm, err = context.findVM(instances, group[0]); err != nil {
+			return
+		}
+		if err = context.restoreInstance(director, instance, vm, group); err != nil {
+			return
+		}
+	}
+	return
+}
+
+//restoreInstance - stops the vm, puts the rdbs in place and starts it again.
+//the vm is started again even when putting the rdbs in place failed
+func (context *Redis) restoreInstance(director cfbackup.Bosh, instance cfbackup.BoshInstance, vm RedisVM, artifacts []RedisArtifact) (err error) {
+	lo.G.Infof("stopping redis vm %s/%d", instance.Job, instance.Index)
+	if err = cfbackup.ChangeJobStateAndReport(director, context.BackupContext, context.DeploymentName, instance.Job, "stopped", instance.Index); err != nil {
+		return errwrap.Wrap(err, "failed stopping the redis vm")
+	}
+
+	importErr := context.importAll(vm, artifacts)
+
+	lo.G.Infof("starting redis vm %s/%d", instance.Job, instance.Index)
+	if err = cfbackup.ChangeJobStateAndReport(director, context.BackupContext, context.DeploymentName, instance.Job, "started", instance.Index); err != nil {
+		if importErr != nil {
+			return errwrap.Wrapf(importErr, "starting the redis vm again also failed: %v", err)
+		}
+		return errwrap.Wrap(err, "failed starting the redis vm")
+	}
+	if importErr != nil {
+		return importErr
+	}
+
+	for _, artifact := range artifacts {
+		var dump RedisDump
+		if dump, err = NewRedisDump(context.redisInfo(vm, artifact.InstanceDir)); err != nil {
+			return
+		}
+		if err = dump.Reload(); err != nil {
+			return errwrap.Wrap(err, fmt.Sprintf("failed reloading redis instance %s", artifact.InstanceDir))
+		}
+	}
+	return
+}
+
+func (context *Redis) importAll(vm RedisVM, artifacts []RedisArtifact) (err error) {
+	for _, artifact := range artifacts {
+		if err = context.restore(vm, artifact); err != nil {
+			return
+		}
+	}
+	return
+}
+
+func (context *Redis) dump(vm RedisVM, artifact RedisArtifact) (err error) {
+	var (
+		dump   RedisDump
+		writer io.WriteCloser
+	)
+	if dump, err = NewRedisDump(context.redisInfo(vm, artifact.InstanceDir)); err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("failed connecting to redis vm %s", vm.IP))
+	}
+	if writer, err = context.Writer(context.artifactPath(artifact)); err != nil {
+		return
+	}
+	defer writer.Close()
+
+	lo.G.Infof("saving redis instance %s on %s", artifact.InstanceDir, vm.IP)
+	progressWriter := context.NewProgressWriter(writer, context.artifactPath(artifact), 0)
+	if err = dump.Dump(progressWriter); err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("failed saving redis instance %s", artifact.InstanceDir))
+	}
+	progressWriter.Done()
+	return
+}
+
+func (context *Redis) restore(vm RedisVM, artifact RedisArtifact) (err error) {
+	var (
+		dump   RedisDump
+		reader io.ReadCloser
+	)
+	if dump, err = NewRedisDump(context.redisInfo(vm, artifact.InstanceDir)); err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("failed connecting to redis vm %s", vm.IP))
+	}
+	if reader, err = context.Reader(context.artifactPath(artifact)); err != nil {
+		return
+	}
+	defer reader.Close()
+
+	lo.G.Infof("restoring redis instance %s on %s", artifact.InstanceDir, vm.IP)
+	progressReader := context.NewProgressReader(reader, context.artifactPath(artifact), context.ArtifactSize(context.artifactPath(artifact)))
+	if err = dump.Import(progressReader); err != nil {
+		return errwrap.Wrap(err, fmt.Sprintf("failed restoring redis instance %s", artifact.InstanceDir))
+	}
+	progressReader.Done()
+	return
+}
+
+func (context *Redis) redisInfo(vm RedisVM, instanceDir string) *cfbackup.RedisInfo {
+	return &cfbackup.RedisInfo{
+		SystemInfo: cfbackup.SystemInfo{
+			Product:           RedisProductID,
+			Component:         vm.Job,
+			Identifier:        path.Base(instanceDir),
+			Ip:                vm.IP,
+			User:              vm.VcapUser,
+			Pass:              vm.VcapPass,
+			VcapUser:          vm.VcapUser,
+			VcapPass:          vm.VcapPass,
+			SSHPrivateKey:     context.SSHPrivateKey,
+			RemoteArchivePath: RedisRemoteArchivePath,
+			SSHTunnels:        context.SSHTunnels,
+		},
+		InstanceDir:   instanceDir,
+		BGSaveCommand: context.BGSaveCommand,
+		ConfigCommand: context.ConfigCommand,
+	}
+}
+
+func (context *Redis) artifactPath(artifact RedisArtifact) string {
+	return path.Join(context.TargetDir, RedisBackupDir, artifact.File)
+}
+
+//instanceDirs - a dedicated node runs a single instance, the shared vm one in
+//every dir of its data dir
+func (context *Redis) instanceDirs(vm RedisVM) (instanceDirs []string, err error) {
+	if vm.Job == RedisDedicatedNodeJob {
+		return []string{RedisDedicatedNodeDir}, nil
+	}
+
+	var (
+		config   command.SshConfig
+		executer command.Executer
+		listing  bytes.Buffer
+	)
+	if config, err = context.SSHTunnels.TunnelSSHConfig(command.SshConfig{
+		Username: vm.VcapUser,
+		Password: vm.VcapPass,
+		Host:     vm.IP,
+		Port:     22,
+		SSLKey:   context.SSHPrivateKey,
+	}); err != nil {
+		return
+	}
+	if executer, err = NewRemoteExecuter(config); err != nil {
+		return nil, errwrap.Wrap(err, fmt.Sprintf("failed connecting to redis vm %s", vm.IP))
+	}
+	if err = executer.Execute(&listing, fmt.Sprintf("ls -1 %s 2>/dev/null; true", RedisSharedVMDataDir)); err != nil {
+		return nil, errwrap.Wrap(err, fmt.Sprintf("failed listing the instances of redis vm %s", vm.IP))
+	}
+	for _, name := range strings.Fields(listing.String()) {
+		instanceDirs = append(instanceDirs, path.Join(RedisSharedVMDataDir, name))
+	}
+	lo.G.Infof("found %d shared-vm instances on %s", len(instanceDirs), vm.IP)
+	return
+}
+
+//boshInstances - the director and the instances of the deployment it lists
+func (context *Redis) boshInstances() (director cfbackup.Bosh, instances []cfbackup.BoshInstance, err error) {
+	if director, err = cfbackup.NewDirector(context.Director.Ip, context.Director.User, context.Director.Pass, 25555, context.DirectorTLS); err != nil {
+		return nil, nil, errwrap.Wrap(err, "failed creating new director")
+	}
+	if instances, err = director.GetInstances(context.DeploymentName); err != nil {
+		return nil, nil, errwrap.Wrap(err, "failed listing the p-redis instances")
+	}
+	return
+}
+
+//findInstance - the bosh instance of the vm, the jobs are named after the
+//installation jobs with a partition suffix
+func findInstance(instances []cfbackup.BoshInstance, vm RedisVM) (cfbackup.BoshInstance, error) {
+	for _, instance := range instances {
+		if (instance.Job == vm.Job || strings.HasPrefix(instance.Job, vm.Job+"-")) && instance.HasIP(vm.IP) {
+			return instance, nil
+		}
+	}
+	return cfbackup.BoshInstance{}, errwrap.Wrap(ErrRedisInstanceNotFound, vm.IP)
+}
+
+//findVM - the bosh instance with the job and index of the artifact and the vm
+//of the installation running it
+func (context *Redis) findVM(instances []cfbackup.BoshInstance, artifact RedisArtifact) (cfbackup.BoshInstance, RedisVM, error) {
+	for _, instance := range instances {
+		if instance.Job != artifact.Job || instance.Index != artifact.Index {
+			continue
+		}
+		for _, vm := range context.VMs {
+			if instance.HasIP(vm.IP) {
+				return instance, vm, nil
+			}
+		}
+	}
+	return cfbackup.BoshInstance{}, RedisVM{}, errwrap.Wrap(ErrRedisInstanceNotFound, fmt.Sprintf("%s/%d", artifact.Job, artifact.Index))
+}
+
+//groupByInstance - the artifacts of every bosh instance, in the order of the
+//backup
+func groupByInstance(artifacts []RedisArtifact) (groups [][]RedisArtifact) {
+	index := make(map[string]int)
+	for _, artifact := range artifacts {
+		key := fmt.Sprintf("%s/%d", artifact.Job, artifact.Index)
+		if i, ok := index[key]; ok {
+			groups[i] = append(groups[i], artifact)
+			continue
+		}
+		index[key] = len(groups)
+		groups = append(groups, []RedisArtifact{artifact})
+	}
+	return
+}
+
+func (context *Redis) writeManifest(artifacts []RedisArtifact) (err error) {
+	var writer io.WriteCloser
+	if writer, err = context.Writer(path.Join(context.TargetDir, RedisBackupDir, RedisManifestFileName)); err != nil {
+		return
+	}
+	defer writer.Close()
+	if err = json.NewEncoder(writer).Encode(artifacts); err != nil {
+		return errwrap.Wrap(err, "failed writing the redis instances")
+	}
+	return
+}
+
+func (context *Redis) readManifest() (artifacts []RedisArtifact, err error) {
+	var reader io.ReadCloser
+	if reader, err = context.Reader(path.Join(context.TargetDir, RedisBackupDir, RedisManifestFileName)); err != nil {
+		return
+	}
+	defer reader.Close()
+	if err = json.NewDecoder(reader).Decode(&artifacts); err != nil {
+		return nil, errwrap.Wrap(err, "unable to unmarshal the redis instances")
+	}
+	return
+}
@@ -0,0 +1,26 @@
+package redis
+
+import (
+	"github.com/pivotalservices/cfbackup/tileregistry"
+	"github.com/pivotalservices/cfbackup/tiles/opsmanager"
+)
+
+//New -- builds the redis tile from the installation settings of ops manager
+func (s *RedisBuilder) New(tileSpec tileregistry.TileSpec) (tileregistry.TileCloser, error) {
+	return tileSpec.Build(RedisTileName, s.build)
+}
+
+func (s *RedisBuilder) build(tileSpec tileregistry.TileSpec) (redisCloser tileregistry.TileCloser, err error) {
+	var (
+		installation *opsmanager.TileInstallation
+		redis        *Redis
+	)
+	if installation, err = opsmanager.NewTileInstallation(tileSpec); err != nil {
+		return
+	}
+	if redis, err = NewRedis(installation.InstallationInfo, installation.Settings.GetBoshName(), installation.BackupContext, installation.SSHKey); err != nil {
+		return
+	}
+	redisCloser = installation.TileCloser(redis)
+	return
+}
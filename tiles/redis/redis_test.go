@@ -0,0 +1,267 @@
+package redis_test
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io/ioutil"
+	"os"
+	"path"
+	"time"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/pivotalservices/cfbackup"
+	cffakes "github.com/pivotalservices/cfbackup/fakes"
+	. "github.com/pivotalservices/cfbackup/tiles/redis"
+	"github.com/pivotalservices/cfbackup/tiles/redis/fakes"
+	"github.com/pivotalservices/gtils/command"
+)
+
+var _ = Describe("Redis", func() {
+	var (
+		tmpDir         string
+		backupContext  cfbackup.BackupContext
+		redis          *Redis
+		deploymentName = "p-redis-74cd520809cfba32a992"
+
+		originalNewDirector       = cfbackup.NewDirector
+		originalNewRedisDump      = NewRedisDump
+		originalNewRemoteExecuter = NewRemoteExecuter
+		originalTaskPingFreq      = cfbackup.TaskPingFreq
+	)
+
+	BeforeEach(func() {
+		var err error
+		tmpDir, err = ioutil.TempDir("", "cfbackup-redis")
+		Ω(err).ShouldNot(HaveOccurred())
+		backupContext = cfbackup.NewBackupContext(tmpDir, map[string]string{}, "")
+
+		config := cfbackup.NewConfigurationParser("../../fixtures/installation-settings-1-4-variant.json")
+		redis, err = NewRedis(&config.InstallationSettings, config.InstallationSettings.GetBoshName(), backupContext, "ssh-key")
+		Ω(err).ShouldNot(HaveOccurred())
+	})
+
+	AfterEach(func() {
+		os.RemoveAll(tmpDir)
+	})
+
+	Describe("NewRedis", func() {
+		It("then it should find the deployment of the p-redis product", func() {
+			Ω(redis.DeploymentName).Should(Equal(deploymentName))
+		})
+
+		It("then it should find the shared vm and the dedicated nodes", func() {
+			Ω(redis.VMs).Should(HaveLen(6))
+			Ω(redis.VMs[0]).Should(Equal(RedisVM{Job: RedisSharedVMJob, IP: "192.168.8.61", VcapUser: "vcap", VcapPass: "0a778c27a3fc2d9b"}))
+			for _, vm := range redis.VMs[1:] {
+				Ω(vm.Job).Should(Equal(RedisDedicatedNodeJob))
+				Ω(vm.VcapPass).Should(Equal("1604122a38636b9f"))
+			}
+		})
+
+		It("then it should resolve the renamed commands", func() {
+			Ω(redis.BGSaveCommand).Should(Equal("a1c0044735ee8c161f9d"))
+			Ω(redis.ConfigCommand).Should(Equal("a750779e3b85ab870fab"))
+		})
+
+		It("then it should resolve the director", func() {
+			Ω(redis.Director.User).Should(Equal("director"))
+		})
+
+		Context("when the installation has no p-redis product", func() {
+			It("then it should fail", func() {
+				config := cfbackup.NewConfigurationParser("../../fixtures/installation-settings-1-6.json")
+				_, err := NewRedis(&config.InstallationSettings, config.InstallationSettings.GetBoshName(), backupContext, "")
+				Ω(err).Should(HaveOccurred())
+			})
+		})
+	})
+
+	Describe("against fake instances and a fake director", func() {
+		var (
+			server    *cffakes.FakeDirectorServer
+			instances *fakes.FakeRedis
+			executer  *fakes.FakeExecuter
+			sharedDir = path.Join(RedisSharedVMDataDir, "guid-1")
+			sharedKey = "192.168.8.61:" + sharedDir
+			nodeKey   = "192.168.8.62:" + RedisDedicatedNodeDir
+		)
+
+		BeforeEach(func() {
+			redis.VMs = redis.VMs[:2]
+
+			instances = fakes.NewFakeRedis()
+			instances.RDBs[sharedKey] = []byte("shared rdb 1")
+			instances.RDBs["192.168.8.61:"+path.Join(RedisSharedVMDataDir, "guid-2")] = []byte("shared rdb 2")
+			instances.RDBs[nodeKey] = []byte("dedicated rdb")
+			NewRedisDump = func(info *cfbackup.RedisInfo) (RedisDump, error) {
+				return instances.Instance(info), nil
+			}
+			executer = &fakes.FakeExecuter{Output: "guid-1\nguid-2\n"}
+			NewRemoteExecuter = func(config command.SshConfig) (command.Executer, error) {
+				return executer, nil
+			}
+
+			cfbackup.TaskPingFreq = time.Millisecond
+			server = cffakes.NewFakeDirectorServer(redis.Director.User, redis.Director.Pass)
+			server.AddDeployment(deploymentName, "manifest", []cfbackup.VMObject{
+				cfbackup.VMObject{Job: "cf-redis-broker-partition-abc", Index: 0, IPs: []string{"192.168.8.61"}, State: cfbackup.BOSHJobStateRunning},
+				cfbackup.VMObject{Job: "dedicated-node-partition-abc", Index: 0, IPs: []string{"192.168.8.63"}, State: cfbackup.BOSHJobStateRunning},
+				cfbackup.VMObject{Job: "dedicated-node-partition-abc", Index: 1, IPs: []string{"192.168.8.62"}, State: cfbackup.BOSHJobStateRunning},
+			})
+			cfbackup.NewDirector = server.DirectorCreator(originalNewDirector)
+		})
+
+		AfterEach(func() {
+			server.Close()
+			cfbackup.NewDirector = originalNewDirector
+			cfbackup.TaskPingFreq = originalTaskPingFreq
+			NewRedisDump = originalNewRedisDump
+			NewRemoteExecuter = originalNewRemoteExecuter
+		})
+
+		Describe("Backup", func() {
+			It("then it should write an rdb for every instance", func() {
+				Ω(redis.Backup()).Should(Succeed())
+				for file, rdb := range map[string]string{
+					"cf-redis-broker-partition-abc-0-guid-1.rdb": "shared rdb 1",
+					"cf-redis-broker-partition-abc-0-guid-2.rdb": "shared rdb 2",
+					"dedicated-node-partition-abc-1-redis.rdb":   "dedicated rdb",
+				} {
+					Ω(ioutil.ReadFile(path.Join(tmpDir, RedisBackupDir, file))).Should(Equal([]byte(rdb)))
+				}
+			})
+
+			It("then it should report the bytes of every rdb", func() {
+				var done []cfbackup.ProgressEvent
+				redis.Progress = cfbackup.ProgressFunc(func(event cfbackup.ProgressEvent) {
+					if event.Done {
+						done = append(done, event)
+					}
+				})
+				Ω(redis.Backup()).Should(Succeed())
+				Ω(done).Should(HaveLen(3))
+				Ω(done[2].Artifact).Should(Equal(path.Join(tmpDir, RedisBackupDir, "dedicated-node-partition-abc-1-redis.rdb")))
+				Ω(done[2].Bytes).Should(Equal(int64(len("dedicated rdb"))))
+			})
+
+			It("then it should list the instances of the shared vm only", func() {
+				Ω(redis.Backup()).Should(Succeed())
+				Ω(executer.Commands()).Should(HaveLen(1))
+				Ω(executer.Commands()[0]).Should(ContainSubstring(RedisSharedVMDataDir))
+			})
+
+			It("then it should write the instances the restore works from", func() {
+				Ω(redis.Backup()).Should(Succeed())
+				manifest, err := ioutil.ReadFile(path.Join(tmpDir, RedisBackupDir, RedisManifestFileName))
+				Ω(err).ShouldNot(HaveOccurred())
+				var artifacts []RedisArtifact
+				Ω(json.Unmarshal(manifest, &artifacts)).Should(Succeed())
+				Ω(artifacts).Should(HaveLen(3))
+				Ω(artifacts[2]).Should(Equal(RedisArtifact{Job: "dedicated-node-partition-abc", Index: 1, InstanceDir: RedisDedicatedNodeDir, File: "dedicated-node-partition-abc-1-redis.rdb"}))
+			})
+
+			It("then it should leave the vms running", func() {
+				Ω(redis.Backup()).Should(Succeed())
+				Ω(server.Requests()).ShouldNot(ContainElement(ContainSubstring("PUT")))
+			})
+
+			Context("when the director does not list a vm", func() {
+				BeforeEach(func() {
+					redis.VMs[1].IP = "10.0.0.1"
+				})
+
+				It("then it should fail", func() {
+					err := redis.Backup()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring(ErrRedisInstanceNotFound.Error()))
+				})
+			})
+
+			Context("when saving an instance fails", func() {
+				BeforeEach(func() {
+					instances.ErrFake = errors.New("MISCONF")
+				})
+
+				It("then it should fail", func() {
+					Ω(redis.Backup()).ShouldNot(Succeed())
+				})
+			})
+		})
+
+		Describe("Restore", func() {
+			BeforeEach(func() {
+				Ω(redis.Backup()).Should(Succeed())
+				instances.RDBs = make(map[string][]byte)
+			})
+
+			It("then it should put every rdb back on its instance", func() {
+				Ω(redis.Restore()).Should(Succeed())
+				Ω(instances.RDBs[sharedKey]).Should(Equal([]byte("shared rdb 1")))
+				Ω(instances.RDBs[nodeKey]).Should(Equal([]byte("dedicated rdb")))
+			})
+
+			It("then it should import while the vm is stopped and reload once it runs", func() {
+				Ω(redis.Restore()).Should(Succeed())
+				events := instances.Events()[3:]
+				Ω(events).Should(Equal([]string{
+					"import " + sharedKey,
+					"import 192.168.8.61:" + path.Join(RedisSharedVMDataDir, "guid-2"),
+					"reload " + sharedKey,
+					"reload 192.168.8.61:" + path.Join(RedisSharedVMDataDir, "guid-2"),
+					"import " + nodeKey,
+					"reload " + nodeKey,
+				}))
+
+				var changes []string
+				for _, request := range server.Requests() {
+					if len(request) > 3 && request[:3] == "PUT" {
+						changes = append(changes, request)
+					}
+				}
+				Ω(changes).Should(HaveLen(4))
+				Ω(changes[0]).Should(HavePrefix(fmt.Sprintf("PUT /deployments/%s/jobs/cf-redis-broker-partition-abc/0", deploymentName)))
+				Ω(changes[2]).Should(HavePrefix(fmt.Sprintf("PUT /deployments/%s/jobs/dedicated-node-partition-abc/1", deploymentName)))
+				Ω(server.JobState(deploymentName, "cf-redis-broker-partition-abc", 0)).Should(Equal(cfbackup.BOSHJobStateRunning))
+				Ω(server.JobState(deploymentName, "dedicated-node-partition-abc", 1)).Should(Equal(cfbackup.BOSHJobStateRunning))
+			})
+
+			Context("when importing an rdb fails", func() {
+				BeforeEach(func() {
+					instances.ErrFake = errors.New("disk full")
+					instances.FailOn = nodeKey
+				})
+
+				It("then it should fail and start the vm again", func() {
+					err := redis.Restore()
+					Ω(err).Should(HaveOccurred())
+					Ω(err.Error()).Should(ContainSubstring("disk full"))
+					Ω(server.JobState(deploymentName, "dedicated-node-partition-abc", 1)).Should(Equal(cfbackup.BOSHJobStateRunning))
+					Ω(instances.Events()).ShouldNot(ContainElement("reload " + nodeKey))
+				})
+			})
+
+			Context("when the instance of an rdb is gone", func() {
+				BeforeEach(func() {
+					redis.VMs = redis.VMs[:1]
+				})
+
+				It("then it should fail", func() {
+					Ω(redis.Restore()).ShouldNot(Succeed())
+				})
+			})
+
+			Context("when a vm can not be stopped", func() {
+				BeforeEach(func() {
+					server.FailJobState("cf-redis-broker-partition-abc", 0, cfbackup.BOSHJobStateStopped, "timed out")
+				})
+
+				It("then it should fail without importing", func() {
+					Ω(redis.Restore()).ShouldNot(Succeed())
+					Ω(instances.RDBs).Should(BeEmpty())
+				})
+			})
+		})
+	})
+})
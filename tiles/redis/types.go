@@ -0,0 +1,50 @@
+package redis
+
+import (
+	"github.com/pivotalservices/cfbackup"
+)
+
+type (
+	//Redis - the redis service tile. every service instance, on the shared vm
+	//or a dedicated node, is backed up with an rdb of a background save and
+	//restored by putting the rdb back while its vm is stopped
+	Redis struct {
+		cfbackup.BackupContext
+		DeploymentName string
+		//VMs - the shared vm and the dedicated nodes
+		VMs           []RedisVM
+		BGSaveCommand string
+		ConfigCommand string
+		SSHPrivateKey string
+		//Director - the director the vms are stopped and started through
+		Director *cfbackup.SystemInfo
+	}
+
+	//RedisVM - a vm of the product and the job it runs in the installation
+	RedisVM struct {
+		Job      string
+		IP       string
+		VcapUser string
+		VcapPass string
+	}
+
+	//RedisArtifact - the rdb of an instance in the backup, restored on the
+	//bosh instance with the same job and index
+	RedisArtifact struct {
+		Job         string `json:"job"`
+		Index       int    `json:"index"`
+		InstanceDir string `json:"instance_dir"`
+		File        string `json:"file"`
+	}
+
+	//RedisDump - dumps and imports an instance, and reloads it once its vm
+	//is started again
+	RedisDump interface {
+		cfbackup.PersistanceBackup
+		Reload() error
+	}
+
+	//RedisBuilder - builds the redis tile from the installation settings of
+	//ops manager
+	RedisBuilder struct{}
+)
@@ -0,0 +1,132 @@
+package cfbackup
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"fmt"
+	"io/ioutil"
+	"net"
+	"net/http"
+	"net/url"
+	"strings"
+
+	errwrap "github.com/pkg/errors"
+)
+
+//Trust - a copy of the config which also trusts the given pem encoded
+//certificates, like the director certificate from the installation settings.
+//once a certificate is trusted the certificates are verified
+func (s TLSConfig) Trust(certificates ...string) TLSConfig {
+	s.trusted = append(append([]string{}, s.trusted...), certificates...)
+	return s
+}
+
+//Verify - whether certificates are verified at all
+func (s TLSConfig) Verify() bool {
+	return s.Strict || s.CABundle != "" || len(s.trusted) > 0
+}
+
+//ClientConfig - the tls config of a client following the TLSConfig
+func (s TLSConfig) ClientConfig() (config *tls.Config, err error) {
+	config = &tls.Config{InsecureSkipVerify: !s.Verify()}
+	if !config.InsecureSkipVerify {
+		config.RootCAs, err = s.rootCAs()
+	}
+	return
+}
+
+//HTTPClient - an http client following the TLSConfig
+func (s TLSConfig) HTTPClient() (*http.Client, error) {
+	transport, err := s.Transport()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Client{Transport: transport}, nil
+}
+
+//Transport - an http transport following the TLSConfig
+func (s TLSConfig) Transport() (*http.Transport, error) {
+	proxy, err := s.proxy()
+	if err != nil {
+		return nil, err
+	}
+	config, err := s.ClientConfig()
+	if err != nil {
+		return nil, err
+	}
+	return &http.Transport{
+		Proxy:           proxy,
+		TLSClientConfig: config,
+	}, nil
+}
+
+func (s TLSConfig) proxy() (func(*http.Request) (*url.URL, error), error) {
+	if s.Proxy == "" {
+		return http.ProxyFromEnvironment, nil
+	}
+	proxyURL, err := url.Parse(s.Proxy)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed parsing the proxy url")
+	}
+	switch proxyURL.Scheme {
+	case "http", "https", "socks5":
+	default:
+		return nil, fmt.Errorf("unsupported proxy scheme %q, use http, https or socks5", proxyURL.Scheme)
+	}
+	return func(req *http.Request) (*url.URL, error) {
+		if bypassProxy(req.URL.Host) {
+			return nil, nil
+		}
+		return proxyURL, nil
+	}, nil
+}
+
+//bypassProxy - whether the host is reached without the proxy. the loopback
+//addresses, which the ssh tunnels listen on, are, as ProxyFromEnvironment
+//does
+func bypassProxy(address string) bool {
+	host := address
+	if h, _, err := net.SplitHostPort(address); err == nil {
+		host = h
+	}
+	if host == "localhost" {
+		return true
+	}
+	ip := net.ParseIP(host)
+	return ip != nil && ip.IsLoopback()
+}
+
+func (s TLSConfig) rootCAs() (*x509.CertPool, error) {
+	pool, err := x509.SystemCertPool()
+	if err != nil || pool == nil {
+		pool = x509.NewCertPool()
+	}
+
+	certificates := s.trusted
+	if s.CABundle != "" {
+		var bundle string
+		if bundle, err = s.readCABundle(); err != nil {
+			return nil, err
+		}
+		certificates = append([]string{bundle}, certificates...)
+	}
+
+	for _, certificate := range certificates {
+		if !pool.AppendCertsFromPEM([]byte(certificate)) {
+			return nil, fmt.Errorf("no pem encoded certificates found in: %.40q", certificate)
+		}
+	}
+	return pool, nil
+}
+
+//readCABundle - the bundle is either pem itself or the path of a pem file
+func (s TLSConfig) readCABundle() (string, error) {
+	if strings.Contains(s.CABundle, "-----BEGIN") {
+		return s.CABundle, nil
+	}
+	bundle, err := ioutil.ReadFile(s.CABundle)
+	if err != nil {
+		return "", errwrap.Wrap(err, "failed reading the ca bundle")
+	}
+	return string(bundle), nil
+}
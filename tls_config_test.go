@@ -0,0 +1,130 @@
+package cfbackup_test
+
+import (
+	"io/ioutil"
+	"net/http"
+	"net/http/httptest"
+	"os"
+	"path"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	. "github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/fakes"
+)
+
+var _ = Describe("TLSConfig", func() {
+	Context("when connecting to a director with a self signed certificate", func() {
+		var (
+			server *fakes.FakeDirectorServer
+			ip     string
+			port   int
+		)
+
+		BeforeEach(func() {
+			server = fakes.NewFakeDirectorServer("director", "director-pass")
+			ip, port = server.Address()
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("then it should accept the certificate without verification", func() {
+			_, err := NewDirector(ip, "director", "director-pass", port, TLSConfig{})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("then it should reject the certificate with strict verification", func() {
+			_, err := NewDirector(ip, "director", "director-pass", port, TLSConfig{Strict: true})
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("then it should accept the certificate once it is trusted", func() {
+			_, err := NewDirector(ip, "director", "director-pass", port, TLSConfig{Strict: true}.Trust(server.CertificatePEM()))
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("then it should verify once the director certificate is trusted", func() {
+			_, err := NewDirector(ip, "director", "director-pass", port, TLSConfig{}.Trust(server.CertificatePEM()))
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("then it should verify against an inline ca bundle", func() {
+			_, err := NewDirector(ip, "director", "director-pass", port, TLSConfig{CABundle: server.CertificatePEM()})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+
+		It("then it should verify against a ca bundle file", func() {
+			tmpDir, _ := ioutil.TempDir("", "ca")
+			defer os.RemoveAll(tmpDir)
+			bundle := path.Join(tmpDir, "ca.pem")
+			ioutil.WriteFile(bundle, []byte(server.CertificatePEM()), 0600)
+
+			_, err := NewDirector(ip, "director", "director-pass", port, TLSConfig{CABundle: bundle})
+			Ω(err).ShouldNot(HaveOccurred())
+		})
+	})
+
+	Context("when the ca bundle is unusable", func() {
+		It("then it should fail for a bundle without certificates", func() {
+			_, err := TLSConfig{CABundle: "-----BEGIN CERTIFICATE-----\nnot a certificate"}.HTTPClient()
+			Ω(err).Should(HaveOccurred())
+		})
+
+		It("then it should fail for a missing bundle file", func() {
+			_, err := TLSConfig{CABundle: "/does/not/exist/ca.pem"}.HTTPClient()
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+
+	Context("when a proxy is given", func() {
+		var (
+			proxy   *httptest.Server
+			proxied string
+		)
+
+		BeforeEach(func() {
+			proxied = ""
+			proxy = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				proxied = r.URL.String()
+				w.Write([]byte("proxied"))
+			}))
+		})
+
+		AfterEach(func() {
+			proxy.Close()
+		})
+
+		It("then it should send requests through the proxy", func() {
+			client, err := TLSConfig{Proxy: proxy.URL}.HTTPClient()
+			Ω(err).ShouldNot(HaveOccurred())
+			res, err := client.Get("http://opsman.example.com/api/v0/installations")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer res.Body.Close()
+			body, _ := ioutil.ReadAll(res.Body)
+			Ω(string(body)).Should(Equal("proxied"))
+			Ω(proxied).Should(Equal("http://opsman.example.com/api/v0/installations"))
+		})
+
+		It("then it should reach the loopback addresses of the ssh tunnels without the proxy", func() {
+			tunnel := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				w.Write([]byte("tunneled"))
+			}))
+			defer tunnel.Close()
+			client, err := TLSConfig{Proxy: proxy.URL}.HTTPClient()
+			Ω(err).ShouldNot(HaveOccurred())
+			res, err := client.Get(tunnel.URL + "/api/v0/cluster")
+			Ω(err).ShouldNot(HaveOccurred())
+			defer res.Body.Close()
+			body, _ := ioutil.ReadAll(res.Body)
+			Ω(string(body)).Should(Equal("tunneled"))
+			Ω(proxied).Should(BeEmpty())
+		})
+
+		It("then it should fail for an unsupported proxy scheme", func() {
+			_, err := TLSConfig{Proxy: "ftp://proxy.example.com"}.HTTPClient()
+			Ω(err).Should(HaveOccurred())
+		})
+	})
+})
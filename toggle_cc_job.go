@@ -1,6 +1,7 @@
 package cfbackup
 
 import (
+	"bytes"
 	"encoding/json"
 	"errors"
 	"fmt"
@@ -9,10 +10,11 @@ import (
 	"net/http"
 	"regexp"
 	"strconv"
+	"sync"
 	"time"
 
-	"github.com/enaml-ops/enaml/enamlbosh"
 	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
 )
 
 // Not ping server so frequently and exausted the resources
@@ -21,6 +23,20 @@ var TaskPingFreq = 1000 * time.Millisecond
 //CloudControllerJobs - array storing a list of CCJobs
 type CloudControllerJobs []CCJob
 
+//ToggleStrategy - defines how the cloud controller jobs are walked when
+//changing their state
+type ToggleStrategy string
+
+const (
+	//ToggleSequential - change the state of one job/index at a time
+	ToggleSequential ToggleStrategy = "sequential"
+	//ToggleParallel - change the state of every job/index at once
+	ToggleParallel ToggleStrategy = "parallel"
+	//ToggleAZBatch - change the state of every job/index in an AZ at once,
+	//one AZ after the other
+	ToggleAZBatch ToggleStrategy = "az"
+)
+
 //CloudController - a struct representing a cloud controller
 type CloudController struct {
 	deploymentName   string
@@ -29,25 +45,35 @@ type CloudController struct {
 	port             int
 	username         string
 	password         string
+	strategy         ToggleStrategy
+	progress         ProgressReporter
+	tls              TLSConfig
 }
 
 type boshDirector struct {
-	client *enamlbosh.Client
+	client *http.Client
+	auth   directorAuth
 	ip     string
 	port   int
 }
 
 //GetInfo -- calls info endpoint on targetted bosh director
 func (s *boshDirector) GetInfo() (io.ReadCloser, error) {
-	endpoint := fmt.Sprintf(ERDirectorInfoURL, s.ip)
+	endpoint := fmt.Sprintf("%s:%d/info", s.ip, s.port)
 	return s.get(endpoint)
 }
 
 //GetCloudControllerVMSet - returns a list of vm objects from your targetted
-//bosh director and given deployment
+//bosh director and given deployment. the full listing (which includes job
+//states) runs as a bosh task, so we wait on it and return its result as a
+//json array
 func (s *boshDirector) GetCloudControllerVMSet(name string) (io.ReadCloser, error) {
-	endpoint := fmt.Sprintf("%s:%d/deployments/%s/vms", s.ip, s.port, name)
-	return s.get(endpoint)
+	output, err := s.runListTask(fmt.Sprintf("%s:%d/deployments/%s/vms?format=full", s.ip, s.port, name))
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed fetching vms task result")
+	}
+	defer output.Close()
+	return vmListFromTaskResult(output)
 }
 
 //GetDeploymentManifest -- returns the deployment manifest for the given
@@ -61,15 +87,11 @@ func (s *boshDirector) GetDeploymentManifest(name string) (io.ReadCloser, error)
 //deployment. this can be used to start or stop a vm
 func (s *boshDirector) ChangeJobState(deployment, job, state string, index int) (int, error) {
 	endpoint := fmt.Sprintf("%s:%d/deployments/%s/jobs/%s/%d?state=%s", s.ip, s.port, deployment, job, index, state)
-	req, err := s.client.NewRequest("PUT", endpoint, nil)
+	res, err := s.do("PUT", endpoint, http.Header{"Content-Type": {"text/yaml"}})
 	if err != nil {
-		return 0, errwrap.Wrap(err, "failed creating request")
-	}
-	req.Header.Set("content-type", "text/yaml")
-	res, err := s.client.HTTPClient().Do(req)
-	if err != nil {
-		return 0, errwrap.Wrap(err, "failed calling http client")
+		return 0, err
 	}
+	res.Body.Close()
 	taskID, err := retrieveTaskID(res)
 	if err != nil {
 		return 0, errwrap.Wrap(err, "failed retrieving taskid from response body")
@@ -94,21 +116,48 @@ func (s *boshDirector) RetrieveTaskStatus(id int) (*Task, error) {
 }
 
 func (s *boshDirector) get(endpoint string) (io.ReadCloser, error) {
-	req, err := s.client.NewRequest("GET", endpoint, nil)
-	if err != nil {
-		return nil, errwrap.Wrap(err, "failed creating request")
-	}
-	req.Header.Set("content-type", "application/json")
-	res, err := s.client.HTTPClient().Do(req)
+	res, err := s.do("GET", endpoint, http.Header{"Content-Type": {"application/json"}})
 	if err != nil {
-		return nil, errwrap.Wrap(err, "failed calling http client")
+		return nil, err
 	}
 	if res.StatusCode != 200 {
+		res.Body.Close()
 		return nil, fmt.Errorf("unsuccessful status code in response: %v ", res.StatusCode)
 	}
 	return res.Body, nil
 }
 
+//do - sends an authorized request to the director. a request rejected with
+//a 401 is sent once more with fresh credentials, as uaa tokens can be
+//revoked or expire before we expected them to
+func (s *boshDirector) do(method, endpoint string, header http.Header) (*http.Response, error) {
+	res, err := s.send(method, endpoint, header)
+	if err == nil && res.StatusCode == http.StatusUnauthorized && s.auth.reset() {
+		res.Body.Close()
+		lo.G.Debug("director rejected our credentials, retrying with a new token")
+		res, err = s.send(method, endpoint, header)
+	}
+	return res, err
+}
+
+func (s *boshDirector) send(method, endpoint string, header http.Header) (*http.Response, error) {
+	req, err := http.NewRequest(method, endpoint, nil)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed creating request")
+	}
+	for key, values := range header {
+		req.Header[key] = values
+	}
+	if err = s.auth.authorize(req); err != nil {
+		return nil, errwrap.Wrap(err, "failed authorizing request")
+	}
+	res, err := s.client.Do(req)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed calling http client")
+	}
+	return res, nil
+}
+
 func retrieveTaskID(resp *http.Response) (taskId int, err error) {
 	if resp.StatusCode != 302 && resp.StatusCode != 200 {
 		return 0, fmt.Errorf("unsuccessfull status code response: %v", resp.StatusCode)
@@ -122,6 +171,26 @@ func retrieveTaskID(resp *http.Response) (taskId int, err error) {
 	return strconv.Atoi(idString)
 }
 
+//vmListFromTaskResult - the task result holds one json object per line, we
+//hand it back as a json array to match the plain vms listing
+func vmListFromTaskResult(result io.Reader) (io.ReadCloser, error) {
+	var vms []VMObject
+	decoder := json.NewDecoder(result)
+
+	for decoder.More() {
+		var vm VMObject
+		if err := decoder.Decode(&vm); err != nil {
+			return nil, errwrap.Wrap(err, "unable to unmarshal vms task result")
+		}
+		vms = append(vms, vm)
+	}
+	b, err := json.Marshal(vms)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "unable to marshal vm list")
+	}
+	return ioutil.NopCloser(bytes.NewReader(b)), nil
+}
+
 func retrieveTaskStatus(data io.ReadCloser) (task *Task, err error) {
 	defer data.Close()
 	task = &Task{}
@@ -140,26 +209,32 @@ func retrieveTaskStatus(data io.ReadCloser) (task *Task, err error) {
 	return task, nil
 }
 
-//NewDirector - a function representing a constructor for a director object
+//NewDirector - a function representing a constructor for a director object,
+//verifying the certificates of the director and its uaa as the tls config asks
 //go:generate counterfeiter -o fakes/fake_director_creator.go . DirectorCreator
-type DirectorCreator func(ip, username, password string, port int) (Bosh, error)
+type DirectorCreator func(ip, username, password string, port int, tlsConfig TLSConfig) (Bosh, error)
 
-var NewDirector DirectorCreator = func(ip, username, password string, port int) (Bosh, error) {
+var NewDirector DirectorCreator = func(ip, username, password string, port int, tlsConfig TLSConfig) (Bosh, error) {
 	// Check if a scheme is present (RFC 3986, section 3.1).
 	// If not, prepend "//" to use the network-path reference format (section 4.2).
 	if !regexp.MustCompile(`^([a-zA-Z][-+.a-zA-Z0-9]+:)?//`).MatchString(ip) {
 		ip = "https://" + ip
 	}
-	return newBoshDirector(ip, username, password, port)
+	return newBoshDirector(ip, username, password, port, tlsConfig)
 }
 
-func newBoshDirector(ip, username, password string, port int) (Bosh, error) {
-	client, err := enamlbosh.NewClient(username, password, ip, port, true)
+func newBoshDirector(ip, username, password string, port int, tlsConfig TLSConfig) (Bosh, error) {
+	client, err := newDirectorHTTPClient(tlsConfig)
 	if err != nil {
-		return nil, errwrap.Wrap(err, "failed creating bosh client")
+		return nil, err
+	}
+	auth, err := newDirectorAuth(client, fmt.Sprintf("%s:%d/info", ip, port), username, password)
+	if err != nil {
+		return nil, errwrap.Wrap(err, "failed detecting director auth")
 	}
 	return &boshDirector{
 		client: client,
+		auth:   auth,
 		ip:     ip,
 		port:   port,
 	}, nil
@@ -176,18 +251,93 @@ func NewCloudController(ip, username, password, deploymentName string, cloudCont
 	}, nil
 }
 
-//Start - a method to execute a start event on a cloud controller
+//SetToggleStrategy - sets the strategy used to stop and start the cloud
+//controller jobs. an empty strategy defaults to ToggleSequential
+func (c *CloudController) SetToggleStrategy(strategy ToggleStrategy) {
+	c.strategy = strategy
+}
+
+//SetDirectorTLS - how the certificates of the director toggling the cloud
+//controller jobs are verified
+func (c *CloudController) SetDirectorTLS(tlsConfig TLSConfig) {
+	c.tls = tlsConfig
+}
+
+//SetProgressReporter - reports the bosh tasks stopping and starting the
+//cloud controller jobs
+func (c *CloudController) SetProgressReporter(progress ProgressReporter) {
+	c.progress = progress
+}
+
+//Start - a method to execute a start event on a cloud controller. only the
+//jobs which were running before they were stopped are started
 func (c *CloudController) Start() error {
 	return c.toggleController("started")
 }
 
-//Stop - a method which executes a stop against a cloud controller
+//Stop - a method which executes a stop against a cloud controller. if
+//stopping a job fails, every job we attempted to stop is started again
 func (c *CloudController) Stop() error {
 	return c.toggleController("stopped")
 }
 
+//GroupByAZ - groups the jobs by availability zone, keeping the order in which
+//each zone is first seen
+func (s CloudControllerJobs) GroupByAZ() []CloudControllerJobs {
+	return s.groupBy(func(ccjob CCJob) string { return ccjob.AZ })
+}
+
+//GroupByJobGroup - groups the jobs by the job pattern they matched, keeping
+//the order in which each group is first seen (the order they are stopped in)
+func (s CloudControllerJobs) GroupByJobGroup() []CloudControllerJobs {
+	return s.groupBy(func(ccjob CCJob) string { return ccjob.Group })
+}
+
+func (s CloudControllerJobs) groupBy(key func(CCJob) string) (groups []CloudControllerJobs) {
+	index := make(map[string]int)
+
+	for _, ccjob := range s {
+		i, ok := index[key(ccjob)]
+		if !ok {
+			i = len(groups)
+			index[key(ccjob)] = i
+			groups = append(groups, CloudControllerJobs{})
+		}
+		groups[i] = append(groups[i], ccjob)
+	}
+	return
+}
+
+//Running - returns the jobs which were not deliberately stopped when their
+//state was captured. jobs without a known state are assumed to be running
+func (s CloudControllerJobs) Running() (running CloudControllerJobs) {
+	for _, ccjob := range s {
+		if ccjob.State != BOSHJobStateStopped {
+			running = append(running, ccjob)
+		}
+	}
+	return
+}
+
+func (c *CloudController) batches(jobs CloudControllerJobs) ([]CloudControllerJobs, error) {
+	switch c.strategy {
+	case "", ToggleSequential:
+		batches := make([]CloudControllerJobs, 0, len(jobs))
+		for _, ccjob := range jobs {
+			batches = append(batches, CloudControllerJobs{ccjob})
+		}
+		return batches, nil
+	case ToggleParallel:
+		return []CloudControllerJobs{jobs}, nil
+	case ToggleAZBatch:
+		return jobs.GroupByAZ(), nil
+	default:
+		return nil, fmt.Errorf("unknown cloud controller toggle strategy: %v", c.strategy)
+	}
+}
+
 func (c *CloudController) toggleController(state string) error {
-	director, err := NewDirector(c.ip, c.username, c.password, 25555)
+	director, err := NewDirector(c.ip, c.username, c.password, 25555, c.tls)
 	if err != nil {
 		return errwrap.Wrap(err, "failed creating new director")
 	}
@@ -196,23 +346,134 @@ func (c *CloudController) toggleController(state string) error {
 		return fmt.Errorf("no cloudcontroller vms found to toggle")
 	}
 
-	for _, ccjob := range c.cloudControllers {
-		taskID, err := director.ChangeJobState(c.deploymentName, ccjob.Job, state, ccjob.Index)
+	jobs := c.cloudControllers
+	if state == "started" {
+		jobs = jobs.Running()
+	}
 
-		if err != nil {
-			return errwrap.Wrap(err, "failed calling ChangeJobState")
+	attempted, err := c.toggleJobs(director, jobs, state)
+	if err != nil && state == "stopped" {
+		err = c.rollback(director, attempted, err)
+	}
+	return err
+}
+
+//toggleJobs - walks the job groups in stop order (or the reverse when
+//starting), toggling each group in batches according to the strategy. it
+//returns every job it attempted to toggle
+func (c *CloudController) toggleJobs(director Bosh, jobs CloudControllerJobs, state string) (attempted CloudControllerJobs, err error) {
+	groups := jobs.GroupByJobGroup()
+	if state == "started" {
+		for i, j := 0, len(groups)-1; i < j; i, j = i+1, j-1 {
+			groups[i], groups[j] = groups[j], groups[i]
 		}
+	}
 
-		err = c.waitUntilDone(taskID, director)
-		if err != nil {
-			return errwrap.Wrap(err, "failed calling waitUntilDone")
+	for _, group := range groups {
+		var batches []CloudControllerJobs
+		if batches, err = c.batches(group); err != nil {
+			return
+		}
+
+		for _, batch := range batches {
+			attempted = append(attempted, batch...)
+
+			if err = c.toggleBatch(director, batch, state); err != nil {
+				return
+			}
 		}
+	}
+	return
+}
+
+//rollback - restarts every previously running job we attempted to stop,
+//including the ones whose stop failed as we cant be sure what state they were
+//left in
+func (c *CloudController) rollback(director Bosh, attempted CloudControllerJobs, stopErr error) error {
+	if len(attempted) == 0 {
+		return stopErr
+	}
+	lo.G.Error("stopping cloud controller failed, restarting already stopped jobs: ", stopErr)
 
+	if _, err := c.toggleJobs(director, attempted.Running(), "started"); err != nil {
+		return errwrap.Wrapf(stopErr, "restarting stopped jobs also failed: %v", err)
+	}
+	return stopErr
+}
+
+//toggleBatch - changes the state of every job in the batch at once and waits
+//on all of the resulting bosh tasks
+func (c *CloudController) toggleBatch(director Bosh, batch CloudControllerJobs, state string) error {
+	var wg sync.WaitGroup
+	errs := make([]error, len(batch))
+
+	for i, ccjob := range batch {
+		wg.Add(1)
+		go func(i int, ccjob CCJob) {
+			defer wg.Done()
+			errs[i] = c.toggleJob(director, ccjob, state)
+		}(i, ccjob)
+	}
+	wg.Wait()
+
+	var firstErr error
+	for i, err := range errs {
+		if err == nil {
+			continue
+		}
+		if firstErr == nil {
+			firstErr = err
+		} else {
+			lo.G.Errorf("changing %s/%d to %s failed: %v", batch[i].Job, batch[i].Index, state, err)
+		}
+	}
+	return firstErr
+}
+
+func (c *CloudController) toggleJob(director Bosh, ccjob CCJob, state string) error {
+	return ChangeJobStateAndReport(director, c.progress, c.deploymentName, ccjob.Job, state, ccjob.Index)
+}
+
+//ChangeJobStateAndWait - changes the state of job/index of the deployment and
+//waits on the resulting bosh task, streaming its events into the log
+func ChangeJobStateAndWait(director Bosh, deployment, job, state string, index int) error {
+	return ChangeJobStateAndReport(director, nil, deployment, job, state, index)
+}
+
+//ChangeJobStateAndReport - ChangeJobStateAndWait, reporting the bosh task to
+//the progress reporter once it is done. a nil reporter reports nothing
+func ChangeJobStateAndReport(director Bosh, progress ProgressReporter, deployment, job, state string, index int) error {
+	started := time.Now()
+	taskID, err := director.ChangeJobState(deployment, job, state, index)
+	if err != nil {
+		return errwrap.Wrap(err, "failed calling ChangeJobState")
+	}
+
+	err = watchTask(taskID, director)
+	if progress != nil {
+		event := ProgressEvent{Type: ProgressBOSHTask, TaskID: taskID, Deployment: deployment, Job: fmt.Sprintf("%s/%d", job, index), State: state, Wait: time.Since(started)}
+		if err != nil {
+			event.Error = err.Error()
+		}
+		progress.Report(event)
+	}
+	if err != nil {
+		return errwrap.Wrap(err, "failed calling waitUntilDone")
 	}
 	return nil
 }
 
-func (c *CloudController) waitUntilDone(taskID int, director Bosh) (err error) {
+func waitUntilDone(taskID int, director Bosh) (err error) {
+	return waitOnTask(taskID, director, nil)
+}
+
+//watchTask - waits on a task while streaming its events into the log, the
+//error of a failed task carries its last events
+func watchTask(taskID int, director Bosh) (err error) {
+	return waitOnTask(taskID, director, &taskEvents{taskID: taskID, director: director})
+}
+
+func waitOnTask(taskID int, director Bosh, events *taskEvents) (err error) {
 	time.Sleep(TaskPingFreq)
 	result, err := director.RetrieveTaskStatus(taskID)
 	if err != nil {
@@ -221,15 +482,21 @@ func (c *CloudController) waitUntilDone(taskID int, director Bosh) (err error) {
 
 	switch Taskresult[result.State] {
 	case BOSHError:
+		if events != nil {
+			return events.failure(result)
+		}
 		err = fmt.Errorf("Task %d process failed", taskID)
 		return
 	case BOSHQueued:
-		err = c.waitUntilDone(taskID, director)
+		events.follow()
+		err = waitOnTask(taskID, director, events)
 		return
 	case BOSHProcessing:
-		err = c.waitUntilDone(taskID, director)
+		events.follow()
+		err = waitOnTask(taskID, director, events)
 		return
 	case BOSHDone:
+		events.follow()
 		return
 	default:
 		return errors.New("unkown task result error")
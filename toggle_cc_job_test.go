@@ -9,6 +9,7 @@ import (
 	"net/url"
 	"os"
 	"strconv"
+	"sync"
 
 	"io"
 	"strings"
@@ -20,6 +21,7 @@ import (
 	"github.com/onsi/gomega/ghttp"
 
 	. "github.com/pivotalservices/cfbackup"
+	"github.com/pivotalservices/cfbackup/fakes"
 )
 
 var (
@@ -43,7 +45,9 @@ var (
 	retrieveTaskStatusCount int  = 0
 )
 
-type mockDirector struct{}
+type mockDirector struct {
+	fakes.FakeBosh
+}
 
 func (s *mockDirector) GetInfo() (io.ReadCloser, error) {
 	return nil, nil
@@ -83,11 +87,13 @@ var _ = Describe("ToggleCcJob", func() {
 	TaskPingFreq = time.Millisecond
 	var cloudController *CloudController
 	var newDirectorCallCount int
+	var newDirectorTLS TLSConfig
 
 	BeforeEach(func() {
 		newDirectorCallCount = 0
-		NewDirector = func(ip, username, password string, port int) (Bosh, error) {
+		NewDirector = func(ip, username, password string, port int, tlsConfig TLSConfig) (Bosh, error) {
 			newDirectorCallCount++
+			newDirectorTLS = tlsConfig
 			return &mockDirector{}, nil
 		}
 		var err error
@@ -134,6 +140,12 @@ var _ = Describe("ToggleCcJob", func() {
 				cloudController.Start()
 				Expect(newDirectorCallCount).To(Equal(3))
 			})
+
+			It("should create the director with the director tls config", func() {
+				cloudController.SetDirectorTLS(TLSConfig{Strict: true})
+				cloudController.Start()
+				Expect(newDirectorTLS).To(Equal(TLSConfig{Strict: true}))
+			})
 		})
 		Context("Task status is error", func() {
 			BeforeEach(func() {
@@ -147,6 +159,329 @@ var _ = Describe("ToggleCcJob", func() {
 		})
 	})
 
+	Describe("Toggle strategies", func() {
+		var (
+			fakeDirector *fakes.FakeBosh
+			azjobs       = CloudControllerJobs{
+				CCJob{Job: "job1", Index: 0, AZ: "z1"},
+				CCJob{Job: "job1", Index: 1, AZ: "z2"},
+				CCJob{Job: "job2", Index: 0, AZ: "z1"},
+			}
+			lock        sync.Mutex
+			inFlight    int
+			maxInFlight int
+		)
+
+		BeforeEach(func() {
+			inFlight = 0
+			maxInFlight = 0
+			fakeDirector = new(fakes.FakeBosh)
+			fakeDirector.ChangeJobStateStub = func(_, _, _ string, _ int) (int, error) {
+				lock.Lock()
+				inFlight++
+				if inFlight > maxInFlight {
+					maxInFlight = inFlight
+				}
+				lock.Unlock()
+				time.Sleep(20 * time.Millisecond)
+				lock.Lock()
+				inFlight--
+				lock.Unlock()
+				return 1, nil
+			}
+			fakeDirector.RetrieveTaskStatusReturns(&Task{State: "done"}, nil)
+			NewDirector = func(ip, username, password string, port int, tlsConfig TLSConfig) (Bosh, error) {
+				return fakeDirector, nil
+			}
+			var err error
+			cloudController, err = NewCloudController(ip, username, password, deploymentName, azjobs)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		Context("when no strategy is set", func() {
+			It("then it should toggle one job at a time", func() {
+				Ω(cloudController.Stop()).Should(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(3))
+				Ω(maxInFlight).Should(Equal(1))
+			})
+		})
+
+		Context("when the parallel strategy is set", func() {
+			BeforeEach(func() {
+				cloudController.SetToggleStrategy(ToggleParallel)
+			})
+			It("then it should toggle every job at once", func() {
+				Ω(cloudController.Stop()).Should(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(3))
+				Ω(maxInFlight).Should(Equal(3))
+			})
+		})
+
+		Context("when the az strategy is set", func() {
+			BeforeEach(func() {
+				cloudController.SetToggleStrategy(ToggleAZBatch)
+			})
+			It("then it should toggle the jobs one az at a time", func() {
+				Ω(cloudController.Stop()).Should(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(3))
+				Ω(maxInFlight).Should(Equal(2))
+				_, _, _, lastIndex := fakeDirector.ChangeJobStateArgsForCall(2)
+				Ω(lastIndex).Should(Equal(1))
+			})
+		})
+
+		Context("when some jobs were stopped before the backup", func() {
+			BeforeEach(func() {
+				var err error
+				cloudController, err = NewCloudController(ip, username, password, deploymentName, CloudControllerJobs{
+					CCJob{Job: "job1", Index: 0, State: "running"},
+					CCJob{Job: "job1", Index: 1, State: "stopped"},
+					CCJob{Job: "job2", Index: 0},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+			It("then it should stop every job", func() {
+				Ω(cloudController.Stop()).Should(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(3))
+			})
+			It("then it should only start the jobs that were not stopped", func() {
+				Ω(cloudController.Start()).Should(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(2))
+				for i := 0; i < fakeDirector.ChangeJobStateCallCount(); i++ {
+					_, job, _, index := fakeDirector.ChangeJobStateArgsForCall(i)
+					Ω(CCJob{Job: job, Index: index}).ShouldNot(Equal(CCJob{Job: "job1", Index: 1}))
+				}
+			})
+		})
+
+		Context("when the jobs belong to several job groups", func() {
+			BeforeEach(func() {
+				var err error
+				cloudController, err = NewCloudController(ip, username, password, deploymentName, CloudControllerJobs{
+					CCJob{Job: "clock_global", Index: 0, Group: "clock_global"},
+					CCJob{Job: "cloud_controller_worker", Index: 0, Group: "cloud_controller_worker"},
+					CCJob{Job: "cloud_controller", Index: 0, Group: "cloud_controller"},
+				})
+				Expect(err).ShouldNot(HaveOccurred())
+				cloudController.SetToggleStrategy(ToggleParallel)
+			})
+			It("then it should stop the groups in order", func() {
+				Ω(cloudController.Stop()).Should(Succeed())
+				for i, job := range []string{"clock_global", "cloud_controller_worker", "cloud_controller"} {
+					_, actual, _, _ := fakeDirector.ChangeJobStateArgsForCall(i)
+					Ω(actual).Should(Equal(job))
+				}
+			})
+			It("then it should start the groups in reverse order", func() {
+				Ω(cloudController.Start()).Should(Succeed())
+				for i, job := range []string{"cloud_controller", "cloud_controller_worker", "clock_global"} {
+					_, actual, _, _ := fakeDirector.ChangeJobStateArgsForCall(i)
+					Ω(actual).Should(Equal(job))
+				}
+			})
+		})
+
+		Context("when an unknown strategy is set", func() {
+			BeforeEach(func() {
+				cloudController.SetToggleStrategy(ToggleStrategy("random"))
+			})
+			It("then it should fail without touching any job", func() {
+				Ω(cloudController.Stop()).ShouldNot(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(0))
+			})
+		})
+
+		Context("when stopping a later job fails", func() {
+			BeforeEach(func() {
+				fakeDirector.ChangeJobStateStub = func(_, job, state string, _ int) (int, error) {
+					if job == "job2" && state == "stopped" {
+						return 0, errors.New("stopping error")
+					}
+					return 1, nil
+				}
+			})
+			It("then it should restart the jobs it attempted to stop", func() {
+				err := cloudController.Stop()
+				Ω(err).Should(MatchError(ContainSubstring("stopping error")))
+
+				var started []CCJob
+				for i := 0; i < fakeDirector.ChangeJobStateCallCount(); i++ {
+					_, job, state, index := fakeDirector.ChangeJobStateArgsForCall(i)
+					if state == "started" {
+						started = append(started, CCJob{Job: job, Index: index})
+					}
+				}
+				Ω(started).Should(ConsistOf(
+					CCJob{Job: "job1", Index: 0},
+					CCJob{Job: "job1", Index: 1},
+					CCJob{Job: "job2", Index: 0},
+				))
+			})
+		})
+
+		Context("when the task of a job fails", func() {
+			BeforeEach(func() {
+				var events []BoshTaskEvent
+				for i := 1; i <= 7; i++ {
+					events = append(events, BoshTaskEvent{Stage: "Updating job", Task: "job1/0", Index: i, Total: 7, State: "finished"})
+				}
+				events[6].State = "failed"
+				events[6].Error = &BoshTaskEventError{Code: 450001, Message: "timed out"}
+				fakeDirector.GetTaskEventsReturns(events, 1400, nil)
+				fakeDirector.GetTaskOutputReturns(ioutil.NopCloser(strings.NewReader("debug output")), nil)
+				fakeDirector.RetrieveTaskStatusReturns(&Task{State: "error", Result: "job1/0 failed to start"}, nil)
+			})
+			It("then it should return the last task events in the error", func() {
+				err := cloudController.Start()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("job1/0 failed to start"))
+				Ω(err.Error()).Should(ContainSubstring("(3/7)"))
+				Ω(err.Error()).Should(ContainSubstring("(7/7) failed: error 450001: timed out"))
+				Ω(err.Error()).ShouldNot(ContainSubstring("(2/7)"))
+			})
+			It("then it should fetch the debug output of the task", func() {
+				cloudController.Start()
+				Ω(fakeDirector.GetTaskOutputCallCount()).Should(BeNumerically(">", 0))
+				_, outputType := fakeDirector.GetTaskOutputArgsForCall(0)
+				Ω(outputType).Should(Equal(BOSHTaskOutputDebug))
+			})
+		})
+
+		Context("when a job's task is still processing", func() {
+			BeforeEach(func() {
+				fakeDirector.RetrieveTaskStatusReturnsOnCall(0, &Task{State: "processing"}, nil)
+				fakeDirector.RetrieveTaskStatusReturnsOnCall(1, &Task{State: "done"}, nil)
+				fakeDirector.GetTaskEventsReturnsOnCall(0, []BoshTaskEvent{{Task: "job1/0", Index: 1, Total: 2, State: "started"}}, 120, nil)
+				fakeDirector.GetTaskEventsReturnsOnCall(1, []BoshTaskEvent{{Task: "job1/0", Index: 2, Total: 2, State: "finished"}}, 240, nil)
+			})
+			It("then it should follow the task events while waiting", func() {
+				c, _ := NewCloudController(ip, username, password, deploymentName, CloudControllerJobs{CCJob{Job: "job1", Index: 0}})
+				Ω(c.Start()).Should(Succeed())
+				Ω(fakeDirector.GetTaskEventsCallCount()).Should(Equal(2))
+				taskID, offset := fakeDirector.GetTaskEventsArgsForCall(0)
+				Ω(taskID).Should(Equal(1))
+				Ω(offset).Should(BeZero())
+			})
+			It("then it should only fetch the events past the ones already seen", func() {
+				c, _ := NewCloudController(ip, username, password, deploymentName, CloudControllerJobs{CCJob{Job: "job1", Index: 0}})
+				Ω(c.Start()).Should(Succeed())
+				_, offset := fakeDirector.GetTaskEventsArgsForCall(1)
+				Ω(offset).Should(Equal(int64(120)))
+			})
+		})
+
+		Context("when starting a job fails", func() {
+			BeforeEach(func() {
+				fakeDirector.ChangeJobStateStub = func(_, job, state string, _ int) (int, error) {
+					if job == "job2" {
+						return 0, errors.New("starting error")
+					}
+					return 1, nil
+				}
+			})
+			It("then it should not try to roll anything back", func() {
+				Ω(cloudController.Start()).ShouldNot(Succeed())
+				Ω(fakeDirector.ChangeJobStateCallCount()).Should(Equal(3))
+			})
+		})
+	})
+
+	Describe("against a fake director", func() {
+		var (
+			server *fakes.FakeDirectorServer
+			ccJobs CloudControllerJobs
+		)
+
+		BeforeEach(func() {
+			server = fakes.NewFakeDirectorServer("director", "director-pass")
+			server.AddDeployment(deploymentName, "manifest", []VMObject{
+				VMObject{Job: "cloud_controller-partition-abc", Index: 0, AZ: "z1", State: BOSHJobStateRunning},
+				VMObject{Job: "cloud_controller-partition-abc", Index: 1, AZ: "z2", State: BOSHJobStateRunning},
+				VMObject{Job: "router-partition-abc", Index: 0, AZ: "z1", State: BOSHJobStateRunning},
+			})
+			NewDirector = server.DirectorCreator(originalNewDirector)
+
+			director, err := NewDirector(ip, "director", "director-pass", 25555, TLSConfig{})
+			Expect(err).ShouldNot(HaveOccurred())
+			body, err := director.GetCloudControllerVMSet(deploymentName)
+			Expect(err).ShouldNot(HaveOccurred())
+			vms, err := ReadAndUnmarshalVMObjects(body)
+			Expect(err).ShouldNot(HaveOccurred())
+			ccJobs, err = GetCCVMs(vms)
+			Expect(err).ShouldNot(HaveOccurred())
+
+			cloudController, err = NewCloudController(ip, "director", "director-pass", deploymentName, ccJobs)
+			Expect(err).ShouldNot(HaveOccurred())
+		})
+
+		AfterEach(func() {
+			server.Close()
+		})
+
+		It("then it should find the cloud controllers from the full vm listing", func() {
+			Ω(ccJobs).Should(HaveLen(2))
+			Ω(ccJobs[1].AZ).Should(Equal("z2"))
+		})
+
+		It("then it should stop and start the cloud controllers", func() {
+			Ω(cloudController.Stop()).Should(Succeed())
+			Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 0)).Should(Equal(BOSHJobStateStopped))
+			Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 1)).Should(Equal(BOSHJobStateStopped))
+			Ω(server.JobState(deploymentName, "router-partition-abc", 0)).Should(Equal(BOSHJobStateRunning))
+
+			Ω(cloudController.Start()).Should(Succeed())
+			Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 0)).Should(Equal(BOSHJobStateRunning))
+			Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 1)).Should(Equal(BOSHJobStateRunning))
+		})
+
+		Context("when the task stopping a cloud controller fails", func() {
+			BeforeEach(func() {
+				server.FailJobState("cloud_controller-partition-abc", 1, "stopped", "disk full")
+			})
+
+			It("then it should report the task events and restart the cloud controllers", func() {
+				err := cloudController.Stop()
+				Ω(err).Should(HaveOccurred())
+				Ω(err.Error()).Should(ContainSubstring("disk full"))
+				Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 0)).Should(Equal(BOSHJobStateRunning))
+			})
+		})
+
+		Context("when the director rejects a state change", func() {
+			BeforeEach(func() {
+				server.FailNextRequest("PUT", fmt.Sprintf("/deployments/%s/jobs/cloud_controller-partition-abc/0", deploymentName), http.StatusInternalServerError)
+			})
+
+			It("then it should fail to stop the cloud controllers", func() {
+				Ω(cloudController.Stop()).ShouldNot(Succeed())
+				Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 1)).Should(Equal(BOSHJobStateRunning))
+			})
+		})
+
+		Context("when the credentials are wrong", func() {
+			It("then it should fail to stop the cloud controllers", func() {
+				cloudController, _ = NewCloudController(ip, "director", "wrong", deploymentName, ccJobs)
+				Ω(cloudController.Stop()).ShouldNot(Succeed())
+				Ω(server.JobState(deploymentName, "cloud_controller-partition-abc", 0)).Should(Equal(BOSHJobStateRunning))
+			})
+		})
+	})
+
+	Describe("CloudControllerJobs GroupByAZ", func() {
+		It("then it should group the jobs by az in the order the azs are first seen", func() {
+			groups := CloudControllerJobs{
+				CCJob{Job: "job1", Index: 0, AZ: "z2"},
+				CCJob{Job: "job1", Index: 1, AZ: "z1"},
+				CCJob{Job: "job2", Index: 0, AZ: "z2"},
+			}.GroupByAZ()
+			Ω(groups).Should(HaveLen(2))
+			Ω(groups[0]).Should(HaveLen(2))
+			Ω(groups[0][1].Job).Should(Equal("job2"))
+			Ω(groups[1]).Should(HaveLen(1))
+			Ω(groups[1][0].AZ).Should(Equal("z1"))
+		})
+	})
+
 	Describe("NewDirector", func() {
 
 		const tokenResponse = `{
@@ -197,7 +532,7 @@ var _ = Describe("ToggleCcJob", func() {
 				host, port, _ := net.SplitHostPort(u.Host)
 				host = u.Scheme + "://" + host
 				portInt, _ := strconv.Atoi(port)
-				boshclient, err = originalNewDirector(host, userControl, passControl, portInt)
+				boshclient, err = originalNewDirector(host, userControl, passControl, portInt, TLSConfig{})
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 
@@ -220,6 +555,60 @@ var _ = Describe("ToggleCcJob", func() {
 			})
 		})
 
+		Context("when fetching the cloud controller vm set", func() {
+			var boshclient Bosh
+			var server *ghttp.Server
+			BeforeEach(func() {
+				server = ghttp.NewTLSServer()
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/info"),
+						ghttp.RespondWith(http.StatusOK, basicAuthBoshInfo),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", fmt.Sprintf("/deployments/%s/vms", manifestName), "format=full"),
+						ghttp.RespondWith(http.StatusFound, "", http.Header{"Location": []string{"/tasks/42"}}),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/tasks/42"),
+						ghttp.RespondWith(http.StatusOK, `{"id":42,"state":"queued"}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/tasks/42"),
+						ghttp.RespondWith(http.StatusOK, `{"id":42,"state":"done"}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/tasks/42/output", "type=result"),
+						ghttp.RespondWith(http.StatusOK, `{"job_name":"cloud_controller","index":0,"az":"z1","job_state":"running"}
+{"job_name":"cloud_controller","index":1,"az":"z2","job_state":"stopped"}
+`),
+					),
+				)
+
+				u, _ := url.Parse(server.URL())
+				host, port, _ := net.SplitHostPort(u.Host)
+				portInt, _ := strconv.Atoi(port)
+				var err error
+				boshclient, err = originalNewDirector(u.Scheme+"://"+host, userControl, passControl, portInt, TLSConfig{})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("then it should return the vms from the full listing task as a json array", func() {
+				body, err := boshclient.GetCloudControllerVMSet(manifestName)
+				Ω(err).ShouldNot(HaveOccurred())
+				vms, err := ReadAndUnmarshalVMObjects(body)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(vms).Should(Equal([]VMObject{
+					VMObject{Job: "cloud_controller", Index: 0, AZ: "z1", State: "running"},
+					VMObject{Job: "cloud_controller", Index: 1, AZ: "z2", State: "stopped"},
+				}))
+			})
+		})
+
 		Context("When called with UAA Auth", func() {
 			var boshclient Bosh
 			var server *ghttp.Server
@@ -245,7 +634,7 @@ var _ = Describe("ToggleCcJob", func() {
 				host, port, _ := net.SplitHostPort(u.Host)
 				host = u.Scheme + "://" + host
 				portInt, _ := strconv.Atoi(port)
-				boshclient, err = originalNewDirector(host, userControl, passControl, portInt)
+				boshclient, err = originalNewDirector(host, userControl, passControl, portInt, TLSConfig{})
 				Expect(err).ShouldNot(HaveOccurred())
 			})
 
@@ -267,5 +656,159 @@ var _ = Describe("ToggleCcJob", func() {
 				Ω(lastReq.Header["Authorization"]).Should(ConsistOf("Bearer abcdef01234567890"))
 			})
 		})
+
+		Context("When called with UAA Auth and the credentials are a uaa user", func() {
+			var boshclient Bosh
+			var server *ghttp.Server
+			BeforeEach(func() {
+				server = ghttp.NewTLSServer()
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/info"),
+						ghttp.RespondWith(http.StatusOK, fmt.Sprintf(uaaBoshInfo, server.URL())),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						verifyGrantType("client_credentials"),
+						ghttp.VerifyBasicAuth(userControl, passControl),
+						ghttp.RespondWith(http.StatusUnauthorized, ""),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						verifyGrantType("password"),
+						ghttp.VerifyBasicAuth("bosh_cli", ""),
+						ghttp.RespondWith(http.StatusOK, tokenResponse),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", fmt.Sprintf("/deployments/%s", manifestName)),
+						ghttp.VerifyHeaderKV("Authorization", "Bearer abcdef01234567890"),
+						ghttp.RespondWith(http.StatusOK, "[]"),
+					),
+				)
+				u, _ := url.Parse(server.URL())
+				host, port, _ := net.SplitHostPort(u.Host)
+				portInt, _ := strconv.Atoi(port)
+				var err error
+				boshclient, err = originalNewDirector(u.Scheme+"://"+host, userControl, passControl, portInt, TLSConfig{})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("should fall back to a password grant", func() {
+				_, err := boshclient.GetDeploymentManifest(manifestName)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(server.ReceivedRequests()).Should(HaveLen(4))
+			})
+		})
+
+		Context("When called with UAA Auth and the token expires", func() {
+			var boshclient Bosh
+			var server *ghttp.Server
+			BeforeEach(func() {
+				server = ghttp.NewTLSServer()
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/info"),
+						ghttp.RespondWith(http.StatusOK, fmt.Sprintf(uaaBoshInfo, server.URL())),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						verifyGrantType("client_credentials"),
+						ghttp.RespondWith(http.StatusOK, `{"access_token":"expiring","refresh_token":"0987654321fedcba","expires_in":0}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", fmt.Sprintf("/deployments/%s", manifestName)),
+						ghttp.VerifyHeaderKV("Authorization", "Bearer expiring"),
+						ghttp.RespondWith(http.StatusOK, "[]"),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						verifyGrantType("refresh_token"),
+						ghttp.RespondWith(http.StatusOK, tokenResponse),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", fmt.Sprintf("/deployments/%s", manifestName)),
+						ghttp.VerifyHeaderKV("Authorization", "Bearer abcdef01234567890"),
+						ghttp.RespondWith(http.StatusOK, "[]"),
+					),
+				)
+				u, _ := url.Parse(server.URL())
+				host, port, _ := net.SplitHostPort(u.Host)
+				portInt, _ := strconv.Atoi(port)
+				var err error
+				boshclient, err = originalNewDirector(u.Scheme+"://"+host, userControl, passControl, portInt, TLSConfig{})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("should refresh the token before the next request", func() {
+				_, err := boshclient.GetDeploymentManifest(manifestName)
+				Ω(err).ShouldNot(HaveOccurred())
+				_, err = boshclient.GetDeploymentManifest(manifestName)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(server.ReceivedRequests()).Should(HaveLen(5))
+			})
+		})
+
+		Context("When called with UAA Auth and the director rejects the token", func() {
+			var boshclient Bosh
+			var server *ghttp.Server
+			BeforeEach(func() {
+				server = ghttp.NewTLSServer()
+				server.AppendHandlers(
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", "/info"),
+						ghttp.RespondWith(http.StatusOK, fmt.Sprintf(uaaBoshInfo, server.URL())),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						ghttp.RespondWith(http.StatusOK, `{"access_token":"revoked","expires_in":3599}`),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", fmt.Sprintf("/deployments/%s", manifestName)),
+						ghttp.RespondWith(http.StatusUnauthorized, ""),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("POST", "/oauth/token"),
+						verifyGrantType("client_credentials"),
+						ghttp.RespondWith(http.StatusOK, tokenResponse),
+					),
+					ghttp.CombineHandlers(
+						ghttp.VerifyRequest("GET", fmt.Sprintf("/deployments/%s", manifestName)),
+						ghttp.VerifyHeaderKV("Authorization", "Bearer abcdef01234567890"),
+						ghttp.RespondWith(http.StatusOK, "[]"),
+					),
+				)
+				u, _ := url.Parse(server.URL())
+				host, port, _ := net.SplitHostPort(u.Host)
+				portInt, _ := strconv.Atoi(port)
+				var err error
+				boshclient, err = originalNewDirector(u.Scheme+"://"+host, userControl, passControl, portInt, TLSConfig{})
+				Expect(err).ShouldNot(HaveOccurred())
+			})
+
+			AfterEach(func() {
+				server.Close()
+			})
+
+			It("should retry the request once with a new token", func() {
+				_, err := boshclient.GetDeploymentManifest(manifestName)
+				Ω(err).ShouldNot(HaveOccurred())
+				Ω(server.ReceivedRequests()).Should(HaveLen(5))
+			})
+		})
 	})
 })
+
+func verifyGrantType(grantType string) http.HandlerFunc {
+	return func(w http.ResponseWriter, req *http.Request) {
+		Ω(req.ParseForm()).Should(Succeed())
+		Ω(req.PostForm.Get("grant_type")).Should(Equal(grantType))
+	}
+}
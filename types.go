@@ -2,12 +2,19 @@ package cfbackup
 
 import (
 	"crypto/cipher"
+	"hash"
 	"io"
+	"net"
 	"net/http"
+	"net/url"
+	"sync"
+	"time"
 
+	"github.com/pivotalservices/cfbackup/metrics"
 	"github.com/pivotalservices/gtils/command"
 	ghttp "github.com/pivotalservices/gtils/http"
 	"github.com/xchapter7x/goutil"
+	"golang.org/x/crypto/ssh"
 )
 
 //go:generate counterfeiter -o fakes/fake_bosh.go . Bosh
@@ -24,6 +31,92 @@ type (
 		GetDeploymentManifest(deploymentName string) (io.ReadCloser, error)
 		ChangeJobState(string, string, string, int) (int, error)
 		RetrieveTaskStatus(int) (*Task, error)
+		GetDeployments() ([]BoshDeployment, error)
+		GetInstances(deploymentName string) ([]BoshInstance, error)
+		GetTaskOutput(taskID int, outputType string) (io.ReadCloser, error)
+		GetTaskEvents(taskID int, offset int64) ([]BoshTaskEvent, int64, error)
+		CancelTask(taskID int) error
+		GetReleases() ([]BoshRelease, error)
+		GetStemcells() ([]BoshStemcell, error)
+	}
+
+	//BoshNameVersion - a release or stemcell used by a deployment
+	BoshNameVersion struct {
+		Name    string `json:"name"`
+		Version string `json:"version"`
+	}
+
+	//BoshDeployment - a deployment as listed by the director
+	BoshDeployment struct {
+		Name        string            `json:"name"`
+		CloudConfig string            `json:"cloud_config"`
+		Releases    []BoshNameVersion `json:"releases"`
+		Stemcells   []BoshNameVersion `json:"stemcells"`
+	}
+
+	//BoshProcess - a process monitored on an instance
+	BoshProcess struct {
+		Name  string `json:"name"`
+		State string `json:"state"`
+	}
+
+	//BoshInstance - an instance of a deployment as listed by the director,
+	//ProcessState is the aggregated state of its processes
+	BoshInstance struct {
+		ID           string        `json:"id"`
+		Job          string        `json:"job_name"`
+		Index        int           `json:"index"`
+		AZ           string        `json:"az"`
+		IPs          []string      `json:"ips"`
+		VMCID        string        `json:"vm_cid"`
+		AgentID      string        `json:"agent_id"`
+		ProcessState string        `json:"job_state"`
+		State        string        `json:"state"`
+		Bootstrap    bool          `json:"bootstrap"`
+		Processes    []BoshProcess `json:"processes"`
+	}
+
+	//BoshTaskEventError - the error attached to a failed task event
+	BoshTaskEventError struct {
+		Code    int    `json:"code"`
+		Message string `json:"message"`
+	}
+
+	//BoshTaskEvent - a single event from the event output of a task
+	BoshTaskEvent struct {
+		Time     int64               `json:"time"`
+		Stage    string              `json:"stage"`
+		Tags     []string            `json:"tags"`
+		Total    int                 `json:"total"`
+		Task     string              `json:"task"`
+		Index    int                 `json:"index"`
+		State    string              `json:"state"`
+		Progress int                 `json:"progress"`
+		Error    *BoshTaskEventError `json:"error,omitempty"`
+	}
+
+	//BoshReleaseVersion - a version of a release uploaded to the director
+	BoshReleaseVersion struct {
+		Version           string `json:"version"`
+		CommitHash        string `json:"commit_hash"`
+		CurrentlyDeployed bool   `json:"currently_deployed"`
+	}
+
+	//BoshRelease - a release uploaded to the director
+	BoshRelease struct {
+		Name            string               `json:"name"`
+		ReleaseVersions []BoshReleaseVersion `json:"release_versions"`
+	}
+
+	//BoshStemcell - a stemcell uploaded to the director
+	BoshStemcell struct {
+		Name            string `json:"name"`
+		OperatingSystem string `json:"operating_system"`
+		Version         string `json:"version"`
+		CID             string `json:"cid"`
+		Deployments     []struct {
+			Name string `json:"name"`
+		} `json:"deployments"`
 	}
 
 	//InstallationInfo - inteferface for gettting at insallation info
@@ -39,11 +132,39 @@ type (
 		//SetPGDumpUtilVersions()
 	}
 
+	//CredentialsAPI - resolves credentials an installation json no longer
+	//contains, through the ops manager credentials api
+	CredentialsAPI interface {
+		GetCredential(productGUID, reference string) (credential Credential, err error)
+	}
+
+	//ProductGUIDResolver - a credentials api which also resolves the guid of a
+	//deployed product, for installation json without product guids
+	ProductGUIDResolver interface {
+		FindProductGUID(productType string) (guid string, err error)
+	}
+
+	//Credential - a credential as returned by the ops manager credentials api
+	Credential struct {
+		Type  string            `json:"type"`
+		Value map[string]string `json:"value"`
+	}
+
+	//CredentialsInstallation - installation info which looks up db and vm
+	//credentials missing from the installation json through the credentials api
+	CredentialsInstallation struct {
+		InstallationInfo
+		API CredentialsAPI
+	}
+
 	//NFSBackup - this is a nfs backup object
 	NFSBackup struct {
 		Caller     command.Executer
 		RemoteOps  remoteOpsInterface
 		BackupType string
+		//Metrics - keeps the duration of the dumps and imports, none is kept
+		//when nil
+		Metrics *Metrics
 	}
 
 	//BackupContext - stores the base context information for a backup/restore
@@ -51,6 +172,293 @@ type (
 		TargetDir string
 		IsS3      bool
 		StorageProvider
+		//TLS - how the certificates of ops manager and its uaa are verified
+		TLS TLSConfig
+		//DirectorTLS - how the certificates of the director and its uaa are
+		//verified, the TLS of the tile trusting the director certificate from
+		//the installation settings
+		DirectorTLS TLSConfig
+		//Progress - gets the progress events of the backup or restore, none
+		//are sent when nil
+		Progress ProgressReporter
+		//SSHTunnels - how the vms are reached over ssh, directly when nil
+		SSHTunnels *SSHTunnels
+		//Metrics - keeps the metrics of the storage and the nfs of the
+		//backup or restore, none are kept when nil
+		Metrics *Metrics
+	}
+
+	//ProgressReporter - receives the progress events of a backup or restore
+	ProgressReporter interface {
+		Report(event ProgressEvent)
+	}
+
+	//ProgressFunc - a function receiving progress events
+	ProgressFunc func(event ProgressEvent)
+
+	//ProgressChannel - a channel receiving progress events, the backup or
+	//restore waits on a channel nobody reads
+	ProgressChannel chan<- ProgressEvent
+
+	//ProgressReporters - passes every event on to each of the reporters
+	ProgressReporters []ProgressReporter
+
+	//ProgressEvent - a step, cloud controller or bytes transferred event of a
+	//backup or restore
+	ProgressEvent struct {
+		Type string    `json:"type"`
+		Time time.Time `json:"time"`
+		//Step - the tile or step a tile or step event is about
+		Step string `json:"step,omitempty"`
+		//Error - why a finished tile or step failed
+		Error string `json:"error,omitempty"`
+		//Message - what a warning is about
+		Message string `json:"message,omitempty"`
+		//Artifact - the file a bytes transferred event is about
+		Artifact string `json:"artifact,omitempty"`
+		//Bytes - the bytes of the artifact transferred so far
+		Bytes int64 `json:"bytes,omitempty"`
+		//Total - the size of the artifact, zero when it is not known
+		Total int64 `json:"total,omitempty"`
+		//Rate - bytes per second transferred so far
+		Rate float64 `json:"rate,omitempty"`
+		//ETA - how long the rest of the artifact takes at the rate, zero
+		//without a total
+		ETA time.Duration `json:"eta,omitempty"`
+		//Done - the artifact is completely transferred
+		Done bool `json:"done,omitempty"`
+		//SHA256 - the checksum of the bytes of a done artifact, before they
+		//are encrypted
+		SHA256 string `json:"sha256,omitempty"`
+		//TaskID, Job and State - the bosh task, the job/index it changed and
+		//the state it changed it to
+		TaskID int    `json:"task_id,omitempty"`
+		Job    string `json:"job,omitempty"`
+		State  string `json:"state,omitempty"`
+		//Wait - how long a bosh task was waited on
+		Wait time.Duration `json:"wait,omitempty"`
+		//Deployment - the deployment of a bosh task or the foundation
+		Deployment string `json:"deployment,omitempty"`
+		//DirectorName and DirectorUUID - the director of the foundation
+		DirectorName string `json:"director_name,omitempty"`
+		DirectorUUID string `json:"director_uuid,omitempty"`
+	}
+
+	//ProgressCounter - counts the bytes written or read for an artifact,
+	//reporting them every ProgressInterval and once it is done
+	ProgressCounter struct {
+		Artifact string
+		Total    int64
+		reporter ProgressReporter
+		hash     hash.Hash
+		bytes    int64
+		started  time.Time
+		reported time.Time
+	}
+
+	//ProgressWriter - a writer counting the bytes written to it
+	ProgressWriter struct {
+		io.Writer
+		*ProgressCounter
+	}
+
+	//ProgressReader - a reader counting the bytes read from it
+	ProgressReader struct {
+		io.Reader
+		*ProgressCounter
+	}
+
+	//RunReport - what a backup or restore did, collected from its progress
+	//events. events are passed on to the reporter it was made with
+	RunReport struct {
+		Action     string           `json:"action"`
+		BackupID   string           `json:"backup_id,omitempty"`
+		Foundation ReportFoundation `json:"foundation"`
+		Started    time.Time        `json:"started"`
+		Finished   time.Time        `json:"finished"`
+		Duration   float64          `json:"duration_seconds"`
+		//CCDowntime - how long the cloud controller was stopped
+		CCDowntime float64          `json:"cc_downtime_seconds"`
+		Tiles      []*ReportTile    `json:"tiles"`
+		BOSHTasks  []ReportBOSHTask `json:"bosh_tasks,omitempty"`
+		Warnings   []string         `json:"warnings,omitempty"`
+		Error      string           `json:"error,omitempty"`
+		//ErrorChain - the message of every error wrapped into the error,
+		//outermost first
+		ErrorChain []string `json:"error_chain,omitempty"`
+		forward    ProgressReporter
+		mutex      sync.Mutex
+		tile       *ReportTile
+		component  *ReportComponent
+		ccStopped  time.Time
+	}
+
+	//ReportFoundation - the foundation a report is about
+	ReportFoundation struct {
+		OpsManagerHost string `json:"ops_manager_host,omitempty"`
+		Deployment     string `json:"deployment,omitempty"`
+		DirectorName   string `json:"director_name,omitempty"`
+		DirectorUUID   string `json:"director_uuid,omitempty"`
+	}
+
+	//ReportTile - a tile backed up or restored
+	ReportTile struct {
+		Name       string             `json:"name"`
+		Error      string             `json:"error,omitempty"`
+		Started    time.Time          `json:"started"`
+		Finished   time.Time          `json:"finished"`
+		Duration   float64            `json:"duration_seconds"`
+		Components []*ReportComponent `json:"components,omitempty"`
+		Artifacts  []ReportArtifact   `json:"artifacts,omitempty"`
+	}
+
+	//ReportComponent - a step of a tile, such as a database of the elastic
+	//runtime
+	ReportComponent struct {
+		Name     string    `json:"name"`
+		Error    string    `json:"error,omitempty"`
+		Started  time.Time `json:"started"`
+		Finished time.Time `json:"finished"`
+		Duration float64   `json:"duration_seconds"`
+	}
+
+	//ReportArtifact - a file written or read by a tile
+	ReportArtifact struct {
+		Path      string  `json:"path"`
+		Component string  `json:"component,omitempty"`
+		Bytes     int64   `json:"bytes"`
+		SHA256    string  `json:"sha256"`
+		Rate      float64 `json:"bytes_per_second"`
+	}
+
+	//ReportBOSHTask - a bosh task changing the state of a job
+	ReportBOSHTask struct {
+		TaskID     int     `json:"task_id"`
+		Deployment string  `json:"deployment"`
+		Job        string  `json:"job"`
+		State      string  `json:"state"`
+		Wait       float64 `json:"wait_seconds"`
+		Error      string  `json:"error,omitempty"`
+	}
+
+	//Metrics - the prometheus metrics of backups and restores, kept up to
+	//date from their progress events and by the storage providers and nfs
+	Metrics struct {
+		Registry *metrics.Registry
+		//TileLastSuccess - when the tile was last backed up or restored
+		TileLastSuccess *metrics.Metric
+		//TileDuration - how long the last run of the tile took
+		TileDuration *metrics.Metric
+		//TileRuns - the runs of the tile by status
+		TileRuns *metrics.Metric
+		//ComponentBytes - the bytes of the last artifact of a component
+		ComponentBytes *metrics.Metric
+		//ComponentDuration - how long the last run of a component took
+		ComponentDuration *metrics.Metric
+		//CCDowntime - how long the cloud controller was last stopped
+		CCDowntime *metrics.Metric
+		//BOSHTaskWait - the time spent waiting on bosh tasks
+		BOSHTaskWait *metrics.Metric
+		//BOSHTasks - the bosh tasks waited on by state and status
+		BOSHTasks *metrics.Metric
+		//Errors - the errors by type: tile, component, bosh_task, storage
+		//and nfs
+		Errors *metrics.Metric
+		//Warnings - the warnings reported
+		Warnings *metrics.Metric
+		//StorageBytes - the bytes written to and read from a storage provider
+		StorageBytes *metrics.Metric
+		//NFSDuration - how long the last nfs dump or import took
+		NFSDuration *metrics.Metric
+		mutex       sync.Mutex
+		tile        string
+		component   string
+		started     map[string]time.Time
+		ccStopped   time.Time
+	}
+
+	storageWriter struct {
+		io.WriteCloser
+		provider string
+		metrics  *Metrics
+	}
+
+	storageReader struct {
+		io.ReadCloser
+		provider string
+		metrics  *Metrics
+	}
+
+	//TLSConfig - verification of the certificates of the services we call.
+	//certificates are verified once Strict is set, a CABundle is given or a
+	//certificate is trusted, otherwise every certificate is accepted. CABundle is a pem file or
+	//inline pem, trusted on top of the system roots. Proxy is the http,
+	//https or socks5 proxy url the services are called through, the proxy
+	//environment variables are used without one
+	TLSConfig struct {
+		CABundle string
+		Strict   bool
+		Proxy    string
+		trusted  []string
+	}
+
+	//SSHTunnels - the chain of jump hosts the vms are reached through and
+	//the tunnels opened through it, one per vm until they are closed
+	SSHTunnels struct {
+		JumpHosts []command.SshConfig
+		mutex     sync.Mutex
+		tunnels   map[string]*sshTunnel
+	}
+
+	//sshTunnel - a local listener forwarding every connection through the
+	//jump host chain to a single target
+	sshTunnel struct {
+		listener net.Listener
+		clients  []*ssh.Client
+	}
+
+	//UAAGrant - a grant a uaa token is requested with: the uaa client and
+	//the form values of the grant
+	UAAGrant struct {
+		ClientID     string
+		ClientSecret string
+		Values       url.Values
+	}
+
+	//UAATokenSource - fetches bearer tokens from a uaa and caches them until
+	//they are about to expire. a token is refreshed with its refresh token
+	//when it has one, and requested again otherwise. the grants are tried in
+	//order and whichever works is remembered
+	UAATokenSource struct {
+		//HTTPClient - the client the uaa is called with
+		HTTPClient func() (*http.Client, error)
+		//TokenURL - the token endpoint of the uaa
+		TokenURL string
+		Grants   []UAAGrant
+		//StatusError - the error of a token request the uaa answers with a
+		//status other than 200, a generic one when nil
+		StatusError func(statusCode int, message string) error
+
+		mutex  sync.Mutex
+		grant  int
+		token  uaaToken
+		expiry time.Time
+	}
+
+	//SSHTunneled - a system dump whose vm can be reached through ssh tunnels
+	SSHTunneled interface {
+		SetSSHTunnels(tunnels *SSHTunnels)
+	}
+
+	//TLSConfigured - a storage provider reached over http following a
+	//TLSConfig
+	TLSConfigured interface {
+		SetTLSConfig(config TLSConfig)
+	}
+
+	//Metered - a system dump keeping metrics of its dumps and imports
+	Metered interface {
+		SetMetrics(metrics *Metrics)
 	}
 
 	//StreamReadCloser - wrapper for a cipher.StreadReader to implement Closer interface as well
@@ -122,6 +530,7 @@ type (
 		InstallationName                   string              `json:"installation_name"`
 		SingletonAvailabilityZoneReference string              `json:"singleton_availability_zone_reference"`
 		Stemcell                           interface{}         `json:"stemcell"`
+		DirectorSSL                        map[string]string   `json:"director_ssl"`
 	}
 
 	// Jobs contains job settings for a product
@@ -180,8 +589,11 @@ type (
 
 	//CCJob - a cloud controller job object
 	CCJob struct {
-		Job   string
-		Index int
+		Job   string `json:"job"`
+		Index int    `json:"index"`
+		AZ    string `json:"az,omitempty"`
+		State string `json:"job_state,omitempty"`
+		Group string `json:"group,omitempty"`
 	}
 
 	//PersistanceBackup - a struct representing a persistence backup
@@ -214,6 +626,8 @@ type (
 		VcapPass          string
 		SSHPrivateKey     string
 		RemoteArchivePath string
+		//SSHTunnels - how the vm is reached over ssh, directly when nil
+		SSHTunnels *SSHTunnels
 	}
 	//PgInfo - a struct representing a pgres systemdump implementation
 	PgInfo struct {
@@ -229,12 +643,70 @@ type (
 	NfsInfo struct {
 		SystemInfo
 		BackupType string
+		//Metrics - what the nfs backup keeps its metrics in
+		Metrics *Metrics
 	}
 	//DirectorInfo - a struct representing a director systemdump implementation
 	DirectorInfo struct {
 		SystemInfo
 		Database string
 	}
+	//DirectorBlobstoreInfo - a struct representing the director's local
+	//blobstore systemdump implementation
+	DirectorBlobstoreInfo struct {
+		SystemInfo
+	}
+	//RedisInfo - a struct representing a redis instance systemdump
+	//implementation, the instance dir holds its redis.conf
+	RedisInfo struct {
+		SystemInfo
+		InstanceDir string
+		//BGSaveCommand - the name BGSAVE is renamed to on the instance
+		BGSaveCommand string
+		//ConfigCommand - the name CONFIG is renamed to on the instance
+		ConfigCommand string
+	}
+	//DirectorProcesses - the processes of the director job matching the
+	//patterns, stopped and started with monit
+	DirectorProcesses struct {
+		Caller   command.Executer
+		Patterns []string
+	}
+	//BlobstoreBackup - a struct representing a backup of the director's local blobstore
+	BlobstoreBackup struct {
+		Caller    command.Executer
+		RemoteOps remoteOpsInterface
+	}
+	//RedisBackup - a struct representing an rdb backup of a redis instance
+	RedisBackup struct {
+		Caller        command.Executer
+		RemoteOps     remoteOpsInterface
+		InstanceDir   string
+		BGSaveCommand string
+		ConfigCommand string
+	}
+	//ArchiveNamer - implemented by systemdumps which share their component with
+	//another systemdump and so can not be archived under the component name
+	ArchiveNamer interface {
+		ArchiveName() string
+	}
+	//BoshInfo - the details a director reports on its info endpoint
+	BoshInfo struct {
+		Name               string `json:"name"`
+		UUID               string `json:"uuid"`
+		Version            string `json:"version"`
+		UserAuthentication struct {
+			Type    string `json:"type"`
+			Options struct {
+				URL string `json:"url"`
+			} `json:"options"`
+		} `json:"user_authentication"`
+		Features struct {
+			ConfigServer struct {
+				Status bool `json:"status"`
+			} `json:"config_server"`
+		} `json:"features"`
+	}
 	//SystemsInfo holds the values for all the supported SystemDump used by an installation
 	SystemsInfo struct {
 		SystemDumps map[string]SystemDump
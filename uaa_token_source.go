@@ -0,0 +1,126 @@
+package cfbackup
+
+import (
+	"encoding/json"
+	"fmt"
+	"io/ioutil"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+
+	errwrap "github.com/pkg/errors"
+	"github.com/xchapter7x/lo"
+)
+
+//UAATokenRefreshMargin - how long before its expiry a uaa token is refreshed
+var UAATokenRefreshMargin = 30 * time.Second
+
+type uaaToken struct {
+	AccessToken  string `json:"access_token"`
+	RefreshToken string `json:"refresh_token"`
+	ExpiresIn    int    `json:"expires_in"`
+}
+
+//Token - the cached access token, refreshed or requested again once it is
+//about to expire
+func (s *UAATokenSource) Token() (string, error) {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+
+	if s.token.AccessToken == "" || time.Now().Add(UAATokenRefreshMargin).After(s.expiry) {
+		if err := s.refresh(); err != nil {
+			return "", err
+		}
+	}
+	return s.token.AccessToken, nil
+}
+
+//Reset - drops the cached token, the next Token requests a new one
+func (s *UAATokenSource) Reset() {
+	s.mutex.Lock()
+	defer s.mutex.Unlock()
+	s.token = uaaToken{}
+}
+
+//refresh - uses the refresh token when there is one, and tries the grant
+//which worked last otherwise. once that one fails every grant is tried again
+//from the first, the uaa may have taken a client or user on or off since
+func (s *UAATokenSource) refresh() (err error) {
+	if len(s.Grants) == 0 {
+		return fmt.Errorf("no uaa grant to request a token with")
+	}
+
+	if s.token.RefreshToken != "" {
+		grant := s.Grants[s.grant]
+		if err = s.requestToken(UAAGrant{
+			ClientID:     grant.ClientID,
+			ClientSecret: grant.ClientSecret,
+			Values: url.Values{
+				"grant_type":    {UAAGrantTypeRefreshToken},
+				"refresh_token": {s.token.RefreshToken},
+			},
+		}); err == nil {
+			return
+		}
+		lo.G.Debug("uaa refresh token rejected, requesting a new token: ", err)
+		s.token = uaaToken{}
+	}
+
+	if err = s.requestToken(s.Grants[s.grant]); err == nil {
+		return
+	}
+	lo.G.Debug("uaa rejected the grant: ", s.Grants[s.grant].Values.Get("grant_type"), err)
+	for i := range s.Grants {
+		if i == s.grant {
+			continue
+		}
+		if err = s.requestToken(s.Grants[i]); err == nil {
+			s.grant = i
+			return
+		}
+		lo.G.Debug("uaa rejected the grant: ", s.Grants[i].Values.Get("grant_type"), err)
+	}
+	return
+}
+
+func (s *UAATokenSource) requestToken(grant UAAGrant) error {
+	req, err := http.NewRequest("POST", s.TokenURL, strings.NewReader(grant.Values.Encode()))
+	if err != nil {
+		return errwrap.Wrap(err, "failed creating token request")
+	}
+	req.Header.Set("Content-Type", "application/x-www-form-urlencoded")
+	req.Header.Set("Accept", "application/json")
+	req.SetBasicAuth(grant.ClientID, grant.ClientSecret)
+
+	client, err := s.HTTPClient()
+	if err != nil {
+		return err
+	}
+
+	lo.G.Debug("requesting a token from: ", s.TokenURL)
+	res, err := client.Do(req)
+	if err != nil {
+		return errwrap.Wrap(err, "failed calling uaa")
+	}
+	defer res.Body.Close()
+
+	if res.StatusCode != http.StatusOK {
+		errMsg, _ := ioutil.ReadAll(res.Body)
+		if s.StatusError != nil {
+			return s.StatusError(res.StatusCode, string(errMsg))
+		}
+		return fmt.Errorf("%s grant rejected by uaa with status code: %v", grant.Values.Get("grant_type"), res.StatusCode)
+	}
+	token := uaaToken{}
+	if err = json.NewDecoder(res.Body).Decode(&token); err != nil {
+		return errwrap.Wrap(err, "unable to unmarshal uaa token")
+	}
+	if token.AccessToken == "" {
+		return fmt.Errorf("uaa returned an empty access token")
+	}
+	s.token = token
+	s.expiry = time.Now().Add(time.Duration(token.ExpiresIn) * time.Second)
+	lo.G.Debug("token acquired")
+	return nil
+}
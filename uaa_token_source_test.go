@@ -0,0 +1,116 @@
+package cfbackup_test
+
+import (
+	"errors"
+	"net/http"
+	"net/url"
+
+	. "github.com/onsi/ginkgo"
+	. "github.com/onsi/gomega"
+	"github.com/onsi/gomega/ghttp"
+	. "github.com/pivotalservices/cfbackup"
+)
+
+var _ = Describe("UAATokenSource", func() {
+	var (
+		server   *ghttp.Server
+		tokens   *UAATokenSource
+		rejected = errors.New("rejected")
+	)
+
+	BeforeEach(func() {
+		server = ghttp.NewServer()
+		tokens = &UAATokenSource{
+			HTTPClient: func() (*http.Client, error) { return http.DefaultClient, nil },
+			TokenURL:   server.URL() + "/oauth/token",
+			Grants: []UAAGrant{
+				{ClientID: "director", ClientSecret: "secret", Values: url.Values{"grant_type": {UAAGrantTypeClientCredentials}}},
+				{ClientID: UAABoshCLIClient, Values: url.Values{"grant_type": {UAAGrantTypePassword}}},
+			},
+		}
+	})
+
+	AfterEach(func() {
+		server.Close()
+	})
+
+	Context("when the first grant is rejected", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth("director", "secret"),
+					ghttp.RespondWith(http.StatusUnauthorized, ""),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth(UAABoshCLIClient, ""),
+					ghttp.RespondWith(http.StatusOK, `{"access_token":"first","expires_in":3599}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth(UAABoshCLIClient, ""),
+					ghttp.RespondWith(http.StatusOK, `{"access_token":"second","expires_in":3599}`),
+				),
+			)
+		})
+
+		It("then it should fall back to the next grant", func() {
+			Ω(tokens.Token()).Should(Equal("first"))
+		})
+
+		It("then it should cache the token", func() {
+			tokens.Token()
+			Ω(tokens.Token()).Should(Equal("first"))
+			Ω(server.ReceivedRequests()).Should(HaveLen(2))
+		})
+
+		It("then it should request the grant which worked once the token is reset", func() {
+			tokens.Token()
+			tokens.Reset()
+			Ω(tokens.Token()).Should(Equal("second"))
+			Ω(server.ReceivedRequests()).Should(HaveLen(3))
+		})
+	})
+
+	Context("when the grant which worked is rejected later on", func() {
+		BeforeEach(func() {
+			server.AppendHandlers(
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth("director", "secret"),
+					ghttp.RespondWith(http.StatusUnauthorized, ""),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth(UAABoshCLIClient, ""),
+					ghttp.RespondWith(http.StatusOK, `{"access_token":"password","expires_in":3599}`),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth(UAABoshCLIClient, ""),
+					ghttp.RespondWith(http.StatusUnauthorized, ""),
+				),
+				ghttp.CombineHandlers(
+					ghttp.VerifyBasicAuth("director", "secret"),
+					ghttp.RespondWith(http.StatusOK, `{"access_token":"client","expires_in":3599}`),
+				),
+			)
+		})
+
+		It("then it should try every grant again from the first", func() {
+			Ω(tokens.Token()).Should(Equal("password"))
+			tokens.Reset()
+			Ω(tokens.Token()).Should(Equal("client"))
+			Ω(server.ReceivedRequests()).Should(HaveLen(4))
+		})
+	})
+
+	Context("when the uaa rejects every grant", func() {
+		BeforeEach(func() {
+			server.RouteToHandler("POST", "/oauth/token", ghttp.RespondWith(http.StatusUnauthorized, ""))
+			tokens.StatusError = func(statusCode int, message string) error {
+				return rejected
+			}
+		})
+
+		It("then it should return the status error", func() {
+			_, err := tokens.Token()
+			Ω(err).Should(Equal(rejected))
+		})
+	})
+})